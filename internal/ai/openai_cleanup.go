@@ -7,8 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -21,6 +24,11 @@ type OpenAIExecutor struct {
 	apiKey string
 	model  string
 	client *http.Client
+	// syntax checks the files the model returns; those that no longer
+	// parse are sent back for repair up to repairAttempts times, then
+	// dropped.
+	syntax         cleanup.SyntaxChecker
+	repairAttempts int
 }
 
 const (
@@ -47,9 +55,11 @@ func NewOpenAIExecutorFromConfig(cfg config.Config) (*OpenAIExecutor, error) {
 		model = "gpt-5"
 	}
 	return &OpenAIExecutor{
-		apiKey: apiKey,
-		model:  model,
-		client: &http.Client{Timeout: defaultHTTPTimeout},
+		apiKey:         apiKey,
+		model:          model,
+		client:         &http.Client{Timeout: defaultHTTPTimeout},
+		syntax:         cleanup.SyntaxChecker{Commands: cfg.Cleanup.Syntax.Checkers},
+		repairAttempts: cfg.Cleanup.Syntax.RepairAttempts,
 	}, nil
 }
 
@@ -96,6 +106,7 @@ func (e *OpenAIExecutor) TransformProject(ctx context.Context, _ string, files [
 			summaries = append(summaries, "batch failed: "+shortError(err.Error(), maxBatchErrorSummarySize))
 			continue
 		}
+		res = e.checkSyntax(ctx, batch, task, res)
 		for p, c := range res.ChangedFiles {
 			changedFiles[p] = c
 		}
@@ -196,25 +207,9 @@ func (e *OpenAIExecutor) transformBatch(ctx context.Context, files []cleanup.Pro
 		safety, string(taskJSON), string(ruleJSON), string(filesJSON),
 	)
 
-	reqBody := chatCompletionRequest{
-		Model: e.model,
-		Messages: []map[string]string{
-			{"role": "system", "content": system},
-			{"role": "user", "content": user},
-		},
-		ResponseFormat: map[string]string{"type": "json_object"},
-	}
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return cleanup.ProjectTransformResult{}, fmt.Errorf("marshal OpenAI request: %w", err)
-	}
-	text, err := e.chatCompletionsWithRetry(ctx, body, 3)
-	if err != nil {
-		return cleanup.ProjectTransformResult{}, err
-	}
 	var out cleanupProjectLLMOutput
-	if err := json.Unmarshal([]byte(text), &out); err != nil {
-		return cleanup.ProjectTransformResult{}, fmt.Errorf("parse cleanup JSON output: %w", err)
+	if err := e.complete(ctx, system, user, 3, &out); err != nil {
+		return cleanup.ProjectTransformResult{}, err
 	}
 
 	changedFiles := map[string]string{}
@@ -233,6 +228,118 @@ func (e *OpenAIExecutor) transformBatch(ctx context.Context, files []cleanup.Pro
 	}, nil
 }
 
+// checkSyntax asks the model to fix the returned files that no longer parse,
+// up to e.repairAttempts times, and drops those that still do not.
+func (e *OpenAIExecutor) checkSyntax(ctx context.Context, files []cleanup.ProjectFile, task cleanup.Task, res cleanup.ProjectTransformResult) cleanup.ProjectTransformResult {
+	original := map[string]string{}
+	for _, f := range files {
+		original[f.Path] = f.Content
+	}
+	broken := map[string]string{}
+	check := func(paths []string) {
+		for _, p := range paths {
+			before, ok := original[p]
+			if !ok {
+				continue
+			}
+			checked, err := e.syntax.Check(p, before, res.ChangedFiles[p])
+			if err != nil {
+				broken[p] = err.Error()
+				continue
+			}
+			delete(broken, p)
+			res.ChangedFiles[p] = checked
+		}
+	}
+	check(slices.Sorted(maps.Keys(res.ChangedFiles)))
+	for attempt := 0; attempt < e.repairAttempts && len(broken) > 0; attempt++ {
+		repaired, err := e.repairSyntax(ctx, task, original, res.ChangedFiles, broken)
+		if err != nil {
+			break
+		}
+		var retry []string
+		for p := range broken {
+			if c, ok := repaired[p]; ok {
+				res.ChangedFiles[p] = c
+				retry = append(retry, p)
+			}
+		}
+		slices.Sort(retry)
+		check(retry)
+	}
+	if len(broken) == 0 {
+		return res
+	}
+	dropped := slices.Sorted(maps.Keys(broken))
+	for i, p := range dropped {
+		delete(res.ChangedFiles, p)
+		dropped[i] = filepath.Base(p) + ": " + shortError(broken[p], 120)
+	}
+	res.Changed = len(res.ChangedFiles) > 0
+	res.Summary = joinNonEmpty(res.Summary, fmt.Sprintf("skipped %d files that do not parse (%s)", len(dropped), strings.Join(dropped, "; ")), "; ")
+	return res
+}
+
+// repairSyntax sends the files in broken back with their parse errors and
+// returns the model's corrected content by path.
+func (e *OpenAIExecutor) repairSyntax(ctx context.Context, task cleanup.Task, original, changed, broken map[string]string) (map[string]string, error) {
+	type brokenFile struct {
+		Path     string `json:"path"`
+		Original string `json:"original"`
+		Changed  string `json:"changed"`
+		Error    string `json:"error"`
+	}
+	var files []brokenFile
+	for _, p := range slices.Sorted(maps.Keys(broken)) {
+		files = append(files, brokenFile{Path: p, Original: original[p], Changed: changed[p], Error: broken[p]})
+	}
+	filesJSON, err := json.Marshal(files)
+	if err != nil {
+		return nil, fmt.Errorf("marshal files: %w", err)
+	}
+	system := "You are a code cleanup engine. Return strict JSON with keys: changed, summary, files. files is an array of {path, content}."
+	user := fmt.Sprintf(
+		"Task: %s\nThe changed content of these files no longer parses (json; error is the parser's message): %s\n\nFix only the syntax errors in changed, keeping its cleanup changes, and return every file with its full corrected content. Return JSON only.",
+		task.Description, string(filesJSON),
+	)
+	reqCtx, cancel := context.WithTimeout(ctx, perBatchRequestTimeout)
+	defer cancel()
+	var out cleanupProjectLLMOutput
+	if err := e.complete(reqCtx, system, user, 3, &out); err != nil {
+		return nil, err
+	}
+	repaired := map[string]string{}
+	for _, f := range out.Files {
+		repaired[strings.TrimSpace(f.Path)] = f.Content
+	}
+	return repaired, nil
+}
+
+// complete sends one system and user message, trying up to maxAttempts
+// times, and decodes the JSON reply into out.
+func (e *OpenAIExecutor) complete(ctx context.Context, system, user string, maxAttempts int, out any) error {
+	reqBody := chatCompletionRequest{
+		Model: e.model,
+		Messages: []map[string]string{
+			{"role": "system", "content": system},
+			{"role": "user", "content": user},
+		},
+		ResponseFormat: map[string]string{"type": "json_object"},
+	}
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("marshal OpenAI request: %w", err)
+	}
+	text, err := e.chatCompletionsWithRetry(ctx, body, maxAttempts)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(text), out); err != nil {
+		return fmt.Errorf("parse JSON output: %w", err)
+	}
+	return nil
+}
+
 func (e *OpenAIExecutor) chatCompletionsWithRetry(ctx context.Context, body []byte, maxAttempts int) (string, error) {
 	var lastErr error
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
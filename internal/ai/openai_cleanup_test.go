@@ -0,0 +1,88 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"cool-code-cleanup/internal/cleanup"
+)
+
+// stubTransport answers each request with the next reply and records the
+// user messages it was sent.
+type stubTransport struct {
+	replies []string
+	sent    []string
+}
+
+func (s *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body chatCompletionRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	s.sent = append(s.sent, body.Messages[len(body.Messages)-1]["content"])
+	reply := `{}`
+	if len(s.replies) > 0 {
+		reply, s.replies = s.replies[0], s.replies[1:]
+	}
+	resp, err := json.Marshal(map[string]any{"choices": []any{map[string]any{"message": map[string]string{"content": reply}}}})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(string(resp))), Header: http.Header{}}, nil
+}
+
+// filesReply is the model's reply changing path to content.
+func filesReply(t *testing.T, path, content string) string {
+	t.Helper()
+	out, err := json.Marshal(map[string]any{"changed": true, "summary": "cleaned", "files": []any{map[string]string{"path": path, "content": content}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestCheckSyntaxRepairsOrDropsFilesThatDoNotParse(t *testing.T) {
+	const (
+		path   = "main.go"
+		before = "package main\n\nfunc main() {\n\tprintln(1)\n}\n"
+		broken = "package main\n\nfunc main() {\n\tprintln(2)\n"
+		fixed  = "package main\n\nfunc main() {\n\tprintln(2)\n}\n"
+	)
+	files := []cleanup.ProjectFile{{Path: path, Content: before}}
+	cases := []struct {
+		name    string
+		repair  string
+		want    string
+		dropped bool
+	}{
+		{name: "repaired", repair: fixed, want: fixed},
+		{name: "still broken", repair: broken, dropped: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			stub := &stubTransport{replies: []string{filesReply(t, path, broken), filesReply(t, path, c.repair)}}
+			e := &OpenAIExecutor{model: "test", client: &http.Client{Transport: stub}, repairAttempts: 1}
+			res, err := e.TransformProject(context.Background(), "", files, cleanup.Task{Description: "tidy"}, nil, true, false)
+			if err != nil {
+				t.Fatalf("transform: %v", err)
+			}
+			if len(stub.sent) != 2 || !strings.Contains(stub.sent[1], "no longer parses") {
+				t.Fatalf("expected one repair request, sent %q", stub.sent)
+			}
+			got, ok := res.ChangedFiles[path]
+			if c.dropped {
+				if ok || !strings.Contains(res.Summary, "skipped 1 files that do not parse") {
+					t.Fatalf("expected the file dropped, got %+v", res)
+				}
+				return
+			}
+			if got != c.want {
+				t.Fatalf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
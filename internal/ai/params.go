@@ -0,0 +1,41 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cool-code-cleanup/internal/discovery"
+	"cool-code-cleanup/internal/profile"
+)
+
+// InferParameters proposes request params for a route from its handler
+// source. The result is unvalidated; callers run profile.ValidateParams.
+func (f *OpenAIFallback) InferParameters(r discovery.Route, handlerSource string) ([]profile.Param, error) {
+	if f == nil || f.executor == nil {
+		return nil, nil
+	}
+
+	system := "You infer HTTP request parameters from route handler source code. Return strict JSON only."
+	user := fmt.Sprintf(
+		`Infer the request parameters this handler reads, with a realistic example value for each.
+Return strict JSON in this shape:
+{"params":[{"name":"...","in":"path|query|header|body","type":"string|integer|number|boolean|array|object|file","format":"email|uuid|url|date|date-time|slug|","required":true,"min":0,"max":0,"min_length":0,"max_length":0,"enum":["..."],"example":"..."}]}
+Omit min/max/min_length/max_length/enum/format when the source does not constrain them.
+Path params must use the names in the route path. Only include params the handler actually reads.
+
+Route: %s %s (framework: %s, handler: %s)
+
+Handler source:
+%s`, r.Method, r.Path, r.Framework, r.Handler, handlerSource)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	var out struct {
+		Params []profile.Param `json:"params"`
+	}
+	if err := f.executor.complete(ctx, system, user, 2, &out); err != nil {
+		return nil, err
+	}
+	return out.Params, nil
+}
@@ -0,0 +1,283 @@
+package baseline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cool-code-cleanup/internal/runner"
+)
+
+const SchemaVersion = 1
+
+// Baseline is a saved profile run to compare later runs with. Routes are
+// keyed by method and path, since route IDs embed line numbers that shift as
+// code changes.
+type Baseline struct {
+	SchemaVersion int     `json:"schema_version"`
+	Name          string  `json:"name"`
+	RunID         string  `json:"run_id"`
+	CreatedAt     string  `json:"created_at"`
+	Routes        []Route `json:"routes"`
+}
+
+// Route is what one route returned for each parameter set, and its latency
+// statistics when it was measured.
+type Route struct {
+	Method   string             `json:"method"`
+	Path     string             `json:"path"`
+	Variants []Variant          `json:"variants"`
+	Latency  *runner.RouteStats `json:"latency,omitempty"`
+}
+
+type Variant struct {
+	Name    string `json:"name"`
+	Invalid bool   `json:"invalid"`
+	Status  int    `json:"status"`
+	Outcome string `json:"outcome"`
+}
+
+// Thresholds decide which differences are regressions. A route's p95 latency
+// regressed when it grew by more than P95IncreasePct percent and at least
+// P95IncreaseMs milliseconds; a percentage of 0 disables the latency check.
+type Thresholds struct {
+	P95IncreasePct      float64
+	P95IncreaseMs       float64
+	ErrorRateIncrease   float64
+	FailOnStatusChange  bool
+	FailOnRemovedRoutes bool
+}
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+)
+
+// RouteDiff compares one route between the baseline and the current run.
+type RouteDiff struct {
+	Method          string   `json:"method"`
+	Path            string   `json:"path"`
+	Change          string   `json:"change,omitempty"`
+	StatusChanges   []string `json:"status_changes,omitempty"`
+	P95BeforeMs     float64  `json:"p95_before_ms"`
+	P95AfterMs      float64  `json:"p95_after_ms"`
+	P95DeltaPct     float64  `json:"p95_delta_pct"`
+	ErrorRateBefore float64  `json:"error_rate_before"`
+	ErrorRateAfter  float64  `json:"error_rate_after"`
+	Regressions     []string `json:"regressions,omitempty"`
+}
+
+// Comparison is the per-route diff of a run against a named baseline.
+type Comparison struct {
+	Baseline      string      `json:"baseline"`
+	BaselineRunID string      `json:"baseline_run_id"`
+	Routes        []RouteDiff `json:"routes"`
+	Regressions   int         `json:"regressions"`
+}
+
+var reNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+func DefaultDir() string {
+	return filepath.Join(".ccc", "baselines")
+}
+
+// Path returns the file of the baseline called name in dir. Names are used
+// as file names, so only letters, digits, '.', '_' and '-' are allowed.
+func Path(dir, name string) (string, error) {
+	if name == "" || reNameUnsafe.MatchString(name) || strings.Trim(name, ".") == "" {
+		return "", fmt.Errorf("invalid baseline name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// Build collects a run's invocations and latency statistics by route, in
+// invocation order.
+func Build(name, runID string, invocations []runner.Invocation, latency []runner.RouteStats) Baseline {
+	b := Baseline{SchemaVersion: SchemaVersion, Name: name, RunID: runID, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+	idx := map[string]int{}
+	route := func(method, path string) *Route {
+		k := key(method, path)
+		i, ok := idx[k]
+		if !ok {
+			i = len(b.Routes)
+			idx[k] = i
+			b.Routes = append(b.Routes, Route{Method: method, Path: path})
+		}
+		return &b.Routes[i]
+	}
+	for _, inv := range invocations {
+		r := route(inv.Method, inv.Path)
+		r.Variants = append(r.Variants, Variant{Name: inv.Variant, Invalid: inv.Invalid, Status: inv.Status, Outcome: inv.Outcome})
+	}
+	for _, s := range latency {
+		stats := s
+		route(s.Method, s.Path).Latency = &stats
+	}
+	return b
+}
+
+func key(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+func Save(path string, b Baseline) error {
+	clean := filepath.Clean(path)
+	if err := os.MkdirAll(filepath.Dir(clean), 0o755); err != nil {
+		return fmt.Errorf("create baseline directory: %w", err)
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(clean, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write baseline %s: %w", clean, err)
+	}
+	return nil
+}
+
+func Load(path string) (Baseline, error) {
+	clean := filepath.Clean(path)
+	data, err := os.ReadFile(clean)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Baseline{}, fmt.Errorf("baseline %s not found; save one with ccc profile --save-baseline <name>", clean)
+		}
+		return Baseline{}, fmt.Errorf("read baseline %s: %w", clean, err)
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return Baseline{}, fmt.Errorf("parse baseline %s: %w", clean, err)
+	}
+	if b.SchemaVersion != SchemaVersion {
+		return Baseline{}, fmt.Errorf("unsupported baseline schema_version=%d in %s (expected %d)", b.SchemaVersion, clean, SchemaVersion)
+	}
+	return b, nil
+}
+
+// Compare diffs current against base route by route: routes in current
+// order, then removed ones. Status changes are matched by variant name.
+func Compare(base, current Baseline, t Thresholds) Comparison {
+	c := Comparison{Baseline: base.Name, BaselineRunID: base.RunID}
+	before := map[string]Route{}
+	for _, r := range base.Routes {
+		before[key(r.Method, r.Path)] = r
+	}
+	seen := map[string]bool{}
+	for _, r := range current.Routes {
+		k := key(r.Method, r.Path)
+		seen[k] = true
+		d := RouteDiff{Method: r.Method, Path: r.Path}
+		b, ok := before[k]
+		if !ok {
+			d.Change = ChangeAdded
+			d.P95AfterMs, d.ErrorRateAfter = stats(r.Latency)
+			c.Routes = append(c.Routes, d)
+			continue
+		}
+		d.P95BeforeMs, d.ErrorRateBefore = stats(b.Latency)
+		d.P95AfterMs, d.ErrorRateAfter = stats(r.Latency)
+		if d.P95BeforeMs > 0 {
+			d.P95DeltaPct = (d.P95AfterMs - d.P95BeforeMs) / d.P95BeforeMs * 100
+		}
+		d.StatusChanges = statusChanges(b.Variants, r.Variants)
+		if t.FailOnStatusChange && len(d.StatusChanges) > 0 {
+			d.Regressions = append(d.Regressions, "status changed")
+		}
+		if t.P95IncreasePct > 0 && b.Latency != nil && r.Latency != nil && d.P95DeltaPct > t.P95IncreasePct && d.P95AfterMs-d.P95BeforeMs >= t.P95IncreaseMs {
+			d.Regressions = append(d.Regressions, fmt.Sprintf("p95 +%.0f%%", d.P95DeltaPct))
+		}
+		if t.ErrorRateIncrease > 0 && d.ErrorRateAfter-d.ErrorRateBefore > t.ErrorRateIncrease {
+			d.Regressions = append(d.Regressions, fmt.Sprintf("error rate +%.1f%%", (d.ErrorRateAfter-d.ErrorRateBefore)*100))
+		}
+		c.Routes = append(c.Routes, d)
+	}
+	for _, r := range base.Routes {
+		if seen[key(r.Method, r.Path)] {
+			continue
+		}
+		d := RouteDiff{Method: r.Method, Path: r.Path, Change: ChangeRemoved}
+		d.P95BeforeMs, d.ErrorRateBefore = stats(r.Latency)
+		if t.FailOnRemovedRoutes {
+			d.Regressions = append(d.Regressions, "route removed")
+		}
+		c.Routes = append(c.Routes, d)
+	}
+	for _, d := range c.Routes {
+		if len(d.Regressions) > 0 {
+			c.Regressions++
+		}
+	}
+	return c
+}
+
+func stats(s *runner.RouteStats) (p95, errorRate float64) {
+	if s == nil {
+		return 0, 0
+	}
+	return s.LatencyMs.P95, s.ErrorRate
+}
+
+// statusChanges lists variants whose status differs, e.g. "valid 200->500".
+func statusChanges(before, after []Variant) []string {
+	status := map[string]int{}
+	for _, v := range before {
+		status[variantKey(v)] = v.Status
+	}
+	var out []string
+	for _, v := range after {
+		if s, ok := status[variantKey(v)]; ok && s != v.Status {
+			out = append(out, fmt.Sprintf("%s %d->%d", v.Name, s, v.Status))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func variantKey(v Variant) string {
+	if v.Invalid {
+		return "invalid:" + v.Name
+	}
+	return "valid:" + v.Name
+}
+
+// FormatTable renders the comparison as an aligned table, one route per row.
+func FormatTable(c Comparison) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROUTE\tCHANGE\tSTATUS\tP95 BEFORE\tP95 AFTER\tDELTA\tREGRESSION")
+	for _, d := range c.Routes {
+		change := d.Change
+		if change == "" {
+			change = "-"
+		}
+		status := strings.Join(d.StatusChanges, ", ")
+		if status == "" {
+			status = "-"
+		}
+		delta := "-"
+		if d.Change == "" && d.P95BeforeMs > 0 {
+			delta = fmt.Sprintf("%+.1f%%", d.P95DeltaPct)
+		}
+		regression := strings.Join(d.Regressions, ", ")
+		if regression == "" {
+			regression = "-"
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%s\t%s\t%s\t%s\t%s\n", d.Method, d.Path, change, status, formatMs(d.P95BeforeMs, d.Change == ChangeAdded), formatMs(d.P95AfterMs, d.Change == ChangeRemoved), delta, regression)
+	}
+	_ = w.Flush()
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func formatMs(ms float64, absent bool) string {
+	if absent {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fms", ms)
+}
@@ -0,0 +1,112 @@
+package baseline
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"cool-code-cleanup/internal/runner"
+)
+
+func routeStats(method, path string, p95, errorRate float64) runner.RouteStats {
+	return runner.RouteStats{Method: method, Path: path, ErrorRate: errorRate, LatencyMs: runner.LatencyStats{P95: p95}}
+}
+
+func TestCompareFlagsRegressionsAgainstThresholds(t *testing.T) {
+	base := Build("main", "run-1", []runner.Invocation{
+		{Method: "GET", Path: "/users", Variant: "valid", Status: 200},
+		{Method: "POST", Path: "/users", Variant: "valid", Status: 201},
+		{Method: "POST", Path: "/users", Variant: "missing_name", Invalid: true, Status: 400},
+		{Method: "GET", Path: "/orders", Variant: "valid", Status: 200},
+		{Method: "DELETE", Path: "/legacy", Variant: "valid", Status: 204},
+	}, []runner.RouteStats{
+		routeStats("GET", "/users", 10, 0),
+		routeStats("POST", "/users", 20, 0),
+		routeStats("GET", "/orders", 2, 0),
+	})
+	current := Build("", "run-2", []runner.Invocation{
+		{Method: "GET", Path: "/users", Variant: "valid", Status: 200},
+		{Method: "POST", Path: "/users", Variant: "valid", Status: 201},
+		{Method: "POST", Path: "/users", Variant: "missing_name", Invalid: true, Status: 500},
+		{Method: "GET", Path: "/orders", Variant: "valid", Status: 200},
+		{Method: "GET", Path: "/health", Variant: "valid", Status: 200},
+	}, []runner.RouteStats{
+		routeStats("GET", "/users", 20, 0.1),
+		routeStats("POST", "/users", 21, 0),
+		// +100% but only 2ms slower: below p95_increase_ms.
+		routeStats("GET", "/orders", 4, 0),
+	})
+
+	c := Compare(base, current, Thresholds{P95IncreasePct: 25, P95IncreaseMs: 5, ErrorRateIncrease: 0.05, FailOnStatusChange: true, FailOnRemovedRoutes: true})
+	if c.Baseline != "main" || c.BaselineRunID != "run-1" {
+		t.Fatalf("unexpected comparison header %+v", c)
+	}
+	got := map[string]RouteDiff{}
+	var order []string
+	for _, d := range c.Routes {
+		got[d.Method+" "+d.Path] = d
+		order = append(order, d.Method+" "+d.Path)
+	}
+	if want := []string{"GET /users", "POST /users", "GET /orders", "GET /health", "DELETE /legacy"}; !slices.Equal(order, want) {
+		t.Fatalf("route order = %v, want %v", order, want)
+	}
+	if d := got["GET /users"]; !slices.Equal(d.Regressions, []string{"p95 +100%", "error rate +10.0%"}) {
+		t.Fatalf("GET /users regressions = %v", d.Regressions)
+	}
+	if d := got["POST /users"]; !slices.Equal(d.StatusChanges, []string{"missing_name 400->500"}) || !slices.Equal(d.Regressions, []string{"status changed"}) {
+		t.Fatalf("POST /users = %+v", d)
+	}
+	if d := got["GET /orders"]; len(d.Regressions) != 0 || d.P95DeltaPct != 100 {
+		t.Fatalf("GET /orders = %+v", d)
+	}
+	if d := got["GET /health"]; d.Change != ChangeAdded || len(d.Regressions) != 0 {
+		t.Fatalf("GET /health = %+v", d)
+	}
+	if d := got["DELETE /legacy"]; d.Change != ChangeRemoved || !slices.Equal(d.Regressions, []string{"route removed"}) {
+		t.Fatalf("DELETE /legacy = %+v", d)
+	}
+	if c.Regressions != 3 {
+		t.Fatalf("regressions = %d, want 3", c.Regressions)
+	}
+
+	lenient := Compare(base, current, Thresholds{})
+	if lenient.Regressions != 0 {
+		t.Fatalf("zero thresholds should flag nothing, got %+v", lenient.Routes)
+	}
+
+	table := FormatTable(c)
+	for _, want := range []string{"ROUTE", "POST /users", "missing_name 400->500", "DELETE /legacy", "removed", "+100.0%"} {
+		if !strings.Contains(table, want) {
+			t.Fatalf("table missing %q:\n%s", want, table)
+		}
+	}
+}
+
+func TestSaveLoadRoundTripAndNames(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), DefaultDir())
+	path, err := Path(dir, "release-1.2")
+	if err != nil {
+		t.Fatalf("path: %v", err)
+	}
+	b := Build("release-1.2", "run-1", []runner.Invocation{{Method: "GET", Path: "/users", Variant: "valid", Status: 200}}, []runner.RouteStats{routeStats("GET", "/users", 12.5, 0)})
+	if err := Save(path, b); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if loaded.Name != "release-1.2" || len(loaded.Routes) != 1 || loaded.Routes[0].Latency == nil || loaded.Routes[0].Latency.LatencyMs.P95 != 12.5 {
+		t.Fatalf("unexpected baseline %+v", loaded)
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil || !strings.Contains(err.Error(), "--save-baseline") {
+		t.Fatalf("expected a not-found hint, got %v", err)
+	}
+	for _, name := range []string{"", "..", "../x", "a/b", "with space"} {
+		if _, err := Path(dir, name); err == nil {
+			t.Fatalf("expected %q to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cool-code-cleanup/internal/rules"
+)
+
+// Chain runs executors one after another on each task, each on the files as
+// the ones before it left them, e.g. deterministic rewrites and then the
+// model for what they do not cover. Once an executor reports the task
+// complete, the ones after it get only its leftover findings, in the task
+// description, the files they are in and the files it did not handle;
+// without any they are skipped. A failing executor is noted in the summary
+// and skipped; the task fails only when all of them do.
+type Chain []ProjectExecutor
+
+func (c Chain) TransformProject(ctx context.Context, projectRoot string, files []ProjectFile, task Task, selectedRules []rules.Rule, safe, aggressive bool) (ProjectTransformResult, error) {
+	current := make([]ProjectFile, len(files))
+	copy(current, files)
+	index := map[string]int{}
+	for i, f := range current {
+		index[f.Path] = i
+	}
+	out := ProjectTransformResult{ChangedFiles: map[string]string{}, FileSummaries: map[string]string{}}
+	var summaries []string
+	var lastErr error
+	var only map[string]bool
+	failed := 0
+	for _, executor := range c {
+		scope := current
+		if only != nil {
+			scope = nil
+			for _, f := range current {
+				if only[f.Path] {
+					scope = append(scope, f)
+				}
+			}
+		}
+		res, err := executor.TransformProject(ctx, projectRoot, scope, task, selectedRules, safe, aggressive)
+		if err != nil {
+			failed++
+			lastErr = err
+			summaries = append(summaries, fmt.Sprintf("%T failed: %v", executor, err))
+			continue
+		}
+		for path, content := range res.ChangedFiles {
+			i, ok := index[path]
+			if !ok {
+				continue
+			}
+			current[i].Content = content
+			if content == files[i].Content {
+				delete(out.ChangedFiles, path)
+				delete(out.FileSummaries, path)
+				continue
+			}
+			out.ChangedFiles[path] = content
+			if s := strings.TrimSpace(nonEmpty(res.FileSummaries[path], res.Summary)); s != "" {
+				if prev := out.FileSummaries[path]; prev != "" {
+					s = prev + "; " + s
+				}
+				out.FileSummaries[path] = s
+			}
+		}
+		if s := strings.TrimSpace(res.Summary); s != "" {
+			summaries = append(summaries, s)
+		}
+		if res.Complete {
+			unhandled := unhandledFiles(scope, res.Handled)
+			if len(res.Leftover) == 0 && len(unhandled) == 0 {
+				break
+			}
+			task, only = leftoverTask(projectRoot, task, res.Leftover, unhandled)
+		}
+	}
+	if len(c) > 0 && failed == len(c) {
+		return ProjectTransformResult{}, lastErr
+	}
+	out.Changed = len(out.ChangedFiles) > 0
+	out.Summary = strings.Join(summaries, "; ")
+	return out, nil
+}
+
+// unhandledFiles returns the paths in scope that are not in handled; with no
+// handled paths every file counts as handled.
+func unhandledFiles(scope []ProjectFile, handled []string) []string {
+	if len(handled) == 0 {
+		return nil
+	}
+	done := map[string]bool{}
+	for _, p := range handled {
+		done[p] = true
+	}
+	var out []string
+	for _, f := range scope {
+		if !done[f.Path] {
+			out = append(out, f.Path)
+		}
+	}
+	return out
+}
+
+// leftoverTask narrows task to findings and the unhandled files, which still
+// get the whole task, and returns the files it covers.
+func leftoverTask(projectRoot string, task Task, findings []Finding, unhandled []string) (Task, map[string]bool) {
+	only := map[string]bool{}
+	task.Files = nil
+	for _, p := range unhandled {
+		only[p] = true
+		task.Files = append(task.Files, p)
+	}
+	if len(findings) == 0 {
+		return task, only
+	}
+	var sb strings.Builder
+	sb.WriteString(task.Description)
+	if len(unhandled) > 0 {
+		sb.WriteString("\nIn the files of these findings, which could not be fixed mechanically, only address them; do all of the task in the other files:")
+	} else {
+		sb.WriteString("\nOnly address these findings, which could not be fixed mechanically:")
+	}
+	for _, f := range findings {
+		if !only[f.Path] {
+			only[f.Path] = true
+			task.Files = append(task.Files, f.Path)
+		}
+		fmt.Fprintf(&sb, "\n- %s:%d: %s", diffName(projectRoot, f.Path), f.Line, f.Message)
+	}
+	task.Description = sb.String()
+	return task, only
+}
@@ -6,16 +6,18 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"cool-code-cleanup/internal/rules"
 )
 
+// Edit is one change to a file. Diff is the unified diff of the change,
+// empty for analysis suggestions that do not edit the file.
 type Edit struct {
 	File        string `json:"file"`
 	Description string `json:"description"`
-	Before      string `json:"before,omitempty"`
-	After       string `json:"after,omitempty"`
+	Diff        string `json:"diff,omitempty"`
 	Applied     bool   `json:"applied"`
 }
 
@@ -43,6 +45,9 @@ type TaskResult struct {
 	Applied      bool     `json:"applied"`
 	Summary      string   `json:"summary"`
 	Error        string   `json:"error,omitempty"`
+	// InvalidSyntax lists the changed files skipped because they no longer
+	// parse, each with the parse error.
+	InvalidSyntax []string `json:"invalid_syntax,omitempty"`
 }
 
 type ProgressEvent struct {
@@ -57,6 +62,23 @@ type ProjectTransformResult struct {
 	Changed      bool              `json:"changed"`
 	Summary      string            `json:"summary"`
 	ChangedFiles map[string]string `json:"changed_files"`
+	// FileSummaries optionally describes what changed in each file; it is
+	// used for the file's edit instead of Summary.
+	FileSummaries map[string]string `json:"file_summaries,omitempty"`
+	// Complete reports that the executor did all the task asks for in
+	// Handled, or in every file when Handled is empty, except Leftover,
+	// what it found but could not rewrite. A Chain hands only those, and
+	// the files it did not handle, to the executors after it.
+	Complete bool      `json:"complete,omitempty"`
+	Handled  []string  `json:"handled,omitempty"`
+	Leftover []Finding `json:"leftover,omitempty"`
+}
+
+// Finding is a problem found at a line of a file.
+type Finding struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
 }
 
 type ProjectExecutor interface {
@@ -98,6 +120,39 @@ func BuildProjectSnapshot(projectRoot string) ([]ProjectFile, error) {
 	return files, err
 }
 
+// ReadFiles snapshots the given files so their edits can be rolled back.
+func ReadFiles(paths []string) ([]ProjectFile, error) {
+	files := make([]ProjectFile, 0, len(paths))
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		files = append(files, ProjectFile{Path: path, Content: string(raw)})
+	}
+	return files, nil
+}
+
+// Restore writes back the snapshot content of each of paths, undoing the
+// edits made since the snapshot. Paths missing from the snapshot are left
+// alone.
+func Restore(snapshot []ProjectFile, paths []string) error {
+	byPath := map[string]string{}
+	for _, f := range snapshot {
+		byPath[f.Path] = f.Content
+	}
+	for _, path := range paths {
+		content, ok := byPath[path]
+		if !ok {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return fmt.Errorf("restore %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
 func BuildTaskPlan(files []ProjectFile, selectedRules []rules.Rule) []Task {
 	var tasks []Task
 	targets := allFilePaths(files)
@@ -116,7 +171,26 @@ func BuildTaskPlan(files []ProjectFile, selectedRules []rules.Rule) []Task {
 	return tasks
 }
 
-func ExecuteTaskPlan(projectRoot string, snapshot []ProjectFile, tasks []Task, selectedRules []rules.Rule, safe, aggressive, dryRun bool, executor ProjectExecutor, onProgress func(ProgressEvent)) (Plan, []Edit, []TaskResult, error) {
+// ExecuteOptions are the optional hooks of ExecuteTaskPlan.
+type ExecuteOptions struct {
+	// Check validates a changed file and returns the content to keep; a
+	// file it rejects is skipped.
+	Check func(path, before, after string) (string, error)
+	// Review is given every changed file that passed Check and returns the
+	// content to keep; returning the file's Before rejects the change. What
+	// it returns is checked again when it differs.
+	Review func(FileChange) (string, error)
+	// Verify runs after each task writes its files; a task it fails is
+	// reverted with the failure as the task's error.
+	Verify func() error
+	// OnProgress is told about each task and file as it is handled.
+	OnProgress func(ProgressEvent)
+}
+
+// ExecuteTaskPlan runs tasks in order, each on the files as earlier tasks
+// left them, with the hooks set in opts. Content Review changes is checked
+// again before it is kept.
+func ExecuteTaskPlan(projectRoot string, snapshot []ProjectFile, tasks []Task, selectedRules []rules.Rule, safe, aggressive, dryRun bool, executor ProjectExecutor, opts ExecuteOptions) (Plan, []Edit, []TaskResult, error) {
 	if executor == nil {
 		return Plan{}, nil, nil, fmt.Errorf("cleanup project executor is required")
 	}
@@ -132,8 +206,8 @@ func ExecuteTaskPlan(projectRoot string, snapshot []ProjectFile, tasks []Task, s
 
 	for _, task := range tasks {
 		taskFiles := filesForTask(snapshot, task, current)
-		if onProgress != nil {
-			onProgress(ProgressEvent{
+		if opts.OnProgress != nil {
+			opts.OnProgress(ProgressEvent{
 				RuleID:      task.RuleID,
 				RuleTitle:   task.RuleTitle,
 				Phase:       "running",
@@ -149,8 +223,8 @@ func ExecuteTaskPlan(projectRoot string, snapshot []ProjectFile, tasks []Task, s
 				Applied: false,
 				Error:   err.Error(),
 			})
-			if onProgress != nil {
-				onProgress(ProgressEvent{
+			if opts.OnProgress != nil {
+				opts.OnProgress(ProgressEvent{
 					RuleID:      task.RuleID,
 					RuleTitle:   task.RuleTitle,
 					Phase:       "error",
@@ -164,10 +238,10 @@ func ExecuteTaskPlan(projectRoot string, snapshot []ProjectFile, tasks []Task, s
 				TaskID:  task.ID,
 				RuleID:  task.RuleID,
 				Applied: false,
-				Summary: "no changes",
+				Summary: nonEmpty(result.Summary, "no changes"),
 			})
-			if onProgress != nil {
-				onProgress(ProgressEvent{
+			if opts.OnProgress != nil {
+				opts.OnProgress(ProgressEvent{
 					RuleID:      task.RuleID,
 					RuleTitle:   task.RuleTitle,
 					Phase:       "no_change",
@@ -178,24 +252,74 @@ func ExecuteTaskPlan(projectRoot string, snapshot []ProjectFile, tasks []Task, s
 		}
 
 		changedPaths := make([]string, 0, len(result.ChangedFiles))
-		for path, next := range result.ChangedFiles {
+		previous := map[string]string{}
+		planStart, appliedStart := len(plan.Edits), len(applied)
+		rejected := 0
+		var invalid []string
+		for _, path := range sortedKeys(result.ChangedFiles) {
+			next := result.ChangedFiles[path]
 			prev, ok := current[path]
 			if !ok || next == prev {
 				continue
 			}
+			// check runs opts.Check on content, recording a rejection as
+			// invalid syntax.
+			check := func(content string) (string, bool) {
+				if opts.Check == nil {
+					return content, true
+				}
+				checked, err := opts.Check(path, prev, content)
+				if err != nil {
+					invalid = append(invalid, fmt.Sprintf("%s: %v", diffName(projectRoot, path), err))
+					if opts.OnProgress != nil {
+						opts.OnProgress(ProgressEvent{
+							File:        path,
+							RuleID:      task.RuleID,
+							RuleTitle:   task.RuleTitle,
+							Phase:       "invalid",
+							Description: err.Error(),
+						})
+					}
+					return "", false
+				}
+				return checked, true
+			}
+			if next, ok = check(next); !ok || next == prev {
+				continue
+			}
+			note := ""
+			if opts.Review != nil {
+				reviewed, err := opts.Review(FileChange{Task: task, Path: path, Before: prev, After: next})
+				if err != nil {
+					return plan, applied, results, fmt.Errorf("review %s: %w", path, err)
+				}
+				if reviewed == prev {
+					rejected++
+					continue
+				}
+				if reviewed != next {
+					// Hunks accepted in part, or edited, may not parse
+					// together.
+					note = " (partially accepted in review)"
+					if reviewed, ok = check(reviewed); !ok || reviewed == prev {
+						continue
+					}
+				}
+				next = reviewed
+			}
+			previous[path] = prev
 			current[path] = next
 			changedPaths = append(changedPaths, path)
 			edit := Edit{
 				File:        path,
-				Description: fmt.Sprintf("[%s] %s", task.RuleID, nonEmpty(result.Summary, "AI project cleanup change")),
-				Before:      "project-level AI task",
-				After:       "updated content",
+				Description: fmt.Sprintf("[%s] %s%s", task.RuleID, nonEmpty(result.FileSummaries[path], nonEmpty(result.Summary, "AI project cleanup change")), note),
+				Diff:        UnifiedDiff(diffName(projectRoot, path), prev, next),
 				Applied:     !dryRun,
 			}
 			plan.Edits = append(plan.Edits, edit)
 			applied = append(applied, edit)
-			if onProgress != nil {
-				onProgress(ProgressEvent{
+			if opts.OnProgress != nil {
+				opts.OnProgress(ProgressEvent{
 					File:        path,
 					RuleID:      task.RuleID,
 					RuleTitle:   task.RuleTitle,
@@ -205,22 +329,66 @@ func ExecuteTaskPlan(projectRoot string, snapshot []ProjectFile, tasks []Task, s
 			}
 		}
 		if len(changedPaths) == 0 {
+			switch {
+			case len(invalid) > 0:
+				results = append(results, TaskResult{
+					TaskID:        task.ID,
+					RuleID:        task.RuleID,
+					Applied:       false,
+					Summary:       fmt.Sprintf("changes to %d files skipped: invalid syntax", len(invalid)),
+					InvalidSyntax: invalid,
+				})
+			case rejected > 0:
+				results = append(results, TaskResult{
+					TaskID:  task.ID,
+					RuleID:  task.RuleID,
+					Applied: false,
+					Summary: fmt.Sprintf("changes to %d files rejected in review", rejected),
+				})
+			}
 			continue
 		}
-		results = append(results, TaskResult{
-			TaskID:       task.ID,
-			RuleID:       task.RuleID,
-			ChangedFiles: changedPaths,
-			Applied:      !dryRun,
-			Summary:      nonEmpty(result.Summary, "task applied"),
-		})
+		taskResult := TaskResult{
+			TaskID:        task.ID,
+			RuleID:        task.RuleID,
+			ChangedFiles:  changedPaths,
+			Applied:       !dryRun,
+			Summary:       nonEmpty(result.Summary, "task applied"),
+			InvalidSyntax: invalid,
+		}
 		if !dryRun {
 			for _, path := range changedPaths {
 				if err := os.WriteFile(path, []byte(current[path]), 0o644); err != nil {
-					return plan, applied, results, fmt.Errorf("write %s: %w", path, err)
+					return plan, applied, append(results, taskResult), fmt.Errorf("write %s: %w", path, err)
+				}
+			}
+			if opts.Verify != nil {
+				if err := opts.Verify(); err != nil {
+					for _, path := range changedPaths {
+						current[path] = previous[path]
+						if werr := os.WriteFile(path, []byte(previous[path]), 0o644); werr != nil {
+							return plan, applied, append(results, taskResult), fmt.Errorf("revert %s: %w", path, werr)
+						}
+					}
+					for i := planStart; i < len(plan.Edits); i++ {
+						plan.Edits[i].Applied = false
+					}
+					applied = applied[:appliedStart]
+					taskResult.Applied = false
+					taskResult.Summary = "reverted: verification failed"
+					taskResult.Error = "verification failed: " + err.Error()
+					if opts.OnProgress != nil {
+						opts.OnProgress(ProgressEvent{
+							RuleID:      task.RuleID,
+							RuleTitle:   task.RuleTitle,
+							Phase:       "reverted",
+							Description: "verification failed",
+						})
+					}
 				}
 			}
 		}
+		results = append(results, taskResult)
 	}
 
 	if len(tasks) > 0 && len(results) == len(tasks) {
@@ -239,9 +407,18 @@ func ExecuteTaskPlan(projectRoot string, snapshot []ProjectFile, tasks []Task, s
 	return plan, applied, results, nil
 }
 
+// Evidence is what profiling measured about the code: hot functions from CPU
+// profiles and code no profiled route executed.
+type Evidence struct {
+	Hotspots            []Hotspot
+	UnexecutedFunctions []Unexecuted
+	UnexecutedFiles     []string
+}
+
 // BuildPlan is a compatibility planner used by profile mode's cleanup proposal step.
-// Cleanup mode itself uses the project-wide task execution pipeline.
-func BuildPlan(projectRoot string, selectedRules []rules.Rule, safe, aggressive bool) (Plan, error) {
+// Cleanup mode itself uses the project-wide task execution pipeline. Evidence
+// adds suggestions to the files it was found in.
+func BuildPlan(projectRoot string, selectedRules []rules.Rule, safe, aggressive bool, evidence Evidence) (Plan, error) {
 	cap := capabilitiesFromRules(selectedRules)
 	var plan Plan
 	err := walkTargetFiles(projectRoot, func(path string) error {
@@ -255,8 +432,7 @@ func BuildPlan(projectRoot string, selectedRules []rules.Rule, safe, aggressive
 				plan.Edits = append(plan.Edits, Edit{
 					File:        path,
 					Description: "Normalize whitespace and collapse excessive blank lines",
-					Before:      "text formatting",
-					After:       "normalized",
+					Diff:        UnifiedDiff(diffName(projectRoot, path), content, normalizeWhitespace(content)),
 					Applied:     false,
 				})
 			}
@@ -266,14 +442,13 @@ func BuildPlan(projectRoot string, selectedRules []rules.Rule, safe, aggressive
 				plan.Edits = append(plan.Edits, Edit{
 					File:        path,
 					Description: "Remove redundant always-true guard conditions",
-					Before:      "if true",
-					After:       "bare block",
+					Diff:        UnifiedDiff(diffName(projectRoot, path), content, removeTrueGuards(content)),
 					Applied:     false,
 				})
 			}
 		}
 		if cap.detectExpensiveFunctions {
-			suggestions := detectExpensiveSuggestions(content)
+			suggestions := append(hotspotSuggestions(projectRoot, path, evidence.Hotspots), detectExpensiveSuggestions(content)...)
 			if len(suggestions) > 0 {
 				desc := "Performance analysis suggestion(s): " + strings.Join(suggestions, "; ") + " (analysis suggestion)"
 				plan.Edits = append(plan.Edits, Edit{
@@ -283,35 +458,50 @@ func BuildPlan(projectRoot string, selectedRules []rules.Rule, safe, aggressive
 				})
 			}
 		}
+		if desc := deadCodeSuggestion(projectRoot, path, evidence); desc != "" {
+			plan.Edits = append(plan.Edits, Edit{
+				File:        path,
+				Description: desc,
+				Applied:     false,
+			})
+		}
 		return nil
 	})
 	return plan, err
 }
 
 // ApplyPlan is a compatibility applier used by profile mode's cleanup proposal step.
+// Each applied edit's diff is redone against the file as earlier edits left
+// it, so edits to the same file chain, in dry-run too.
 func ApplyPlan(plan Plan, safe, aggressive, dryRun bool) ([]Edit, error) {
 	applied := make([]Edit, 0, len(plan.Edits))
+	current := map[string]string{}
 	for _, edit := range plan.Edits {
 		if strings.Contains(strings.ToLower(edit.Description), "analysis suggestion") {
 			applied = append(applied, edit)
 			continue
 		}
-		raw, err := os.ReadFile(edit.File)
-		if err != nil {
-			return applied, fmt.Errorf("read %s: %w", edit.File, err)
+		orig, ok := current[edit.File]
+		if !ok {
+			raw, err := os.ReadFile(edit.File)
+			if err != nil {
+				return applied, fmt.Errorf("read %s: %w", edit.File, err)
+			}
+			orig = string(raw)
 		}
-		orig := string(raw)
 		next := orig
 		switch edit.Description {
 		case "Normalize whitespace and collapse excessive blank lines":
 			next = normalizeWhitespace(orig)
 		case "Remove redundant always-true guard conditions":
 			if aggressive && !safe {
-				next = regexp.MustCompile(`if\s+\(?true\)?\s*\{`).ReplaceAllString(orig, "{")
+				next = removeTrueGuards(orig)
 			}
 		}
+		edit.Diff = UnifiedDiff(diffName("", edit.File), orig, next)
 		if next != orig {
 			edit.Applied = true
+			current[edit.File] = next
 			if !dryRun {
 				if err := os.WriteFile(edit.File, []byte(next), 0o644); err != nil {
 					return applied, fmt.Errorf("write %s: %w", edit.File, err)
@@ -323,6 +513,19 @@ func ApplyPlan(plan Plan, safe, aggressive, dryRun bool) ([]Edit, error) {
 	return applied, nil
 }
 
+func removeTrueGuards(content string) string {
+	return regexp.MustCompile(`if\s+\(?true\)?\s*\{`).ReplaceAllString(content, "{")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func allFilePaths(files []ProjectFile) []string {
 	out := make([]string, 0, len(files))
 	for _, f := range files {
@@ -2,10 +2,12 @@ package cleanup
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"cool-code-cleanup/internal/rules"
 )
@@ -81,13 +83,91 @@ func TestProjectWidePhasesAndExecution(t *testing.T) {
 		t.Fatalf("expected task plan")
 	}
 
-	plan, applied, taskResults, err := ExecuteTaskPlan(dir, snapshot, tasks, selected, false, true, false, fakeProjectExec{}, nil)
+	plan, applied, taskResults, err := ExecuteTaskPlan(dir, snapshot, tasks, selected, false, true, false, fakeProjectExec{}, ExecuteOptions{})
 	if err != nil {
 		t.Fatalf("execute: %v", err)
 	}
 	if len(plan.Edits) == 0 || len(applied) == 0 || len(taskResults) == 0 {
 		t.Fatalf("expected edits and task results")
 	}
+	wantDiff := "--- a/sample.go\n+++ b/sample.go\n@@ -1,2 +1,2 @@\n package main\n-func x(){ if true { println(\"ok\") } }\n+func x(){ { println(\"ok\") } }\n"
+	if applied[0].Diff != wantDiff {
+		t.Fatalf("unexpected diff:\n%s", applied[0].Diff)
+	}
+}
+
+func TestUnifiedDiffHunks(t *testing.T) {
+	var before, after strings.Builder
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&before, "line %d\n", i)
+		switch i {
+		case 2:
+			fmt.Fprintf(&after, "line %d changed\n", i)
+		case 6:
+			// within 2*context of line 2: same hunk
+		case 15:
+			fmt.Fprintf(&after, "line %d\ninserted\n", i)
+		default:
+			fmt.Fprintf(&after, "line %d\n", i)
+		}
+	}
+	hunks := DiffHunks(before.String(), after.String())
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %+v", hunks)
+	}
+	if got := hunks[0].Header(); got != "@@ -1,9 +1,8 @@" {
+		t.Fatalf("first hunk header %q", got)
+	}
+	if got := hunks[1].Header(); got != "@@ -13,6 +12,7 @@" {
+		t.Fatalf("second hunk header %q", got)
+	}
+	if hunks[1].Lines[3] != "+inserted\n" {
+		t.Fatalf("unexpected second hunk %q", hunks[1].Lines)
+	}
+
+	if d := UnifiedDiff("a.txt", "x\ny\n", "x\ny\n"); d != "" {
+		t.Fatalf("expected no diff for equal content, got %q", d)
+	}
+	want := "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n x\n-y\n+y\n\\ No newline at end of file\n"
+	if d := UnifiedDiff("a.txt", "x\ny\n", "x\ny"); d != want {
+		t.Fatalf("unexpected diff for dropped final newline:\n%s", d)
+	}
+	want = "--- a/new.txt\n+++ b/new.txt\n@@ -0,0 +1 @@\n+hello\n"
+	if d := UnifiedDiff("new.txt", "", "hello\n"); d != want {
+		t.Fatalf("unexpected diff for new content:\n%s", d)
+	}
+}
+
+func TestApplyPlanChainsDiffsInDryRun(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "sample.go")
+	content := "package main\n\n\n\nfunc x() {\n\tif true {\n\t\tprintln(\"ok\")   \n\t}\n}\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	selected := []rules.Rule{
+		{ID: "refactor_dry", Enabled: true},
+		{ID: "remove_redundant_guards", Title: "Remove redundant guard conditions", Enabled: true},
+	}
+	plan, err := BuildPlan(dir, selected, false, true, Evidence{})
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	if len(plan.Edits) != 2 || plan.Edits[0].Diff == "" || plan.Edits[1].Diff == "" {
+		t.Fatalf("expected two proposed edits with diffs, got %+v", plan.Edits)
+	}
+	applied, err := ApplyPlan(plan, false, true, true)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	// The guard edit's diff starts from the whitespace edit's result.
+	if strings.Contains(applied[1].Diff, "   \n") || !strings.Contains(applied[1].Diff, "-\tif true {\n+\t{\n") {
+		t.Fatalf("guard diff not chained on the normalized file:\n%s", applied[1].Diff)
+	}
+	raw, _ := os.ReadFile(file)
+	if string(raw) != content {
+		t.Fatalf("dry-run wrote the file")
+	}
 }
 
 func TestExecuteTaskPlanContinuesAfterTaskError(t *testing.T) {
@@ -118,7 +198,7 @@ func TestExecuteTaskPlanContinuesAfterTaskError(t *testing.T) {
 		},
 	}
 	tasks := BuildTaskPlan(snapshot, selected)
-	plan, applied, results, err := ExecuteTaskPlan(dir, snapshot, tasks, selected, false, true, true, flakyProjectExec{}, nil)
+	plan, applied, results, err := ExecuteTaskPlan(dir, snapshot, tasks, selected, false, true, true, flakyProjectExec{}, ExecuteOptions{})
 	if err != nil {
 		t.Fatalf("execute should continue after partial failures: %v", err)
 	}
@@ -132,3 +212,437 @@ func TestExecuteTaskPlanContinuesAfterTaskError(t *testing.T) {
 		t.Fatalf("expected successful task changes after failure")
 	}
 }
+
+func TestHotspotsFeedExpensiveFunctionRule(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "orders.go")
+	if err := os.WriteFile(file, []byte("package main\nfunc total() int { return 1 }\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	hotspots := []Hotspot{{File: "orders.go", Function: "main.total", Line: 2, CPUPct: 42.5, Routes: []string{"GET /orders"}}}
+	if err := SaveHotspots(filepath.Join(dir, DefaultHotspotsPath()), "run-1", hotspots); err != nil {
+		t.Fatalf("save hotspots: %v", err)
+	}
+	loaded, found, err := LoadHotspots(filepath.Join(dir, DefaultHotspotsPath()))
+	if err != nil || !found || len(loaded) != 1 {
+		t.Fatalf("load hotspots: found=%t err=%v %+v", found, err, loaded)
+	}
+
+	expensive := rules.Rule{ID: "detect_expensive_functions", Title: "Detect expensive functions", Enabled: true}
+	naming := rules.Rule{ID: "standardize_naming", Title: "Standardize naming", Enabled: true}
+	plan, err := BuildPlan(dir, []rules.Rule{expensive}, true, false, Evidence{Hotspots: loaded})
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	if len(plan.Edits) != 1 || !strings.Contains(plan.Edits[0].Description, "Profiled hot path: main.total (orders.go:2) 42.5% CPU on GET /orders") {
+		t.Fatalf("expected a profiled hot path suggestion, got %+v", plan.Edits)
+	}
+
+	snapshot, err := BuildProjectSnapshot(dir)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	tasks := AnnotateHotspots(BuildTaskPlan(snapshot, []rules.Rule{expensive, naming}), loaded)
+	if !strings.Contains(tasks[0].Description, "main.total (orders.go:2)") {
+		t.Fatalf("expected hotspots in expensive-function task, got %q", tasks[0].Description)
+	}
+	if strings.Contains(tasks[1].Description, "main.total") {
+		t.Fatalf("unrelated task annotated: %q", tasks[1].Description)
+	}
+}
+
+func TestCoverageEvidenceProposesDeadCode(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"orders.go": "package main\nfunc list() {}\nfunc legacyExport() {}\n",
+		"legacy.go": "package main\nfunc old() {}\n",
+		"main.go":   "package main\nfunc main() {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	evidence := Evidence{
+		UnexecutedFunctions: []Unexecuted{{File: "orders.go", Function: "legacyExport", Line: 3}},
+		UnexecutedFiles:     []string{"legacy.go"},
+	}
+	plan, err := BuildPlan(dir, nil, true, false, evidence)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	byFile := map[string]string{}
+	for _, e := range plan.Edits {
+		byFile[filepath.Base(e.File)] = e.Description
+	}
+	if len(byFile) != 2 {
+		t.Fatalf("expected suggestions for 2 files, got %+v", plan.Edits)
+	}
+	if !strings.Contains(byFile["orders.go"], "legacyExport (line 3)") || !strings.Contains(byFile["legacy.go"], "file never executed") {
+		t.Fatalf("unexpected dead-code suggestions: %+v", byFile)
+	}
+	applied, err := ApplyPlan(plan, true, false, false)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("expected suggestions to pass through unapplied: %v %+v", err, applied)
+	}
+}
+
+func TestApplyAndEditHunks(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	after := "A\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	hunks := DiffHunks(before, after)
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(hunks))
+	}
+	got, err := ApplyHunks(before, hunks[1:])
+	if err != nil || got != before+"m\n" {
+		t.Fatalf("apply second hunk only: %q %v", got, err)
+	}
+	if got, _ := ApplyHunks(before, hunks); got != after {
+		t.Fatalf("apply all: %q", got)
+	}
+
+	edited, err := EditHunk(hunks[0], strings.Replace(FormatHunk(hunks[0]), "+A\n", "# comment\n+a2\n+a3\n", 1))
+	if err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if got, _ := ApplyHunks(before, []Hunk{edited}); got != "a2\na3\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n" {
+		t.Fatalf("apply edited hunk: %q", got)
+	}
+	if _, err := EditHunk(hunks[0], strings.Replace(FormatHunk(hunks[0]), " b\n", " B\n", 1)); err == nil {
+		t.Fatalf("expected editing a context line to be rejected")
+	}
+}
+
+func TestEnclosingScope(t *testing.T) {
+	goSrc := "package main\n\nfunc outer() {\n\tif ok {\n\t\tx()\n\t}\n}\n"
+	if got := EnclosingScope("main.go", goSrc, 5); got != "func outer() {" {
+		t.Fatalf("go scope %q", got)
+	}
+	jsSrc := "class Api {\n  list(req) {\n    if (x) {\n      y()\n    }\n  }\n}\n"
+	if got := EnclosingScope("api.ts", jsSrc, 4); got != "list(req) {" {
+		t.Fatalf("js scope %q", got)
+	}
+	pySrc := "class A:\n    def run(self):\n        pass\n"
+	if got := EnclosingScope("a.py", pySrc, 3); got != "def run(self):" {
+		t.Fatalf("python scope %q", got)
+	}
+	if got := EnclosingScope("notes.txt", "func x()\n", 1); got != "" {
+		t.Fatalf("unknown language scope %q", got)
+	}
+}
+
+func TestExecuteTaskPlanWritesOnlyReviewedContent(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "kept.go")
+	rejected := filepath.Join(dir, "rejected.go")
+	content := "package main\nfunc x(){ if true { println(\"ok\") } }\n"
+	for _, f := range []string{kept, rejected} {
+		if err := os.WriteFile(f, []byte(content), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	snapshot, err := BuildProjectSnapshot(dir)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	selected := []rules.Rule{{ID: "remove_redundant_guards", Enabled: true, Title: "Remove redundant guards"}}
+	var reviewed []string
+	review := func(c FileChange) (string, error) {
+		reviewed = append(reviewed, filepath.Base(c.Path))
+		if c.Path == rejected {
+			return c.Before, nil
+		}
+		return strings.Replace(c.After, "println", "print", 1), nil
+	}
+	_, applied, results, err := ExecuteTaskPlan(dir, snapshot, BuildTaskPlan(snapshot, selected), selected, false, true, false, fakeProjectExec{}, ExecuteOptions{Review: review})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if strings.Join(reviewed, ",") != "kept.go,rejected.go" {
+		t.Fatalf("files reviewed out of order: %v", reviewed)
+	}
+	if len(applied) != 1 || applied[0].File != kept || !strings.Contains(applied[0].Description, "partially accepted") {
+		t.Fatalf("unexpected edits %+v", applied)
+	}
+	if len(results) != 1 || len(results[0].ChangedFiles) != 1 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if raw, _ := os.ReadFile(kept); string(raw) != "package main\nfunc x(){ { print(\"ok\") } }\n" {
+		t.Fatalf("kept file: %q", raw)
+	}
+	if raw, _ := os.ReadFile(rejected); string(raw) != content {
+		t.Fatalf("rejected file was written: %q", raw)
+	}
+}
+
+func TestExecuteTaskPlanRevertsTasksThatFailVerification(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "sample.go")
+	content := "package main\nfunc x(){ if true { println(\"ok\") } }\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	snapshot, err := BuildProjectSnapshot(dir)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	selected := []rules.Rule{{ID: "remove_redundant_guards", Enabled: true, Title: "Remove redundant guards"}}
+	var sawWritten bool
+	verify := func() error {
+		raw, _ := os.ReadFile(file)
+		sawWritten = string(raw) != content
+		return Verifier{Dir: dir, Command: "echo './sample.go:2: undefined: println2'; exit 1", Timeout: 10 * time.Second}.Run()
+	}
+	plan, applied, results, err := ExecuteTaskPlan(dir, snapshot, BuildTaskPlan(snapshot, selected), selected, false, true, false, fakeProjectExec{}, ExecuteOptions{Verify: verify})
+	if err == nil || !strings.Contains(err.Error(), "all cleanup tasks failed") {
+		t.Fatalf("expected the only task to count as failed, got %v", err)
+	}
+	if !sawWritten {
+		t.Fatalf("verification ran before the task's edits were written")
+	}
+	if raw, _ := os.ReadFile(file); string(raw) != content {
+		t.Fatalf("failed task was not reverted: %q", raw)
+	}
+	if len(applied) != 0 || len(plan.Edits) != 1 || plan.Edits[0].Applied {
+		t.Fatalf("reverted edits should be planned but not applied: plan=%+v applied=%+v", plan.Edits, applied)
+	}
+	if len(results) != 1 || results[0].Applied || !strings.Contains(results[0].Error, "undefined: println2") {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestResolveVerifyCommand(t *testing.T) {
+	dir := t.TempDir()
+	if got := ResolveVerifyCommand(dir, "auto"); got != "" {
+		t.Fatalf("expected no command for an unknown project, got %q", got)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tsconfig.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got := ResolveVerifyCommand(dir, "auto"); got != "npx --no-install tsc --noEmit" {
+		t.Fatalf("auto for a TypeScript project: %q", got)
+	}
+	if got := ResolveVerifyCommand(dir, "none"); got != "" {
+		t.Fatalf("none: %q", got)
+	}
+	if got := ResolveVerifyCommand(dir, "make check"); got != "make check" {
+		t.Fatalf("custom: %q", got)
+	}
+	if err := (Verifier{Dir: dir, Command: "sleep 5", Timeout: 100 * time.Millisecond}).Run(); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
+
+// truncatingProjectExec cuts the last line off broken.go, as a model that
+// ran out of output would.
+type truncatingProjectExec struct{}
+
+func (truncatingProjectExec) TransformProject(_ context.Context, _ string, files []ProjectFile, _ Task, _ []rules.Rule, _ bool, _ bool) (ProjectTransformResult, error) {
+	changed := map[string]string{}
+	for _, f := range files {
+		next := strings.ReplaceAll(f.Content, "if true {", "{")
+		if filepath.Base(f.Path) == "broken.go" {
+			next = next[:strings.LastIndex(strings.TrimSuffix(next, "\n"), "\n")+1]
+		}
+		changed[f.Path] = next
+	}
+	return ProjectTransformResult{Changed: true, Summary: "changed", ChangedFiles: changed}, nil
+}
+
+func TestExecuteTaskPlanSkipsFilesThatDoNotParse(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.go")
+	broken := filepath.Join(dir, "broken.go")
+	content := "package main\n\nfunc x() {\n\tif true {\n\t\tprintln(\"ok\")\n\t}\n}\n"
+	for _, f := range []string{good, broken} {
+		if err := os.WriteFile(f, []byte(content), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	snapshot, err := BuildProjectSnapshot(dir)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	selected := []rules.Rule{{ID: "remove_redundant_guards", Enabled: true, Title: "Remove redundant guards"}}
+	var reviewed []string
+	review := func(c FileChange) (string, error) {
+		reviewed = append(reviewed, filepath.Base(c.Path))
+		return c.After, nil
+	}
+	_, applied, results, err := ExecuteTaskPlan(dir, snapshot, BuildTaskPlan(snapshot, selected), selected, false, true, false, truncatingProjectExec{}, ExecuteOptions{Check: SyntaxChecker{}.Check, Review: review})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if strings.Join(reviewed, ",") != "good.go" {
+		t.Fatalf("a file that does not parse reached review: %v", reviewed)
+	}
+	if len(applied) != 1 || applied[0].File != good {
+		t.Fatalf("unexpected edits %+v", applied)
+	}
+	if len(results) != 1 || len(results[0].InvalidSyntax) != 1 || !strings.HasPrefix(results[0].InvalidSyntax[0], "broken.go: ") {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if raw, _ := os.ReadFile(broken); string(raw) != content {
+		t.Fatalf("file that does not parse was written: %q", raw)
+	}
+	// The change is gofmt'ed like the file was.
+	if raw, _ := os.ReadFile(good); string(raw) != "package main\n\nfunc x() {\n\t{\n\t\tprintln(\"ok\")\n\t}\n}\n" {
+		t.Fatalf("good file: %q", raw)
+	}
+}
+
+func TestExecuteTaskPlanChecksReviewedContentAgain(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	content := "package main\n\nfunc x() {\n\tif true {\n\t\tprintln(\"ok\")\n\t}\n}\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	snapshot, err := BuildProjectSnapshot(dir)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	selected := []rules.Rule{{ID: "remove_redundant_guards", Enabled: true, Title: "Remove redundant guards"}}
+	// Accepting only part of the change leaves an unbalanced brace.
+	review := func(c FileChange) (string, error) {
+		return strings.Replace(c.Before, "\t}\n}\n", "}\n", 1), nil
+	}
+	_, applied, results, err := ExecuteTaskPlan(dir, snapshot, BuildTaskPlan(snapshot, selected), selected, false, true, false, fakeProjectExec{}, ExecuteOptions{Check: SyntaxChecker{}.Check, Review: review})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if len(applied) != 0 || len(results) != 1 || len(results[0].InvalidSyntax) != 1 {
+		t.Fatalf("reviewed content that does not parse should be skipped: %+v %+v", applied, results)
+	}
+	if raw, _ := os.ReadFile(file); string(raw) != content {
+		t.Fatalf("reviewed content that does not parse was written: %q", raw)
+	}
+}
+
+func TestCheckSyntax(t *testing.T) {
+	var c SyntaxChecker
+	if _, err := c.Check("a.go", "package a\n", "package a\nfunc f( {\n"); err == nil {
+		t.Fatalf("expected a Go parse error")
+	}
+	if got, err := c.Check("a.go", "package a\nfunc  f() {}\n", "package a\nfunc  g() {}\n"); err != nil || got != "package a\nfunc  g() {}\n" {
+		t.Fatalf("a file that was not gofmt'ed should be kept as is: %q %v", got, err)
+	}
+	if got, err := c.Check("a.go", "package a\nfunc f( {\n", "package a\nfunc g( {\n"); err != nil || got == "" {
+		t.Fatalf("a file that did not parse before should not be judged: %v", err)
+	}
+
+	js := "const s = `a ${b} (`;\n// (\nfunction f(x) { return [x, '}']; }\n"
+	if _, err := c.Check("a.js", js, js); err != nil {
+		t.Fatalf("valid JavaScript rejected: %v", err)
+	}
+	if _, err := c.Check("a.ts", js, strings.TrimSuffix(js, "}\n")); err == nil || !strings.Contains(err.Error(), "never closed") {
+		t.Fatalf("expected an unclosed bracket, got %v", err)
+	}
+	py := "def f(x):\n    \"\"\"Return (x.\"\"\"\n    return [x]  # ]\n"
+	if _, err := c.Check("a.py", py, py); err != nil {
+		t.Fatalf("valid Python rejected: %v", err)
+	}
+	if _, err := c.Check("a.py", py, strings.Replace(py, "\"\"\"\n", "\n", 1)); err == nil || !strings.Contains(err.Error(), "unterminated string") {
+		t.Fatalf("expected an unterminated string, got %v", err)
+	}
+
+	c.Commands = map[string]string{".py": `sh -c '! grep -q BROKEN "$0"' {file}`}
+	if _, err := c.Check("a.py", py, py+"BROKEN\n"); err == nil {
+		t.Fatalf("expected the configured checker to reject the file")
+	}
+	if _, err := c.Check("a.py", "BROKEN\n", "BROKEN again\n"); err != nil {
+		t.Fatalf("a file the checker rejected before should not be judged: %v", err)
+	}
+}
+
+// printRenamingExec renames println to print, on the files it is given.
+type printRenamingExec struct{}
+
+func (printRenamingExec) TransformProject(_ context.Context, _ string, files []ProjectFile, _ Task, _ []rules.Rule, _ bool, _ bool) (ProjectTransformResult, error) {
+	changed := map[string]string{}
+	for _, f := range files {
+		if next := strings.ReplaceAll(f.Content, "println", "print"); next != f.Content {
+			changed[f.Path] = next
+		}
+	}
+	return ProjectTransformResult{Changed: len(changed) > 0, Summary: "renamed", ChangedFiles: changed}, nil
+}
+
+func TestChainRunsExecutorsOnEachOthersOutput(t *testing.T) {
+	files := []ProjectFile{{Path: "a.go", Content: "package main\nfunc x(){ if true { println(\"ok\") } }\n"}, {Path: "b.go", Content: "package main\n"}}
+	task := Task{ID: "t", RuleID: "remove_redundant_guards"}
+	res, err := Chain{fakeProjectExec{}, printRenamingExec{}}.TransformProject(context.Background(), "", files, task, nil, false, true)
+	if err != nil {
+		t.Fatalf("chain: %v", err)
+	}
+	if len(res.ChangedFiles) != 1 || res.ChangedFiles["a.go"] != "package main\nfunc x(){ { print(\"ok\") } }\n" {
+		t.Fatalf("unexpected files %+v", res.ChangedFiles)
+	}
+	if res.Summary != "removed redundant guards; renamed" {
+		t.Fatalf("summary: %q", res.Summary)
+	}
+	if files[0].Content != "package main\nfunc x(){ if true { println(\"ok\") } }\n" {
+		t.Fatalf("chain changed its input")
+	}
+
+	task.RuleID = "fail"
+	res, err = Chain{flakyProjectExec{}, printRenamingExec{}}.TransformProject(context.Background(), "", files, task, nil, false, true)
+	if err != nil || !strings.Contains(res.Summary, "failed: context deadline exceeded") || !res.Changed {
+		t.Fatalf("a failing executor should be skipped: %+v %v", res, err)
+	}
+	if _, err := (Chain{flakyProjectExec{}}).TransformProject(context.Background(), "", files, task, nil, false, true); err == nil {
+		t.Fatalf("expected an error when every executor fails")
+	}
+}
+
+// leftoverExec fixes nothing and reports complete with a finding in a.go.
+type leftoverExec struct{}
+
+func (leftoverExec) TransformProject(_ context.Context, _ string, _ []ProjectFile, _ Task, _ []rules.Rule, _ bool, _ bool) (ProjectTransformResult, error) {
+	return ProjectTransformResult{Complete: true, Leftover: []Finding{{Path: "a.go", Line: 2, Message: "cannot fix"}}}, nil
+}
+
+// taskRecordingExec records the task and files it is given.
+type taskRecordingExec struct {
+	task  *Task
+	files *[]string
+}
+
+func (e taskRecordingExec) TransformProject(_ context.Context, _ string, files []ProjectFile, task Task, _ []rules.Rule, _ bool, _ bool) (ProjectTransformResult, error) {
+	*e.task = task
+	for _, f := range files {
+		*e.files = append(*e.files, f.Path)
+	}
+	return ProjectTransformResult{}, nil
+}
+
+func TestChainHandsOnlyLeftoverFindings(t *testing.T) {
+	files := []ProjectFile{{Path: "a.go", Content: "package main\n"}, {Path: "b.go", Content: "package main\n"}}
+	var got Task
+	var seen []string
+	if _, err := (Chain{leftoverExec{}, taskRecordingExec{&got, &seen}}).TransformProject(context.Background(), "", files, Task{Description: "harden"}, nil, false, true); err != nil {
+		t.Fatalf("chain: %v", err)
+	}
+	if len(seen) != 1 || seen[0] != "a.go" || !strings.HasSuffix(got.Description, "\n- a.go:2: cannot fix") {
+		t.Fatalf("next executor got %v and %q", seen, got.Description)
+	}
+}
+
+// handledExec reports complete for a.go only, with a finding in it.
+type handledExec struct{}
+
+func (handledExec) TransformProject(_ context.Context, _ string, _ []ProjectFile, _ Task, _ []rules.Rule, _ bool, _ bool) (ProjectTransformResult, error) {
+	return ProjectTransformResult{Complete: true, Handled: []string{"a.go"}, Leftover: []Finding{{Path: "a.go", Line: 2, Message: "cannot fix"}}}, nil
+}
+
+func TestChainHandsOnUnhandledFiles(t *testing.T) {
+	files := []ProjectFile{{Path: "a.go", Content: "package main\n"}, {Path: "b.py", Content: "pass\n"}}
+	var got Task
+	var seen []string
+	if _, err := (Chain{handledExec{}, taskRecordingExec{&got, &seen}}).TransformProject(context.Background(), "", files, Task{Description: "harden"}, nil, false, true); err != nil {
+		t.Fatalf("chain: %v", err)
+	}
+	if strings.Join(seen, ",") != "a.go,b.py" || !strings.Contains(got.Description, "do all of the task in the other files") || !strings.HasSuffix(got.Description, "\n- a.go:2: cannot fix") {
+		t.Fatalf("next executor got %v and %q", seen, got.Description)
+	}
+}
@@ -0,0 +1,39 @@
+package cleanup
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Unexecuted is a function no profiled route executed under coverage. File
+// is relative to the project root, with forward slashes.
+type Unexecuted struct {
+	File     string `json:"file"`
+	Function string `json:"function"`
+	Line     int    `json:"line,omitempty"`
+}
+
+// deadCodeSuggestion describes the coverage evidence that code in the file at
+// path is dead, or returns "" when there is none.
+func deadCodeSuggestion(projectRoot, path string, evidence Evidence) string {
+	rel, err := filepath.Rel(projectRoot, path)
+	if err != nil {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+	if slices.Contains(evidence.UnexecutedFiles, rel) {
+		return "Dead-code candidate: file never executed by any profiled route (analysis suggestion)"
+	}
+	var fns []string
+	for _, u := range evidence.UnexecutedFunctions {
+		if u.File == rel {
+			fns = append(fns, fmt.Sprintf("%s (line %d)", u.Function, u.Line))
+		}
+	}
+	if len(fns) == 0 {
+		return ""
+	}
+	return "Dead-code candidates never executed by any profiled route: " + strings.Join(fns, ", ") + " (analysis suggestion)"
+}
@@ -0,0 +1,272 @@
+package cleanup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// maxEditDistance bounds the line diff's work. Files that differ in more
+// lines than this are shown as one replaced block after the common prefix
+// and suffix.
+const maxEditDistance = 2000
+
+// Hunk is one block of a unified diff. Lines keep their "\n" and are
+// prefixed with ' ', '-' or '+'.
+type Hunk struct {
+	OldStart int      `json:"old_start"`
+	OldLines int      `json:"old_lines"`
+	NewStart int      `json:"new_start"`
+	NewLines int      `json:"new_lines"`
+	Lines    []string `json:"lines"`
+}
+
+// Header is the hunk's "@@ -l,s +l,s @@" line.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// diffOp is one line of the edit script with the line numbers (0-based) of
+// both sides before it.
+type diffOp struct {
+	kind byte
+	text string
+	a, b int
+}
+
+// DiffHunks returns the hunks that turn before into after.
+func DiffHunks(before, after string) []Hunk {
+	ops := diffLines(splitLines(before), splitLines(after))
+	var hunks []Hunk
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// Extend over changes separated by at most 2*diffContext unchanged
+		// lines, so their context does not overlap.
+		j := i
+		for {
+			for j < len(ops) && ops[j].kind != ' ' {
+				j++
+			}
+			k := j
+			for k < len(ops) && ops[k].kind == ' ' {
+				k++
+			}
+			if k < len(ops) && k-j <= 2*diffContext {
+				j = k
+				continue
+			}
+			break
+		}
+		start, end := max(0, i-diffContext), min(len(ops), j+diffContext)
+		h := Hunk{OldStart: ops[start].a, NewStart: ops[start].b}
+		for _, op := range ops[start:end] {
+			h.Lines = append(h.Lines, string(op.kind)+op.text)
+			if op.kind != '+' {
+				h.OldLines++
+			}
+			if op.kind != '-' {
+				h.NewLines++
+			}
+		}
+		// An empty range starts at the line before it.
+		if h.OldLines > 0 {
+			h.OldStart++
+		}
+		if h.NewLines > 0 {
+			h.NewStart++
+		}
+		hunks = append(hunks, h)
+		i = end
+	}
+	return hunks
+}
+
+// UnifiedDiff returns the diff from before to after with name in the file
+// headers, or "" when they are equal.
+func UnifiedDiff(name, before, after string) string {
+	return FormatDiff(name, DiffHunks(before, after))
+}
+
+// FormatDiff renders hunks as a git-style unified diff of name.
+func FormatDiff(name string, hunks []Hunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", name, name)
+	for _, h := range hunks {
+		sb.WriteString(h.Header())
+		sb.WriteByte('\n')
+		for _, line := range h.Lines {
+			sb.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// Patch joins the diffs of edits into one patch, in edit order.
+func Patch(edits []Edit) string {
+	var sb strings.Builder
+	for _, e := range edits {
+		sb.WriteString(e.Diff)
+	}
+	return sb.String()
+}
+
+// splitLines splits s after each "\n"; a last line without one is kept as
+// is, so a changed final newline shows in the diff.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns an edit script from a to b: Myers' algorithm on what
+// remains after the common prefix and suffix are trimmed.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{kind: ' ', text: a[i], a: i, b: i})
+	}
+	middle := myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	for _, op := range middle {
+		op.a += prefix
+		op.b += prefix
+		ops = append(ops, op)
+	}
+	for i := 0; i < suffix; i++ {
+		ai, bi := len(a)-suffix+i, len(b)-suffix+i
+		ops = append(ops, diffOp{kind: ' ', text: a[ai], a: ai, b: bi})
+	}
+	return ops
+}
+
+func myers(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil
+	}
+	off := n + m
+	v := make([]int, 2*off+2)
+	// trace[d] holds v[-d..d] as it was before round d.
+	var trace [][]int
+	found := -1
+	for d := 0; d <= min(off, maxEditDistance) && found < 0; d++ {
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				found = d
+				break
+			}
+		}
+	}
+	if found < 0 {
+		return replaceAll(a, b)
+	}
+
+	// Walk back from (n, m), collecting the script in reverse.
+	var rev []diffOp
+	x, y := n, m
+	for d := found; d > 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, diffOp{kind: ' ', text: a[x], a: x, b: y})
+		}
+		if x == prevX {
+			y--
+			rev = append(rev, diffOp{kind: '+', text: b[y], a: x, b: y})
+		} else {
+			x--
+			rev = append(rev, diffOp{kind: '-', text: a[x], a: x, b: y})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		rev = append(rev, diffOp{kind: ' ', text: a[x], a: x, b: y})
+	}
+	ops := make([]diffOp, len(rev))
+	for i, op := range rev {
+		ops[len(rev)-1-i] = op
+	}
+	return ops
+}
+
+// replaceAll deletes all of a and inserts all of b.
+func replaceAll(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i, line := range a {
+		ops = append(ops, diffOp{kind: '-', text: line, a: i, b: 0})
+	}
+	for i, line := range b {
+		ops = append(ops, diffOp{kind: '+', text: line, a: len(a), b: i})
+	}
+	return ops
+}
+
+// diffName is path as diff headers show it: relative to root, or to the
+// working directory when root is empty.
+func diffName(root, path string) string {
+	if root == "" {
+		root, _ = os.Getwd()
+	}
+	if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
@@ -0,0 +1,131 @@
+package cleanup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cool-code-cleanup/internal/rules"
+)
+
+const HotspotsSchemaVersion = 1
+
+// maxHotspotsPerTask caps the hot functions listed in a task description.
+const maxHotspotsPerTask = 10
+
+// Hotspot is a function a CPU profile found hot. File is relative to the
+// project root, with forward slashes.
+type Hotspot struct {
+	File     string   `json:"file"`
+	Function string   `json:"function"`
+	Line     int      `json:"line,omitempty"`
+	CPUPct   float64  `json:"cpu_pct"`
+	Routes   []string `json:"routes,omitempty"`
+}
+
+type HotspotFile struct {
+	SchemaVersion int       `json:"schema_version"`
+	RunID         string    `json:"run_id,omitempty"`
+	Hotspots      []Hotspot `json:"hotspots"`
+}
+
+func DefaultHotspotsPath() string {
+	return filepath.Join(".ccc", "hotspots.json")
+}
+
+// LoadHotspots reads the hotspots saved by the last profiled run. A missing
+// file is not an error.
+func LoadHotspots(path string) ([]Hotspot, bool, error) {
+	clean := filepath.Clean(path)
+	data, err := os.ReadFile(clean)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("read hotspots %s: %w", clean, err)
+	}
+	var f HotspotFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, false, fmt.Errorf("parse hotspots %s: %w", clean, err)
+	}
+	if f.SchemaVersion != HotspotsSchemaVersion {
+		return nil, false, fmt.Errorf("unsupported hotspots schema_version=%d (expected %d)", f.SchemaVersion, HotspotsSchemaVersion)
+	}
+	return f.Hotspots, true, nil
+}
+
+func SaveHotspots(path, runID string, hotspots []Hotspot) error {
+	clean := filepath.Clean(path)
+	if err := os.MkdirAll(filepath.Dir(clean), 0o755); err != nil {
+		return fmt.Errorf("create hotspots directory: %w", err)
+	}
+	data, err := json.MarshalIndent(HotspotFile{SchemaVersion: HotspotsSchemaVersion, RunID: runID, Hotspots: hotspots}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(clean, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write hotspots %s: %w", clean, err)
+	}
+	return nil
+}
+
+// AnnotateHotspots appends the profiled hot functions to the description of
+// every expensive-function task, hottest first, so the executor starts from
+// measured hot paths rather than pattern matches alone.
+func AnnotateHotspots(tasks []Task, hotspots []Hotspot) []Task {
+	if len(hotspots) == 0 {
+		return tasks
+	}
+	sorted := append([]Hotspot(nil), hotspots...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CPUPct > sorted[j].CPUPct })
+	if len(sorted) > maxHotspotsPerTask {
+		sorted = sorted[:maxHotspotsPerTask]
+	}
+	var b strings.Builder
+	b.WriteString("\n\nProfiled hot functions (CPU share while driving routes); prioritize these:")
+	for _, h := range sorted {
+		fmt.Fprintf(&b, "\n- %s", formatHotspot(h))
+	}
+	out := make([]Task, len(tasks))
+	copy(out, tasks)
+	for i, t := range out {
+		r := rules.Rule{ID: t.RuleID, Title: t.RuleTitle, Description: t.Description}
+		if capabilitiesFromRules([]rules.Rule{r}).detectExpensiveFunctions {
+			out[i].Description += b.String()
+		}
+	}
+	return out
+}
+
+// hotspotSuggestions returns the profiled-hot-path notes for the file at
+// path.
+func hotspotSuggestions(projectRoot, path string, hotspots []Hotspot) []string {
+	rel, err := filepath.Rel(projectRoot, path)
+	if err != nil {
+		return nil
+	}
+	rel = filepath.ToSlash(rel)
+	var out []string
+	for _, h := range hotspots {
+		if h.File == rel {
+			out = append(out, "Profiled hot path: "+formatHotspot(h))
+		}
+	}
+	return out
+}
+
+func formatHotspot(h Hotspot) string {
+	loc := h.File
+	if h.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", h.File, h.Line)
+	}
+	s := fmt.Sprintf("%s (%s) %.1f%% CPU", h.Function, loc, h.CPUPct)
+	if len(h.Routes) > 0 {
+		s += " on " + strings.Join(h.Routes, ", ")
+	}
+	return s
+}
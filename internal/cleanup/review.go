@@ -0,0 +1,154 @@
+package cleanup
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// FileChange is a file a task changed, offered for review before it is
+// written.
+type FileChange struct {
+	Task   Task
+	Path   string
+	Before string
+	After  string
+}
+
+// ApplyHunks applies hunks, a subset of DiffHunks(before, after) in order,
+// to before. Lines of hunks left out stay as they were. A hunk's '+' lines
+// may have been edited; its ' ' and '-' lines must still match before.
+func ApplyHunks(before string, hunks []Hunk) (string, error) {
+	lines := splitLines(before)
+	var out strings.Builder
+	pos := 0
+	for _, h := range hunks {
+		start := h.OldStart - 1
+		if h.OldLines == 0 {
+			start = h.OldStart
+		}
+		if start < pos || start+h.OldLines > len(lines) {
+			return "", fmt.Errorf("hunk %s does not apply", h.Header())
+		}
+		for ; pos < start; pos++ {
+			out.WriteString(lines[pos])
+		}
+		for _, l := range h.Lines {
+			if l == "" {
+				continue
+			}
+			switch l[0] {
+			case ' ', '-':
+				if pos >= len(lines) || lines[pos] != l[1:] {
+					return "", fmt.Errorf("hunk %s does not apply at line %d", h.Header(), pos+1)
+				}
+				if l[0] == ' ' {
+					out.WriteString(l[1:])
+				}
+				pos++
+			case '+':
+				out.WriteString(l[1:])
+			}
+		}
+	}
+	for ; pos < len(lines); pos++ {
+		out.WriteString(lines[pos])
+	}
+	return out.String(), nil
+}
+
+// EditHunk replaces h's lines with edited, h as FormatHunk showed it and then
+// changed by the user. The "@@" header and lines starting with '#' are
+// dropped; the hunk keeps its position. Only '+' lines may be added, changed
+// or removed, and '-' lines turned into context (' ') to keep them; the
+// original lines must otherwise be left as they were.
+func EditHunk(h Hunk, edited string) (Hunk, error) {
+	var lines []string
+	for _, l := range splitLines(edited) {
+		switch {
+		case strings.HasPrefix(l, "#"), strings.HasPrefix(l, "@@"):
+		case strings.HasPrefix(l, `\`):
+			// "\ No newline at end of file" belongs to the line before it.
+			if n := len(lines); n > 0 {
+				lines[n-1] = strings.TrimSuffix(lines[n-1], "\n")
+			}
+		case l == "\n":
+			// Editors may strip the space of an empty context line.
+			lines = append(lines, " \n")
+		case strings.HasPrefix(l, " "), strings.HasPrefix(l, "-"), strings.HasPrefix(l, "+"):
+			lines = append(lines, l)
+		default:
+			return Hunk{}, fmt.Errorf("edited hunk line %q must start with ' ', '-' or '+'", strings.TrimSuffix(l, "\n"))
+		}
+	}
+	if !slices.Equal(oldSide(lines), oldSide(h.Lines)) {
+		return Hunk{}, fmt.Errorf("edited hunk changes lines of the original file; only edit '+' lines")
+	}
+	out := h
+	out.Lines = lines
+	out.NewLines = 0
+	for _, l := range lines {
+		if l[0] != '-' {
+			out.NewLines++
+		}
+	}
+	return out, nil
+}
+
+// oldSide returns the original lines a hunk covers.
+func oldSide(lines []string) []string {
+	var out []string
+	for _, l := range lines {
+		if l != "" && l[0] != '+' {
+			out = append(out, l[1:])
+		}
+	}
+	return out
+}
+
+// FormatHunk renders h alone, header first, as EditHunk reads it back.
+func FormatHunk(h Hunk) string {
+	diff := FormatDiff("", []Hunk{h})
+	return diff[strings.Index(diff, "@@"):]
+}
+
+var scopePatterns = map[string]*regexp.Regexp{
+	".go": regexp.MustCompile(`^(func|type)\s`),
+	".py": regexp.MustCompile(`^\s*(async\s+def|def|class)\s`),
+	".js": regexp.MustCompile(`^\s*(export\s+)?(default\s+)?((async\s+)?function\b|class\s|(const|let|var)\s+\w+\s*=\s*(async\s*)?(\(|function\b|\w+\s*=>))|^\s*(async\s+)?\w+\s*\([^)]*\)\s*\{\s*$`),
+}
+
+// EnclosingScope returns the declaration line (a function, method or type)
+// closest above line (1-based) in content, judged by path's language, or "".
+func EnclosingScope(path, content string, line int) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".ts", ".tsx", ".jsx", ".mjs", ".cjs":
+		ext = ".js"
+	}
+	re, ok := scopePatterns[ext]
+	if !ok {
+		return ""
+	}
+	lines := splitLines(content)
+	for i := min(line, len(lines)) - 1; i >= 0; i-- {
+		l := strings.TrimRight(lines[i], "\r\n")
+		if re.MatchString(l) && !isControlStatement(l) {
+			return strings.TrimSpace(l)
+		}
+	}
+	return ""
+}
+
+// isControlStatement reports whether l, which looks like a method header,
+// is an if/for/while/switch/catch block instead.
+func isControlStatement(l string) bool {
+	word, _, _ := strings.Cut(strings.TrimSpace(l), "(")
+	switch strings.TrimSpace(word) {
+	case "if", "for", "while", "switch", "catch", "else if":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package cleanup
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// syntaxCheckTimeout bounds one run of a configured syntax checker.
+const syntaxCheckTimeout = 30 * time.Second
+
+// SyntaxChecker rejects changed files that no longer parse. Go files are
+// parsed in process. Commands maps an extension (".js") to a local command
+// that exits non-zero for a file that does not parse, with {file} standing
+// for its path; extensions without one, or whose command is not installed,
+// get a built-in check that strings, comments and brackets are closed.
+type SyntaxChecker struct {
+	Commands map[string]string
+}
+
+// Check returns after as it should be written, or an error when it does not
+// parse. A Go file is gofmt'ed when before was. A file that did not pass
+// the check before the change is not judged by it.
+func (c SyntaxChecker) Check(path, before, after string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".go" {
+		return checkGo(path, before, after)
+	}
+	if cmd := strings.TrimSpace(c.Commands[ext]); cmd != "" && commandInstalled(cmd) {
+		err := runSyntaxCommand(cmd, ext, after)
+		if err != nil && runSyntaxCommand(cmd, ext, before) == nil {
+			return "", err
+		}
+		return after, nil
+	}
+	lang := "js"
+	if ext == ".py" {
+		lang = "py"
+	}
+	if err := checkDelimiters(after, lang); err != nil && checkDelimiters(before, lang) == nil {
+		return "", err
+	}
+	return after, nil
+}
+
+func checkGo(path, before, after string) (string, error) {
+	fset := token.NewFileSet()
+	if _, err := parser.ParseFile(fset, path, after, parser.AllErrors); err != nil {
+		if _, berr := parser.ParseFile(token.NewFileSet(), path, before, parser.AllErrors); berr != nil {
+			return after, nil
+		}
+		return "", err
+	}
+	formatted, err := format.Source([]byte(before))
+	if err != nil || !bytes.Equal(formatted, []byte(before)) {
+		return after, nil
+	}
+	if formatted, err = format.Source([]byte(after)); err != nil {
+		return "", err
+	}
+	return string(formatted), nil
+}
+
+// commandInstalled reports whether the program cmd starts with is on PATH.
+func commandInstalled(cmd string) bool {
+	_, err := exec.LookPath(strings.Fields(cmd)[0])
+	return err == nil
+}
+
+// runSyntaxCommand checks content with cmd, as a temporary file with ext.
+func runSyntaxCommand(cmd, ext, content string) error {
+	dir, err := os.MkdirTemp("", "ccc-syntax-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "check"+ext)
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		return err
+	}
+	quoted := "'" + strings.ReplaceAll(file, "'", `'\''`) + "'"
+	if strings.Contains(cmd, "{file}") {
+		cmd = strings.ReplaceAll(cmd, "{file}", quoted)
+	} else {
+		cmd += " " + quoted
+	}
+	return Verifier{Dir: dir, Command: cmd, Timeout: syntaxCheckTimeout}.Run()
+}
+
+// checkDelimiters reports the first string, comment or bracket content
+// leaves open, or a closing bracket that matches nothing. lang is "js" (also
+// TypeScript) or "py". JavaScript regex literals are not recognized, which is
+// why Check only trusts it for files it accepted before the change.
+func checkDelimiters(content, lang string) error {
+	type open struct {
+		ch   byte
+		line int
+	}
+	var stack []open
+	line := 1
+	closing := map[byte]byte{')': '(', ']': '[', '}': '{'}
+	for i := 0; i < len(content); i++ {
+		ch := content[i]
+		switch {
+		case ch == '\n':
+			line++
+		case lang == "js" && strings.HasPrefix(content[i:], "//"), lang == "py" && ch == '#':
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			i--
+		case lang == "js" && strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				return fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(content[i:i+2+end], "\n")
+			i += end + 3
+		case ch == '"' || ch == '\'' || (lang == "js" && ch == '`'):
+			quote := string(ch)
+			if lang == "py" && strings.HasPrefix(content[i:], strings.Repeat(quote, 3)) {
+				quote = strings.Repeat(quote, 3)
+			}
+			end, ok := stringEnd(content, i+len(quote), quote)
+			if !ok {
+				return fmt.Errorf("line %d: unterminated string", line)
+			}
+			line += strings.Count(content[i:end], "\n")
+			i = end - 1
+		case ch == '(' || ch == '[' || ch == '{':
+			stack = append(stack, open{ch, line})
+		case closing[ch] != 0:
+			if len(stack) == 0 || stack[len(stack)-1].ch != closing[ch] {
+				return fmt.Errorf("line %d: unexpected '%c'", line, ch)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(stack) > 0 {
+		o := stack[len(stack)-1]
+		return fmt.Errorf("line %d: '%c' is never closed", o.line, o.ch)
+	}
+	return nil
+}
+
+// stringEnd returns the index after the quote closing the string whose
+// content starts at i. Only backtick and triple-quoted strings span lines.
+func stringEnd(content string, i int, quote string) (int, bool) {
+	multiline := quote == "`" || len(quote) == 3
+	for ; i < len(content); i++ {
+		switch {
+		case content[i] == '\\':
+			i++
+		case content[i] == '\n' && !multiline:
+			return 0, false
+		case strings.HasPrefix(content[i:], quote):
+			return i + len(quote), true
+		}
+	}
+	return 0, false
+}
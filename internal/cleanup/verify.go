@@ -0,0 +1,86 @@
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// verifyOutputLimit caps how much of a failed check's output is kept.
+const verifyOutputLimit = 4000
+
+// verifyCandidates pick the check for a project by marker file; the first
+// present wins.
+var verifyCandidates = []struct {
+	marker  string
+	command string
+}{
+	{"go.mod", "go build ./... && go test ./..."},
+	{"tsconfig.json", "npx --no-install tsc --noEmit"},
+	{"pytest.ini", "pytest -q"},
+	{"conftest.py", "pytest -q"},
+	{"pyproject.toml", "pytest -q"},
+}
+
+// ResolveVerifyCommand returns the command to run for configured: "auto"
+// detects one from root's files, "none" (like an undetectable project)
+// gives "".
+func ResolveVerifyCommand(root, configured string) string {
+	switch strings.TrimSpace(configured) {
+	case "none", "":
+		return ""
+	case "auto":
+		for _, c := range verifyCandidates {
+			if _, err := os.Stat(filepath.Join(root, c.marker)); err == nil {
+				return c.command
+			}
+		}
+		return ""
+	}
+	return configured
+}
+
+// Verifier runs a shell command that checks the project still builds and
+// passes its tests.
+type Verifier struct {
+	Dir     string
+	Command string
+	Timeout time.Duration
+}
+
+// Run runs the command. The error of a failed run ends with the last part
+// of its output.
+func (v Verifier) Run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), v.Timeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", v.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", v.Command)
+	}
+	cmd.Dir = v.Dir
+	killOnCancel(cmd)
+	// Children of the shell may keep the output open after it is killed.
+	cmd.WaitDelay = 5 * time.Second
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", v.Timeout)
+	}
+	if err != nil {
+		output := strings.TrimSpace(out.String())
+		if len(output) > verifyOutputLimit {
+			output = "..." + output[len(output)-verifyOutputLimit:]
+		}
+		return fmt.Errorf("%s: %v\n%s", v.Command, err, output)
+	}
+	return nil
+}
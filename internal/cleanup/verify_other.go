@@ -0,0 +1,8 @@
+//go:build !unix
+
+package cleanup
+
+import "os/exec"
+
+// Without process groups only the shell itself is killed on timeout.
+func killOnCancel(*exec.Cmd) {}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package cleanup
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killOnCancel runs cmd in its own process group and kills the whole group
+// when its context ends, so a timed-out check leaves nothing running.
+func killOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
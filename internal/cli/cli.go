@@ -32,6 +32,10 @@ func Run(args []string) error {
 		return runCommand("profile", args[1:])
 	case "cleanup":
 		return runCommand("cleanup", args[1:])
+	case "shortcircuit":
+		return runCommand("shortcircuit", args[1:])
+	case "replay":
+		return runCommand("replay", args[1:])
 	default:
 		return fmt.Errorf("unknown command %q\n\n%s", cmd, rootUsage())
 	}
@@ -58,17 +62,35 @@ func runCommand(cmdName string, args []string) error {
 
 	var profileFlags modepkg.ProfileFlags
 	var cleanupFlags modepkg.CleanupFlags
+	var shortCircuitFlags modepkg.ShortCircuitFlags
+	var replayFlags modepkg.ReplayFlags
 	var includeCSV string
 	var ignoreCSV string
+	var loadMixCSV string
 	if cmdName == "profile" {
 		fs.StringVar(&includeCSV, "include-routes", "", "Routes to include (comma-separated paths or METHOD path)")
 		fs.StringVar(&ignoreCSV, "ignore-routes", "", "Routes to ignore (comma-separated paths or METHOD path)")
 		fs.BoolVar(&profileFlags.DependencyShortCircuit, "dependency-short-circuit", true, "Enable dependency route short-circuiting enhancement")
 		fs.BoolVar(&profileFlags.AIRouteInference, "ai-route-inference", true, "Enable AI final-pass route inference")
 		fs.BoolVar(&profileFlags.AIDependencyInference, "ai-dependency-inference", true, "Enable AI dependency inference merge pass")
+		fs.BoolVar(&profileFlags.AIParameterInference, "ai-parameter-inference", true, "Enable AI parameter inference for routes without schemas")
 		fs.BoolVar(&profileFlags.RequireAI, "require-ai", false, "Fail if AI inference is enabled but unavailable")
 		fs.StringVar(&profileFlags.EditPermissionMode, "edit-permission-mode", "", "Edit permission mode (per-edit|per-file)")
 		fs.BoolVar(&profileFlags.AutoApply, "auto-apply", false, "Apply edits without per-file prompts if policy allows")
+		fs.IntVar(&profileFlags.Iterations, "iterations", 0, "Measured calls per route for latency statistics (0 skips latency measurement)")
+		fs.IntVar(&profileFlags.Warmup, "warmup", 0, "Unmeasured warm-up calls per route")
+		fs.IntVar(&profileFlags.Concurrency, "concurrency", 0, "Concurrent measured calls per route")
+		fs.BoolVar(&profileFlags.CPUProfile, "cpu-profile", false, "Capture CPU and allocation profiles of the app per route")
+		fs.BoolVar(&profileFlags.Coverage, "coverage", false, "Collect code coverage per route to find code no route executes")
+		fs.StringVar(&profileFlags.PprofURL, "pprof-url", "", "Go pprof endpoint to attach to (default <base-url>/debug/pprof)")
+		fs.StringVar(&profileFlags.AttachURL, "attach-url", "", "Profile an app already running at this URL instead of starting one")
+		fs.StringVar(&profileFlags.HARPath, "har", "", "Record profiling traffic to this HAR file")
+		fs.StringVar(&profileFlags.SaveBaseline, "save-baseline", "", "Save this run's statuses and latency as a named baseline")
+		fs.StringVar(&profileFlags.CompareBaseline, "compare", "", "Compare this run with a named baseline and fail on regressions")
+		fs.DurationVar(&profileFlags.LoadDuration, "load-duration", 0, "Run a load test for this long after profiling (e.g. 30s)")
+		fs.Float64Var(&profileFlags.LoadRPS, "load-rps", 0, "Target requests per second for the load test")
+		fs.IntVar(&profileFlags.LoadConcurrency, "load-concurrency", 0, "Load test workers (max in flight with --load-rps)")
+		fs.StringVar(&loadMixCSV, "load-mix", "", "Weighted load mix (comma-separated route=weight)")
 		fs.BoolVar(&profileFlags.CreateBranch, "create-branch", false, "Create a branch at final step")
 		fs.BoolVar(&profileFlags.CommitChanges, "commit-changes", false, "Commit changes at final step")
 	}
@@ -88,6 +110,30 @@ func runCommand(cmdName string, args []string) error {
 		fs.BoolVar(&cleanupFlags.CreateBranch, "create-branch", false, "Create a branch at final step")
 		fs.BoolVar(&cleanupFlags.CommitChanges, "commit-changes", false, "Commit changes at final step")
 		fs.BoolVar(&cleanupFlags.ShowProgress, "show-progress", true, "Show cleanup execution progress output")
+		fs.StringVar(&cleanupFlags.RegressionHAR, "regression-har", "", "Recorded traffic to replay before and after cleanup (default newest .ccc/runs/*/traffic.har)")
+		fs.StringVar(&cleanupFlags.VerifyCommand, "verify-command", "", "Command that checks each task's edits; failing tasks are reverted (auto|none|<command>)")
+		fs.StringVar(&cleanupFlags.Executor, "executor", "", "What runs the cleanup tasks (ai|ast|combined)")
+		fs.StringVar(&cleanupFlags.PatchPath, "patch", "", "Write the diffs of all edits to this .patch file (with --dry-run, instead of printing them)")
+	}
+
+	if cmdName == "shortcircuit" {
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			shortCircuitFlags.Action = args[0]
+			args = args[1:]
+		}
+		fs.Func("id", "Short-circuit manifest id to act on (repeatable)", func(v string) error {
+			shortCircuitFlags.IDs = append(shortCircuitFlags.IDs, strings.TrimSpace(v))
+			return nil
+		})
+		fs.StringVar(&shortCircuitFlags.ManifestDir, "manifest-dir", filepath.Join(".ccc", "shortcircuit"), "Directory holding short-circuit patch manifests")
+	}
+
+	if cmdName == "replay" {
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			replayFlags.HARPath = args[0]
+			args = args[1:]
+		}
+		fs.StringVar(&replayFlags.BaseURL, "base-url", "", "URL of the running app (default profile.app.attach_url or 127.0.0.1:<profile.app.port>)")
 	}
 
 	fs.Usage = func() {
@@ -101,23 +147,33 @@ func runCommand(cmdName string, args []string) error {
 		return err
 	}
 
-	detectBoolFlagSet(fs, "safe", &cliOpts.SafeSet)
-	detectBoolFlagSet(fs, "aggressive", &cliOpts.AggressiveSet)
-	detectBoolFlagSet(fs, "dry-run", &cliOpts.DryRunSet)
+	flagWasSet(fs, "safe", &cliOpts.SafeSet)
+	flagWasSet(fs, "aggressive", &cliOpts.AggressiveSet)
+	flagWasSet(fs, "dry-run", &cliOpts.DryRunSet)
 	if cmdName == "profile" {
 		profileFlags.IncludeRoutes = config.ParseCSV(includeCSV)
 		profileFlags.IgnoreRoutes = config.ParseCSV(ignoreCSV)
-		detectBoolFlagSet(fs, "dependency-short-circuit", &profileFlags.DependencyShortCircuitSet)
-		detectBoolFlagSet(fs, "ai-route-inference", &profileFlags.AIRouteInferenceSet)
-		detectBoolFlagSet(fs, "ai-dependency-inference", &profileFlags.AIDependencyInferenceSet)
-		detectBoolFlagSet(fs, "require-ai", &profileFlags.RequireAISet)
-		detectBoolFlagSet(fs, "auto-apply", &profileFlags.AutoApplySet)
-		detectBoolFlagSet(fs, "create-branch", &profileFlags.CreateBranchSet)
-		detectBoolFlagSet(fs, "commit-changes", &profileFlags.CommitChangesSet)
+		profileFlags.LoadMix = config.ParseCSV(loadMixCSV)
+		flagWasSet(fs, "dependency-short-circuit", &profileFlags.DependencyShortCircuitSet)
+		flagWasSet(fs, "ai-route-inference", &profileFlags.AIRouteInferenceSet)
+		flagWasSet(fs, "ai-dependency-inference", &profileFlags.AIDependencyInferenceSet)
+		flagWasSet(fs, "ai-parameter-inference", &profileFlags.AIParameterInferenceSet)
+		flagWasSet(fs, "require-ai", &profileFlags.RequireAISet)
+		flagWasSet(fs, "auto-apply", &profileFlags.AutoApplySet)
+		flagWasSet(fs, "iterations", &profileFlags.IterationsSet)
+		flagWasSet(fs, "warmup", &profileFlags.WarmupSet)
+		flagWasSet(fs, "concurrency", &profileFlags.ConcurrencySet)
+		flagWasSet(fs, "cpu-profile", &profileFlags.CPUProfileSet)
+		flagWasSet(fs, "coverage", &profileFlags.CoverageSet)
+		flagWasSet(fs, "create-branch", &profileFlags.CreateBranchSet)
+		flagWasSet(fs, "commit-changes", &profileFlags.CommitChangesSet)
+	}
+	if cmdName == "replay" && replayFlags.HARPath == "" && fs.NArg() > 0 {
+		replayFlags.HARPath = fs.Arg(0)
 	}
 	if cmdName == "cleanup" {
-		detectBoolFlagSet(fs, "create-branch", &cleanupFlags.CreateBranchSet)
-		detectBoolFlagSet(fs, "commit-changes", &cleanupFlags.CommitChangesSet)
+		flagWasSet(fs, "create-branch", &cleanupFlags.CreateBranchSet)
+		flagWasSet(fs, "commit-changes", &cleanupFlags.CommitChangesSet)
 	}
 	cliOpts.ConfigPath = cliOpts.ProjectConfigPath
 
@@ -141,6 +197,10 @@ func runCommand(cmdName string, args []string) error {
 		err = modepkg.RunProfile(rt, profileFlags)
 	case "cleanup":
 		err = modepkg.RunCleanup(rt, cleanupFlags)
+	case "shortcircuit":
+		err = modepkg.RunShortCircuit(rt, shortCircuitFlags)
+	case "replay":
+		err = modepkg.RunReplay(rt, replayFlags)
 	default:
 		err = fmt.Errorf("unsupported mode %q", cmdName)
 	}
@@ -158,7 +218,9 @@ func runCommand(cmdName string, args []string) error {
 	return nil
 }
 
-func detectBoolFlagSet(fs *flag.FlagSet, name string, target *bool) {
+// flagWasSet records in target whether the named flag, of any type, was
+// given on the command line.
+func flagWasSet(fs *flag.FlagSet, name string, target *bool) {
 	*target = false
 	fs.Visit(func(f *flag.Flag) {
 		if f.Name == name {
@@ -182,6 +244,9 @@ Commands:
   configure   Configure global OpenAI/settings defaults
   profile     Profile API routes and propose cleanup
   cleanup     Analyze code and apply cleanup options
+  shortcircuit
+              List, verify or revert short-circuit patches
+  replay      Re-send recorded profiling traffic (HAR) to a running app
   help        Show this help
 
 Run "ccc <command> --help" for command options.
@@ -190,9 +255,11 @@ Run "ccc <command> --help" for command options.
 
 func commandUsage(mode string) string {
 	headline := map[string]string{
-		"configure": "Configure global OpenAI/settings defaults",
-		"profile":   "Profile API routes and propose cleanup",
-		"cleanup":   "Analyze code and apply cleanup options",
+		"configure":    "Configure global OpenAI/settings defaults",
+		"profile":      "Profile API routes and propose cleanup",
+		"cleanup":      "Analyze code and apply cleanup options",
+		"shortcircuit": "List, verify or revert short-circuit patches",
+		"replay":       "Re-send recorded profiling traffic (HAR) to a running app",
 	}
 
 	base := `
@@ -221,9 +288,24 @@ Profile Flags:
   --dependency-short-circuit Enable short-circuit enhancement
   --ai-route-inference       Enable AI final-pass route inference (default true)
   --ai-dependency-inference  Enable AI dependency inference merge pass (default true)
+  --ai-parameter-inference   Enable AI parameter inference for routes without schemas (default true)
   --require-ai               Fail if AI inference is enabled but unavailable
   --edit-permission-mode     Edit permission mode (per-edit|per-file)
   --auto-apply               Apply edits without prompts where allowed
+  --iterations <n>           Measured calls per route for latency statistics (default 10)
+  --warmup <n>               Unmeasured warm-up calls per route (default 2)
+  --concurrency <n>          Concurrent measured calls per route (default 1)
+  --cpu-profile              Capture CPU and allocation profiles of the app per route
+  --coverage                 Collect code coverage per route to find code no route executes
+  --pprof-url <url>          Go pprof endpoint to attach to (default <base-url>/debug/pprof)
+  --attach-url <url>         Profile an app already running at this URL instead of starting one
+  --har <path>               Record profiling traffic to a HAR file (default .ccc/runs/<id>/traffic.har with profile.har.record)
+  --save-baseline <name>     Save statuses and latency per route as .ccc/baselines/<name>.json
+  --compare <name>           Compare with a saved baseline; exit non-zero on regressions
+  --load-duration <d>        Run a load test for this long after profiling (e.g. 30s)
+  --load-rps <n>             Target requests per second (default: as fast as workers allow)
+  --load-concurrency <n>     Load test workers (max in flight with --load-rps)
+  --load-mix <csv>           Weighted route mix, e.g. "GET /orders=3,POST /orders=1"
   --create-branch            Create a branch at final step
   --commit-changes           Commit changes at final step
 `
@@ -239,6 +321,29 @@ Cleanup Flags:
   --create-branch            Create a branch at final step
   --commit-changes           Commit changes at final step
   --show-progress            Show cleanup execution progress output
+  --regression-har <path>    Traffic to replay before and after cleanup (default newest .ccc/runs/*/traffic.har)
+  --verify-command <cmd>     Check each task's edits with this command and revert on failure (auto|none|<command>, default auto)
+  --executor <name>          Run tasks with the model (ai), offline Go rewrites (ast) or both (combined); default ai
+  --patch <path>             Write the diffs of all edits to a .patch file (--dry-run prints them otherwise)
+`
+	case "shortcircuit":
+		extra = `
+Short-circuit Actions:
+  list                       List recorded patches and their status (default)
+  verify                     Check every patched file still matches its manifest
+  revert                     Remove recorded patches, newest first; refuses diverged files
+
+Short-circuit Flags:
+  --id <id>                  Manifest id to act on (repeatable; default all)
+  --manifest-dir <path>      Manifest directory (default .ccc/shortcircuit)
+`
+	case "replay":
+		extra = `
+Replay Usage:
+  ccc replay <har-file> [flags]
+
+Replay Flags:
+  --base-url <url>           URL of the running app (default profile.app.attach_url or http://127.0.0.1:<profile.app.port>)
 `
 	case "configure":
 		extra = `
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
@@ -32,32 +34,123 @@ type ModesConfig struct {
 }
 
 type ProfileConfig struct {
-	IncludeRoutes            []string `json:"include_routes"`
-	IgnoreRoutes             []string `json:"ignore_routes"`
-	DependencyShortCircuit   bool     `json:"dependency_short_circuit"`
-	AIRouteInference         bool     `json:"ai_route_inference"`
-	AIDependencyInference    bool     `json:"ai_dependency_inference"`
-	RequireAI                bool     `json:"require_ai"`
-	ShortCircuitEnvVar       string   `json:"short_circuit_env_var"`
-	UpdateEnvFile            bool     `json:"update_env_file"`
-	SaveShortCircuitToConfig bool     `json:"save_short_circuit_to_config"`
-	EditPermissionMode       string   `json:"edit_permission_mode"`
-	AutoApply                bool     `json:"auto_apply"`
+	IncludeRoutes            []string         `json:"include_routes"`
+	IgnoreRoutes             []string         `json:"ignore_routes"`
+	DependencyShortCircuit   bool             `json:"dependency_short_circuit"`
+	AIRouteInference         bool             `json:"ai_route_inference"`
+	AIDependencyInference    bool             `json:"ai_dependency_inference"`
+	AIParameterInference     bool             `json:"ai_parameter_inference"`
+	RequireAI                bool             `json:"require_ai"`
+	ShortCircuitEnvVar       string           `json:"short_circuit_env_var"`
+	UpdateEnvFile            bool             `json:"update_env_file"`
+	SaveShortCircuitToConfig bool             `json:"save_short_circuit_to_config"`
+	EditPermissionMode       string           `json:"edit_permission_mode"`
+	AutoApply                bool             `json:"auto_apply"`
+	Iterations               int              `json:"iterations"`
+	Warmup                   int              `json:"warmup"`
+	Concurrency              int              `json:"concurrency"`
+	MeasureUnsafeMethods     bool             `json:"measure_unsafe_methods"`
+	CPUProfile               bool             `json:"cpu_profile"`
+	PprofURL                 string           `json:"pprof_url"`
+	Coverage                 bool             `json:"coverage"`
+	App                      AppConfig        `json:"app"`
+	HAR                      HARConfig        `json:"har"`
+	Regression               RegressionConfig `json:"regression"`
+	Baseline                 BaselineConfig   `json:"baseline"`
 }
 
+// AppConfig describes how the app under test is started and reached. An
+// empty Command falls back to framework heuristics; Port 0 allocates a free
+// port. With AttachURL set the app is not started and requests go there.
+type AppConfig struct {
+	Command               []string          `json:"command"`
+	Dir                   string            `json:"dir"`
+	Env                   map[string]string `json:"env"`
+	EnvFiles              []string          `json:"env_files"`
+	Port                  int               `json:"port"`
+	HealthPath            string            `json:"health_path"`
+	HealthStatus          int               `json:"health_status"`
+	StartupTimeoutSeconds int               `json:"startup_timeout_seconds"`
+	AttachURL             string            `json:"attach_url"`
+}
+
+// HARConfig controls recording profiling traffic as HAR. Values of the
+// listed headers, and of query, form and JSON fields with the listed names,
+// are replaced with REDACTED.
+type HARConfig struct {
+	Record        bool     `json:"record"`
+	RedactHeaders []string `json:"redact_headers"`
+	RedactFields  []string `json:"redact_fields"`
+	OmitBodies    bool     `json:"omit_bodies"`
+}
+
+// RegressionConfig controls the behavioral check after cleanup: the app is
+// restarted, the same requests are sent again, and responses are diffed with
+// those from before. OnChange is prompt, rollback or fail.
+type RegressionConfig struct {
+	Enabled        bool     `json:"enabled"`
+	VolatileFields []string `json:"volatile_fields"`
+	LatencyRatio   float64  `json:"latency_ratio"`
+	LatencyFloorMs float64  `json:"latency_floor_ms"`
+	OnChange       string   `json:"on_change"`
+}
+
+// BaselineConfig sets when a comparison with a saved baseline counts as a
+// regression: p95 latency up by more than P95IncreasePct percent and at
+// least P95IncreaseMs, error rate up by more than ErrorRateIncrease (a
+// fraction), a changed status, or a route that is gone.
+type BaselineConfig struct {
+	P95IncreasePct      float64 `json:"p95_increase_pct"`
+	P95IncreaseMs       float64 `json:"p95_increase_ms"`
+	ErrorRateIncrease   float64 `json:"error_rate_increase"`
+	FailOnStatusChange  bool    `json:"fail_on_status_change"`
+	FailOnRemovedRoutes bool    `json:"fail_on_removed_routes"`
+}
+
+// CleanupConfig selects the cleanup rules and how they run. Executor runs
+// the tasks: "ai" (the model), "ast" (offline Go rewrites) or "combined" (Go
+// rewrites, then the model).
 type CleanupConfig struct {
-	RemoveRedundantGuards bool   `json:"remove_redundant_guards"`
-	DryRefactor           bool   `json:"dry_refactor"`
-	HardenErrorHandling   bool   `json:"harden_error_handling"`
-	GateFeaturesEnv       bool   `json:"gate_features_env"`
-	SplitFunctions        bool   `json:"split_functions"`
-	StandardizeNaming     bool   `json:"standardize_naming"`
-	SimplifyComplexLogic  bool   `json:"simplify_complex_logic"`
-	DetectExpensive       bool   `json:"detect_expensive_functions"`
-	EditPermissionMode    string `json:"edit_permission_mode"`
-	AutoApply             bool   `json:"auto_apply"`
+	RemoveRedundantGuards bool         `json:"remove_redundant_guards"`
+	DryRefactor           bool         `json:"dry_refactor"`
+	HardenErrorHandling   bool         `json:"harden_error_handling"`
+	GateFeaturesEnv       bool         `json:"gate_features_env"`
+	SplitFunctions        bool         `json:"split_functions"`
+	StandardizeNaming     bool         `json:"standardize_naming"`
+	SimplifyComplexLogic  bool         `json:"simplify_complex_logic"`
+	DetectExpensive       bool         `json:"detect_expensive_functions"`
+	EditPermissionMode    string       `json:"edit_permission_mode"`
+	AutoApply             bool         `json:"auto_apply"`
+	Executor              string       `json:"executor"`
+	Verify                VerifyConfig `json:"verify"`
+	Syntax                SyntaxConfig `json:"syntax"`
+}
+
+// VerifyConfig is the check run after each cleanup task writes files; the
+// task is reverted when it fails. Command is run by the shell; "auto" picks
+// one from the project's files and "none" turns the check off.
+type VerifyConfig struct {
+	Command        string `json:"command"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
 }
 
+// SyntaxConfig checks that files changed by cleanup still parse before they
+// are written. Go is parsed in process; Checkers maps other extensions to a
+// local command that fails for a file that does not parse ({file} is its
+// path). Extensions without one, or whose program is not installed, get a
+// built-in bracket and string check, which is not a parser and only a last
+// resort.
+// RepairAttempts is how often the model is asked to fix a file that does not
+// parse before the file is skipped.
+type SyntaxConfig struct {
+	Checkers       map[string]string `json:"checkers"`
+	RepairAttempts int               `json:"repair_attempts"`
+}
+
+// tscSyntaxCheck parses TypeScript and JSX with tsc (5.6 or later) without
+// type checking or resolving imports, so only syntax errors fail it.
+const tscSyntaxCheck = "tsc --noEmit --noCheck --noResolve --allowJs --jsx preserve {file}"
+
 type GitConfig struct {
 	AutoOfferBranchAndCommit bool `json:"auto_offer_branch_and_commit"`
 }
@@ -110,12 +203,43 @@ func DefaultConfig() Config {
 			DependencyShortCircuit:   true,
 			AIRouteInference:         true,
 			AIDependencyInference:    true,
+			AIParameterInference:     true,
 			RequireAI:                false,
 			ShortCircuitEnvVar:       "CoolCodeCleanupShortCircuit",
 			UpdateEnvFile:            false,
 			SaveShortCircuitToConfig: true,
 			EditPermissionMode:       "per-file",
 			AutoApply:                false,
+			Iterations:               10,
+			Warmup:                   2,
+			Concurrency:              1,
+			MeasureUnsafeMethods:     false,
+			CPUProfile:               false,
+			Coverage:                 false,
+			App: AppConfig{
+				Port:                  8000,
+				HealthPath:            "/health",
+				StartupTimeoutSeconds: 30,
+			},
+			HAR: HARConfig{
+				Record:        false,
+				RedactHeaders: []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization", "X-Api-Key", "X-CSRFToken"},
+				RedactFields:  []string{"password", "token", "access_token", "refresh_token", "id_token", "secret", "api_key"},
+			},
+			Regression: RegressionConfig{
+				Enabled:        true,
+				VolatileFields: []string{"created_at", "updated_at", "timestamp", "date", "expires_at", "request_id", "trace_id", "token", "access_token", "refresh_token", "id_token", "csrf_token"},
+				LatencyRatio:   2,
+				LatencyFloorMs: 50,
+				OnChange:       "prompt",
+			},
+			Baseline: BaselineConfig{
+				P95IncreasePct:      25,
+				P95IncreaseMs:       5,
+				ErrorRateIncrease:   0.05,
+				FailOnStatusChange:  true,
+				FailOnRemovedRoutes: true,
+			},
 		},
 		Cleanup: CleanupConfig{
 			RemoveRedundantGuards: true,
@@ -128,6 +252,25 @@ func DefaultConfig() Config {
 			DetectExpensive:       true,
 			EditPermissionMode:    "per-file",
 			AutoApply:             false,
+			Executor:              "ai",
+			Verify: VerifyConfig{
+				Command:        "auto",
+				TimeoutSeconds: 600,
+			},
+			Syntax: SyntaxConfig{
+				Checkers: map[string]string{
+					".js":  "node --check {file}",
+					".mjs": "node --check {file}",
+					".cjs": "node --check {file}",
+					".ts":  tscSyntaxCheck,
+					".tsx": tscSyntaxCheck,
+					".mts": tscSyntaxCheck,
+					".cts": tscSyntaxCheck,
+					".jsx": tscSyntaxCheck,
+					".py":  `python3 -c "import ast, sys; ast.parse(open(sys.argv[1]).read())" {file}`,
+				},
+				RepairAttempts: 1,
+			},
 		},
 		Git: GitConfig{
 			AutoOfferBranchAndCommit: true,
@@ -170,7 +313,30 @@ func Resolve(cli CLIOverrides) (Effective, error) {
 	effective.SourceChains["profile.dependency_short_circuit"] = []string{SourceDefault}
 	effective.SourceChains["profile.ai_route_inference"] = []string{SourceDefault}
 	effective.SourceChains["profile.ai_dependency_inference"] = []string{SourceDefault}
+	effective.SourceChains["profile.ai_parameter_inference"] = []string{SourceDefault}
 	effective.SourceChains["profile.require_ai"] = []string{SourceDefault}
+	effective.SourceChains["profile.iterations"] = []string{SourceDefault}
+	effective.SourceChains["profile.warmup"] = []string{SourceDefault}
+	effective.SourceChains["profile.concurrency"] = []string{SourceDefault}
+	effective.SourceChains["profile.cpu_profile"] = []string{SourceDefault}
+	effective.SourceChains["profile.coverage"] = []string{SourceDefault}
+	effective.SourceChains["profile.app.port"] = []string{SourceDefault}
+	effective.SourceChains["profile.app.health_path"] = []string{SourceDefault}
+	effective.SourceChains["profile.app.startup_timeout_seconds"] = []string{SourceDefault}
+	effective.SourceChains["profile.har.record"] = []string{SourceDefault}
+	effective.SourceChains["profile.har.redact_headers"] = []string{SourceDefault}
+	effective.SourceChains["profile.har.redact_fields"] = []string{SourceDefault}
+	effective.SourceChains["profile.har.omit_bodies"] = []string{SourceDefault}
+	effective.SourceChains["profile.regression.enabled"] = []string{SourceDefault}
+	effective.SourceChains["profile.regression.volatile_fields"] = []string{SourceDefault}
+	effective.SourceChains["profile.regression.latency_ratio"] = []string{SourceDefault}
+	effective.SourceChains["profile.regression.latency_floor_ms"] = []string{SourceDefault}
+	effective.SourceChains["profile.regression.on_change"] = []string{SourceDefault}
+	effective.SourceChains["profile.baseline.p95_increase_pct"] = []string{SourceDefault}
+	effective.SourceChains["profile.baseline.p95_increase_ms"] = []string{SourceDefault}
+	effective.SourceChains["profile.baseline.error_rate_increase"] = []string{SourceDefault}
+	effective.SourceChains["profile.baseline.fail_on_status_change"] = []string{SourceDefault}
+	effective.SourceChains["profile.baseline.fail_on_removed_routes"] = []string{SourceDefault}
 	effective.SourceChains["cleanup.remove_redundant_guards"] = []string{SourceDefault}
 	effective.SourceChains["cleanup.dry_refactor"] = []string{SourceDefault}
 	effective.SourceChains["cleanup.harden_error_handling"] = []string{SourceDefault}
@@ -179,6 +345,11 @@ func Resolve(cli CLIOverrides) (Effective, error) {
 	effective.SourceChains["cleanup.standardize_naming"] = []string{SourceDefault}
 	effective.SourceChains["cleanup.simplify_complex_logic"] = []string{SourceDefault}
 	effective.SourceChains["cleanup.detect_expensive_functions"] = []string{SourceDefault}
+	effective.SourceChains["cleanup.executor"] = []string{SourceDefault}
+	effective.SourceChains["cleanup.verify.command"] = []string{SourceDefault}
+	effective.SourceChains["cleanup.verify.timeout_seconds"] = []string{SourceDefault}
+	effective.SourceChains["cleanup.syntax.checkers"] = []string{SourceDefault}
+	effective.SourceChains["cleanup.syntax.repair_attempts"] = []string{SourceDefault}
 
 	globalFile, exists, err := loadConfigFile(globalConfigPath)
 	if err != nil {
@@ -256,6 +427,133 @@ func mergeConfig(base Config, overlay Config, chains map[string][]string, source
 		base.Profile.ShortCircuitEnvVar = overlay.Profile.ShortCircuitEnvVar
 		chains["profile.short_circuit_env_var"] = append(chains["profile.short_circuit_env_var"], source)
 	}
+	if overlay.Profile.Iterations != base.Profile.Iterations {
+		base.Profile.Iterations = overlay.Profile.Iterations
+		chains["profile.iterations"] = append(chains["profile.iterations"], source)
+	}
+	if overlay.Profile.Warmup != base.Profile.Warmup {
+		base.Profile.Warmup = overlay.Profile.Warmup
+		chains["profile.warmup"] = append(chains["profile.warmup"], source)
+	}
+	if overlay.Profile.Concurrency != base.Profile.Concurrency {
+		base.Profile.Concurrency = overlay.Profile.Concurrency
+		chains["profile.concurrency"] = append(chains["profile.concurrency"], source)
+	}
+	if overlay.Profile.MeasureUnsafeMethods != base.Profile.MeasureUnsafeMethods {
+		base.Profile.MeasureUnsafeMethods = overlay.Profile.MeasureUnsafeMethods
+		chains["profile.measure_unsafe_methods"] = append(chains["profile.measure_unsafe_methods"], source)
+	}
+	if overlay.Profile.CPUProfile != base.Profile.CPUProfile {
+		base.Profile.CPUProfile = overlay.Profile.CPUProfile
+		chains["profile.cpu_profile"] = append(chains["profile.cpu_profile"], source)
+	}
+	if overlay.Profile.Coverage != base.Profile.Coverage {
+		base.Profile.Coverage = overlay.Profile.Coverage
+		chains["profile.coverage"] = append(chains["profile.coverage"], source)
+	}
+	if overlay.Profile.PprofURL != "" && overlay.Profile.PprofURL != base.Profile.PprofURL {
+		base.Profile.PprofURL = overlay.Profile.PprofURL
+		chains["profile.pprof_url"] = append(chains["profile.pprof_url"], source)
+	}
+	if len(overlay.Profile.App.Command) > 0 {
+		base.Profile.App.Command = overlay.Profile.App.Command
+		chains["profile.app.command"] = append(chains["profile.app.command"], source)
+	}
+	if overlay.Profile.App.Dir != "" && overlay.Profile.App.Dir != base.Profile.App.Dir {
+		base.Profile.App.Dir = overlay.Profile.App.Dir
+		chains["profile.app.dir"] = append(chains["profile.app.dir"], source)
+	}
+	if len(overlay.Profile.App.Env) > 0 {
+		env := map[string]string{}
+		for k, v := range base.Profile.App.Env {
+			env[k] = v
+		}
+		for k, v := range overlay.Profile.App.Env {
+			env[k] = v
+		}
+		base.Profile.App.Env = env
+		chains["profile.app.env"] = append(chains["profile.app.env"], source)
+	}
+	if len(overlay.Profile.App.EnvFiles) > 0 {
+		base.Profile.App.EnvFiles = dedupe(overlay.Profile.App.EnvFiles)
+		chains["profile.app.env_files"] = append(chains["profile.app.env_files"], source)
+	}
+	if overlay.Profile.App.Port != base.Profile.App.Port {
+		base.Profile.App.Port = overlay.Profile.App.Port
+		chains["profile.app.port"] = append(chains["profile.app.port"], source)
+	}
+	if overlay.Profile.App.HealthPath != "" && overlay.Profile.App.HealthPath != base.Profile.App.HealthPath {
+		base.Profile.App.HealthPath = overlay.Profile.App.HealthPath
+		chains["profile.app.health_path"] = append(chains["profile.app.health_path"], source)
+	}
+	if overlay.Profile.App.HealthStatus != base.Profile.App.HealthStatus {
+		base.Profile.App.HealthStatus = overlay.Profile.App.HealthStatus
+		chains["profile.app.health_status"] = append(chains["profile.app.health_status"], source)
+	}
+	if overlay.Profile.App.StartupTimeoutSeconds != base.Profile.App.StartupTimeoutSeconds {
+		base.Profile.App.StartupTimeoutSeconds = overlay.Profile.App.StartupTimeoutSeconds
+		chains["profile.app.startup_timeout_seconds"] = append(chains["profile.app.startup_timeout_seconds"], source)
+	}
+	if overlay.Profile.App.AttachURL != "" && overlay.Profile.App.AttachURL != base.Profile.App.AttachURL {
+		base.Profile.App.AttachURL = overlay.Profile.App.AttachURL
+		chains["profile.app.attach_url"] = append(chains["profile.app.attach_url"], source)
+	}
+	if overlay.Profile.HAR.Record != base.Profile.HAR.Record {
+		base.Profile.HAR.Record = overlay.Profile.HAR.Record
+		chains["profile.har.record"] = append(chains["profile.har.record"], source)
+	}
+	if !slices.Equal(overlay.Profile.HAR.RedactHeaders, base.Profile.HAR.RedactHeaders) {
+		base.Profile.HAR.RedactHeaders = dedupe(overlay.Profile.HAR.RedactHeaders)
+		chains["profile.har.redact_headers"] = append(chains["profile.har.redact_headers"], source)
+	}
+	if !slices.Equal(overlay.Profile.HAR.RedactFields, base.Profile.HAR.RedactFields) {
+		base.Profile.HAR.RedactFields = dedupe(overlay.Profile.HAR.RedactFields)
+		chains["profile.har.redact_fields"] = append(chains["profile.har.redact_fields"], source)
+	}
+	if overlay.Profile.HAR.OmitBodies != base.Profile.HAR.OmitBodies {
+		base.Profile.HAR.OmitBodies = overlay.Profile.HAR.OmitBodies
+		chains["profile.har.omit_bodies"] = append(chains["profile.har.omit_bodies"], source)
+	}
+	if overlay.Profile.Regression.Enabled != base.Profile.Regression.Enabled {
+		base.Profile.Regression.Enabled = overlay.Profile.Regression.Enabled
+		chains["profile.regression.enabled"] = append(chains["profile.regression.enabled"], source)
+	}
+	if !slices.Equal(overlay.Profile.Regression.VolatileFields, base.Profile.Regression.VolatileFields) {
+		base.Profile.Regression.VolatileFields = dedupe(overlay.Profile.Regression.VolatileFields)
+		chains["profile.regression.volatile_fields"] = append(chains["profile.regression.volatile_fields"], source)
+	}
+	if overlay.Profile.Regression.LatencyRatio != base.Profile.Regression.LatencyRatio {
+		base.Profile.Regression.LatencyRatio = overlay.Profile.Regression.LatencyRatio
+		chains["profile.regression.latency_ratio"] = append(chains["profile.regression.latency_ratio"], source)
+	}
+	if overlay.Profile.Regression.LatencyFloorMs != base.Profile.Regression.LatencyFloorMs {
+		base.Profile.Regression.LatencyFloorMs = overlay.Profile.Regression.LatencyFloorMs
+		chains["profile.regression.latency_floor_ms"] = append(chains["profile.regression.latency_floor_ms"], source)
+	}
+	if overlay.Profile.Regression.OnChange != "" && overlay.Profile.Regression.OnChange != base.Profile.Regression.OnChange {
+		base.Profile.Regression.OnChange = overlay.Profile.Regression.OnChange
+		chains["profile.regression.on_change"] = append(chains["profile.regression.on_change"], source)
+	}
+	if overlay.Profile.Baseline.P95IncreasePct != base.Profile.Baseline.P95IncreasePct {
+		base.Profile.Baseline.P95IncreasePct = overlay.Profile.Baseline.P95IncreasePct
+		chains["profile.baseline.p95_increase_pct"] = append(chains["profile.baseline.p95_increase_pct"], source)
+	}
+	if overlay.Profile.Baseline.P95IncreaseMs != base.Profile.Baseline.P95IncreaseMs {
+		base.Profile.Baseline.P95IncreaseMs = overlay.Profile.Baseline.P95IncreaseMs
+		chains["profile.baseline.p95_increase_ms"] = append(chains["profile.baseline.p95_increase_ms"], source)
+	}
+	if overlay.Profile.Baseline.ErrorRateIncrease != base.Profile.Baseline.ErrorRateIncrease {
+		base.Profile.Baseline.ErrorRateIncrease = overlay.Profile.Baseline.ErrorRateIncrease
+		chains["profile.baseline.error_rate_increase"] = append(chains["profile.baseline.error_rate_increase"], source)
+	}
+	if overlay.Profile.Baseline.FailOnStatusChange != base.Profile.Baseline.FailOnStatusChange {
+		base.Profile.Baseline.FailOnStatusChange = overlay.Profile.Baseline.FailOnStatusChange
+		chains["profile.baseline.fail_on_status_change"] = append(chains["profile.baseline.fail_on_status_change"], source)
+	}
+	if overlay.Profile.Baseline.FailOnRemovedRoutes != base.Profile.Baseline.FailOnRemovedRoutes {
+		base.Profile.Baseline.FailOnRemovedRoutes = overlay.Profile.Baseline.FailOnRemovedRoutes
+		chains["profile.baseline.fail_on_removed_routes"] = append(chains["profile.baseline.fail_on_removed_routes"], source)
+	}
 	if overlay.Cleanup.EditPermissionMode != "" && overlay.Cleanup.EditPermissionMode != base.Cleanup.EditPermissionMode {
 		base.Cleanup.EditPermissionMode = overlay.Cleanup.EditPermissionMode
 		chains["cleanup.edit_permission_mode"] = append(chains["cleanup.edit_permission_mode"], source)
@@ -272,6 +570,10 @@ func mergeConfig(base Config, overlay Config, chains map[string][]string, source
 		base.Profile.AIDependencyInference = overlay.Profile.AIDependencyInference
 		chains["profile.ai_dependency_inference"] = append(chains["profile.ai_dependency_inference"], source)
 	}
+	if overlay.Profile.AIParameterInference != base.Profile.AIParameterInference {
+		base.Profile.AIParameterInference = overlay.Profile.AIParameterInference
+		chains["profile.ai_parameter_inference"] = append(chains["profile.ai_parameter_inference"], source)
+	}
 	if overlay.Profile.RequireAI != base.Profile.RequireAI {
 		base.Profile.RequireAI = overlay.Profile.RequireAI
 		chains["profile.require_ai"] = append(chains["profile.require_ai"], source)
@@ -308,6 +610,33 @@ func mergeConfig(base Config, overlay Config, chains map[string][]string, source
 		base.Cleanup.DetectExpensive = overlay.Cleanup.DetectExpensive
 		chains["cleanup.detect_expensive_functions"] = append(chains["cleanup.detect_expensive_functions"], source)
 	}
+	if overlay.Cleanup.Executor != "" && overlay.Cleanup.Executor != base.Cleanup.Executor {
+		base.Cleanup.Executor = overlay.Cleanup.Executor
+		chains["cleanup.executor"] = append(chains["cleanup.executor"], source)
+	}
+	if overlay.Cleanup.Verify.Command != "" && overlay.Cleanup.Verify.Command != base.Cleanup.Verify.Command {
+		base.Cleanup.Verify.Command = overlay.Cleanup.Verify.Command
+		chains["cleanup.verify.command"] = append(chains["cleanup.verify.command"], source)
+	}
+	if overlay.Cleanup.Verify.TimeoutSeconds != 0 && overlay.Cleanup.Verify.TimeoutSeconds != base.Cleanup.Verify.TimeoutSeconds {
+		base.Cleanup.Verify.TimeoutSeconds = overlay.Cleanup.Verify.TimeoutSeconds
+		chains["cleanup.verify.timeout_seconds"] = append(chains["cleanup.verify.timeout_seconds"], source)
+	}
+	if !maps.Equal(overlay.Cleanup.Syntax.Checkers, base.Cleanup.Syntax.Checkers) {
+		checkers := map[string]string{}
+		for ext, cmd := range base.Cleanup.Syntax.Checkers {
+			checkers[ext] = cmd
+		}
+		for ext, cmd := range overlay.Cleanup.Syntax.Checkers {
+			checkers[ext] = cmd
+		}
+		base.Cleanup.Syntax.Checkers = checkers
+		chains["cleanup.syntax.checkers"] = append(chains["cleanup.syntax.checkers"], source)
+	}
+	if overlay.Cleanup.Syntax.RepairAttempts != base.Cleanup.Syntax.RepairAttempts {
+		base.Cleanup.Syntax.RepairAttempts = overlay.Cleanup.Syntax.RepairAttempts
+		chains["cleanup.syntax.repair_attempts"] = append(chains["cleanup.syntax.repair_attempts"], source)
+	}
 	if len(overlay.Profile.IncludeRoutes) > 0 {
 		base.Profile.IncludeRoutes = dedupe(overlay.Profile.IncludeRoutes)
 		appendSourceIfMissing(chains, "profile.include_routes", source)
@@ -342,6 +671,62 @@ func applyEnv(e *Effective) {
 		e.SourceChains["profile.edit_permission_mode"] = append(e.SourceChains["profile.edit_permission_mode"], SourceEnv)
 		e.SourceChains["cleanup.edit_permission_mode"] = append(e.SourceChains["cleanup.edit_permission_mode"], SourceEnv)
 	}
+	if n, ok := intEnv("CCC_PROFILE_ITERATIONS"); ok {
+		e.Config.Profile.Iterations = n
+		e.SourceChains["profile.iterations"] = append(e.SourceChains["profile.iterations"], SourceEnv)
+	}
+	if n, ok := intEnv("CCC_PROFILE_WARMUP"); ok {
+		e.Config.Profile.Warmup = n
+		e.SourceChains["profile.warmup"] = append(e.SourceChains["profile.warmup"], SourceEnv)
+	}
+	if n, ok := intEnv("CCC_PROFILE_CONCURRENCY"); ok {
+		e.Config.Profile.Concurrency = n
+		e.SourceChains["profile.concurrency"] = append(e.SourceChains["profile.concurrency"], SourceEnv)
+	}
+	if cpu, ok := boolEnv("CCC_PROFILE_CPU"); ok {
+		e.Config.Profile.CPUProfile = cpu
+		e.SourceChains["profile.cpu_profile"] = append(e.SourceChains["profile.cpu_profile"], SourceEnv)
+	}
+	if cov, ok := boolEnv("CCC_PROFILE_COVERAGE"); ok {
+		e.Config.Profile.Coverage = cov
+		e.SourceChains["profile.coverage"] = append(e.SourceChains["profile.coverage"], SourceEnv)
+	}
+	if url := strings.TrimSpace(os.Getenv("CCC_PPROF_URL")); url != "" {
+		e.Config.Profile.PprofURL = url
+		e.SourceChains["profile.pprof_url"] = append(e.SourceChains["profile.pprof_url"], SourceEnv)
+	}
+	if record, ok := boolEnv("CCC_PROFILE_HAR"); ok {
+		e.Config.Profile.HAR.Record = record
+		e.SourceChains["profile.har.record"] = append(e.SourceChains["profile.har.record"], SourceEnv)
+	}
+	if check, ok := boolEnv("CCC_REGRESSION_CHECK"); ok {
+		e.Config.Profile.Regression.Enabled = check
+		e.SourceChains["profile.regression.enabled"] = append(e.SourceChains["profile.regression.enabled"], SourceEnv)
+	}
+	if onChange := strings.TrimSpace(os.Getenv("CCC_REGRESSION_ON_CHANGE")); onChange != "" {
+		e.Config.Profile.Regression.OnChange = onChange
+		e.SourceChains["profile.regression.on_change"] = append(e.SourceChains["profile.regression.on_change"], SourceEnv)
+	}
+	if executor := strings.TrimSpace(os.Getenv("CCC_CLEANUP_EXECUTOR")); executor != "" {
+		e.Config.Cleanup.Executor = executor
+		e.SourceChains["cleanup.executor"] = append(e.SourceChains["cleanup.executor"], SourceEnv)
+	}
+	if cmd := strings.TrimSpace(os.Getenv("CCC_VERIFY_COMMAND")); cmd != "" {
+		e.Config.Cleanup.Verify.Command = cmd
+		e.SourceChains["cleanup.verify.command"] = append(e.SourceChains["cleanup.verify.command"], SourceEnv)
+	}
+	if pct, ok := floatEnv("CCC_BASELINE_P95_INCREASE_PCT"); ok {
+		e.Config.Profile.Baseline.P95IncreasePct = pct
+		e.SourceChains["profile.baseline.p95_increase_pct"] = append(e.SourceChains["profile.baseline.p95_increase_pct"], SourceEnv)
+	}
+	if n, ok := intEnv("CCC_APP_PORT"); ok {
+		e.Config.Profile.App.Port = n
+		e.SourceChains["profile.app.port"] = append(e.SourceChains["profile.app.port"], SourceEnv)
+	}
+	if url := strings.TrimSpace(os.Getenv("CCC_APP_ATTACH_URL")); url != "" {
+		e.Config.Profile.App.AttachURL = url
+		e.SourceChains["profile.app.attach_url"] = append(e.SourceChains["profile.app.attach_url"], SourceEnv)
+	}
 	if include := strings.TrimSpace(os.Getenv("CCC_PROFILE_INCLUDE_ROUTES")); include != "" {
 		e.Config.Profile.IncludeRoutes = ParseCSV(include)
 		e.SourceChains["profile.include_routes"] = []string{SourceEnv}
@@ -418,6 +803,68 @@ func validate(cfg Config) error {
 	if !validModes[cfg.Cleanup.EditPermissionMode] {
 		return fmt.Errorf("invalid cleanup edit_permission_mode %q (expected per-edit or per-file)", cfg.Cleanup.EditPermissionMode)
 	}
+	if cfg.Profile.Iterations < 0 {
+		return fmt.Errorf("invalid profile iterations %d (expected 0 to skip latency measurement, or more)", cfg.Profile.Iterations)
+	}
+	if cfg.Profile.Warmup < 0 {
+		return fmt.Errorf("invalid profile warmup %d (expected 0 or more)", cfg.Profile.Warmup)
+	}
+	if cfg.Profile.Concurrency < 1 {
+		return fmt.Errorf("invalid profile concurrency %d (expected at least 1)", cfg.Profile.Concurrency)
+	}
+	app := cfg.Profile.App
+	if app.Port < 0 || app.Port > 65535 {
+		return fmt.Errorf("invalid profile app port %d (expected 0 to allocate one, or 1-65535)", app.Port)
+	}
+	if !strings.HasPrefix(app.HealthPath, "/") {
+		return fmt.Errorf("invalid profile app health_path %q (expected a path starting with /)", app.HealthPath)
+	}
+	if app.HealthStatus != 0 && (app.HealthStatus < 100 || app.HealthStatus > 599) {
+		return fmt.Errorf("invalid profile app health_status %d (expected 0 for any non-5xx, or an HTTP status)", app.HealthStatus)
+	}
+	if app.StartupTimeoutSeconds < 1 {
+		return fmt.Errorf("invalid profile app startup_timeout_seconds %d (expected at least 1)", app.StartupTimeoutSeconds)
+	}
+	if app.AttachURL != "" {
+		if u, err := url.Parse(app.AttachURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid profile app attach_url %q (expected an http or https URL)", app.AttachURL)
+		}
+	}
+	reg := cfg.Profile.Regression
+	switch reg.OnChange {
+	case "prompt", "rollback", "fail":
+	default:
+		return fmt.Errorf("invalid profile regression on_change %q (expected prompt, rollback or fail)", reg.OnChange)
+	}
+	if reg.LatencyRatio < 0 || reg.LatencyFloorMs < 0 {
+		return fmt.Errorf("invalid profile regression latency_ratio %g / latency_floor_ms %g (expected 0 or more)", reg.LatencyRatio, reg.LatencyFloorMs)
+	}
+	bl := cfg.Profile.Baseline
+	if bl.P95IncreasePct < 0 || bl.P95IncreaseMs < 0 {
+		return fmt.Errorf("invalid profile baseline p95_increase_pct %g / p95_increase_ms %g (expected 0 or more)", bl.P95IncreasePct, bl.P95IncreaseMs)
+	}
+	if bl.ErrorRateIncrease < 0 || bl.ErrorRateIncrease > 1 {
+		return fmt.Errorf("invalid profile baseline error_rate_increase %g (expected a fraction from 0 to 1)", bl.ErrorRateIncrease)
+	}
+	switch cfg.Cleanup.Executor {
+	case "ai", "ast", "combined":
+	default:
+		return fmt.Errorf("invalid cleanup executor %q (expected ai, ast or combined)", cfg.Cleanup.Executor)
+	}
+	if strings.TrimSpace(cfg.Cleanup.Verify.Command) == "" {
+		return fmt.Errorf("invalid cleanup verify command (expected auto, none or a shell command)")
+	}
+	if cfg.Cleanup.Verify.TimeoutSeconds < 1 {
+		return fmt.Errorf("invalid cleanup verify timeout_seconds %d (expected at least 1)", cfg.Cleanup.Verify.TimeoutSeconds)
+	}
+	for ext := range cfg.Cleanup.Syntax.Checkers {
+		if !strings.HasPrefix(ext, ".") {
+			return fmt.Errorf("invalid cleanup syntax checker extension %q (expected a file extension such as .js)", ext)
+		}
+	}
+	if cfg.Cleanup.Syntax.RepairAttempts < 0 {
+		return fmt.Errorf("invalid cleanup syntax repair_attempts %d (expected 0 or more)", cfg.Cleanup.Syntax.RepairAttempts)
+	}
 	return nil
 }
 
@@ -489,3 +936,27 @@ func boolEnv(name string) (bool, bool) {
 	}
 	return v, true
 }
+
+func floatEnv(name string) (float64, bool) {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+func intEnv(name string) (int, bool) {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return 0, false
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
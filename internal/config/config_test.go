@@ -2,6 +2,7 @@ package config
 
 import (
 	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -83,6 +84,174 @@ func TestResolveConfigPathBackCompatAlias(t *testing.T) {
 	}
 }
 
+func TestResolveProfileLatencySettings(t *testing.T) {
+	tmp := t.TempDir()
+	projectPath := filepath.Join(tmp, ".ccc", "config.json")
+	projectCfg := DefaultConfig()
+	projectCfg.Profile.Iterations = 50
+	projectCfg.Profile.Concurrency = 4
+	if err := Save(projectPath, projectCfg); err != nil {
+		t.Fatalf("save project config: %v", err)
+	}
+	t.Setenv("CCC_PROFILE_ITERATIONS", "200")
+
+	eff, err := Resolve(CLIOverrides{ProjectConfigPath: projectPath, GlobalConfigPath: filepath.Join(tmp, "missing.json")})
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if p := eff.Config.Profile; p.Iterations != 200 || p.Concurrency != 4 || p.Warmup != 2 {
+		t.Fatalf("unexpected latency settings %+v", p)
+	}
+	assertChain(t, eff.SourceChains["profile.iterations"], []string{SourceDefault, SourceProjectConfig, SourceEnv})
+
+	t.Setenv("CCC_PROFILE_CONCURRENCY", "0")
+	if _, err := Resolve(CLIOverrides{ProjectConfigPath: projectPath, GlobalConfigPath: filepath.Join(tmp, "missing.json")}); err == nil {
+		t.Fatalf("expected concurrency 0 to be rejected")
+	}
+}
+
+func TestResolveProfileAppSection(t *testing.T) {
+	tmp := t.TempDir()
+	globalPath := filepath.Join(tmp, "global.json")
+	globalCfg := DefaultConfig()
+	globalCfg.Profile.App.Env = map[string]string{"LOG_LEVEL": "debug", "DB_URL": "sqlite://global"}
+	if err := Save(globalPath, globalCfg); err != nil {
+		t.Fatalf("save global config: %v", err)
+	}
+	projectPath := filepath.Join(tmp, ".ccc", "config.json")
+	projectCfg := DefaultConfig()
+	projectCfg.Profile.App.Command = []string{"./bin/server", "--port", "$PORT"}
+	projectCfg.Profile.App.Env = map[string]string{"DB_URL": "sqlite://project"}
+	projectCfg.Profile.App.HealthPath = "/ready"
+	projectCfg.Profile.App.HealthStatus = 204
+	if err := Save(projectPath, projectCfg); err != nil {
+		t.Fatalf("save project config: %v", err)
+	}
+	t.Setenv("CCC_APP_PORT", "0")
+
+	eff, err := Resolve(CLIOverrides{ProjectConfigPath: projectPath, GlobalConfigPath: globalPath})
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	app := eff.Config.Profile.App
+	if len(app.Command) != 3 || app.Port != 0 || app.HealthPath != "/ready" || app.HealthStatus != 204 || app.StartupTimeoutSeconds != 30 {
+		t.Fatalf("unexpected app settings %+v", app)
+	}
+	if app.Env["LOG_LEVEL"] != "debug" || app.Env["DB_URL"] != "sqlite://project" {
+		t.Fatalf("expected project env to extend global env, got %v", app.Env)
+	}
+	assertChain(t, eff.SourceChains["profile.app.port"], []string{SourceDefault, SourceEnv})
+	assertChain(t, eff.SourceChains["profile.app.env"], []string{SourceGlobalConfig, SourceProjectConfig})
+
+	t.Setenv("CCC_APP_ATTACH_URL", "localhost:3000")
+	if _, err := Resolve(CLIOverrides{ProjectConfigPath: projectPath, GlobalConfigPath: globalPath}); err == nil {
+		t.Fatalf("expected attach_url without scheme to be rejected")
+	}
+}
+
+func TestResolveProfileHARRedaction(t *testing.T) {
+	tmp := t.TempDir()
+	projectPath := filepath.Join(tmp, ".ccc", "config.json")
+	projectCfg := DefaultConfig()
+	projectCfg.Profile.HAR.RedactFields = []string{"password", "ssn"}
+	if err := Save(projectPath, projectCfg); err != nil {
+		t.Fatalf("save project config: %v", err)
+	}
+	t.Setenv("CCC_PROFILE_HAR", "true")
+
+	eff, err := Resolve(CLIOverrides{ProjectConfigPath: projectPath, GlobalConfigPath: filepath.Join(tmp, "missing.json")})
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	har := eff.Config.Profile.HAR
+	if !har.Record || !slices.Equal(har.RedactFields, []string{"password", "ssn"}) || !slices.Contains(har.RedactHeaders, "Authorization") {
+		t.Fatalf("unexpected har settings %+v", har)
+	}
+	assertChain(t, eff.SourceChains["profile.har.record"], []string{SourceDefault, SourceEnv})
+	assertChain(t, eff.SourceChains["profile.har.redact_fields"], []string{SourceDefault, SourceProjectConfig})
+	assertChain(t, eff.SourceChains["profile.har.redact_headers"], []string{SourceDefault})
+}
+
+func TestResolveProfileBaselineThresholds(t *testing.T) {
+	tmp := t.TempDir()
+	projectPath := filepath.Join(tmp, ".ccc", "config.json")
+	projectCfg := DefaultConfig()
+	projectCfg.Profile.Baseline.FailOnRemovedRoutes = false
+	if err := Save(projectPath, projectCfg); err != nil {
+		t.Fatalf("save project config: %v", err)
+	}
+	t.Setenv("CCC_BASELINE_P95_INCREASE_PCT", "40")
+
+	eff, err := Resolve(CLIOverrides{ProjectConfigPath: projectPath, GlobalConfigPath: filepath.Join(tmp, "missing.json")})
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	b := eff.Config.Profile.Baseline
+	if b.P95IncreasePct != 40 || b.P95IncreaseMs != 5 || b.ErrorRateIncrease != 0.05 || !b.FailOnStatusChange || b.FailOnRemovedRoutes {
+		t.Fatalf("unexpected baseline settings %+v", b)
+	}
+	assertChain(t, eff.SourceChains["profile.baseline.p95_increase_pct"], []string{SourceDefault, SourceEnv})
+	assertChain(t, eff.SourceChains["profile.baseline.fail_on_removed_routes"], []string{SourceDefault, SourceProjectConfig})
+
+	t.Setenv("CCC_BASELINE_P95_INCREASE_PCT", "-1")
+	if _, err := Resolve(CLIOverrides{ProjectConfigPath: projectPath, GlobalConfigPath: filepath.Join(tmp, "missing.json")}); err == nil {
+		t.Fatalf("expected a negative threshold to be rejected")
+	}
+}
+
+func TestResolveCleanupVerify(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("CCC_VERIFY_COMMAND", "make check")
+	t.Setenv("CCC_CLEANUP_EXECUTOR", "combined")
+	eff, err := Resolve(CLIOverrides{ProjectConfigPath: filepath.Join(tmp, "missing-project.json"), GlobalConfigPath: filepath.Join(tmp, "missing.json")})
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if v := eff.Config.Cleanup.Verify; v.Command != "make check" || v.TimeoutSeconds != 600 {
+		t.Fatalf("unexpected verify settings %+v", v)
+	}
+	assertChain(t, eff.SourceChains["cleanup.verify.command"], []string{SourceDefault, SourceEnv})
+	assertChain(t, eff.SourceChains["cleanup.verify.timeout_seconds"], []string{SourceDefault})
+	if eff.Config.Cleanup.Executor != "combined" {
+		t.Fatalf("unexpected executor %q", eff.Config.Cleanup.Executor)
+	}
+	assertChain(t, eff.SourceChains["cleanup.executor"], []string{SourceDefault, SourceEnv})
+
+	t.Setenv("CCC_CLEANUP_EXECUTOR", "gpt")
+	if _, err := Resolve(CLIOverrides{ProjectConfigPath: filepath.Join(tmp, "missing-project.json"), GlobalConfigPath: filepath.Join(tmp, "missing.json")}); err == nil {
+		t.Fatalf("expected an unknown executor to be rejected")
+	}
+}
+
+func TestResolveCleanupSyntaxCheckers(t *testing.T) {
+	tmp := t.TempDir()
+	project := filepath.Join(tmp, "project.json")
+	cfg := DefaultConfig()
+	cfg.Cleanup.Syntax.Checkers = map[string]string{".ts": "npx --no-install tsc --noEmit {file}"}
+	cfg.Cleanup.Syntax.RepairAttempts = 0
+	if err := Save(project, cfg); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	eff, err := Resolve(CLIOverrides{ProjectConfigPath: project, GlobalConfigPath: filepath.Join(tmp, "missing.json")})
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	s := eff.Config.Cleanup.Syntax
+	if s.Checkers[".ts"] == "" || s.Checkers[".js"] != "node --check {file}" || s.RepairAttempts != 0 {
+		t.Fatalf("unexpected syntax settings %+v", s)
+	}
+	assertChain(t, eff.SourceChains["cleanup.syntax.checkers"], []string{SourceDefault, SourceProjectConfig})
+	assertChain(t, eff.SourceChains["cleanup.syntax.repair_attempts"], []string{SourceDefault, SourceProjectConfig})
+
+	cfg.Cleanup.Syntax.Checkers = map[string]string{"ts": "tsc"}
+	if err := Save(project, cfg); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := Resolve(CLIOverrides{ProjectConfigPath: project, GlobalConfigPath: filepath.Join(tmp, "missing.json")}); err == nil {
+		t.Fatalf("expected an extension without a dot to be rejected")
+	}
+}
+
 func assertChain(t *testing.T, got, want []string) {
 	t.Helper()
 	if len(got) != len(want) {
@@ -0,0 +1,268 @@
+// Package coverage runs the app with code coverage enabled, once at startup
+// and once per route, to attribute executed functions to the routes that
+// reached them and to find code no route executes.
+package coverage
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cool-code-cleanup/internal/runner"
+)
+
+// Coverage tool kinds.
+const (
+	KindGoCover  = "go_cover"
+	KindNodeV8   = "node_v8"
+	KindPython   = "coverage_py"
+	StartupRunID = "startup"
+)
+
+// Function identifies a function by its file (relative to the project root,
+// with forward slashes), name and declaration line.
+type Function struct {
+	File string `json:"file"`
+	Name string `json:"name"`
+	Line int    `json:"line,omitempty"`
+}
+
+// Run is what one app process executed. Functions and Files map every
+// function and file the tool reported to whether it ran.
+type Run struct {
+	Functions map[Function]bool
+	Files     map[string]bool
+}
+
+func newRun() Run {
+	return Run{Functions: map[Function]bool{}, Files: map[string]bool{}}
+}
+
+// RouteRun is the coverage of a process that served one route's invocations
+// (and those of its dependencies).
+type RouteRun struct {
+	RouteID     string
+	Method      string
+	Path        string
+	Invocations int
+	Run         Run
+}
+
+// RouteCoverage lists what a route executed beyond app startup.
+type RouteCoverage struct {
+	RouteID     string     `json:"route_id"`
+	Method      string     `json:"method"`
+	Path        string     `json:"path"`
+	Invocations int        `json:"invocations"`
+	Functions   []Function `json:"functions"`
+	Files       []string   `json:"files"`
+}
+
+type Result struct {
+	Kind                string          `json:"kind"`
+	Dir                 string          `json:"dir"`
+	StartupFunctions    int             `json:"startup_functions"`
+	Routes              []RouteCoverage `json:"routes"`
+	UnexecutedFunctions []Function      `json:"unexecuted_functions"`
+	UnexecutedFiles     []string        `json:"unexecuted_files"`
+	Warnings            []string        `json:"warnings,omitempty"`
+}
+
+// DefaultRunDir is where a run keeps its coverage output.
+func DefaultRunDir(runID string) string {
+	return filepath.Join(".ccc", "runs", runID, "coverage")
+}
+
+// Collector starts the app under the coverage tool for its framework and
+// reads each process's coverage.
+type Collector struct {
+	Kind   string
+	root   string
+	dir    string
+	module string
+	binary string
+}
+
+// NewCollector prepares coverage for the app at root, keeping output in dir.
+// Go apps are built once with -cover.
+func NewCollector(root, dir string) (*Collector, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create coverage directory: %w", err)
+	}
+	c := &Collector{root: root, dir: dir}
+	switch runner.AppFramework(root) {
+	case "go":
+		c.Kind = KindGoCover
+		module, binary, err := buildGoCover(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		c.module, c.binary = module, binary
+	case "node":
+		c.Kind = KindNodeV8
+	case "django":
+		c.Kind = KindPython
+	default:
+		return nil, fmt.Errorf("no supported app found in %s", root)
+	}
+	return c, nil
+}
+
+// StartOptions returns how to start one app process that writes its coverage
+// to runDir. coverage.py saves its data only on a normal exit, so Python apps
+// are stopped with SIGINT.
+func (c *Collector) StartOptions(runDir string) (runner.StartOptions, error) {
+	if err := os.MkdirAll(runDir, 0o755); err != nil {
+		return runner.StartOptions{}, fmt.Errorf("create coverage directory: %w", err)
+	}
+	switch c.Kind {
+	case KindGoCover:
+		return runner.StartOptions{
+			Env:  []string{"GOCOVERDIR=" + runDir},
+			Wrap: func([]string) []string { return []string{c.binary} },
+		}, nil
+	case KindNodeV8:
+		env, err := nodeEnv(c.dir, runDir)
+		return runner.StartOptions{Env: env}, err
+	default:
+		return runner.StartOptions{Wrap: wrapPython(runDir), StopSignal: os.Interrupt}, nil
+	}
+}
+
+// Read returns the coverage a stopped process wrote to runDir.
+func (c *Collector) Read(runDir string) (Run, error) {
+	switch c.Kind {
+	case KindGoCover:
+		return readGoCover(c.module, runDir)
+	case KindNodeV8:
+		return readNodeV8(c.root, runDir)
+	default:
+		return readPython(c.root, runDir)
+	}
+}
+
+// Attribute credits each route with the functions its process executed that
+// startup did not, and lists the functions and files no process executed.
+func Attribute(kind, dir string, startup Run, routes []RouteRun) Result {
+	res := Result{Kind: kind, Dir: dir}
+	executed := map[Function]bool{}
+	executedFiles := map[string]bool{}
+	universe := map[Function]bool{}
+	files := map[string]bool{}
+	note := func(r Run) {
+		for f, ran := range r.Functions {
+			universe[f] = true
+			if ran {
+				executed[f] = true
+			}
+		}
+		for f, ran := range r.Files {
+			files[f] = true
+			if ran {
+				executedFiles[f] = true
+			}
+		}
+	}
+	note(startup)
+	for _, ran := range startup.Functions {
+		if ran {
+			res.StartupFunctions++
+		}
+	}
+	for _, rr := range routes {
+		note(rr.Run)
+		rc := RouteCoverage{RouteID: rr.RouteID, Method: rr.Method, Path: rr.Path, Invocations: rr.Invocations}
+		seenFiles := map[string]bool{}
+		for f, ran := range rr.Run.Functions {
+			if ran && !startup.Functions[f] {
+				rc.Functions = append(rc.Functions, f)
+				if !seenFiles[f.File] {
+					seenFiles[f.File] = true
+					rc.Files = append(rc.Files, f.File)
+				}
+			}
+		}
+		sortFunctions(rc.Functions)
+		sort.Strings(rc.Files)
+		res.Routes = append(res.Routes, rc)
+	}
+	for f := range universe {
+		if !executed[f] {
+			res.UnexecutedFunctions = append(res.UnexecutedFunctions, f)
+		}
+	}
+	for f := range files {
+		if !executedFiles[f] {
+			res.UnexecutedFiles = append(res.UnexecutedFiles, f)
+		}
+	}
+	sortFunctions(res.UnexecutedFunctions)
+	sort.Strings(res.UnexecutedFiles)
+	return res
+}
+
+func sortFunctions(fns []Function) {
+	sort.Slice(fns, func(i, j int) bool {
+		if fns[i].File != fns[j].File {
+			return fns[i].File < fns[j].File
+		}
+		return fns[i].Line < fns[j].Line
+	})
+}
+
+// sourceFiles lists project files with one of exts, skipping dependency,
+// build and test files, relative to root with forward slashes.
+func sourceFiles(root string, exts ...string) []string {
+	var out []string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			switch d.Name() {
+			case ".git", ".ccc", "node_modules", "vendor", "dist", "build", "bin", "coverage", "__pycache__", ".venv", "venv", "site-packages":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := d.Name()
+		if !hasExt(name, exts) || isTestFile(name) {
+			return nil
+		}
+		if rel, err := filepath.Rel(root, path); err == nil {
+			out = append(out, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return out
+}
+
+func hasExt(name string, exts []string) bool {
+	for _, e := range exts {
+		if strings.HasSuffix(name, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTestFile(name string) bool {
+	return strings.HasSuffix(name, "_test.go") || strings.Contains(name, ".test.") || strings.Contains(name, ".spec.") ||
+		strings.HasPrefix(name, "test_") || strings.HasSuffix(name, "_test.py")
+}
+
+// relToRoot returns path relative to root with forward slashes, when it lies
+// inside root.
+func relToRoot(root, path string) (string, bool) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	rel, err := filepath.Rel(root, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
@@ -0,0 +1,122 @@
+package coverage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseGoFuncsMapsImportPathsToProjectFiles(t *testing.T) {
+	out := `example.com/app/main.go:9:			used		100.0%
+example.com/app/main.go:11:			unused		0.0%
+example.com/app/zz_ccc_coverage_hook.go:11:	init		100.0%
+example.com/app/sub/sub.go:5:		Never		0.0%
+golang.org/x/text/width.go:3:		Lookup		50.0%
+total				(statements)	85.7%
+`
+	run := ParseGoFuncs(out, "example.com/app")
+	want := map[Function]bool{
+		{File: "main.go", Name: "used", Line: 9}:     true,
+		{File: "main.go", Name: "unused", Line: 11}:  false,
+		{File: "sub/sub.go", Name: "Never", Line: 5}: false,
+	}
+	if len(run.Functions) != len(want) {
+		t.Fatalf("unexpected functions: %+v", run.Functions)
+	}
+	for f, ran := range want {
+		if got, ok := run.Functions[f]; !ok || got != ran {
+			t.Fatalf("function %+v: got ran=%t present=%t", f, got, ok)
+		}
+	}
+	if !run.Files["main.go"] || run.Files["sub/sub.go"] {
+		t.Fatalf("unexpected files: %+v", run.Files)
+	}
+}
+
+func TestParseV8CoverageKeysFunctionsBySourceLine(t *testing.T) {
+	root := t.TempDir()
+	src := "const lib = require('./lib');\nfunction used() { return 1; }\n\nfunction unused() { return 2; }\n"
+	path := filepath.Join(root, "server.js")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	type rng struct {
+		StartOffset int `json:"startOffset"`
+		EndOffset   int `json:"endOffset"`
+		Count       int `json:"count"`
+	}
+	type fn struct {
+		FunctionName string `json:"functionName"`
+		Ranges       []rng  `json:"ranges"`
+	}
+	type script struct {
+		URL       string `json:"url"`
+		Functions []fn   `json:"functions"`
+	}
+	data, _ := json.Marshal(map[string]any{"result": []script{
+		{URL: "file://" + path, Functions: []fn{
+			{"", []rng{{0, len(src), 1}}},
+			{"used", []rng{{30, 59, 3}}},
+			{"unused", []rng{{61, 91, 0}}},
+		}},
+		{URL: "node:internal/main", Functions: []fn{{"run", []rng{{0, 10, 1}}}}},
+		{URL: "file://" + filepath.Join(root, "node_modules", "x", "index.js"), Functions: []fn{{"dep", []rng{{0, 10, 0}}}}},
+	}})
+	run := newRun()
+	run.Files["lib.js"] = false
+	if err := ParseV8Coverage(data, root, run); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !run.Functions[Function{File: "server.js", Name: "used", Line: 2}] {
+		t.Fatalf("expected used on line 2 to have run: %+v", run.Functions)
+	}
+	if ran, ok := run.Functions[Function{File: "server.js", Name: "unused", Line: 4}]; !ok || ran {
+		t.Fatalf("expected unused on line 4 to be reported unexecuted: %+v", run.Functions)
+	}
+	if len(run.Functions) != 2 {
+		t.Fatalf("expected only project functions, got %+v", run.Functions)
+	}
+	if !run.Files["server.js"] || run.Files["lib.js"] {
+		t.Fatalf("unexpected files: %+v", run.Files)
+	}
+}
+
+func TestAttributeSubtractsStartupAndFindsUnexecutedCode(t *testing.T) {
+	initFn := Function{File: "main.go", Name: "main", Line: 20}
+	list := Function{File: "orders.go", Name: "listOrders", Line: 5}
+	create := Function{File: "orders.go", Name: "createOrder", Line: 15}
+	legacy := Function{File: "legacy.go", Name: "exportCSV", Line: 3}
+	startup := Run{
+		Functions: map[Function]bool{initFn: true, list: false, create: false, legacy: false},
+		Files:     map[string]bool{"main.go": true, "orders.go": false, "legacy.go": false},
+	}
+	routes := []RouteRun{
+		{RouteID: "list", Method: "GET", Path: "/orders", Invocations: 2, Run: Run{
+			Functions: map[Function]bool{initFn: true, list: true, create: false, legacy: false},
+			Files:     map[string]bool{"main.go": true, "orders.go": true, "legacy.go": false},
+		}},
+	}
+	res := Attribute(KindGoCover, "/tmp/cov", startup, routes)
+	if res.StartupFunctions != 1 {
+		t.Fatalf("expected 1 startup function, got %d", res.StartupFunctions)
+	}
+	if len(res.Routes) != 1 || !slices.Equal(res.Routes[0].Functions, []Function{list}) || !slices.Equal(res.Routes[0].Files, []string{"orders.go"}) {
+		t.Fatalf("unexpected route attribution: %+v", res.Routes)
+	}
+	if !slices.Equal(res.UnexecutedFunctions, []Function{legacy, create}) {
+		t.Fatalf("unexpected unexecuted functions: %+v", res.UnexecutedFunctions)
+	}
+	if !slices.Equal(res.UnexecutedFiles, []string{"legacy.go"}) {
+		t.Fatalf("unexpected unexecuted files: %+v", res.UnexecutedFiles)
+	}
+}
+
+func TestWrapPythonRunsUnderCoverage(t *testing.T) {
+	got := wrapPython("/tmp/run")([]string{"python", "manage.py", "runserver", "127.0.0.1:8000"})
+	want := []string{"python", "-m", "coverage", "run", "--data-file=/tmp/run/.coverage", "manage.py", "runserver", "127.0.0.1:8000", "--noreload"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("unexpected wrapped command:\n got %v\nwant %v", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+package coverage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cool-code-cleanup/internal/runner"
+)
+
+// goHookFile is added to the main package, through a build overlay, only in
+// the coverage binary.
+const goHookFile = "zz_ccc_coverage_hook.go"
+
+// goHook flushes coverage counters on SIGINT/SIGTERM. A -cover binary only
+// writes them when main returns or os.Exit is called, which most servers
+// never do.
+const goHook = `package main
+
+import (
+	"os"
+	"os/signal"
+	"runtime/coverage"
+	"syscall"
+)
+
+func init() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ch
+		if dir := os.Getenv("GOCOVERDIR"); dir != "" {
+			_ = coverage.WriteMetaDir(dir)
+			_ = coverage.WriteCountersDir(dir)
+		}
+		os.Exit(130)
+	}()
+}
+`
+
+var (
+	goModuleLine = regexp.MustCompile(`(?m)^module\s+(\S+)`)
+	goFuncRow    = regexp.MustCompile(`^(\S+):(\d+):\s+(\S+)\s+([\d.]+)%$`)
+)
+
+// buildGoCover builds the app at root with -cover into dir and returns the
+// module path and binary.
+func buildGoCover(root, dir string) (string, string, error) {
+	mod, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return "", "", fmt.Errorf("read go.mod: %w", err)
+	}
+	m := goModuleLine.FindSubmatch(mod)
+	if m == nil {
+		return "", "", fmt.Errorf("no module line in %s", filepath.Join(root, "go.mod"))
+	}
+	binary := filepath.Join(dir, "app.cover")
+	if err := runner.BuildGo(root, binary, filepath.Join(dir, "build"), []string{"-cover"}, map[string]string{goHookFile: goHook}); err != nil {
+		return "", "", err
+	}
+	return string(m[1]), binary, nil
+}
+
+func readGoCover(module, runDir string) (Run, error) {
+	entries, _ := filepath.Glob(filepath.Join(runDir, "covcounters.*"))
+	if len(entries) == 0 {
+		return Run{}, errors.New("no coverage counters written to " + runDir)
+	}
+	out, err := exec.Command("go", "tool", "covdata", "func", "-i="+runDir).Output()
+	if err != nil {
+		return Run{}, fmt.Errorf("go tool covdata: %w", err)
+	}
+	return ParseGoFuncs(string(out), module), nil
+}
+
+// ParseGoFuncs parses `go tool covdata func` output. Files are reported by
+// import path; those outside module are dropped.
+func ParseGoFuncs(text, module string) Run {
+	run := newRun()
+	sc := bufio.NewScanner(strings.NewReader(text))
+	for sc.Scan() {
+		m := goFuncRow.FindStringSubmatch(strings.TrimSpace(sc.Text()))
+		if m == nil {
+			continue
+		}
+		rel, ok := strings.CutPrefix(m[1], module+"/")
+		if !ok || rel == goHookFile {
+			continue
+		}
+		line, _ := strconv.Atoi(m[2])
+		pct, _ := strconv.ParseFloat(m[4], 64)
+		ran := pct > 0
+		run.Functions[Function{File: rel, Name: m[3], Line: line}] = ran
+		run.Files[rel] = run.Files[rel] || ran
+	}
+	return run
+}
@@ -0,0 +1,129 @@
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"cool-code-cleanup/internal/perf"
+)
+
+var nodeExts = []string{".js", ".mjs", ".cjs", ".ts", ".mts", ".cts"}
+
+type v8Coverage struct {
+	Result []struct {
+		URL       string `json:"url"`
+		Functions []struct {
+			FunctionName string `json:"functionName"`
+			Ranges       []struct {
+				StartOffset int `json:"startOffset"`
+				EndOffset   int `json:"endOffset"`
+				Count       int `json:"count"`
+			} `json:"ranges"`
+		} `json:"functions"`
+	} `json:"result"`
+}
+
+// nodeEnv points NODE_V8_COVERAGE at runDir and loads the exit hook, which
+// is written once to dir.
+func nodeEnv(dir, runDir string) ([]string, error) {
+	hook, err := perf.WriteNodeExitHook(dir)
+	if err != nil {
+		return nil, err
+	}
+	opts := strings.TrimSpace(os.Getenv("NODE_OPTIONS") + fmt.Sprintf(" --require=%q", hook))
+	return []string{"NODE_V8_COVERAGE=" + runDir, "NODE_OPTIONS=" + opts}, nil
+}
+
+// readNodeV8 reads the V8 coverage files in runDir. Project source files the
+// process never loaded are reported as not executed.
+func readNodeV8(root, runDir string) (Run, error) {
+	paths, err := filepath.Glob(filepath.Join(runDir, "coverage-*.json"))
+	if err != nil {
+		return Run{}, err
+	}
+	if len(paths) == 0 {
+		return Run{}, fmt.Errorf("no V8 coverage written to %s (the app must exit normally to write it)", runDir)
+	}
+	run := newRun()
+	for _, f := range sourceFiles(root, nodeExts...) {
+		run.Files[f] = false
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Run{}, fmt.Errorf("read %s: %w", path, err)
+		}
+		if err := ParseV8Coverage(data, root, run); err != nil {
+			return Run{}, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	return run, nil
+}
+
+// ParseV8Coverage adds the project functions in one NODE_V8_COVERAGE file to
+// run. The script's top-level function marks the file as loaded; every other
+// function is keyed by name and declaration line, read from the source.
+func ParseV8Coverage(data []byte, root string, run Run) error {
+	var cov v8Coverage
+	if err := json.Unmarshal(data, &cov); err != nil {
+		return err
+	}
+	for _, script := range cov.Result {
+		path, ok := strings.CutPrefix(script.URL, "file://")
+		if !ok {
+			continue
+		}
+		rel, ok := relToRoot(root, path)
+		if !ok || !hasExt(rel, nodeExts) || strings.Contains(rel, "node_modules/") {
+			continue
+		}
+		src, _ := os.ReadFile(path)
+		lines := newLineIndex(string(src))
+		for _, fn := range script.Functions {
+			if len(fn.Ranges) == 0 {
+				continue
+			}
+			r := fn.Ranges[0]
+			ran := r.Count > 0
+			if fn.FunctionName == "" && r.StartOffset == 0 {
+				run.Files[rel] = run.Files[rel] || ran
+				continue
+			}
+			name := fn.FunctionName
+			if name == "" {
+				name = "(anonymous)"
+			}
+			f := Function{File: rel, Name: name, Line: lines.line(r.StartOffset)}
+			run.Functions[f] = run.Functions[f] || ran
+		}
+	}
+	return nil
+}
+
+// lineIndex maps V8 source offsets (UTF-16 code units) to 1-based lines.
+type lineIndex []int
+
+func newLineIndex(src string) lineIndex {
+	var starts lineIndex
+	for i, u := range utf16.Encode([]rune(src)) {
+		if u == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+func (l lineIndex) line(offset int) int {
+	n := 1
+	for _, start := range l {
+		if start > offset {
+			break
+		}
+		n++
+	}
+	return n
+}
@@ -0,0 +1,99 @@
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// pythonReport prints, for every project .py file (argv[2] lists them,
+// newline-separated, relative to argv[1]), whether it ran and which of its
+// functions ran, from the coverage.py data file in argv[3]. A function ran
+// when any line of its body did.
+const pythonReport = `import ast, json, os, sys
+import coverage
+root, files, data_file = sys.argv[1], sys.argv[2].splitlines(), sys.argv[3]
+data = coverage.CoverageData(basename=data_file)
+data.read()
+measured = {os.path.realpath(f): set(data.lines(f) or []) for f in data.measured_files()}
+out = {"files": {}, "functions": []}
+for rel in files:
+    path = os.path.realpath(os.path.join(root, rel))
+    lines = measured.get(path, set())
+    out["files"][rel] = bool(lines)
+    try:
+        tree = ast.parse(open(path, encoding="utf-8").read())
+    except Exception:
+        continue
+    for node in ast.walk(tree):
+        if isinstance(node, (ast.FunctionDef, ast.AsyncFunctionDef)):
+            body = range(node.body[0].lineno, (node.end_lineno or node.lineno) + 1)
+            out["functions"].append({"file": rel, "name": node.name, "line": node.lineno, "ran": any(l in lines for l in body)})
+print(json.dumps(out))
+`
+
+func pythonDataFile(runDir string) string {
+	return filepath.Join(runDir, ".coverage")
+}
+
+// wrapPython runs a Python launch command under coverage.py. Django's
+// autoreloader is turned off so the measured process is the one serving.
+func wrapPython(runDir string) func([]string) []string {
+	return func(argv []string) []string {
+		if len(argv) == 0 || !strings.HasPrefix(filepath.Base(argv[0]), "python") {
+			return argv
+		}
+		out := []string{argv[0], "-m", "coverage", "run", "--data-file=" + pythonDataFile(runDir)}
+		out = append(out, argv[1:]...)
+		if slices.Contains(argv, "runserver") && !slices.Contains(argv, "--noreload") {
+			out = append(out, "--noreload")
+		}
+		return out
+	}
+}
+
+func readPython(root, runDir string) (Run, error) {
+	dataFile := pythonDataFile(runDir)
+	if _, err := os.Stat(dataFile); err != nil {
+		return Run{}, fmt.Errorf("no coverage.py data written to %s (is coverage installed?)", runDir)
+	}
+	python, err := exec.LookPath("python")
+	if err != nil {
+		if python, err = exec.LookPath("python3"); err != nil {
+			return Run{}, fmt.Errorf("python not found to read %s", dataFile)
+		}
+	}
+	files := sourceFiles(root, ".py")
+	out, err := exec.Command(python, "-c", pythonReport, root, strings.Join(files, "\n"), dataFile).Output()
+	if err != nil {
+		return Run{}, fmt.Errorf("read coverage.py data %s: %w", dataFile, err)
+	}
+	return parsePythonReport(out)
+}
+
+func parsePythonReport(data []byte) (Run, error) {
+	var parsed struct {
+		Files     map[string]bool `json:"files"`
+		Functions []struct {
+			File string `json:"file"`
+			Name string `json:"name"`
+			Line int    `json:"line"`
+			Ran  bool   `json:"ran"`
+		} `json:"functions"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return Run{}, fmt.Errorf("parse coverage.py report: %w", err)
+	}
+	run := newRun()
+	for f, ran := range parsed.Files {
+		run.Files[f] = ran
+	}
+	for _, fn := range parsed.Functions {
+		run.Functions[Function{File: fn.File, Name: fn.Name, Line: fn.Line}] = fn.Ran
+	}
+	return run, nil
+}
@@ -11,17 +11,21 @@ type Graph struct {
 	Dependencies map[string][]string `json:"dependencies"`
 	Confidence   string              `json:"confidence"`
 	Rationale    string              `json:"rationale"`
+	SourceChain  []string            `json:"source_chain,omitempty"`
+	Sequences    []ResolvedSequence  `json:"sequences,omitempty"`
+	Warnings     []string            `json:"warnings,omitempty"`
 }
 
 type Fallback interface {
 	Infer(routes []discovery.Route) (Graph, error)
 }
 
-func Detect(routes []discovery.Route, fallback Fallback) (Graph, error) {
+func Detect(routes []discovery.Route, fallback Fallback, overrides *Overrides) (Graph, error) {
 	g := Graph{
 		Dependencies: map[string][]string{},
 		Confidence:   "high",
 		Rationale:    "deterministic heuristics",
+		SourceChain:  []string{SourceHeuristics},
 	}
 
 	authRoutes := findAuthRoutes(routes)
@@ -34,11 +38,13 @@ func Detect(routes []discovery.Route, fallback Fallback) (Graph, error) {
 	}
 
 	if len(routes) == 0 || fallback == nil {
+		applyOverrides(&g, routes, overrides)
 		return g, nil
 	}
 
 	fg, err := fallback.Infer(routes)
 	if err != nil {
+		applyOverrides(&g, routes, overrides)
 		return g, err
 	}
 
@@ -46,8 +52,10 @@ func Detect(routes []discovery.Route, fallback Fallback) (Graph, error) {
 	mergeDependencies(g.Dependencies, fg.Dependencies)
 	added := dependencyEdgeCount(g.Dependencies) - before
 	if added == 0 {
+		applyOverrides(&g, routes, overrides)
 		return g, nil
 	}
+	g.SourceChain = append(g.SourceChain, SourceAI)
 
 	rationale := strings.TrimSpace(fg.Rationale)
 	if rationale == "" {
@@ -56,10 +64,11 @@ func Detect(routes []discovery.Route, fallback Fallback) (Graph, error) {
 	if before > 0 {
 		g.Confidence = "medium"
 		g.Rationale = "deterministic heuristics + " + rationale
-		return g, nil
+	} else {
+		g.Confidence = "low"
+		g.Rationale = rationale
 	}
-	g.Confidence = "low"
-	g.Rationale = rationale
+	applyOverrides(&g, routes, overrides)
 	return g, nil
 }
 
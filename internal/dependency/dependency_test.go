@@ -2,6 +2,7 @@ package dependency
 
 import (
 	"fmt"
+	"path/filepath"
 	"testing"
 
 	"cool-code-cleanup/internal/discovery"
@@ -12,7 +13,7 @@ func TestDetectAuthDependencyDeterministic(t *testing.T) {
 		{ID: "r1", Method: "POST", Path: "/auth/login"},
 		{ID: "r2", Method: "GET", Path: "/account/private"},
 	}
-	g, err := Detect(routes, nil)
+	g, err := Detect(routes, nil, nil)
 	if err != nil {
 		t.Fatalf("detect failed: %v", err)
 	}
@@ -34,7 +35,7 @@ func TestDetectMergesAIDependencies(t *testing.T) {
 			},
 			Rationale: "ai inferred auth bootstrap",
 		},
-	})
+	}, nil)
 	if err != nil {
 		t.Fatalf("detect failed: %v", err)
 	}
@@ -51,7 +52,7 @@ func TestDetectReturnsDeterministicGraphOnFallbackError(t *testing.T) {
 		{ID: "r1", Method: "POST", Path: "/auth/login"},
 		{ID: "r2", Method: "GET", Path: "/account/private"},
 	}
-	g, err := Detect(routes, fakeFallback{err: fmt.Errorf("timeout")})
+	g, err := Detect(routes, fakeFallback{err: fmt.Errorf("timeout")}, nil)
 	if err == nil {
 		t.Fatalf("expected fallback error")
 	}
@@ -71,3 +72,47 @@ func (f fakeFallback) Infer(_ []discovery.Route) (Graph, error) {
 	}
 	return f.graph, nil
 }
+
+func TestDetectAppliesOverridesWithHighestPrecedence(t *testing.T) {
+	routes := []discovery.Route{
+		{ID: "r1", Method: "POST", Path: "/auth/login"},
+		{ID: "r2", Method: "GET", Path: "/account/private"},
+		{ID: "r3", Method: "POST", Path: "/tenant/select"},
+		{ID: "r4", Method: "GET", Path: "/reports/daily"},
+	}
+	g, err := Detect(routes, fakeFallback{
+		graph: Graph{Dependencies: map[string][]string{"r4": {"r2"}}},
+	}, &Overrides{
+		SchemaVersion: OverridesSchemaVersion,
+		Forbidden:     []Edge{{Route: "GET /reports/daily", DependsOn: "r2"}},
+		Sequences: []Sequence{
+			{Name: "login then select tenant", Steps: []string{"/tenant/select", "POST /auth/login"}, AppliesTo: []string{"/reports/*", "/account/private"}},
+		},
+		Required: []Edge{{Route: "/missing", DependsOn: "r1"}},
+	})
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+	if got := g.Dependencies["r2"]; len(got) != 2 || got[0] != "r3" || got[1] != "r1" {
+		t.Fatalf("expected sequence order r3,r1 for r2, got %v", got)
+	}
+	if got := g.Dependencies["r4"]; len(got) != 2 || got[0] != "r3" || got[1] != "r1" {
+		t.Fatalf("expected forbidden edge removed and sequence applied for r4, got %v", got)
+	}
+	if got := g.Dependencies["r1"]; len(got) != 1 || got[0] != "r3" {
+		t.Fatalf("expected sequence step r1 to depend on r3, got %v", got)
+	}
+	if got := g.SourceChain; len(got) != 3 || got[2] != SourceOverrides {
+		t.Fatalf("expected overrides at end of source chain, got %v", got)
+	}
+	if len(g.Sequences) != 1 || len(g.Warnings) != 1 {
+		t.Fatalf("expected one resolved sequence and one warning, got %+v / %v", g.Sequences, g.Warnings)
+	}
+}
+
+func TestLoadOverridesMissingFile(t *testing.T) {
+	o, found, err := LoadOverrides(filepath.Join(t.TempDir(), "dependencies.json"))
+	if err != nil || found || o != nil {
+		t.Fatalf("expected missing overrides to be ignored, got %v %v %v", o, found, err)
+	}
+}
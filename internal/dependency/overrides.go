@@ -0,0 +1,204 @@
+package dependency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cool-code-cleanup/internal/discovery"
+)
+
+const (
+	OverridesSchemaVersion = 1
+
+	SourceHeuristics = "heuristics"
+	SourceAI         = "ai_fallback"
+	SourceOverrides  = "overrides"
+)
+
+// Edge declares that Route requires DependsOn to run first. Both sides accept a
+// route ID, "METHOD /path" or a bare "/path"; paths may use path.Match globs.
+type Edge struct {
+	Route     string `json:"route"`
+	DependsOn string `json:"depends_on"`
+}
+
+// Sequence is a named, ordered setup flow (for example "login then select
+// tenant") that must run before every route matched by AppliesTo.
+type Sequence struct {
+	Name      string   `json:"name"`
+	Steps     []string `json:"steps"`
+	AppliesTo []string `json:"applies_to"`
+}
+
+type Overrides struct {
+	SchemaVersion int        `json:"schema_version"`
+	Required      []Edge     `json:"required"`
+	Forbidden     []Edge     `json:"forbidden"`
+	Sequences     []Sequence `json:"sequences"`
+}
+
+// ResolvedSequence is a Sequence with its references resolved to route IDs.
+type ResolvedSequence struct {
+	Name      string   `json:"name"`
+	Steps     []string `json:"steps"`
+	AppliesTo []string `json:"applies_to"`
+}
+
+func DefaultOverridesPath() string {
+	return filepath.Join(".ccc", "dependencies.json")
+}
+
+// LoadOverrides reads an optional overrides file. A missing file is not an error.
+func LoadOverrides(path string) (*Overrides, bool, error) {
+	clean := filepath.Clean(path)
+	data, err := os.ReadFile(clean)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("read dependency overrides %s: %w", clean, err)
+	}
+	var o Overrides
+	if err := json.Unmarshal(data, &o); err != nil {
+		return nil, false, fmt.Errorf("parse dependency overrides %s: %w", clean, err)
+	}
+	if o.SchemaVersion != OverridesSchemaVersion {
+		return nil, false, fmt.Errorf("unsupported dependency overrides schema_version=%d (expected %d)", o.SchemaVersion, OverridesSchemaVersion)
+	}
+	return &o, true, nil
+}
+
+// applyOverrides merges declared edges into g with highest precedence: required
+// edges and sequences are added, then forbidden edges are removed regardless
+// of which source produced them.
+func applyOverrides(g *Graph, routes []discovery.Route, o *Overrides) {
+	if o == nil {
+		return
+	}
+	changed := false
+	for _, e := range o.Required {
+		froms := g.resolve(routes, e.Route, "required route")
+		tos := g.resolve(routes, e.DependsOn, "required depends_on")
+		for _, from := range froms {
+			for _, to := range tos {
+				if from == to {
+					continue
+				}
+				if !slices.Contains(g.Dependencies[from], to) {
+					g.Dependencies[from] = append(g.Dependencies[from], to)
+					changed = true
+				}
+			}
+		}
+	}
+
+	for _, s := range o.Sequences {
+		var steps []string
+		for _, ref := range s.Steps {
+			for _, id := range g.resolve(routes, ref, "sequence "+s.Name+" step") {
+				steps = appendIfMissing(steps, id)
+			}
+		}
+		var targets []string
+		for _, ref := range s.AppliesTo {
+			for _, id := range g.resolve(routes, ref, "sequence "+s.Name+" applies_to") {
+				if !slices.Contains(steps, id) {
+					targets = appendIfMissing(targets, id)
+				}
+			}
+		}
+		if len(steps) == 0 {
+			continue
+		}
+		for i, step := range steps {
+			if i > 0 {
+				g.Dependencies[step] = prependOrdered(g.Dependencies[step], steps[:i])
+			}
+		}
+		for _, target := range targets {
+			g.Dependencies[target] = prependOrdered(g.Dependencies[target], steps)
+		}
+		g.Sequences = append(g.Sequences, ResolvedSequence{Name: s.Name, Steps: steps, AppliesTo: targets})
+		changed = true
+	}
+
+	for _, e := range o.Forbidden {
+		froms := g.resolve(routes, e.Route, "forbidden route")
+		tos := g.resolve(routes, e.DependsOn, "forbidden depends_on")
+		for _, from := range froms {
+			before := len(g.Dependencies[from])
+			g.Dependencies[from] = slices.DeleteFunc(g.Dependencies[from], func(dep string) bool {
+				return slices.Contains(tos, dep)
+			})
+			if len(g.Dependencies[from]) != before {
+				changed = true
+			}
+			if len(g.Dependencies[from]) == 0 {
+				delete(g.Dependencies, from)
+			}
+		}
+	}
+
+	if changed {
+		g.SourceChain = appendIfMissing(g.SourceChain, SourceOverrides)
+		g.Rationale += " + declared overrides"
+	}
+}
+
+// prependOrdered places ordered at the front of deps (in order) and keeps any
+// remaining existing dependencies after them.
+func prependOrdered(deps []string, ordered []string) []string {
+	out := slices.Clone(ordered)
+	for _, d := range deps {
+		out = appendIfMissing(out, d)
+	}
+	return out
+}
+
+func (g *Graph) resolve(routes []discovery.Route, ref, context string) []string {
+	ids := MatchRoutes(routes, ref)
+	if len(ids) == 0 {
+		g.Warnings = append(g.Warnings, fmt.Sprintf("dependency override %s %q matched no routes", context, ref))
+	}
+	return ids
+}
+
+// MatchRoutes returns the IDs of routes matched by ref: a route ID,
+// "METHOD /path" or a bare "/path", where paths may use path.Match globs.
+func MatchRoutes(routes []discovery.Route, ref string) []string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil
+	}
+	for _, r := range routes {
+		if r.ID == ref {
+			return []string{r.ID}
+		}
+	}
+	method := ""
+	pattern := ref
+	if fields := strings.Fields(ref); len(fields) == 2 {
+		method = strings.ToUpper(fields[0])
+		pattern = fields[1]
+	}
+	var out []string
+	for _, r := range routes {
+		if method != "" && !strings.EqualFold(r.Method, method) {
+			continue
+		}
+		if r.Path == pattern {
+			out = append(out, r.ID)
+			continue
+		}
+		if ok, err := path.Match(pattern, r.Path); err == nil && ok {
+			out = append(out, r.ID)
+		}
+	}
+	return out
+}
@@ -16,6 +16,7 @@ type Route struct {
 	File       string   `json:"file"`
 	Handler    string   `json:"handler"`
 	Framework  string   `json:"framework"`
+	Line       int      `json:"line,omitempty"`
 	Middleware []string `json:"middleware,omitempty"`
 }
 
@@ -73,6 +74,7 @@ func scanFile(path string) ([]Route, error) {
 				File:      path,
 				Handler:   "inline_handler",
 				Framework: "node",
+				Line:      lineNo,
 			})
 		}
 		for _, m := range reGoHTTP.FindAllStringSubmatch(line, -1) {
@@ -83,6 +85,7 @@ func scanFile(path string) ([]Route, error) {
 				File:      path,
 				Handler:   "handler",
 				Framework: "go",
+				Line:      lineNo,
 			})
 		}
 		for _, m := range reDjango.FindAllStringSubmatch(line, -1) {
@@ -93,6 +96,7 @@ func scanFile(path string) ([]Route, error) {
 				File:      path,
 				Handler:   m[2],
 				Framework: "django",
+				Line:      lineNo,
 			})
 		}
 	}
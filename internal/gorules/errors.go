@@ -0,0 +1,507 @@
+package gorules
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// discardedErrors propagates errors dropped by a bare call or an
+// assignment to "_", with the call as context, when the enclosing function
+// returns an error:
+//
+//	os.Remove(p)        =>  if err := os.Remove(p); err != nil { return fmt.Errorf("os.Remove: %w", err) }
+//	v, _ := strconv.Atoi(s)  =>  v, err := strconv.Atoi(s); if err != nil { return 0, fmt.Errorf(...) }
+//
+// Where that is not possible it records a finding instead, as it does for
+// explicit discards, where every result is assigned to "_", and for calls in
+// a block that already returns an error, such as cleanup before returning
+// it, where propagating would lose that error.
+func discardedErrors(r *rewriter) {
+	inspectStmtLists(r.file.ast, func(list []ast.Stmt) {
+		returning := returnsError(list)
+		for _, stmt := range list {
+			var call *ast.CallExpr
+			var lhs []ast.Expr
+			define := false
+			switch s := stmt.(type) {
+			case *ast.ExprStmt:
+				call, _ = ast.Unparen(s.X).(*ast.CallExpr)
+			case *ast.AssignStmt:
+				if len(s.Rhs) != 1 {
+					continue
+				}
+				call, _ = ast.Unparen(s.Rhs[0]).(*ast.CallExpr)
+				lhs, define = s.Lhs, s.Tok == token.DEFINE
+			}
+			if call == nil || uncheckedByConvention(r, call) {
+				continue
+			}
+			i := r.errorResult(call)
+			if i < 0 || (lhs != nil && (i >= len(lhs) || !isBlank(lhs[i]))) {
+				continue
+			}
+			how := "ignored"
+			if lhs != nil {
+				how = "assigned to _"
+			}
+			if allBlank(lhs) && lhs != nil {
+				r.note(stmt, "error returned by %s is %s (an explicit discard)", callName(call), how)
+				continue
+			}
+			if returning {
+				r.note(stmt, "error returned by %s is %s (the block returns another error)", callName(call), how)
+				continue
+			}
+			if reason := r.propagate(stmt, call, lhs, i, define); reason != "" {
+				r.note(stmt, "error returned by %s is %s (%s)", callName(call), how, reason)
+			}
+		}
+	})
+}
+
+// propagate rewrites stmt, which drops the error result i of call, to
+// return it wrapped. It returns why it could not.
+func (r *rewriter) propagate(stmt ast.Stmt, call *ast.CallExpr, lhs []ast.Expr, i int, define bool) string {
+	sig := r.enclosingSignature(stmt)
+	if sig == nil || sig.Results().Len() == 0 || !isError(sig.Results().At(sig.Results().Len()-1).Type()) {
+		return "the enclosing function does not return an error"
+	}
+	var results []string
+	for j := 0; j < sig.Results().Len()-1; j++ {
+		zero, ok := r.zeroValue(sig.Results().At(j).Type())
+		if !ok {
+			return "no zero value can be written for the function's other results"
+		}
+		results = append(results, zero)
+	}
+	fmtName, ok := r.fmtName(stmt.Pos())
+	if !ok {
+		return "fmt cannot be imported under its name"
+	}
+	if r.hasComments(stmt.Pos(), stmt.End()) {
+		return "a comment would be lost"
+	}
+	context := types.ExprString(call.Fun)
+	results = append(results, fmtName+".Errorf("+strconv.Quote(context+": %w")+", err)")
+	indent := r.indent(stmt)
+	check := "err != nil {\n" + indent + "\treturn " + strings.Join(results, ", ") + "\n" + indent + "}"
+
+	r.describe("returned the error of %s with context", context)
+	r.require("fmt")
+	if allBlank(lhs) {
+		// "if err := ..." scopes err to the check, so no err around it is
+		// shadowed for longer or overwritten.
+		n := 1
+		if tuple, ok := r.typeOf(call).(*types.Tuple); ok {
+			n = tuple.Len()
+		}
+		vars := make([]string, n)
+		for j := range vars {
+			vars[j] = "_"
+		}
+		vars[i] = "err"
+		r.replaceStmt(stmt, stmt, "if "+strings.Join(vars, ", ")+" := "+r.src(call)+"; "+check)
+		return ""
+	}
+	// v, _ := f() keeps v in scope, so err is declared next to it; it must
+	// not exist yet, or be declared later in the same block.
+	if !define {
+		return "assignment with = would overwrite an existing err"
+	}
+	scope := r.scopeAt(stmt.Pos())
+	if scope == nil {
+		return "the code does not type-check"
+	}
+	if _, obj := scope.LookupParent("err", stmt.Pos()); obj != nil || scope.Lookup("err") != nil {
+		return "err is already declared here"
+	}
+	start, blank := r.offset(stmt.Pos()), lhs[i]
+	text := string(r.file.src[start:r.offset(blank.Pos())]) + "err" + string(r.file.src[r.offset(blank.End()):r.offset(stmt.End())])
+	r.replaceStmt(stmt, stmt, text+"\n"+indent+"if "+check)
+	return ""
+}
+
+// errorfWrap makes fmt.Errorf wrap the error it formats with %v or %s:
+//
+//	fmt.Errorf("load %s: %v", name, err)  =>  fmt.Errorf("load %s: %w", name, err)
+//
+// Calls formatting several errors, or one with another verb, are findings.
+func errorfWrap(r *rewriter) {
+	ast.Inspect(r.file.ast, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !r.isFunc(call.Fun, "fmt", "Errorf") || len(call.Args) == 0 || call.Ellipsis.IsValid() {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		verbs, ok := scanVerbs(lit.Value)
+		if !ok {
+			return true
+		}
+		var errs []formatVerb
+		for _, v := range verbs {
+			if v.arg+1 < len(call.Args) && r.isErrorValue(call.Args[v.arg+1]) {
+				if v.verb == 'w' {
+					return true
+				}
+				errs = append(errs, v)
+			}
+		}
+		switch {
+		case len(errs) == 0:
+		case len(errs) == 1 && (errs[0].verb == 'v' || errs[0].verb == 's'):
+			at := lit.Pos() + token.Pos(errs[0].offset)
+			r.describe("wrapped %s with %%w in fmt.Errorf", types.ExprString(call.Args[errs[0].arg+1]))
+			r.replace(at, at+1, "w")
+		case len(errs) == 1:
+			r.note(call, "fmt.Errorf formats %s with %%%c instead of wrapping it with %%w", types.ExprString(call.Args[errs[0].arg+1]), errs[0].verb)
+		default:
+			r.note(call, "fmt.Errorf formats %d errors without %%w", len(errs))
+		}
+		return true
+	})
+}
+
+// errShadowing points out err variables that hide an err of the same
+// function, so an assignment meant for the outer one is lost. Declarations
+// in if, switch and for headers are left: they are scoped to the statement
+// by design.
+func errShadowing(r *rewriter) {
+	ast.Inspect(r.file.ast, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || id.Name != "err" {
+			return true
+		}
+		obj, ok := r.pkg.info.Defs[id].(*types.Var)
+		if !ok || obj.Parent() == nil || obj.Parent().Parent() == nil || r.inHeader(id) {
+			return true
+		}
+		if _, isField := r.parent(id).(*ast.Field); isField {
+			return true
+		}
+		_, outer := obj.Parent().Parent().LookupParent("err", id.Pos())
+		if v, ok := outer.(*types.Var); ok && r.isLocal(v) {
+			r.note(id, "err shadows the err declared at line %d", r.pkg.fset.Position(v.Pos()).Line)
+		}
+		return true
+	})
+}
+
+// inHeader reports whether id is declared in the init statement of an if,
+// switch or for.
+func (r *rewriter) inHeader(id *ast.Ident) bool {
+	assign, ok := r.parent(id).(*ast.AssignStmt)
+	if !ok {
+		return false
+	}
+	switch p := r.parent(assign).(type) {
+	case *ast.IfStmt:
+		return p.Init == assign
+	case *ast.SwitchStmt:
+		return p.Init == assign
+	case *ast.TypeSwitchStmt:
+		return p.Init == assign
+	case *ast.ForStmt:
+		return p.Init == assign
+	}
+	return false
+}
+
+// isLocal reports whether v is declared in a function.
+func (r *rewriter) isLocal(v *types.Var) bool {
+	return v.Parent() != nil && v.Parent() != types.Universe && (r.pkg.types == nil || v.Parent() != r.pkg.types.Scope()) && v.Parent().Parent() != types.Universe
+}
+
+// inspectStmtLists calls fn with every statement list in f.
+func inspectStmtLists(f *ast.File, fn func([]ast.Stmt)) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch b := n.(type) {
+		case *ast.BlockStmt:
+			fn(b.List)
+		case *ast.CaseClause:
+			fn(b.Body)
+		case *ast.CommClause:
+			fn(b.Body)
+		}
+		return true
+	})
+}
+
+// enclosingSignature returns the signature of the function n is in.
+func (r *rewriter) enclosingSignature(n ast.Node) *types.Signature {
+	for p := r.parent(n); p != nil; p = r.parent(p) {
+		switch f := p.(type) {
+		case *ast.FuncLit:
+			sig, _ := r.typeOf(f).(*types.Signature)
+			return sig
+		case *ast.FuncDecl:
+			if obj := r.pkg.info.Defs[f.Name]; obj != nil {
+				sig, _ := obj.Type().(*types.Signature)
+				return sig
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// scopeAt returns the innermost scope at pos.
+func (r *rewriter) scopeAt(pos token.Pos) *types.Scope {
+	if r.pkg.types == nil {
+		return nil
+	}
+	return r.pkg.types.Scope().Innermost(pos)
+}
+
+// fmtName returns the name fmt is imported under in the file, adding the
+// import when the name is free at pos.
+func (r *rewriter) fmtName(pos token.Pos) (string, bool) {
+	switch name := r.importName("fmt"); name {
+	case "_", ".":
+		return "", false
+	case "":
+	default:
+		return name, true
+	}
+	scope := r.scopeAt(pos)
+	if scope == nil {
+		return "", false
+	}
+	if _, obj := scope.LookupParent("fmt", pos); obj != nil {
+		return "", false
+	}
+	return "fmt", true
+}
+
+// zeroValue returns the zero value of t as the file can write it.
+func (r *rewriter) zeroValue(t types.Type) (string, bool) {
+	if _, ok := t.(*types.TypeParam); ok {
+		name, ok := r.typeExpr(t)
+		return "*new(" + name + ")", ok
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false", true
+		case u.Info()&types.IsString != 0:
+			return `""`, true
+		case u.Info()&types.IsNumeric != 0:
+			return "0", true
+		case u.Kind() == types.UnsafePointer:
+			return "nil", true
+		}
+	case *types.Pointer, *types.Slice, *types.Map, *types.Chan, *types.Signature, *types.Interface:
+		return "nil", true
+	case *types.Struct, *types.Array:
+		name, ok := r.typeExpr(t)
+		return name + "{}", ok
+	}
+	return "", false
+}
+
+// typeExpr writes t with the package names the file imports.
+func (r *rewriter) typeExpr(t types.Type) (string, bool) {
+	ok := true
+	s := types.TypeString(t, func(p *types.Package) string {
+		if p == r.pkg.types {
+			return ""
+		}
+		name := r.importName(p.Path())
+		if name == "" || name == "_" || name == "." {
+			ok = false
+		}
+		return name
+	})
+	return s, ok
+}
+
+// indent returns the whitespace before n on its line.
+func (r *rewriter) indent(n ast.Node) string {
+	start := r.offset(n.Pos())
+	lineStart := strings.LastIndexByte(string(r.file.src[:start]), '\n') + 1
+	if ws := string(r.file.src[lineStart:start]); strings.TrimSpace(ws) == "" {
+		return ws
+	}
+	return ""
+}
+
+// isFunc reports whether e names the function pkg.name.
+func (r *rewriter) isFunc(e ast.Expr, pkg, name string) bool {
+	sel, ok := e.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	fn, ok := r.pkg.info.Uses[sel.Sel].(*types.Func)
+	return ok && fn.Pkg() != nil && fn.Pkg().Path() == pkg && fn.Name() == name
+}
+
+// isErrorValue reports whether e is a value implementing error.
+func (r *rewriter) isErrorValue(e ast.Expr) bool {
+	t := r.typeOf(e)
+	if t == nil || r.isUniverse(e, "nil") {
+		return false
+	}
+	return types.Implements(t, types.Universe.Lookup("error").Type().Underlying().(*types.Interface))
+}
+
+// formatVerb is a verb of a format string: its letter, the offset of the
+// letter in the string literal and the index of the argument it formats.
+type formatVerb struct {
+	verb   byte
+	offset int
+	arg    int
+}
+
+// scanVerbs returns the verbs of the format string literal lit. It fails
+// for explicit argument indexes and for escapes that could spell a '%'.
+func scanVerbs(lit string) ([]formatVerb, bool) {
+	if strings.Contains(lit, `\x25`) || strings.Contains(lit, `\045`) || strings.Contains(lit, `\u0025`) || strings.Contains(lit, `\U00000025`) {
+		return nil, false
+	}
+	var verbs []formatVerb
+	arg := 0
+	raw := strings.HasPrefix(lit, "`")
+	for i := 1; i < len(lit)-1; i++ {
+		if !raw && lit[i] == '\\' {
+			i++
+			continue
+		}
+		if lit[i] != '%' {
+			continue
+		}
+		i++
+		for i < len(lit)-1 && strings.IndexByte("+-# 0", lit[i]) >= 0 {
+			i++
+		}
+		for i < len(lit)-1 && (lit[i] >= '0' && lit[i] <= '9' || lit[i] == '.' || lit[i] == '*') {
+			if lit[i] == '*' {
+				arg++
+			}
+			i++
+		}
+		if i >= len(lit)-1 {
+			break
+		}
+		switch lit[i] {
+		case '%':
+			continue
+		case '[':
+			return nil, false
+		}
+		verbs = append(verbs, formatVerb{verb: lit[i], offset: i, arg: arg})
+		arg++
+	}
+	return verbs, true
+}
+
+// errorResult returns the index of call's last result when it is an error,
+// or -1.
+func (r *rewriter) errorResult(call *ast.CallExpr) int {
+	t := r.typeOf(call)
+	if t == nil {
+		return -1
+	}
+	if tuple, ok := t.(*types.Tuple); ok {
+		if tuple.Len() > 0 && isError(tuple.At(tuple.Len()-1).Type()) {
+			return tuple.Len() - 1
+		}
+		return -1
+	}
+	if isError(t) {
+		return 0
+	}
+	return -1
+}
+
+func isError(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+func isBlank(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+func allBlank(exprs []ast.Expr) bool {
+	for _, e := range exprs {
+		if !isBlank(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// returnsError reports whether list returns something other than nil as its
+// last result, as in "if err != nil { ...; return err }".
+func returnsError(list []ast.Stmt) bool {
+	for _, stmt := range list {
+		ret, ok := stmt.(*ast.ReturnStmt)
+		if !ok || len(ret.Results) == 0 {
+			continue
+		}
+		if id, ok := ast.Unparen(ret.Results[len(ret.Results)-1]).(*ast.Ident); !ok || id.Name != "nil" {
+			return true
+		}
+	}
+	return false
+}
+
+// uncheckedByConvention reports calls whose errors Go code customarily
+// ignores: printing to standard output or error, writes to in-memory
+// buffers and hashes, which do not fail, and tabwriter output.
+func uncheckedByConvention(r *rewriter, call *ast.CallExpr) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	if fn, ok := r.pkg.info.Uses[sel.Sel].(*types.Func); ok && fn.Pkg() != nil && fn.Type().(*types.Signature).Recv() == nil {
+		switch fn.Pkg().Path() + "." + fn.Name() {
+		case "fmt.Print", "fmt.Printf", "fmt.Println":
+			return true
+		case "fmt.Fprint", "fmt.Fprintf", "fmt.Fprintln":
+			return len(call.Args) > 0 && isStdStream(r, call.Args[0])
+		}
+		return false
+	}
+	recv := r.typeOf(sel.X)
+	if recv == nil {
+		return false
+	}
+	switch types.TypeString(derefType(recv), nil) {
+	case "bytes.Buffer", "strings.Builder", "hash.Hash", "hash.Hash32", "hash.Hash64", "text/tabwriter.Writer":
+		return true
+	}
+	return false
+}
+
+// isStdStream reports whether e is os.Stdout or os.Stderr.
+func isStdStream(r *rewriter, e ast.Expr) bool {
+	sel, ok := ast.Unparen(e).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	v, ok := r.pkg.info.Uses[sel.Sel].(*types.Var)
+	return ok && v.Pkg() != nil && v.Pkg().Path() == "os" && (v.Name() == "Stdout" || v.Name() == "Stderr")
+}
+
+func derefType(t types.Type) types.Type {
+	if p, ok := t.(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+// callName is how findings name the function call calls.
+func callName(call *ast.CallExpr) string {
+	name := types.ExprString(call.Fun)
+	if len(name) > 60 {
+		name = name[:60] + "..."
+	}
+	return name
+}
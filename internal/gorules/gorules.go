@@ -0,0 +1,494 @@
+// Package gorules rewrites Go code for cleanup rules deterministically, from
+// go/ast and go/types instead of a model, so it works offline.
+package gorules
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cool-code-cleanup/internal/cleanup"
+	"cool-code-cleanup/internal/rules"
+)
+
+// maxPasses bounds how often the rewrites run over a file; a pass can expose
+// work for the next one, e.g. a collapsed comparison inside a double
+// negation.
+const maxPasses = 3
+
+// A rewrite finds what one rule changes in a file and records it on r.
+type rewrite func(r *rewriter)
+
+// rewrites lists the deterministic rewrites of each rule. Rules not listed
+// have none.
+var rewrites = map[string][]rewrite{
+	"remove_redundant_guards": {rangeNilGuard, lenNilGuard, trueGuard},
+	"simplify_complex_logic":  {boolReturn, boolComparison, doubleNegation, negatedComparison},
+	"harden_error_handling":   {discardedErrors, errorfWrap, errShadowing},
+}
+
+// complete lists the rules whose rewrites cover all the rule asks for in Go
+// code; what they cannot rewrite they report as leftover findings, so a
+// cleanup.Chain hands only those to the model.
+var complete = map[string]bool{
+	"harden_error_handling": true,
+}
+
+// Supports reports whether ruleID has deterministic rewrites.
+func Supports(ruleID string) bool {
+	return len(rewrites[ruleID]) > 0
+}
+
+// Executor is a cleanup.ProjectExecutor for Go files. Other files are left
+// alone. What a rule can only point out, not rewrite, is listed in the
+// result's summary and leftover findings.
+type Executor struct{}
+
+func (Executor) TransformProject(ctx context.Context, _ string, files []cleanup.ProjectFile, task cleanup.Task, _ []rules.Rule, _, _ bool) (cleanup.ProjectTransformResult, error) {
+	res := cleanup.ProjectTransformResult{ChangedFiles: map[string]string{}}
+	todo := rewrites[task.RuleID]
+	if len(todo) == 0 {
+		res.Summary = fmt.Sprintf("no deterministic rewrites for %s", task.RuleID)
+		return res, nil
+	}
+	content := map[string]string{}
+	var paths []string
+	for _, f := range files {
+		if strings.HasSuffix(f.Path, ".go") {
+			content[f.Path] = f.Content
+			paths = append(paths, f.Path)
+		}
+	}
+
+	var changes int
+	var findings []cleanup.Finding
+	done := map[string][]string{}
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil)
+	var handled []string
+	for pass := 0; pass < maxPasses; pass++ {
+		if err := ctx.Err(); err != nil {
+			return cleanup.ProjectTransformResult{}, err
+		}
+		passChanges := 0
+		findings = findings[:0]
+		for _, p := range loadPackages(fset, imp, paths, content) {
+			for _, f := range p.files {
+				if pass == 0 {
+					handled = append(handled, f.path)
+				}
+				r := &rewriter{file: f, pkg: p}
+				for _, rw := range todo {
+					rw(r)
+				}
+				findings = append(findings, r.findings...)
+				next, made := r.apply()
+				if len(made) == 0 {
+					continue
+				}
+				passChanges += len(made)
+				done[f.path] = append(done[f.path], made...)
+				content[f.path] = next
+				if next == originalContent(files, f.path) {
+					delete(res.ChangedFiles, f.path)
+				} else {
+					res.ChangedFiles[f.path] = next
+				}
+			}
+		}
+		changes += passChanges
+		if passChanges == 0 {
+			break
+		}
+	}
+
+	res.Changed = len(res.ChangedFiles) > 0
+	res.FileSummaries = map[string]string{}
+	for path := range res.ChangedFiles {
+		res.FileSummaries[path] = summarize(done[path])
+	}
+	// Only the Go files that parse are handled; without any, nothing is
+	// complete.
+	sort.Strings(handled)
+	res.Complete = complete[task.RuleID] && len(handled) > 0
+	res.Handled = handled
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	res.Leftover = findings
+	var summary []string
+	if changes > 0 {
+		summary = append(summary, fmt.Sprintf("%d rewrites in %d files", changes, len(res.ChangedFiles)))
+	}
+	if len(findings) > 0 {
+		notes := make([]string, len(findings))
+		for i, f := range findings {
+			notes[i] = fmt.Sprintf("%s:%d: %s", filepath.Base(f.Path), f.Line, f.Message)
+		}
+		summary = append(summary, fmt.Sprintf("%d findings: %s", len(notes), strings.Join(notes, "; ")))
+	}
+	res.Summary = strings.Join(summary, "; ")
+	return res, nil
+}
+
+// summarize joins descriptions of rewrites, counting repeats.
+func summarize(made []string) string {
+	count := map[string]int{}
+	var order []string
+	for _, m := range made {
+		if count[m] == 0 {
+			order = append(order, m)
+		}
+		count[m]++
+	}
+	for i, m := range order {
+		if count[m] > 1 {
+			order[i] = fmt.Sprintf("%s (x%d)", m, count[m])
+		}
+	}
+	return strings.Join(order, "; ")
+}
+
+func originalContent(files []cleanup.ProjectFile, path string) string {
+	for _, f := range files {
+		if f.Path == path {
+			return f.Content
+		}
+	}
+	return ""
+}
+
+type goFile struct {
+	path string
+	src  []byte
+	ast  *ast.File
+}
+
+// goPackage is the files of one directory and package name, type-checked
+// together. Type errors, e.g. from imports that cannot be resolved, are
+// tolerated; rewrites that need a type skip expressions without one.
+type goPackage struct {
+	fset  *token.FileSet
+	types *types.Package
+	info  *types.Info
+	files []*goFile
+}
+
+// loadPackages parses the files that parse and type-checks them by package.
+// fset and imp are shared across passes, so imported packages are
+// type-checked from source only once.
+func loadPackages(fset *token.FileSet, imp types.Importer, paths []string, content map[string]string) []*goPackage {
+	groups := map[string]*goPackage{}
+	var keys []string
+	for _, path := range paths {
+		src := []byte(content[path])
+		f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		key := filepath.Dir(path) + "\x00" + f.Name.Name
+		p, ok := groups[key]
+		if !ok {
+			p = &goPackage{fset: fset}
+			groups[key] = p
+			keys = append(keys, key)
+		}
+		p.files = append(p.files, &goFile{path: path, src: src, ast: f})
+	}
+	out := make([]*goPackage, 0, len(keys))
+	for _, key := range keys {
+		p := groups[key]
+		p.info = &types.Info{
+			Types: map[ast.Expr]types.TypeAndValue{},
+			Defs:  map[*ast.Ident]types.Object{},
+			Uses:  map[*ast.Ident]types.Object{},
+		}
+		conf := types.Config{Importer: imp, Error: func(error) {}}
+		astFiles := make([]*ast.File, len(p.files))
+		for i, f := range p.files {
+			astFiles[i] = f.ast
+		}
+		p.types, _ = conf.Check(p.files[0].ast.Name.Name, fset, astFiles, p.info)
+		out = append(out, p)
+	}
+	return out
+}
+
+// textEdit replaces src[start:end] with text; desc says what it does and
+// imports are the package paths the text uses.
+type textEdit struct {
+	start, end int
+	text       string
+	desc       string
+	imports    []string
+}
+
+// rewriter collects the edits and findings of one pass over a file.
+type rewriter struct {
+	file     *goFile
+	pkg      *goPackage
+	edits    []textEdit
+	findings []cleanup.Finding
+	parents  map[ast.Node]ast.Node
+	// desc describes the edits recorded next and needs are the packages
+	// they use.
+	desc  string
+	needs []string
+}
+
+func (r *rewriter) offset(p token.Pos) int {
+	return r.pkg.fset.Position(p).Offset
+}
+
+func (r *rewriter) src(n ast.Node) string {
+	return string(r.file.src[r.offset(n.Pos()):r.offset(n.End())])
+}
+
+func (r *rewriter) line(n ast.Node) int {
+	return r.pkg.fset.Position(n.Pos()).Line
+}
+
+// note records a finding at n.
+func (r *rewriter) note(n ast.Node, format string, args ...any) {
+	r.findings = append(r.findings, cleanup.Finding{Path: r.file.path, Line: r.line(n), Message: fmt.Sprintf(format, args...)})
+}
+
+// describe sets the description of the edits recorded next.
+func (r *rewriter) describe(format string, args ...any) {
+	r.desc = fmt.Sprintf(format, args...)
+	r.needs = nil
+}
+
+// require adds the package path to those the edits recorded next use, to
+// be imported if they are made.
+func (r *rewriter) require(path string) {
+	r.needs = append(r.needs, path)
+}
+
+func (r *rewriter) typeOf(e ast.Expr) types.Type {
+	return r.pkg.info.TypeOf(e)
+}
+
+// isUniverse reports whether id is the predeclared name, not shadowed.
+func (r *rewriter) isUniverse(e ast.Expr, name string) bool {
+	id, ok := ast.Unparen(e).(*ast.Ident)
+	if !ok || id.Name != name {
+		return false
+	}
+	obj := r.pkg.info.Uses[id]
+	return obj == nil || obj == types.Universe.Lookup(name)
+}
+
+// parent returns the node n is directly inside of.
+func (r *rewriter) parent(n ast.Node) ast.Node {
+	if r.parents == nil {
+		r.parents = map[ast.Node]ast.Node{}
+		var stack []ast.Node
+		ast.Inspect(r.file.ast, func(n ast.Node) bool {
+			if n == nil {
+				stack = stack[:len(stack)-1]
+				return false
+			}
+			if len(stack) > 0 {
+				r.parents[n] = stack[len(stack)-1]
+			}
+			stack = append(stack, n)
+			return true
+		})
+	}
+	return r.parents[n]
+}
+
+// hasComments reports whether a comment lies in [from, to).
+func (r *rewriter) hasComments(from, to token.Pos) bool {
+	for _, cg := range r.file.ast.Comments {
+		if cg.Pos() < to && cg.End() > from {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceExpr replaces e with text, an expression whose loosest operator
+// has precedence prec, parenthesized if e's place binds tighter.
+func (r *rewriter) replaceExpr(e ast.Expr, text string, prec int) {
+	need := 0
+	switch p := r.parent(e).(type) {
+	case *ast.BinaryExpr:
+		need = p.Op.Precedence() + 1
+	case *ast.UnaryExpr, *ast.StarExpr:
+		need = token.UnaryPrec
+	case *ast.SelectorExpr, *ast.IndexExpr, *ast.SliceExpr, *ast.TypeAssertExpr:
+		need = primaryPrec
+	case *ast.CallExpr:
+		if p.Fun == e {
+			need = primaryPrec
+		}
+	}
+	if prec < need {
+		text = "(" + text + ")"
+	}
+	r.replace(e.Pos(), e.End(), text)
+}
+
+// replaceStmt replaces the statements from..to with text. text's lines
+// after the first are indented as in the source; when text is empty the
+// statements' lines are removed.
+func (r *rewriter) replaceStmt(from, to ast.Node, text string) {
+	start, end := r.offset(from.Pos()), r.offset(to.End())
+	if text == "" {
+		lineStart := bytes.LastIndexByte(r.file.src[:start], '\n') + 1
+		lineEnd := end + bytes.IndexByte(r.file.src[end:], '\n')
+		if lineEnd < end {
+			lineEnd = len(r.file.src) - 1
+		}
+		if strings.TrimSpace(string(r.file.src[lineStart:start])) == "" && strings.TrimSpace(string(r.file.src[end:lineEnd])) == "" {
+			start, end = lineStart, lineEnd+1
+		}
+	}
+	r.edits = append(r.edits, textEdit{start: start, end: end, text: text, desc: r.desc, imports: r.needs})
+}
+
+func (r *rewriter) replace(from, to token.Pos, text string) {
+	r.edits = append(r.edits, textEdit{start: r.offset(from), end: r.offset(to), text: text, desc: r.desc, imports: r.needs})
+}
+
+// apply returns the file with the edits made, skipping any that overlap an
+// earlier one, and the descriptions of those made. A file that was
+// gofmt-formatted is formatted again.
+func (r *rewriter) apply() (string, []string) {
+	if len(r.edits) == 0 {
+		return string(r.file.src), nil
+	}
+	sort.SliceStable(r.edits, func(i, j int) bool { return r.edits[i].start < r.edits[j].start })
+	var out bytes.Buffer
+	var made []string
+	needs := map[string]bool{}
+	pos := 0
+	for _, e := range r.edits {
+		if e.start < pos {
+			continue
+		}
+		out.Write(r.file.src[pos:e.start])
+		out.WriteString(e.text)
+		pos = e.end
+		if e.desc != "" {
+			made = append(made, e.desc)
+		}
+		for _, path := range e.imports {
+			needs[path] = true
+		}
+	}
+	out.Write(r.file.src[pos:])
+	src := r.addImports(out.Bytes(), needs)
+	if formatted, err := format.Source(r.file.src); err == nil && bytes.Equal(formatted, r.file.src) {
+		if next, err := format.Source(src); err == nil {
+			return string(next), made
+		}
+	}
+	return string(src), made
+}
+
+// addImports adds the packages in needs that the file does not import yet
+// to src, the file after the edits. The edits are all after the imports,
+// so the import declarations are where they were parsed.
+func (r *rewriter) addImports(src []byte, needs map[string]bool) []byte {
+	var missing []string
+	for path := range needs {
+		if r.importName(path) == "" {
+			missing = append(missing, strconv.Quote(path))
+		}
+	}
+	if len(missing) == 0 {
+		return src
+	}
+	sort.Strings(missing)
+	for _, d := range r.file.ast.Decls {
+		g, ok := d.(*ast.GenDecl)
+		if !ok || g.Tok != token.IMPORT {
+			continue
+		}
+		if g.Lparen.IsValid() {
+			at := r.offset(g.Lparen) + 1
+			return splice(src, at, at, "\n\t"+strings.Join(missing, "\n\t"))
+		}
+		spec := g.Specs[0]
+		return splice(src, r.offset(g.Pos()), r.offset(spec.End()), "import (\n\t"+strings.Join(missing, "\n\t")+"\n\t"+r.src(spec)+"\n)")
+	}
+	at := r.offset(r.file.ast.Name.End())
+	return splice(src, at, at, "\n\nimport "+strings.Join(missing, "\nimport "))
+}
+
+func splice(src []byte, start, end int, text string) []byte {
+	out := make([]byte, 0, len(src)+len(text))
+	out = append(out, src[:start]...)
+	out = append(out, text...)
+	return append(out, src[end:]...)
+}
+
+// importName returns the name the file imports path under, or "".
+func (r *rewriter) importName(path string) string {
+	for _, spec := range r.file.ast.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err != nil || p != path {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		if pkg := r.importedPackage(path); pkg != nil {
+			return pkg.Name()
+		}
+		return filepath.Base(path)
+	}
+	return ""
+}
+
+func (r *rewriter) importedPackage(path string) *types.Package {
+	if r.pkg.types == nil {
+		return nil
+	}
+	for _, p := range r.pkg.types.Imports() {
+		if p.Path() == path {
+			return p
+		}
+	}
+	return nil
+}
+
+// primaryPrec is the precedence of operands and primary expressions, which
+// bind tighter than any operator.
+const primaryPrec = token.UnaryPrec + 1
+
+// precedence returns the precedence of e's loosest operator.
+func precedence(e ast.Expr) int {
+	switch x := e.(type) {
+	case *ast.BinaryExpr:
+		return x.Op.Precedence()
+	case *ast.UnaryExpr, *ast.StarExpr:
+		return token.UnaryPrec
+	}
+	return primaryPrec
+}
+
+// dedent removes one tab from the start of each line of text after the
+// first.
+func dedent(text string) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		lines[i] = strings.TrimPrefix(lines[i], "\t")
+	}
+	return strings.Join(lines, "\n")
+}
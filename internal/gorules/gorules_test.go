@@ -0,0 +1,375 @@
+package gorules
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cool-code-cleanup/internal/cleanup"
+	"cool-code-cleanup/internal/rules"
+)
+
+// transform runs ruleID on src as a.go and returns the file as it is after.
+func transform(t *testing.T, ruleID, src string) (string, cleanup.ProjectTransformResult) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "a.go")
+	res, err := Executor{}.TransformProject(context.Background(), "", []cleanup.ProjectFile{{Path: path, Content: src}}, cleanup.Task{RuleID: ruleID}, nil, true, false)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if after, ok := res.ChangedFiles[path]; ok {
+		return after, res
+	}
+	return src, res
+}
+
+func TestRewrites(t *testing.T) {
+	cases := []struct {
+		name, rule, src, want string
+	}{
+		{
+			name: "nil check around range",
+			rule: "remove_redundant_guards",
+			src: `package a
+
+func sum(xs []int, m map[string]int, ch chan int) int {
+	t := 0
+	if xs != nil {
+		for _, x := range xs {
+			t += x
+		}
+	}
+	if nil != m {
+		for _, v := range m {
+			t += v
+		}
+	}
+	if ch != nil {
+		for v := range ch {
+			t += v
+		}
+	}
+	return t
+}
+`,
+			want: `package a
+
+func sum(xs []int, m map[string]int, ch chan int) int {
+	t := 0
+	for _, x := range xs {
+		t += x
+	}
+	for _, v := range m {
+		t += v
+	}
+	if ch != nil {
+		for v := range ch {
+			t += v
+		}
+	}
+	return t
+}
+`,
+		},
+		{
+			name: "nil check before len",
+			rule: "remove_redundant_guards",
+			src: `package a
+
+type s struct{ items []int }
+
+func f(xs []int, v s, p *[3]int) bool {
+	if p != nil && len(p) > 0 {
+		return xs == nil || len(xs) == 0
+	}
+	return v.items != nil && len(v.items) > 0
+}
+`,
+			want: `package a
+
+type s struct{ items []int }
+
+func f(xs []int, v s, p *[3]int) bool {
+	if p != nil && len(p) > 0 {
+		return len(xs) == 0
+	}
+	return len(v.items) > 0
+}
+`,
+		},
+		{
+			name: "if true",
+			rule: "remove_redundant_guards",
+			src: `package a
+
+func f() {
+	if true {
+		println("a")
+	}
+	if true {
+		x := 1
+		println(x)
+	}
+}
+`,
+			want: `package a
+
+func f() {
+	println("a")
+	if true {
+		x := 1
+		println(x)
+	}
+}
+`,
+		},
+		{
+			name: "if returning booleans",
+			rule: "simplify_complex_logic",
+			src: `package a
+
+type flag bool
+
+func pos(n int) bool {
+	if n > 0 {
+		return true
+	}
+	return false
+}
+
+func empty(s string) bool {
+	if s != "" {
+		return false
+	} else {
+		return true
+	}
+}
+
+func named(b bool) flag {
+	if b {
+		return true
+	}
+	return false
+}
+`,
+			want: `package a
+
+type flag bool
+
+func pos(n int) bool {
+	return n > 0
+}
+
+func empty(s string) bool {
+	return s == ""
+}
+
+func named(b bool) flag {
+	if b {
+		return true
+	}
+	return false
+}
+`,
+		},
+		{
+			name: "boolean expressions",
+			rule: "simplify_complex_logic",
+			src: `package a
+
+func f(ok, done bool, a, b float64, i, j int) bool {
+	if ok == true && false == done {
+		return !!ok
+	}
+	if !(a < b) || !(i < j) {
+		return !(a == b) && ok != true
+	}
+	return !(i >= j) == done
+}
+`,
+			want: `package a
+
+func f(ok, done bool, a, b float64, i, j int) bool {
+	if ok && !done {
+		return ok
+	}
+	if !(a < b) || i >= j {
+		return a != b && !ok
+	}
+	return (i < j) == done
+}
+`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got, _ := transform(t, c.rule, c.src); got != c.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDiscardedErrorsArePropagated(t *testing.T) {
+	got, res := transform(t, "harden_error_handling", `package a
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type pair struct{ a, b int }
+
+func f(sb *strings.Builder, tw *tabwriter.Writer, s string) (int, pair, error) {
+	os.Remove("x")
+	_, _ = os.ReadFile("y")
+	n, _ := strconv.Atoi(s)
+	sb.WriteString("ok")
+	fmt.Fprintln(os.Stderr, "ok")
+	tw.Flush()
+	if err := os.Mkdir("d", 0o755); err != nil {
+		os.Remove("d")
+		return 0, pair{}, err
+	}
+	return n, pair{}, nil
+}
+
+func g() {
+	_ = os.Chdir("z")
+}
+`)
+	want := `package a
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type pair struct{ a, b int }
+
+func f(sb *strings.Builder, tw *tabwriter.Writer, s string) (int, pair, error) {
+	if err := os.Remove("x"); err != nil {
+		return 0, pair{}, fmt.Errorf("os.Remove: %w", err)
+	}
+	_, _ = os.ReadFile("y")
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, pair{}, fmt.Errorf("strconv.Atoi: %w", err)
+	}
+	sb.WriteString("ok")
+	fmt.Fprintln(os.Stderr, "ok")
+	tw.Flush()
+	if err := os.Mkdir("d", 0o755); err != nil {
+		os.Remove("d")
+		return 0, pair{}, err
+	}
+	return n, pair{}, nil
+}
+
+func g() {
+	_ = os.Chdir("z")
+}
+`
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	var notes []string
+	for _, f := range res.Leftover {
+		notes = append(notes, fmt.Sprintf("%d: %s", f.Line, f.Message))
+	}
+	if want := "17: error returned by os.ReadFile is assigned to _ (an explicit discard)|" +
+		"26: error returned by os.Remove is ignored (the block returns another error)|" +
+		"33: error returned by os.Chdir is assigned to _ (an explicit discard)"; !res.Complete || strings.Join(notes, "|") != want {
+		t.Fatalf("got findings %q, want %q", notes, want)
+	}
+	for _, s := range res.FileSummaries {
+		if !strings.Contains(s, "returned the error of os.Remove with context") {
+			t.Fatalf("unexpected file summary %q", s)
+		}
+	}
+}
+
+func TestErrorfWrapsAndShadowing(t *testing.T) {
+	got, res := transform(t, "harden_error_handling", `package a
+
+import (
+	"fmt"
+	"os"
+)
+
+func f(name string) error {
+	err := os.Chdir(name)
+	if err != nil {
+		return fmt.Errorf("chdir %s: %v", name, err)
+	}
+	if name != "" {
+		_, err := os.Stat(name)
+		return fmt.Errorf("stat %q: %d%% %s", name, 1, err)
+	}
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("remove: %w (%v)", err, err)
+	}
+	return fmt.Errorf("both: %v %v", err, os.ErrExist)
+}
+`)
+	for _, want := range []string{`"chdir %s: %w", name, err`, `"stat %q: %d%% %w", name, 1, err`, `"remove: %w (%v)", err, err`, `"both: %v %v"`} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("result is missing %s:\n%s", want, got)
+		}
+	}
+	var notes []string
+	for _, f := range res.Leftover {
+		notes = append(notes, f.Message)
+	}
+	if want := "err shadows the err declared at line 9|fmt.Errorf formats 2 errors without %w"; strings.Join(notes, "|") != want {
+		t.Fatalf("got findings %q, want %q", notes, want)
+	}
+}
+
+func TestUnsupportedRuleLeavesFiles(t *testing.T) {
+	_, res := transform(t, "split_functions", "package a\n\nfunc f() {\n\tif true {\n\t}\n}\n")
+	if res.Changed || Supports("split_functions") || !strings.Contains(res.Summary, "no deterministic rewrites") {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+// recordingExec records the task and the files it is given.
+type recordingExec struct {
+	task  *cleanup.Task
+	files *[]string
+}
+
+func (e recordingExec) TransformProject(_ context.Context, _ string, files []cleanup.ProjectFile, task cleanup.Task, _ []rules.Rule, _ bool, _ bool) (cleanup.ProjectTransformResult, error) {
+	*e.task = task
+	for _, f := range files {
+		*e.files = append(*e.files, f.Path)
+	}
+	return cleanup.ProjectTransformResult{}, nil
+}
+
+func TestChainPassesFilesItCannotReadOn(t *testing.T) {
+	dir := t.TempDir()
+	goFile, pyFile, broken := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.py"), filepath.Join(dir, "c.go")
+	files := []cleanup.ProjectFile{
+		{Path: goFile, Content: "package a\n\nfunc f() int { return 1 }\n"},
+		{Path: pyFile, Content: "import os\nos.remove('x')\n"},
+		{Path: broken, Content: "package a\n\nfunc {\n"},
+	}
+	task := cleanup.Task{RuleID: "harden_error_handling", Description: "harden", Files: []string{goFile, pyFile, broken}}
+	var got cleanup.Task
+	var seen []string
+	if _, err := (cleanup.Chain{Executor{}, recordingExec{&got, &seen}}).TransformProject(context.Background(), dir, files, task, nil, true, false); err != nil {
+		t.Fatalf("chain: %v", err)
+	}
+	if len(seen) != 2 || seen[0] != pyFile || seen[1] != broken || got.Description != "harden" {
+		t.Fatalf("next executor got %v and %q", seen, got.Description)
+	}
+}
@@ -0,0 +1,415 @@
+package gorules
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// rangeNilGuard removes a nil check around a range over the same slice or
+// map, since ranging over nil does nothing:
+//
+//	if xs != nil { for _, x := range xs { ... } }  =>  for _, x := range xs { ... }
+func rangeNilGuard(r *rewriter) {
+	ast.Inspect(r.file.ast, func(n ast.Node) bool {
+		s, ok := n.(*ast.IfStmt)
+		if !ok || s.Init != nil || s.Else != nil || len(s.Body.List) != 1 {
+			return true
+		}
+		loop, ok := s.Body.List[0].(*ast.RangeStmt)
+		if !ok {
+			return true
+		}
+		v := r.nilChecked(s.Cond, token.NEQ)
+		if v == nil || types.ExprString(v) != types.ExprString(loop.X) || !r.nilRangesEmpty(v) {
+			return true
+		}
+		if r.hasComments(s.Pos(), loop.Pos()) || r.hasComments(loop.End(), s.End()) {
+			return true
+		}
+		r.describe("removed nil check around range over %s", types.ExprString(v))
+		r.replaceStmt(s, s, dedent(r.src(loop)))
+		return false
+	})
+}
+
+// lenNilGuard drops a nil check that a len check after it already covers:
+//
+//	xs != nil && len(xs) > 0  =>  len(xs) > 0
+//	xs == nil || len(xs) == 0  =>  len(xs) == 0
+func lenNilGuard(r *rewriter) {
+	ast.Inspect(r.file.ast, func(n ast.Node) bool {
+		b, ok := n.(*ast.BinaryExpr)
+		if !ok || (b.Op != token.LAND && b.Op != token.LOR) {
+			return true
+		}
+		nilOp := token.NEQ
+		if b.Op == token.LOR {
+			nilOp = token.EQL
+		}
+		v := r.nilChecked(b.X, nilOp)
+		if v == nil || !r.lenOfNilIsZero(v) {
+			return true
+		}
+		arg, emptyWhenTrue := r.lenCompare(b.Y)
+		if arg == nil || types.ExprString(arg) != types.ExprString(v) || emptyWhenTrue != (b.Op == token.LOR) {
+			return true
+		}
+		if r.hasComments(b.X.Pos(), b.Y.Pos()) {
+			return true
+		}
+		r.describe("removed nil check before len(%s)", types.ExprString(v))
+		r.replaceExpr(b, r.src(b.Y), precedence(b.Y))
+		return false
+	})
+}
+
+// trueGuard inlines the body of an "if true" without else. Bodies that
+// declare names are left, since inlining could clash with the names around
+// them.
+func trueGuard(r *rewriter) {
+	ast.Inspect(r.file.ast, func(n ast.Node) bool {
+		s, ok := n.(*ast.IfStmt)
+		if !ok || s.Init != nil || s.Else != nil || !r.isUniverse(s.Cond, "true") || declares(s.Body.List) {
+			return true
+		}
+		r.describe("removed always-true if")
+		if len(s.Body.List) == 0 {
+			if !r.hasComments(s.Pos(), s.End()) {
+				r.replaceStmt(s, s, "")
+			}
+			return false
+		}
+		first, last := s.Body.List[0], s.Body.List[len(s.Body.List)-1]
+		if r.hasComments(s.Pos(), first.Pos()) || r.hasComments(last.End(), s.End()) {
+			return true
+		}
+		r.replaceStmt(s, s, dedent(string(r.file.src[r.offset(first.Pos()):r.offset(last.End())])))
+		return false
+	})
+}
+
+// boolReturn collapses an if that only chooses which boolean to return:
+//
+//	if cond { return true }; return false  =>  return cond
+//	if cond { return false } else { return true }  =>  return !cond
+func boolReturn(r *rewriter) {
+	ast.Inspect(r.file.ast, func(n ast.Node) bool {
+		var list []ast.Stmt
+		switch b := n.(type) {
+		case *ast.BlockStmt:
+			list = b.List
+		case *ast.CaseClause:
+			list = b.Body
+		case *ast.CommClause:
+			list = b.Body
+		default:
+			return true
+		}
+		for i, stmt := range list {
+			s, ok := stmt.(*ast.IfStmt)
+			if !ok || s.Init != nil || len(s.Body.List) != 1 {
+				continue
+			}
+			then, ok := r.boolResult(s.Body.List[0])
+			if !ok {
+				continue
+			}
+			var last ast.Node
+			switch e := s.Else.(type) {
+			case nil:
+				if i+1 < len(list) {
+					if other, ok := r.boolResult(list[i+1]); ok && other != then {
+						last = list[i+1]
+					}
+				}
+			case *ast.BlockStmt:
+				if len(e.List) == 1 {
+					if other, ok := r.boolResult(e.List[0]); ok && other != then {
+						last = s
+					}
+				}
+			}
+			if last == nil || r.hasComments(s.Pos(), last.End()) || !r.assignableToResult(s.Cond, s.Body.List[0]) {
+				continue
+			}
+			cond := r.src(s.Cond)
+			if !then {
+				cond, _ = r.negate(s.Cond)
+			}
+			r.describe("collapsed if returning true/false into return %s", cond)
+			r.replaceStmt(s, last, "return "+cond)
+		}
+		return true
+	})
+}
+
+// boolComparison drops comparisons of a bool with a constant:
+//
+//	ok == true  =>  ok
+//	ok != true  =>  !ok
+func boolComparison(r *rewriter) {
+	ast.Inspect(r.file.ast, func(n ast.Node) bool {
+		b, ok := n.(*ast.BinaryExpr)
+		if !ok || (b.Op != token.EQL && b.Op != token.NEQ) {
+			return true
+		}
+		operand, constant := b.X, b.Y
+		if r.isUniverse(b.X, "true") || r.isUniverse(b.X, "false") {
+			operand, constant = b.Y, b.X
+		}
+		var value bool
+		switch {
+		case r.isUniverse(constant, "true"):
+			value = true
+		case r.isUniverse(constant, "false"):
+		default:
+			return true
+		}
+		// A named bool type would change the expression's type.
+		if t := r.typeOf(operand); t == nil || !types.Identical(t, types.Typ[types.Bool]) {
+			return true
+		}
+		r.describe("removed comparison with %t", value)
+		if value == (b.Op == token.EQL) {
+			r.replaceExpr(b, r.src(operand), precedence(operand))
+		} else {
+			text, prec := r.negate(operand)
+			r.replaceExpr(b, text, prec)
+		}
+		return false
+	})
+}
+
+// doubleNegation removes "!!".
+func doubleNegation(r *rewriter) {
+	ast.Inspect(r.file.ast, func(n ast.Node) bool {
+		u, ok := n.(*ast.UnaryExpr)
+		if !ok || u.Op != token.NOT {
+			return true
+		}
+		inner, ok := ast.Unparen(u.X).(*ast.UnaryExpr)
+		if !ok || inner.Op != token.NOT {
+			return true
+		}
+		r.describe("removed double negation")
+		r.replaceExpr(u, r.src(inner.X), precedence(inner.X))
+		return false
+	})
+}
+
+// negatedComparison turns a negated comparison into its opposite:
+//
+//	!(a == b)  =>  a != b
+func negatedComparison(r *rewriter) {
+	ast.Inspect(r.file.ast, func(n ast.Node) bool {
+		u, ok := n.(*ast.UnaryExpr)
+		if !ok || u.Op != token.NOT {
+			return true
+		}
+		b, ok := ast.Unparen(u.X).(*ast.BinaryExpr)
+		if !ok || r.opposite(b) == token.ILLEGAL {
+			return true
+		}
+		text, prec := r.negate(b)
+		r.describe("simplified negated comparison")
+		r.replaceExpr(u, text, prec)
+		return false
+	})
+}
+
+// nilChecked returns x when e is "x op nil" (or "nil op x") and x is a
+// variable or field, which can be evaluated again without side effects.
+func (r *rewriter) nilChecked(e ast.Expr, op token.Token) ast.Expr {
+	b, ok := ast.Unparen(e).(*ast.BinaryExpr)
+	if !ok || b.Op != op {
+		return nil
+	}
+	x := b.X
+	if r.isUniverse(x, "nil") {
+		x = b.Y
+	} else if !r.isUniverse(b.Y, "nil") {
+		return nil
+	}
+	if !isVariable(x) {
+		return nil
+	}
+	return x
+}
+
+func isVariable(e ast.Expr) bool {
+	switch x := e.(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		return isVariable(x.X)
+	}
+	return false
+}
+
+// nilRangesEmpty reports whether ranging over a nil e does nothing: it is a
+// slice or map. (A nil channel would block forever.)
+func (r *rewriter) nilRangesEmpty(e ast.Expr) bool {
+	t := r.typeOf(e)
+	if t == nil {
+		return false
+	}
+	switch t.Underlying().(type) {
+	case *types.Slice, *types.Map:
+		return true
+	}
+	return false
+}
+
+// lenOfNilIsZero reports whether len of a nil e is 0.
+func (r *rewriter) lenOfNilIsZero(e ast.Expr) bool {
+	t := r.typeOf(e)
+	if t == nil {
+		return false
+	}
+	switch t.Underlying().(type) {
+	case *types.Slice, *types.Map, *types.Chan:
+		return true
+	}
+	return false
+}
+
+// lenCompare returns x when e compares len(x) with 0, and whether e is true
+// for an empty x.
+func (r *rewriter) lenCompare(e ast.Expr) (ast.Expr, bool) {
+	b, ok := ast.Unparen(e).(*ast.BinaryExpr)
+	if !ok {
+		return nil, false
+	}
+	isZero := func(e ast.Expr) bool {
+		lit, ok := e.(*ast.BasicLit)
+		return ok && lit.Kind == token.INT && lit.Value == "0"
+	}
+	lenArg := func(e ast.Expr) ast.Expr {
+		call, ok := e.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 || !r.isBuiltin(call.Fun, "len") {
+			return nil
+		}
+		return call.Args[0]
+	}
+	switch {
+	case lenArg(b.X) != nil && isZero(b.Y):
+		switch b.Op {
+		case token.GTR, token.NEQ:
+			return lenArg(b.X), false
+		case token.EQL, token.LEQ:
+			return lenArg(b.X), true
+		}
+	case isZero(b.X) && lenArg(b.Y) != nil:
+		switch b.Op {
+		case token.LSS, token.NEQ:
+			return lenArg(b.Y), false
+		case token.EQL, token.GEQ:
+			return lenArg(b.Y), true
+		}
+	}
+	return nil, false
+}
+
+func (r *rewriter) isBuiltin(e ast.Expr, name string) bool {
+	id, ok := e.(*ast.Ident)
+	if !ok || id.Name != name {
+		return false
+	}
+	_, ok = r.pkg.info.Uses[id].(*types.Builtin)
+	return ok
+}
+
+// boolResult reports the value s returns when it is "return true" or
+// "return false".
+func (r *rewriter) boolResult(s ast.Stmt) (value, ok bool) {
+	ret, isRet := s.(*ast.ReturnStmt)
+	if !isRet || len(ret.Results) != 1 {
+		return false, false
+	}
+	switch {
+	case r.isUniverse(ret.Results[0], "true"):
+		return true, true
+	case r.isUniverse(ret.Results[0], "false"):
+		return false, true
+	}
+	return false, false
+}
+
+// assignableToResult reports whether cond can be returned where ret
+// returns a constant, whose recorded type is the function's result type.
+func (r *rewriter) assignableToResult(cond ast.Expr, ret ast.Stmt) bool {
+	want := r.typeOf(ret.(*ast.ReturnStmt).Results[0])
+	got := r.typeOf(cond)
+	return want != nil && got != nil && types.AssignableTo(got, want)
+}
+
+// negate returns the negation of e and its precedence, flipping a
+// comparison or removing a "!" instead of adding one where it can.
+func (r *rewriter) negate(e ast.Expr) (string, int) {
+	e = ast.Unparen(e)
+	switch x := e.(type) {
+	case *ast.UnaryExpr:
+		if x.Op == token.NOT {
+			return r.src(x.X), precedence(x.X)
+		}
+	case *ast.BinaryExpr:
+		if op := r.opposite(x); op != token.ILLEGAL {
+			return r.src(x.X) + " " + op.String() + " " + r.src(x.Y), op.Precedence()
+		}
+	case *ast.Ident:
+		if r.isUniverse(x, "true") {
+			return "false", primaryPrec
+		}
+		if r.isUniverse(x, "false") {
+			return "true", primaryPrec
+		}
+	}
+	if precedence(e) >= token.UnaryPrec {
+		return "!" + r.src(e), token.UnaryPrec
+	}
+	return "!(" + r.src(e) + ")", token.UnaryPrec
+}
+
+// opposite returns the comparison that is true exactly when b is false, or
+// token.ILLEGAL. Orderings are only flipped for integers and strings: with
+// floats, NaN makes both a < b and a >= b false.
+func (r *rewriter) opposite(b *ast.BinaryExpr) token.Token {
+	switch b.Op {
+	case token.EQL:
+		return token.NEQ
+	case token.NEQ:
+		return token.EQL
+	}
+	ordered := map[token.Token]token.Token{token.LSS: token.GEQ, token.GEQ: token.LSS, token.GTR: token.LEQ, token.LEQ: token.GTR}
+	op, ok := ordered[b.Op]
+	if !ok {
+		return token.ILLEGAL
+	}
+	for _, e := range []ast.Expr{b.X, b.Y} {
+		t := r.typeOf(e)
+		if t == nil {
+			return token.ILLEGAL
+		}
+		basic, ok := t.Underlying().(*types.Basic)
+		if !ok || basic.Info()&(types.IsInteger|types.IsString) == 0 {
+			return token.ILLEGAL
+		}
+	}
+	return op
+}
+
+// declares reports whether any of list declares a name in the block.
+func declares(list []ast.Stmt) bool {
+	for _, s := range list {
+		switch x := s.(type) {
+		case *ast.DeclStmt, *ast.LabeledStmt:
+			return true
+		case *ast.AssignStmt:
+			if x.Tok == token.DEFINE {
+				return true
+			}
+		}
+	}
+	return false
+}
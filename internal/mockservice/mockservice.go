@@ -0,0 +1,305 @@
+package mockservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const SchemaVersion = 1
+
+const (
+	KindHTTP = "http"
+	KindSMTP = "smtp"
+)
+
+// Fixture is a canned response served for requests matching Method and Path.
+// Path may use path.Match globs. Body is written as-is when it is a JSON
+// string and JSON-encoded otherwise; BodyFile (relative to the fixtures file)
+// takes precedence and is served verbatim.
+type Fixture struct {
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Status   int               `json:"status"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     json.RawMessage   `json:"body,omitempty"`
+	BodyFile string            `json:"body_file,omitempty"`
+}
+
+// Service is one stand-in for an external dependency. Env maps environment
+// variable names to templates expanded with {url}, {addr}, {host} and {port}
+// once the stub is listening.
+type Service struct {
+	Name     string            `json:"name"`
+	Kind     string            `json:"kind,omitempty"`
+	Env      map[string]string `json:"env"`
+	Fixtures []Fixture         `json:"fixtures,omitempty"`
+}
+
+type File struct {
+	SchemaVersion int       `json:"schema_version"`
+	Services      []Service `json:"services"`
+}
+
+type Request struct {
+	Service string `json:"service"`
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Status  int    `json:"status"`
+	Matched bool   `json:"matched"`
+}
+
+type ServiceSummary struct {
+	Name     string            `json:"name"`
+	Kind     string            `json:"kind"`
+	Addr     string            `json:"addr"`
+	Env      map[string]string `json:"env"`
+	Requests []Request         `json:"requests"`
+}
+
+func DefaultPath() string {
+	return filepath.Join(".ccc", "mocks.json")
+}
+
+// Load reads a fixtures file. A missing file is reported via found=false.
+func Load(path string) (File, bool, error) {
+	clean := filepath.Clean(path)
+	data, err := os.ReadFile(clean)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return File{}, false, nil
+		}
+		return File{}, false, fmt.Errorf("read mock fixtures %s: %w", clean, err)
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return File{}, false, fmt.Errorf("parse mock fixtures %s: %w", clean, err)
+	}
+	if f.SchemaVersion != SchemaVersion {
+		return File{}, false, fmt.Errorf("unsupported mock fixtures schema_version=%d (expected %d)", f.SchemaVersion, SchemaVersion)
+	}
+	baseDir := filepath.Dir(clean)
+	for i := range f.Services {
+		s := &f.Services[i]
+		if strings.TrimSpace(s.Name) == "" {
+			return File{}, false, fmt.Errorf("mock service %d missing name", i+1)
+		}
+		if s.Kind == "" {
+			s.Kind = KindHTTP
+		}
+		if s.Kind != KindHTTP && s.Kind != KindSMTP {
+			return File{}, false, fmt.Errorf("mock service %s has invalid kind %q (expected http or smtp)", s.Name, s.Kind)
+		}
+		for j := range s.Fixtures {
+			fx := &s.Fixtures[j]
+			if fx.BodyFile != "" && !filepath.IsAbs(fx.BodyFile) {
+				fx.BodyFile = filepath.Join(baseDir, fx.BodyFile)
+			}
+		}
+	}
+	return f, true, nil
+}
+
+// Set is a group of running stubs.
+type Set struct {
+	mu       sync.Mutex
+	stubs    []*stub
+	requests []Request
+}
+
+type stub struct {
+	service Service
+	addr    string
+	env     map[string]string
+	close   func() error
+}
+
+// Start launches every service on an ephemeral 127.0.0.1 port. On error, any
+// stub already started is stopped.
+func Start(f File) (*Set, error) {
+	set := &Set{}
+	for _, svc := range f.Services {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			set.Stop()
+			return nil, fmt.Errorf("listen for mock service %s: %w", svc.Name, err)
+		}
+		st := &stub{service: svc, addr: ln.Addr().String()}
+		switch svc.Kind {
+		case KindSMTP:
+			st.close = serveSMTP(ln, func(r Request) { set.record(svc.Name, r) })
+		default:
+			srv := &http.Server{Handler: set.httpHandler(svc), ReadHeaderTimeout: 5 * time.Second}
+			go func() { _ = srv.Serve(ln) }()
+			st.close = srv.Close
+		}
+		st.env = expandEnv(svc, st.addr)
+		set.stubs = append(set.stubs, st)
+	}
+	return set, nil
+}
+
+// Env returns KEY=VALUE pairs pointing the app at the running stubs.
+func (s *Set) Env() []string {
+	if s == nil {
+		return nil
+	}
+	var out []string
+	for _, st := range s.stubs {
+		keys := make([]string, 0, len(st.env))
+		for k := range st.env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			out = append(out, k+"="+st.env[k])
+		}
+	}
+	return out
+}
+
+func (s *Set) Summary() []ServiceSummary {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ServiceSummary, 0, len(s.stubs))
+	for _, st := range s.stubs {
+		sum := ServiceSummary{Name: st.service.Name, Kind: st.service.Kind, Addr: st.addr, Env: st.env, Requests: []Request{}}
+		for _, r := range s.requests {
+			if r.Service == st.service.Name {
+				sum.Requests = append(sum.Requests, r)
+			}
+		}
+		out = append(out, sum)
+	}
+	return out
+}
+
+func (s *Set) Stop() {
+	if s == nil {
+		return
+	}
+	for _, st := range s.stubs {
+		if st.close != nil {
+			_ = st.close()
+		}
+	}
+}
+
+func (s *Set) record(service string, r Request) {
+	r.Service = service
+	s.mu.Lock()
+	s.requests = append(s.requests, r)
+	s.mu.Unlock()
+}
+
+func (s *Set) httpHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fx, ok := matchFixture(svc.Fixtures, r.Method, r.URL.Path)
+		if !ok {
+			s.record(svc.Name, Request{Method: r.Method, Path: r.URL.Path, Status: http.StatusNotFound})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprintf(w, `{"error":"no mock fixture for %s %s"}`, r.Method, r.URL.Path)
+			return
+		}
+		body, err := fixtureBody(fx)
+		if err != nil {
+			s.record(svc.Name, Request{Method: r.Method, Path: r.URL.Path, Status: http.StatusInternalServerError, Matched: true})
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		status := fx.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		for k, v := range fx.Headers {
+			w.Header().Set(k, v)
+		}
+		if w.Header().Get("Content-Type") == "" && json.Valid(body) {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+		s.record(svc.Name, Request{Method: r.Method, Path: r.URL.Path, Status: status, Matched: true})
+	})
+}
+
+func matchFixture(fixtures []Fixture, method, reqPath string) (Fixture, bool) {
+	for _, fx := range fixtures {
+		if fx.Method != "" && !strings.EqualFold(fx.Method, method) {
+			continue
+		}
+		if fx.Path == reqPath {
+			return fx, true
+		}
+		if ok, err := path.Match(fx.Path, reqPath); err == nil && ok {
+			return fx, true
+		}
+	}
+	return Fixture{}, false
+}
+
+func fixtureBody(fx Fixture) ([]byte, error) {
+	if fx.BodyFile != "" {
+		data, err := os.ReadFile(fx.BodyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read mock body file: %w", err)
+		}
+		return data, nil
+	}
+	raw := bytes.TrimSpace(fx.Body)
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var str string
+	if raw[0] == '"' && json.Unmarshal(raw, &str) == nil {
+		return []byte(str), nil
+	}
+	return raw, nil
+}
+
+func expandEnv(svc Service, addr string) map[string]string {
+	host, port, _ := net.SplitHostPort(addr)
+	url := "http://" + addr
+	if svc.Kind == KindSMTP {
+		url = "smtp://" + addr
+	}
+	r := strings.NewReplacer("{url}", url, "{addr}", addr, "{host}", host, "{port}", port)
+	out := map[string]string{}
+	for k, tmpl := range svc.Env {
+		if strings.TrimSpace(tmpl) == "" {
+			tmpl = "{url}"
+		}
+		out[k] = r.Replace(tmpl)
+	}
+	if len(out) == 0 {
+		out[envName(svc.Name)] = url
+	}
+	return out
+}
+
+// envName derives a default variable such as CCC_MOCK_STRIPE_URL.
+func envName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return "CCC_MOCK_" + strings.Trim(b.String(), "_") + "_URL"
+}
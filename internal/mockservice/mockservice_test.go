@@ -0,0 +1,112 @@
+package mockservice
+
+import (
+	"io"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStartServesFixturesAndExposesEnv(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "charge.json"), []byte(`{"id":"ch_recorded"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fixtures := `{
+  "schema_version": 1,
+  "services": [
+    {
+      "name": "stripe",
+      "env": {"STRIPE_API_BASE": "{url}/v1", "STRIPE_PORT": "{port}"},
+      "fixtures": [
+        {"method": "POST", "path": "/v1/charges", "status": 201, "body_file": "charge.json"},
+        {"method": "GET", "path": "/v1/customers/*", "body": {"id": "cus_1"}}
+      ]
+    },
+    {"name": "mail-relay", "kind": "smtp"}
+  ]
+}`
+	path := filepath.Join(dir, "mocks.json")
+	if err := os.WriteFile(path, []byte(fixtures), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, found, err := Load(path)
+	if err != nil || !found {
+		t.Fatalf("load: found=%v err=%v", found, err)
+	}
+	set, err := Start(f)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer set.Stop()
+
+	env := map[string]string{}
+	for _, kv := range set.Env() {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+	base := env["STRIPE_API_BASE"]
+	if !strings.HasPrefix(base, "http://127.0.0.1:") || !strings.HasSuffix(base, "/v1") || env["STRIPE_PORT"] == "" {
+		t.Fatalf("unexpected stripe env: %v", env)
+	}
+	smtpAddr := strings.TrimPrefix(env["CCC_MOCK_MAIL_RELAY_URL"], "smtp://")
+	if smtpAddr == "" {
+		t.Fatalf("expected default env var for smtp service: %v", env)
+	}
+
+	resp, err := http.Post(base+"/charges", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != 201 || string(body) != `{"id":"ch_recorded"}` {
+		t.Fatalf("unexpected charge response %d %s", resp.StatusCode, body)
+	}
+	resp, err = http.Get(base + "/customers/cus_1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected customer response %d %v", resp.StatusCode, resp.Header)
+	}
+	resp, err = http.Get(base + "/refunds")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected 404 for unmatched request, got %d", resp.StatusCode)
+	}
+
+	msg := []byte("Subject: otp\r\n\r\n123456\r\n")
+	if err := smtp.SendMail(smtpAddr, nil, "app@example.com", []string{"user@example.com"}, msg); err != nil {
+		t.Fatalf("send mail: %v", err)
+	}
+
+	summary := set.Summary()
+	if len(summary) != 2 || len(summary[0].Requests) != 3 || len(summary[1].Requests) != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if summary[0].Requests[2].Matched || summary[1].Requests[0].Path != "user@example.com" {
+		t.Fatalf("unexpected recorded requests: %+v", summary)
+	}
+}
+
+func TestLoadMissingAndInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if _, found, err := Load(filepath.Join(dir, "mocks.json")); found || err != nil {
+		t.Fatalf("expected missing file to be skipped, found=%v err=%v", found, err)
+	}
+	path := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(path, []byte(`{"schema_version":1,"services":[{"name":"x","kind":"grpc"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Load(path); err == nil {
+		t.Fatalf("expected invalid kind error")
+	}
+}
@@ -0,0 +1,99 @@
+package mockservice
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serveSMTP runs a minimal SMTP sink on ln: it accepts every envelope and
+// message without delivering anything, recording one Request per accepted
+// message. STARTTLS and AUTH are not advertised.
+func serveSMTP(ln net.Listener, record func(Request)) func() error {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				handleSMTP(conn, record)
+			}()
+		}
+	}()
+	return func() error {
+		err := ln.Close()
+		wg.Wait()
+		return err
+	}
+}
+
+func handleSMTP(conn net.Conn, record func(Request)) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) bool {
+		_ = conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		_, err := conn.Write([]byte(line + "\r\n"))
+		return err == nil
+	}
+	if !reply("220 ccc-mock ESMTP ready") {
+		return
+	}
+	var rcpts []string
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(line)
+		if i := strings.IndexByte(verb, ' '); i >= 0 {
+			verb = verb[:i]
+		}
+		switch verb {
+		case "EHLO":
+			if !reply("250-ccc-mock") || !reply("250 8BITMIME") {
+				return
+			}
+		case "HELO", "NOOP", "MAIL":
+			reply("250 OK")
+		case "RCPT":
+			if i := strings.IndexByte(line, ':'); i >= 0 {
+				rcpts = append(rcpts, strings.Trim(strings.TrimSpace(line[i+1:]), "<>"))
+			}
+			reply("250 OK")
+		case "RSET":
+			rcpts = nil
+			reply("250 OK")
+		case "DATA":
+			if !reply("354 End data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if strings.TrimRight(l, "\r\n") == "." {
+					break
+				}
+			}
+			record(Request{Method: "DATA", Path: strings.Join(rcpts, ","), Status: 250, Matched: true})
+			rcpts = nil
+			reply("250 OK: queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package mode
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"cool-code-cleanup/internal/config"
+	"cool-code-cleanup/internal/runner"
+)
+
+// appTarget is where profiling sends requests and, unless attaching to an
+// app that is already running, how that app is started.
+type appTarget struct {
+	BaseURL        string
+	Attach         bool
+	HealthURL      string
+	HealthStatus   int
+	StartupTimeout time.Duration
+	Start          runner.StartOptions
+	Log            *runner.AppLog
+}
+
+// resolveApp turns profile.app into an appTarget. Env files are read in
+// order, relative to root, and env entries override them; a port of 0 is
+// replaced by a free one.
+func resolveApp(root string, cfg config.AppConfig) (appTarget, error) {
+	target := appTarget{
+		HealthStatus:   cfg.HealthStatus,
+		StartupTimeout: time.Duration(cfg.StartupTimeoutSeconds) * time.Second,
+	}
+	if cfg.AttachURL != "" {
+		target.Attach = true
+		target.BaseURL = strings.TrimRight(cfg.AttachURL, "/")
+		target.HealthURL = target.BaseURL + cfg.HealthPath
+		return target, nil
+	}
+	port := cfg.Port
+	if port == 0 {
+		free, err := runner.FreePort()
+		if err != nil {
+			return appTarget{}, err
+		}
+		port = free
+	}
+	var env []string
+	for _, f := range cfg.EnvFiles {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(root, f)
+		}
+		vars, err := runner.ReadEnvFile(f)
+		if err != nil {
+			return appTarget{}, err
+		}
+		env = append(env, vars...)
+	}
+	keys := make([]string, 0, len(cfg.Env))
+	for k := range cfg.Env {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+cfg.Env[k])
+	}
+	dir := ""
+	if cfg.Dir != "" {
+		dir = cfg.Dir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+	}
+	target.BaseURL = fmt.Sprintf("http://127.0.0.1:%d", port)
+	target.HealthURL = target.BaseURL + cfg.HealthPath
+	target.Start = runner.StartOptions{Command: cfg.Command, Dir: dir, Port: port, Env: env}
+	return target, nil
+}
+
+// openLog sends the app's output to a log file at path (relative to root).
+func (t *appTarget) openLog(root, path string) error {
+	log, err := runner.OpenAppLog(filepath.Join(root, path))
+	if err != nil {
+		return err
+	}
+	t.Log = log
+	t.Start.Output = log
+	return nil
+}
+
+// withEnv returns a copy of the start options with env appended.
+func (t appTarget) withEnv(env ...string) runner.StartOptions {
+	opts := t.Start
+	opts.Env = append(slices.Clone(opts.Env), env...)
+	return opts
+}
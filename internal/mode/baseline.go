@@ -0,0 +1,56 @@
+package mode
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"cool-code-cleanup/internal/app"
+	"cool-code-cleanup/internal/baseline"
+)
+
+// loadBaseline reads the baseline called name from root's baseline
+// directory.
+func loadBaseline(root, name string) (baseline.Baseline, error) {
+	path, err := baseline.Path(filepath.Join(root, baseline.DefaultDir()), name)
+	if err != nil {
+		return baseline.Baseline{}, err
+	}
+	return baseline.Load(path)
+}
+
+// compareBaseline prints the per-route comparison of current with base. The
+// returned error says how many routes regressed, if any.
+func compareBaseline(rt *app.Runtime, base, current baseline.Baseline) (baseline.Comparison, error) {
+	cfg := rt.Effective.Config.Profile.Baseline
+	cmp := baseline.Compare(base, current, baseline.Thresholds{
+		P95IncreasePct:      cfg.P95IncreasePct,
+		P95IncreaseMs:       cfg.P95IncreaseMs,
+		ErrorRateIncrease:   cfg.ErrorRateIncrease,
+		FailOnStatusChange:  cfg.FailOnStatusChange,
+		FailOnRemovedRoutes: cfg.FailOnRemovedRoutes,
+	})
+	fmt.Fprintf(os.Stdout, "Comparison with baseline %q (run %s):\n%s\n", base.Name, base.RunID, baseline.FormatTable(cmp))
+	if cmp.Regressions > 0 {
+		rt.AddStep("step_4_baseline_compare", "failed", fmt.Sprintf("%d of %d routes regressed against %q", cmp.Regressions, len(cmp.Routes), base.Name))
+		return cmp, fmt.Errorf("%d routes regressed against baseline %q", cmp.Regressions, base.Name)
+	}
+	rt.AddStep("step_4_baseline_compare", "completed", fmt.Sprintf("%d routes compared with %q; no regressions", len(cmp.Routes), base.Name))
+	return cmp, nil
+}
+
+// saveBaseline stores current as the baseline called name and returns its
+// path relative to root.
+func saveBaseline(rt *app.Runtime, root, name string, current baseline.Baseline) (string, error) {
+	rel, err := baseline.Path(baseline.DefaultDir(), name)
+	if err != nil {
+		rt.AddStep("step_4_baseline_save", "failed", err.Error())
+		return "", err
+	}
+	if err := baseline.Save(filepath.Join(root, rel), current); err != nil {
+		rt.AddStep("step_4_baseline_save", "failed", err.Error())
+		return "", err
+	}
+	rt.AddStep("step_4_baseline_save", "completed", fmt.Sprintf("saved %d routes as %q in %s", len(current.Routes), name, rel))
+	return rel, nil
+}
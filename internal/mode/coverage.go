@@ -0,0 +1,89 @@
+package mode
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"cool-code-cleanup/internal/coverage"
+	"cool-code-cleanup/internal/discovery"
+	"cool-code-cleanup/internal/profile"
+	"cool-code-cleanup/internal/runner"
+)
+
+// coverageStartTimeout allows for slower startup under coverage tools.
+const coverageStartTimeout = 30 * time.Second
+
+// collectCoverage starts the app under coverage once with no requests, then
+// once per route to run that route's invocations (after its dependencies),
+// and attributes what each process executed. env is added to every start.
+func collectCoverage(root, dir string, target appTarget, env []string, routes []discovery.Route, plans []profile.ParameterPlan, deps map[string][]string, tokenRules []runner.TokenRule) coverage.Result {
+	c, err := coverage.NewCollector(root, dir)
+	if err != nil {
+		return coverage.Result{Dir: dir, Warnings: []string{fmt.Sprintf("coverage disabled: %v", err)}}
+	}
+	run := func(name string, subset []discovery.Route) (coverage.Run, int, error) {
+		runDir := filepath.Join(dir, name)
+		opts, err := c.StartOptions(runDir)
+		if err != nil {
+			return coverage.Run{}, 0, err
+		}
+		start := target.withEnv(slices.Concat(env, opts.Env)...)
+		start.Wrap, start.StopSignal = opts.Wrap, opts.StopSignal
+		proc, cmd := runner.Start(root, start)
+		if proc == nil {
+			return coverage.Run{}, 0, fmt.Errorf("app did not start")
+		}
+		if target.Log != nil {
+			target.Log.Note("coverage run %s: started %s", name, cmd)
+		}
+		_ = proc.WaitForHealth(target.HealthURL, target.HealthStatus, max(target.StartupTimeout, coverageStartTimeout))
+		invocations := runner.Execute(target.BaseURL, subset, plans, deps, runner.NewSession(tokenRules), nil)
+		proc.Stop()
+		cov, err := c.Read(runDir)
+		return cov, len(invocations), err
+	}
+
+	startup, _, err := run(coverage.StartupRunID, nil)
+	if err != nil {
+		return coverage.Result{Kind: c.Kind, Dir: dir, Warnings: []string{fmt.Sprintf("startup coverage failed: %v", err)}}
+	}
+	var warnings []string
+	var runs []coverage.RouteRun
+	for i, r := range routes {
+		cov, n, err := run(fmt.Sprintf("route-%03d", i+1), withDependencies(routes, deps, r.ID))
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s %s: coverage failed: %v", r.Method, r.Path, err))
+			continue
+		}
+		runs = append(runs, coverage.RouteRun{RouteID: r.ID, Method: r.Method, Path: r.Path, Invocations: n, Run: cov})
+	}
+	res := coverage.Attribute(c.Kind, dir, startup, runs)
+	res.Warnings = append(res.Warnings, warnings...)
+	return res
+}
+
+// withDependencies returns the route with id and the routes it transitively
+// depends on.
+func withDependencies(routes []discovery.Route, deps map[string][]string, id string) []discovery.Route {
+	need := map[string]bool{}
+	var visit func(string)
+	visit = func(id string) {
+		if need[id] {
+			return
+		}
+		need[id] = true
+		for _, d := range deps[id] {
+			visit(d)
+		}
+	}
+	visit(id)
+	var out []discovery.Route
+	for _, r := range routes {
+		if need[r.ID] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -11,13 +11,19 @@ import (
 
 	"cool-code-cleanup/internal/ai"
 	"cool-code-cleanup/internal/app"
+	"cool-code-cleanup/internal/baseline"
 	"cool-code-cleanup/internal/cleanup"
 	"cool-code-cleanup/internal/config"
+	"cool-code-cleanup/internal/coverage"
 	"cool-code-cleanup/internal/dependency"
 	"cool-code-cleanup/internal/discovery"
 	"cool-code-cleanup/internal/gitflow"
+	"cool-code-cleanup/internal/gorules"
+	"cool-code-cleanup/internal/mockservice"
+	"cool-code-cleanup/internal/perf"
 	"cool-code-cleanup/internal/permission"
 	"cool-code-cleanup/internal/profile"
+	"cool-code-cleanup/internal/regression"
 	"cool-code-cleanup/internal/rules"
 	"cool-code-cleanup/internal/runner"
 	"cool-code-cleanup/internal/shortcircuit"
@@ -33,11 +39,32 @@ type ProfileFlags struct {
 	AIRouteInferenceSet       bool
 	AIDependencyInference     bool
 	AIDependencyInferenceSet  bool
+	AIParameterInference      bool
+	AIParameterInferenceSet   bool
 	RequireAI                 bool
 	RequireAISet              bool
 	EditPermissionMode        string
 	AutoApply                 bool
 	AutoApplySet              bool
+	Iterations                int
+	IterationsSet             bool
+	Warmup                    int
+	WarmupSet                 bool
+	Concurrency               int
+	ConcurrencySet            bool
+	CPUProfile                bool
+	CPUProfileSet             bool
+	PprofURL                  string
+	AttachURL                 string
+	HARPath                   string
+	SaveBaseline              string
+	CompareBaseline           string
+	Coverage                  bool
+	CoverageSet               bool
+	LoadDuration              time.Duration
+	LoadRPS                   float64
+	LoadConcurrency           int
+	LoadMix                   []string
 	CreateBranch              bool
 	CreateBranchSet           bool
 	CommitChanges             bool
@@ -56,9 +83,23 @@ type CleanupFlags struct {
 	CommitChanges      bool
 	CommitChangesSet   bool
 	ShowProgress       bool
+	RegressionHAR      string
+	PatchPath          string
+	VerifyCommand      string
+	Executor           string
 }
 
 var CleanupExecutorFactory = func(cfg config.Config) (cleanup.ProjectExecutor, error) {
+	switch cfg.Cleanup.Executor {
+	case "ast":
+		return gorules.Executor{}, nil
+	case "combined":
+		model, err := ai.NewOpenAIExecutorFromConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return cleanup.Chain{gorules.Executor{}, model}, nil
+	}
 	return ai.NewOpenAIExecutorFromConfig(cfg)
 }
 
@@ -111,7 +152,12 @@ func RunConfigure(rt *app.Runtime) error {
 
 func RunProfile(rt *app.Runtime, flags ProfileFlags) error {
 	io := tui.NewIO(os.Stdin, os.Stdout)
-	mergeProfileFlags(&rt.Effective.Config, flags)
+	if err := runner.ValidateLoadRPS(flags.LoadRPS); err != nil {
+		return err
+	}
+	if err := mergeProfileFlags(&rt.Effective.Config, flags); err != nil {
+		return err
+	}
 
 	// Step 1a: profiling options
 	options := []tui.ToggleItem{
@@ -184,12 +230,28 @@ func RunProfile(rt *app.Runtime, flags ProfileFlags) error {
 	}
 
 	root, _ := os.Getwd()
+	// Load the baseline to compare with now, so a typo fails before profiling.
+	var compareTo *baseline.Baseline
+	if name := strings.TrimSpace(flags.CompareBaseline); name != "" {
+		b, err := loadBaseline(root, name)
+		if err != nil {
+			rt.AddStep("step_4_baseline_compare", "failed", err.Error())
+			return err
+		}
+		compareTo = &b
+	}
+	if name := strings.TrimSpace(flags.SaveBaseline); name != "" {
+		if _, err := baseline.Path(baseline.DefaultDir(), name); err != nil {
+			rt.AddStep("step_4_baseline_save", "failed", err.Error())
+			return err
+		}
+	}
 	routes, err := discovery.Discover(root)
 	if err != nil {
 		rt.AddStep("route_discovery", "failed", err.Error())
 		return err
 	}
-	aiEnabled := rt.Effective.Config.Profile.AIRouteInference || rt.Effective.Config.Profile.AIDependencyInference || rt.Effective.Config.Profile.RequireAI
+	aiEnabled := rt.Effective.Config.Profile.AIRouteInference || rt.Effective.Config.Profile.AIDependencyInference || rt.Effective.Config.Profile.AIParameterInference || rt.Effective.Config.Profile.RequireAI
 	var aiFallback *ai.OpenAIFallback
 	if aiEnabled {
 		var ferr error
@@ -248,7 +310,15 @@ func RunProfile(rt *app.Runtime, flags ProfileFlags) error {
 	} else {
 		fmt.Fprintln(os.Stdout, "AI dependency inference: disabled")
 	}
-	depGraph, err := dependency.Detect(filtered, depFallback)
+	overrides, overridesFound, err := dependency.LoadOverrides(filepath.Join(root, dependency.DefaultOverridesPath()))
+	if err != nil {
+		rt.AddStep("dependency_overrides", "failed", err.Error())
+		return err
+	}
+	if overridesFound {
+		rt.AddStep("dependency_overrides", "completed", "loaded "+dependency.DefaultOverridesPath())
+	}
+	depGraph, err := dependency.Detect(filtered, depFallback, overrides)
 	if err != nil {
 		reason := aiFailureReason(err)
 		fmt.Fprintf(os.Stdout, "AI dependency inference: failed (%s)\n", reason)
@@ -257,8 +327,10 @@ func RunProfile(rt *app.Runtime, flags ProfileFlags) error {
 			return fmt.Errorf("AI dependency inference failed: %w", err)
 		}
 		rt.AddStep("dependency_detection", "failed", err.Error())
-		depGraph, _ = dependency.Detect(filtered, nil)
+		depGraph, _ = dependency.Detect(filtered, nil, overrides)
 	}
+	rt.Report.SourceChains["profile.dependencies"] = depGraph.SourceChain
+	rt.Report.Warnings = append(rt.Report.Warnings, depGraph.Warnings...)
 	rt.AddStep("route_discovery", "completed", fmt.Sprintf("discovered %d routes", len(filtered)))
 	rt.AddStep("dependency_detection", "completed", depGraph.Rationale)
 	if len(depGraph.Dependencies) == 0 {
@@ -345,7 +417,7 @@ func RunProfile(rt *app.Runtime, flags ProfileFlags) error {
 			ps := tui.StepScreen{
 				Mode:        "Profile",
 				StepName:    "Step 1b: Dependency route short-circuiting enhancement",
-				Description: "Select dependency routes to patch with short-circuit markers.",
+				Description: "Select dependency routes to patch with env-guarded short-circuit returns.",
 				Actions: []tui.Action{
 					{Key: "accept", Label: "Accept", Selected: true},
 					{Key: "cancel", Label: "Cancel"},
@@ -371,7 +443,7 @@ func RunProfile(rt *app.Runtime, flags ProfileFlags) error {
 					selected = append(selected, c)
 				}
 			}
-			applied, err := shortcircuit.Apply(selected, envVar, rt.Effective.Config.Modes.DryRun)
+			applied, err := shortcircuit.Apply(selected, envVar, filepath.Join(root, shortcircuit.DefaultManifestDir()), rt.Effective.Config.Modes.DryRun)
 			if err != nil {
 				return err
 			}
@@ -422,24 +494,314 @@ func RunProfile(rt *app.Runtime, flags ProfileFlags) error {
 	rt.AddStep("step_2_route_selection", "completed", "route selection completed")
 
 	selected := selectedRoutes(filtered, routeList)
-	paramPlans := profile.AnalyzeParameters(selected)
-	rt.AddStep("step_3_parameter_analysis", "completed", fmt.Sprintf("generated plans for %d routes", len(paramPlans)))
+	var paramFallback profile.Fallback
+	if rt.Effective.Config.Profile.AIParameterInference {
+		if aiFallback == nil {
+			fmt.Fprintln(os.Stdout, "AI parameter inference: skipped (AI unavailable)")
+		} else {
+			fmt.Fprintln(os.Stdout, "AI parameter inference: enabled")
+			paramFallback = aiFallback
+		}
+	} else {
+		fmt.Fprintln(os.Stdout, "AI parameter inference: disabled")
+	}
+	paramPlans, paramWarnings := profile.AnalyzeParameters(selected, paramFallback)
+	rt.Report.Warnings = append(rt.Report.Warnings, paramWarnings...)
+	planDir := filepath.Join(root, profile.DefaultPlanDir())
+	storedPlans, err := profile.LoadPlans(planDir, selected)
+	if err != nil {
+		rt.AddStep("step_3_parameter_analysis", "failed", err.Error())
+		return err
+	}
+	for i, p := range paramPlans {
+		if stored, ok := storedPlans[p.RouteID]; ok {
+			paramPlans[i] = profile.MergePlan(p, stored)
+		}
+	}
+	rt.AddStep("step_3_parameter_analysis", "completed", fmt.Sprintf("generated plans for %d routes (%d merged with saved plans, %d with AI-proposed values)", len(paramPlans), len(storedPlans), countAISourced(paramPlans)))
+
+	if !rt.Effective.NonInteractive && len(paramPlans) > 0 {
+		edited, canceled, err := reviewParameterPlans(io, selected, paramPlans)
+		if err != nil {
+			return err
+		}
+		if canceled {
+			rt.AddStep("step_3b_parameter_review", "canceled", "user canceled")
+			return nil
+		}
+		rt.AddStep("step_3b_parameter_review", "completed", fmt.Sprintf("parameters reviewed (edited=%t)", edited))
+	}
+	if len(paramPlans) > 0 {
+		written, err := profile.SavePlans(planDir, selected, paramPlans)
+		if err != nil {
+			rt.AddStep("parameter_plans_saved", "failed", err.Error())
+			return err
+		}
+		rt.AddStep("parameter_plans_saved", "completed", fmt.Sprintf("saved %d plans to %s", len(written), profile.DefaultPlanDir()))
+	}
 
 	// Step 4: profiling execution
 	var invocations []runner.Invocation
+	var session *runner.Session
+	var latency []runner.RouteStats
+	var evidence cleanup.Evidence
+	var target appTarget
+	var proc *runner.AppProcess
+	var mockSet *mockservice.Set
+	var tokenRules []runner.TokenRule
 	if len(selected) > 0 {
-		proc, cmd := runner.Start(root)
-		if proc != nil {
-			defer proc.Stop()
-			_ = runner.WaitForHealth("http://127.0.0.1:8000/health", 2*time.Second)
+		mocks, mocksFound, err := mockservice.Load(filepath.Join(root, mockservice.DefaultPath()))
+		if err != nil {
+			rt.AddStep("mock_services", "failed", err.Error())
+			return err
+		}
+		if mocksFound {
+			mockSet, err = mockservice.Start(mocks)
+			if err != nil {
+				rt.AddStep("mock_services", "failed", err.Error())
+				return err
+			}
+			defer mockSet.Stop()
+			rt.AddStep("mock_services", "completed", fmt.Sprintf("started %d mock services from %s", len(mocks.Services), mockservice.DefaultPath()))
+			for _, kv := range mockSet.Env() {
+				fmt.Fprintf(os.Stdout, "Mock service env: %s\n", kv)
+			}
+		}
+		target, err = resolveApp(root, rt.Effective.Config.Profile.App)
+		if err != nil {
+			rt.AddStep("step_4_app", "failed", err.Error())
+			return err
+		}
+		cpuKind, profileDir := "", filepath.Join(root, perf.DefaultRunDir(rt.Report.RunID))
+		var hookPprofURL string
+		if target.Attach {
+			if !runner.WaitForHealth(target.HealthURL, target.HealthStatus, target.StartupTimeout) {
+				err := fmt.Errorf("no healthy app at %s within %s", target.HealthURL, target.StartupTimeout)
+				rt.AddStep("step_4_app", "failed", err.Error())
+				return err
+			}
+			fmt.Fprintf(os.Stdout, "Attached to running app at %s\n", target.BaseURL)
+			rt.AddStep("step_4_app", "completed", "attached to "+target.BaseURL)
+		} else {
+			logPath := runner.DefaultAppLogPath(rt.Report.RunID)
+			if err := target.openLog(root, logPath); err != nil {
+				rt.AddStep("step_4_app", "failed", err.Error())
+				return err
+			}
+			defer target.Log.Close()
+			rt.Report.AppLog = logPath
+			startOpts := target.withEnv(mockSet.Env()...)
+			if rt.Effective.Config.Profile.CPUProfile {
+				prof, err := perf.Setup(root, profileDir)
+				if err != nil {
+					rt.Report.Warnings = append(rt.Report.Warnings, fmt.Sprintf("cpu profiling disabled: %v", err))
+				} else {
+					cpuKind, hookPprofURL = prof.Kind, prof.PprofURL
+					startOpts.Env = append(startOpts.Env, prof.Start.Env...)
+					startOpts.Wrap, startOpts.StopSignal = prof.Start.Wrap, prof.Start.StopSignal
+				}
+			}
+			var cmd string
+			proc, cmd = runner.Start(root, startOpts)
+			fmt.Fprintf(os.Stdout, "App startup command: %s\n", cmd)
+			fmt.Fprintf(os.Stdout, "App log: %s\n", logPath)
+			if proc != nil {
+				target.Log.Note("started %s", cmd)
+				defer proc.Stop()
+				if proc.WaitForHealth(target.HealthURL, target.HealthStatus, target.StartupTimeout) {
+					rt.AddStep("step_4_app", "completed", fmt.Sprintf("started %q; healthy at %s", cmd, target.HealthURL))
+				} else {
+					rt.Report.Warnings = append(rt.Report.Warnings, fmt.Sprintf("app not healthy at %s (it exited or took longer than %s); see %s", target.HealthURL, target.StartupTimeout, logPath))
+					rt.AddStep("step_4_app", "completed", fmt.Sprintf("started %q; not healthy at %s", cmd, target.HealthURL))
+				}
+			}
+		}
+		tokenRules = runner.DefaultTokenRules()
+		sessionRules, sessionFound, err := runner.LoadSessionConfig(filepath.Join(root, runner.DefaultSessionPath()))
+		if err != nil {
+			rt.AddStep("session_rules", "failed", err.Error())
+			return err
+		}
+		if sessionFound {
+			tokenRules = sessionRules.Rules
+			rt.AddStep("session_rules", "completed", fmt.Sprintf("loaded %d token rules from %s", len(tokenRules), runner.DefaultSessionPath()))
+		}
+		session = runner.NewSession(tokenRules)
+		harPath := strings.TrimSpace(flags.HARPath)
+		if harPath == "" && rt.Effective.Config.Profile.HAR.Record {
+			harPath = runner.DefaultHARPath(rt.Report.RunID)
+		}
+		var rec *runner.HARRecorder
+		if harPath != "" {
+			har := rt.Effective.Config.Profile.HAR
+			rec = runner.NewHARRecorder(runner.Redaction{Headers: har.RedactHeaders, Fields: har.RedactFields, OmitBodies: har.OmitBodies})
+		}
+		invocations = runner.Execute(target.BaseURL, selected, paramPlans, depGraph.Dependencies, session, rec)
+		runner.CorrelateLogs(invocations, target.Log)
+		if rec != nil {
+			if err := rec.Save(filepath.Join(root, harPath)); err != nil {
+				rt.AddStep("step_4_har", "failed", err.Error())
+				return err
+			}
+			rt.Report.HAR = harPath
+			rt.AddStep("step_4_har", "completed", fmt.Sprintf("recorded %d requests to %s", len(rec.Entries()), harPath))
 		}
-		fmt.Fprintf(os.Stdout, "App startup command: %s\n", cmd)
-		invocations = runner.Execute("http://127.0.0.1:8000", selected, paramPlans, depGraph.Dependencies)
 		for _, inv := range invocations {
 			fmt.Fprintln(os.Stdout, runner.FormatInvocation(inv))
 		}
+		measureOpts := runner.MeasureOptions{
+			Iterations:  rt.Effective.Config.Profile.Iterations,
+			Warmup:      rt.Effective.Config.Profile.Warmup,
+			Concurrency: rt.Effective.Config.Profile.Concurrency,
+		}
+		if measureOpts.Iterations == 0 {
+			rt.AddStep("step_4_latency", "skipped", "iterations is 0")
+		} else {
+			measured, skipped := selected, 0
+			if !rt.Effective.Config.Profile.MeasureUnsafeMethods {
+				measured, skipped = safeRoutes(selected)
+			}
+			latency = runner.Measure(target.BaseURL, measured, paramPlans, depGraph.Dependencies, session, measureOpts)
+			for _, s := range latency {
+				fmt.Fprintln(os.Stdout, runner.FormatStats(s))
+			}
+			detail := fmt.Sprintf("measured %d routes (%d calls each after %d warm-up, concurrency %d)", len(latency), measureOpts.Iterations, measureOpts.Warmup, measureOpts.Concurrency)
+			if skipped > 0 {
+				detail += fmt.Sprintf("; left out %d routes with methods other than GET, HEAD and OPTIONS (set profile.measure_unsafe_methods to include them)", skipped)
+			}
+			rt.AddStep("step_4_latency", "completed", detail)
+		}
+		var cpuResult *perf.Result
+		if pprofURL := rt.Effective.Config.Profile.PprofURL; rt.Effective.Config.Profile.CPUProfile && (cpuKind == perf.KindGoPprof || pprofURL != "" || target.Attach) {
+			switch {
+			case pprofURL != "":
+				fmt.Fprintf(os.Stdout, "CPU profile: sampling through net/http/pprof at %s\n", pprofURL)
+			case hookPprofURL != "":
+				pprofURL = hookPprofURL
+				fmt.Fprintf(os.Stdout, "CPU profile: sampling through the net/http/pprof listener added to the profiling build at %s\n", pprofURL)
+			default:
+				pprofURL = target.BaseURL + "/debug/pprof"
+				fmt.Fprintf(os.Stdout, "CPU profile: sampling through the app's own net/http/pprof handler at %s\n", pprofURL)
+			}
+			res := profileGoRoutes(pprofURL, profileDir, target.BaseURL, selected, paramPlans, session)
+			cpuResult = &res
+		}
+		if flags.LoadDuration > 0 {
+			mix, err := runner.ParseLoadMix(flags.LoadMix)
+			if err != nil {
+				rt.AddStep("step_4_load", "failed", err.Error())
+				return err
+			}
+			fmt.Fprintf(os.Stdout, "Load test: running for %s\n", flags.LoadDuration)
+			load, err := runner.Load(target.BaseURL, selected, paramPlans, depGraph.Dependencies, session, runner.LoadOptions{
+				Targets:     mix,
+				Duration:    flags.LoadDuration,
+				RPS:         flags.LoadRPS,
+				Concurrency: flags.LoadConcurrency,
+			})
+			if err != nil {
+				rt.AddStep("step_4_load", "failed", err.Error())
+				return err
+			}
+			fmt.Fprintln(os.Stdout, runner.FormatLoadResult(load))
+			rt.Report.LoadTest = load
+			rt.AddStep("step_4_load", "completed", fmt.Sprintf("%d requests at %.1f req/s (p95 %.1fms, errors %.1f%%)", load.Requests, load.ThroughputRPS, load.LatencyMs.P95, load.ErrorRate*100))
+		}
+		if cpuResult == nil && cpuKind == perf.KindNodeCPUProf {
+			res := collectNodeProfiles(profileDir, proc, latency)
+			cpuResult = &res
+		}
+		if cpuResult == nil && cpuKind == perf.KindPythonCProfile {
+			// Each route is profiled in a process of its own; free the port
+			// first.
+			proc.Stop()
+			fmt.Fprintf(os.Stdout, "CPU profile: restarting the app under cProfile for each of %d routes\n", len(selected))
+			res := profilePythonRoutes(root, profileDir, target, mockSet.Env(), selected, paramPlans, depGraph.Dependencies, tokenRules)
+			cpuResult = &res
+		}
+		if cpuResult != nil {
+			for _, rp := range cpuResult.Routes {
+				fmt.Fprintf(os.Stdout, "cpu %s %s: %s\n", rp.Method, rp.Path, formatTopFunctions(rp.CPU, 3))
+			}
+			if len(cpuResult.Routes) == 0 && len(cpuResult.Top) > 0 {
+				fmt.Fprintf(os.Stdout, "cpu (whole run): %s\n", formatTopFunctions(cpuResult.Top, 5))
+			}
+			rt.Report.Warnings = append(rt.Report.Warnings, cpuResult.Warnings...)
+			rt.Report.CPUProfile = cpuResult
+			evidence.Hotspots = perf.Hotspots(*cpuResult, root, maxHotspots)
+			if len(evidence.Hotspots) > 0 {
+				if err := cleanup.SaveHotspots(filepath.Join(root, cleanup.DefaultHotspotsPath()), rt.Report.RunID, evidence.Hotspots); err != nil {
+					rt.Report.Warnings = append(rt.Report.Warnings, err.Error())
+				}
+			}
+			switch {
+			case len(cpuResult.Routes) > 0:
+				rt.AddStep("step_4_cpu_profile", "completed", fmt.Sprintf("%s: profiled %d routes, %d project hotspots (raw profiles in %s)", cpuResult.Kind, len(cpuResult.Routes), len(evidence.Hotspots), perf.DefaultRunDir(rt.Report.RunID)))
+			case len(cpuResult.Top) > 0:
+				rt.AddStep("step_4_cpu_profile", "completed", fmt.Sprintf("%s: whole run only, no per-route attribution; %d project hotspots (raw profiles in %s)", cpuResult.Kind, len(evidence.Hotspots), perf.DefaultRunDir(rt.Report.RunID)))
+			default:
+				detail := "no samples collected"
+				if len(cpuResult.Warnings) > 0 {
+					detail = cpuResult.Warnings[0]
+				}
+				rt.AddStep("step_4_cpu_profile", "skipped", cpuResult.Kind+": "+detail)
+			}
+		}
+		if rt.Effective.Config.Profile.Coverage && target.Attach {
+			rt.Report.Warnings = append(rt.Report.Warnings, "coverage skipped: it restarts the app, which is not possible when attaching")
+		} else if rt.Effective.Config.Profile.Coverage {
+			// Coverage restarts the app per route; free the port first.
+			proc.Stop()
+			covDir := filepath.Join(root, coverage.DefaultRunDir(rt.Report.RunID))
+			fmt.Fprintf(os.Stdout, "Coverage: restarting the app for startup and each of %d routes\n", len(selected))
+			cov := collectCoverage(root, covDir, target, mockSet.Env(), selected, paramPlans, depGraph.Dependencies, tokenRules)
+			for _, rc := range cov.Routes {
+				fmt.Fprintf(os.Stdout, "coverage %s %s: %d functions in %d files beyond startup\n", rc.Method, rc.Path, len(rc.Functions), len(rc.Files))
+			}
+			rt.Report.Warnings = append(rt.Report.Warnings, cov.Warnings...)
+			rt.Report.Coverage = cov
+			for _, f := range cov.UnexecutedFunctions {
+				evidence.UnexecutedFunctions = append(evidence.UnexecutedFunctions, cleanup.Unexecuted{File: f.File, Function: f.Name, Line: f.Line})
+			}
+			evidence.UnexecutedFiles = cov.UnexecutedFiles
+			rt.AddStep("step_4_coverage", "completed", fmt.Sprintf("%s: %d routes covered; %d functions and %d files never executed", cov.Kind, len(cov.Routes), len(cov.UnexecutedFunctions), len(cov.UnexecutedFiles)))
+		}
+		if mockSet != nil {
+			rt.Report.MockServices = mockSet.Summary()
+		}
+	}
+	findings := runner.Findings(invocations)
+	for _, f := range findings {
+		if len(f.Crashes) > 0 {
+			rt.Report.Warnings = append(rt.Report.Warnings, fmt.Sprintf("%s %s returned 5xx for: %s", f.Method, f.Path, strings.Join(f.Crashes, ", ")))
+		}
+		if len(f.AcceptedInvalid) > 0 {
+			rt.Report.Warnings = append(rt.Report.Warnings, fmt.Sprintf("%s %s accepted invalid input: %s", f.Method, f.Path, strings.Join(f.AcceptedInvalid, ", ")))
+		}
+	}
+	rt.AddStep("step_4_profiling", "completed", fmt.Sprintf("executed %d invocations; %d routes flagged", len(invocations), len(findings)))
+
+	// Step 4b: baseline comparison and storage. Comparing first lets a run
+	// compare with and then replace the same baseline. Regressions fail the
+	// run only once cleanup has been proposed.
+	var baselineErr error
+	if compareTo != nil || strings.TrimSpace(flags.SaveBaseline) != "" {
+		current := baseline.Build(strings.TrimSpace(flags.SaveBaseline), rt.Report.RunID, invocations, latency)
+		summary := map[string]any{}
+		if compareTo != nil {
+			cmp, err := compareBaseline(rt, *compareTo, current)
+			summary["comparison"] = cmp
+			baselineErr = err
+		}
+		if name := strings.TrimSpace(flags.SaveBaseline); name != "" {
+			path, err := saveBaseline(rt, root, name, current)
+			if err != nil {
+				return err
+			}
+			summary["saved"] = path
+		}
+		rt.Report.Baseline = summary
 	}
-	rt.AddStep("step_4_profiling", "completed", fmt.Sprintf("executed %d invocations", len(invocations)))
 
 	// Step 5: cleanup proposal
 	defaultRules := rules.DefaultRules().Rules
@@ -449,7 +811,7 @@ func RunProfile(rt *app.Runtime, flags ProfileFlags) error {
 			selectedRules = append(selectedRules, r)
 		}
 	}
-	cplan, err := cleanup.BuildPlan(root, selectedRules, rt.Effective.Config.Modes.Safe, rt.Effective.Config.Modes.Aggressive)
+	cplan, err := cleanup.BuildPlan(root, selectedRules, rt.Effective.Config.Modes.Safe, rt.Effective.Config.Modes.Aggressive, evidence)
 	if err != nil {
 		return err
 	}
@@ -464,7 +826,7 @@ func RunProfile(rt *app.Runtime, flags ProfileFlags) error {
 	}
 	var approvedPlan cleanup.Plan
 	for file, edits := range fileGroups {
-		ok, err := perm.ApproveFile(io, file, len(edits))
+		ok, err := perm.ApproveFile(io, file, len(edits), cleanup.Patch(edits))
 		if err != nil {
 			return err
 		}
@@ -472,7 +834,7 @@ func RunProfile(rt *app.Runtime, flags ProfileFlags) error {
 			continue
 		}
 		for _, e := range edits {
-			ok, err := perm.ApproveEdit(io, e.File, e.Description)
+			ok, err := perm.ApproveEdit(io, e.File, e.Description, e.Diff)
 			if err != nil {
 				return err
 			}
@@ -481,16 +843,57 @@ func RunProfile(rt *app.Runtime, flags ProfileFlags) error {
 			}
 		}
 	}
+	beforeCleanup, err := cleanup.ReadFiles(editedFiles(approvedPlan.Edits))
+	if err != nil {
+		return err
+	}
+
+	// Step 5b, first half: the regression check repeats the safe routes on a
+	// freshly started app before the edits are written, so both sides start
+	// from the same state.
+	reg := rt.Effective.Config.Profile.Regression
+	var check *regressionCheck
+	var regRoutes []discovery.Route
+	var before []regression.Response
+	if reg.Enabled && !rt.Effective.Config.Modes.DryRun && len(approvedPlan.Edits) > 0 && len(invocations) > 0 {
+		if target.Attach {
+			rt.Report.Warnings = append(rt.Report.Warnings, "regression check skipped: it restarts the app, which is not possible when attaching")
+		} else {
+			var skipped int
+			regRoutes, skipped = regressionRoutes(selected, depGraph.Dependencies)
+			if skipped > 0 {
+				rt.Report.Warnings = append(rt.Report.Warnings, fmt.Sprintf("regression check: %d routes with methods that change state are not repeated", skipped))
+			}
+			// Free the port for the restart.
+			proc.Stop()
+			fmt.Fprintf(os.Stdout, "Regression check: restarting the app to repeat %d routes before cleanup\n", len(regRoutes))
+			check = &regressionCheck{root: root, target: target, env: mockSet.Env(), tokenRules: tokenRules, cfg: reg}
+			if before, err = check.execute("before cleanup", regRoutes, paramPlans, depGraph.Dependencies); err != nil {
+				rt.Report.Warnings = append(rt.Report.Warnings, fmt.Sprintf("regression check skipped: app before cleanup: %v", err))
+				check = nil
+			}
+		}
+	}
 	applied, err := cleanup.ApplyPlan(approvedPlan, rt.Effective.Config.Modes.Safe, rt.Effective.Config.Modes.Aggressive, rt.Effective.Config.Modes.DryRun)
 	if err != nil {
 		return err
 	}
 	rt.AddStep("step_5_cleanup", "completed", fmt.Sprintf("applied %d edits", countApplied(applied)))
 
+	var sessionTokens []runner.CapturedToken
+	if session != nil {
+		sessionTokens = session.Summary()
+	}
 	rt.Report.Routes = map[string]any{
-		"discovered":   filtered,
-		"selected":     selected,
-		"dependencies": depGraph.Dependencies,
+		"discovered":           filtered,
+		"selected":             selected,
+		"dependencies":         depGraph.Dependencies,
+		"dependency_sources":   depGraph.SourceChain,
+		"dependency_sequences": depGraph.Sequences,
+		"parameter_plans":      paramPlans,
+		"robustness_findings":  findings,
+		"session_tokens":       sessionTokens,
+		"latency":              latency,
 	}
 	for _, inv := range invocations {
 		rt.Report.ProfilingRuns = append(rt.Report.ProfilingRuns, inv)
@@ -502,6 +905,15 @@ func RunProfile(rt *app.Runtime, flags ProfileFlags) error {
 		rt.Report.AppliedChanges = append(rt.Report.AppliedChanges, e)
 	}
 
+	// Step 5b: behavioral regression check
+	if changed := appliedFiles(applied); check != nil && len(changed) > 0 {
+		fmt.Fprintf(os.Stdout, "Regression check: restarting the app to repeat %d routes after cleanup\n", len(regRoutes))
+		after, err := check.execute("after cleanup", regRoutes, paramPlans, depGraph.Dependencies)
+		if err := check.compare(rt, io, "step_5_regression_check", before, after, err, beforeCleanup, changed); err != nil {
+			return err
+		}
+	}
+
 	if rt.Effective.Config.Git.AutoOfferBranchAndCommit && !rt.Effective.Config.Modes.DryRun {
 		createBranch, commitChanges, err := decideGitActions(rt.Effective.NonInteractive, flags.CreateBranchSet, flags.CreateBranch, flags.CommitChangesSet, flags.CommitChanges, io)
 		if err != nil {
@@ -530,7 +942,7 @@ func RunProfile(rt *app.Runtime, flags ProfileFlags) error {
 			rt.Report.Git = gitMeta
 		}
 	}
-	return nil
+	return baselineErr
 }
 
 func RunCleanup(rt *app.Runtime, flags CleanupFlags) error {
@@ -543,6 +955,16 @@ func RunCleanup(rt *app.Runtime, flags CleanupFlags) error {
 		rt.Effective.Config.Cleanup.EditPermissionMode = flags.EditPermissionMode
 	}
 	rt.Effective.Config.Cleanup.AutoApply = flags.AutoApply
+	if cmd := strings.TrimSpace(flags.VerifyCommand); cmd != "" {
+		rt.Effective.Config.Cleanup.Verify.Command = cmd
+	}
+	switch flags.Executor {
+	case "":
+	case "ai", "ast", "combined":
+		rt.Effective.Config.Cleanup.Executor = flags.Executor
+	default:
+		return fmt.Errorf("invalid --executor %q (expected ai, ast or combined)", flags.Executor)
+	}
 
 	rulesPath := flags.RulesPath
 	if strings.TrimSpace(rulesPath) == "" {
@@ -626,6 +1048,14 @@ func RunCleanup(rt *app.Runtime, flags CleanupFlags) error {
 
 	rt.AddStep("cleanup_phase_2_planning", "in_progress", "building multi-file cleanup task plan")
 	tasks := cleanup.BuildTaskPlan(snapshot, selectedRules)
+	hotspots, hotspotsFound, err := cleanup.LoadHotspots(filepath.Join(root, cleanup.DefaultHotspotsPath()))
+	if err != nil {
+		rt.Report.Warnings = append(rt.Report.Warnings, err.Error())
+	}
+	if hotspotsFound {
+		tasks = cleanup.AnnotateHotspots(tasks, hotspots)
+		rt.AddStep("cleanup_hotspots", "completed", fmt.Sprintf("loaded %d profiled hotspots from %s", len(hotspots), cleanup.DefaultHotspotsPath()))
+	}
 	rt.AddStep("cleanup_phase_2_planning", "completed", fmt.Sprintf("planned %d tasks", len(tasks)))
 
 	dryRun := rt.Effective.Config.Modes.DryRun
@@ -639,22 +1069,69 @@ func RunCleanup(rt *app.Runtime, flags CleanupFlags) error {
 			dryRun = true
 		}
 	}
+	var check *regressionCheck
+	var har runner.HAR
+	var baseline []regression.Response
+	if reg := rt.Effective.Config.Profile.Regression; reg.Enabled && !dryRun {
+		c, h, base, stop, err := cleanupBaseline(rt, root, flags.RegressionHAR)
+		if err != nil {
+			return err
+		}
+		defer stop()
+		check, har, baseline = c, h, base
+	}
+	// Check each task's edits with the project's build and tests, if the
+	// project passes them to begin with.
+	var verify func() error
+	if cmd := cleanup.ResolveVerifyCommand(root, rt.Effective.Config.Cleanup.Verify.Command); cmd != "" && !dryRun {
+		verifier := cleanup.Verifier{Dir: root, Command: cmd, Timeout: time.Duration(rt.Effective.Config.Cleanup.Verify.TimeoutSeconds) * time.Second}
+		fmt.Fprintf(os.Stdout, "Verification: running %s before cleanup\n", cmd)
+		if err := verifier.Run(); err != nil {
+			rt.Report.Warnings = append(rt.Report.Warnings, "verification skipped: the project fails it before cleanup: "+err.Error())
+			rt.AddStep("cleanup_verify_baseline", "completed", fmt.Sprintf("skipped (%s fails before cleanup)", cmd))
+		} else {
+			verify = verifier.Run
+			rt.AddStep("cleanup_verify_baseline", "completed", fmt.Sprintf("%s passes; it runs after each task", cmd))
+		}
+	}
 	rt.AddStep("cleanup_phase_3_execution", "in_progress", "executing project-level cleanup tasks")
 	var progress func(cleanup.ProgressEvent)
 	var spinnerStop func()
+	var spinner *spinnerDisplay
 	if flags.ShowProgress {
-		var spinner *spinnerDisplay
 		spinner = newSpinnerDisplay()
 		spinner.Start()
 		spinnerStop = spinner.Stop
 		progress = func(ev cleanup.ProgressEvent) {
 			spinner.Update(fmt.Sprintf("%s: %s | %s", ev.Phase, filepath.Base(ev.File), ev.RuleTitle))
-			if ev.Phase == "changed" {
+			switch ev.Phase {
+			case "changed":
 				fmt.Fprintf(os.Stdout, "\r\x1b[2Kchanged: %s | %s | %s\r\n", ev.File, ev.RuleTitle, ev.Description)
+			case "reverted":
+				fmt.Fprintf(os.Stdout, "\r\x1b[2Kreverted: %s | %s\r\n", ev.RuleTitle, ev.Description)
+			case "invalid":
+				fmt.Fprintf(os.Stdout, "\r\x1b[2Kskipped (does not parse): %s | %s | %s\r\n", ev.File, ev.RuleTitle, firstLine(ev.Description))
 			}
 		}
 	}
-	plan, applied, taskResults, err := cleanup.ExecuteTaskPlan(root, snapshot, tasks, selectedRules, rt.Effective.Config.Modes.Safe, rt.Effective.Config.Modes.Aggressive, dryRun, executor, progress)
+	// Review what the tasks write hunk by hunk, unless edits are applied
+	// without prompts.
+	var review func(cleanup.FileChange) (string, error)
+	if !dryRun && !rt.Effective.NonInteractive && !rt.Effective.Config.Cleanup.AutoApply {
+		reviewer := newHunkReviewer(io, root, rt.Effective.Config.Cleanup.EditPermissionMode)
+		if spinner != nil {
+			reviewer.pause, reviewer.resume = spinner.Pause, spinner.Resume
+		}
+		review = reviewer.review
+	}
+	// Files that no longer parse are skipped before review and writing.
+	syntax := cleanup.SyntaxChecker{Commands: rt.Effective.Config.Cleanup.Syntax.Checkers}
+	plan, applied, taskResults, err := cleanup.ExecuteTaskPlan(root, snapshot, tasks, selectedRules, rt.Effective.Config.Modes.Safe, rt.Effective.Config.Modes.Aggressive, dryRun, executor, cleanup.ExecuteOptions{
+		Check:      syntax.Check,
+		Review:     review,
+		Verify:     verify,
+		OnProgress: progress,
+	})
 	if spinnerStop != nil {
 		spinnerStop()
 	}
@@ -663,6 +1140,14 @@ func RunCleanup(rt *app.Runtime, flags CleanupFlags) error {
 		return err
 	}
 	rt.AddStep("cleanup_phase_3_execution", "completed", fmt.Sprintf("executed %d tasks", len(taskResults)))
+	for _, r := range taskResults {
+		if !r.Applied && len(r.ChangedFiles) > 0 && r.Error != "" {
+			rt.Report.Warnings = append(rt.Report.Warnings, fmt.Sprintf("task %s reverted: %s", r.TaskID, firstLine(r.Error)))
+		}
+		for _, f := range r.InvalidSyntax {
+			rt.Report.Warnings = append(rt.Report.Warnings, fmt.Sprintf("task %s skipped a file that does not parse: %s", r.TaskID, firstLine(f)))
+		}
+	}
 	rt.AddStep("cleanup_step_2", "completed", fmt.Sprintf("applied %d AI rule edits", countApplied(applied)))
 	for _, e := range plan.Edits {
 		rt.Report.CleanupPlan = append(rt.Report.CleanupPlan, e)
@@ -673,6 +1158,16 @@ func RunCleanup(rt *app.Runtime, flags CleanupFlags) error {
 	rt.Report.CleanupPlan = append(rt.Report.CleanupPlan, map[string]any{
 		"tasks": taskResults,
 	})
+	if err := writeDiffs(rt, applied, dryRun, flags.PatchPath); err != nil {
+		return err
+	}
+	if changed := appliedFiles(applied); check != nil && len(changed) > 0 {
+		fmt.Fprintf(os.Stdout, "Regression check: restarting the app to replay %d requests after cleanup\n", len(har.Log.Entries))
+		after, err := check.replay("after cleanup", har)
+		if err := check.compare(rt, io, "cleanup_regression_check", baseline, after, err, snapshot, changed); err != nil {
+			return err
+		}
+	}
 	if rt.Effective.Config.Git.AutoOfferBranchAndCommit && !dryRun {
 		createBranch, commitChanges, err := decideGitActions(rt.Effective.NonInteractive, flags.CreateBranchSet, flags.CreateBranch, flags.CommitChangesSet, flags.CommitChanges, io)
 		if err != nil {
@@ -704,7 +1199,7 @@ func RunCleanup(rt *app.Runtime, flags CleanupFlags) error {
 	return nil
 }
 
-func mergeProfileFlags(cfg *config.Config, flags ProfileFlags) {
+func mergeProfileFlags(cfg *config.Config, flags ProfileFlags) error {
 	if len(flags.IncludeRoutes) > 0 {
 		cfg.Profile.IncludeRoutes = slices.Clone(flags.IncludeRoutes)
 	}
@@ -723,12 +1218,46 @@ func mergeProfileFlags(cfg *config.Config, flags ProfileFlags) {
 	if flags.AIDependencyInferenceSet {
 		cfg.Profile.AIDependencyInference = flags.AIDependencyInference
 	}
+	if flags.AIParameterInferenceSet {
+		cfg.Profile.AIParameterInference = flags.AIParameterInference
+	}
 	if flags.RequireAISet {
 		cfg.Profile.RequireAI = flags.RequireAI
 	}
 	if flags.AutoApplySet {
 		cfg.Profile.AutoApply = flags.AutoApply
 	}
+	if flags.IterationsSet {
+		if flags.Iterations < 0 {
+			return fmt.Errorf("invalid --iterations %d (expected 0 to skip latency measurement, or more)", flags.Iterations)
+		}
+		cfg.Profile.Iterations = flags.Iterations
+	}
+	if flags.WarmupSet {
+		if flags.Warmup < 0 {
+			return fmt.Errorf("invalid --warmup %d (expected 0 or more)", flags.Warmup)
+		}
+		cfg.Profile.Warmup = flags.Warmup
+	}
+	if flags.ConcurrencySet {
+		if flags.Concurrency < 1 {
+			return fmt.Errorf("invalid --concurrency %d (expected at least 1)", flags.Concurrency)
+		}
+		cfg.Profile.Concurrency = flags.Concurrency
+	}
+	if flags.CPUProfileSet {
+		cfg.Profile.CPUProfile = flags.CPUProfile
+	}
+	if flags.CoverageSet {
+		cfg.Profile.Coverage = flags.Coverage
+	}
+	if strings.TrimSpace(flags.PprofURL) != "" {
+		cfg.Profile.PprofURL = strings.TrimSpace(flags.PprofURL)
+	}
+	if strings.TrimSpace(flags.AttachURL) != "" {
+		cfg.Profile.App.AttachURL = strings.TrimSpace(flags.AttachURL)
+	}
+	return nil
 }
 
 func filterRoutes(routes []discovery.Route, include, ignore []string) []discovery.Route {
@@ -834,6 +1363,36 @@ func selectedRoutes(routes []discovery.Route, list tui.ToggleList) []discovery.R
 	return out
 }
 
+// writeDiffs writes the edits' diffs to patchPath when it is set, or prints
+// them in dry-run so the simulated changes can be reviewed.
+func writeDiffs(rt *app.Runtime, edits []cleanup.Edit, dryRun bool, patchPath string) error {
+	patch := cleanup.Patch(edits)
+	if patchPath == "" {
+		if dryRun && patch != "" {
+			fmt.Fprint(os.Stdout, patch)
+		}
+		return nil
+	}
+	if dir := filepath.Dir(patchPath); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			rt.AddStep("cleanup_patch", "failed", err.Error())
+			return fmt.Errorf("create patch directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(patchPath, []byte(patch), 0o644); err != nil {
+		rt.AddStep("cleanup_patch", "failed", err.Error())
+		return fmt.Errorf("write patch %s: %w", patchPath, err)
+	}
+	rt.Report.Patch = patchPath
+	rt.AddStep("cleanup_patch", "completed", fmt.Sprintf("wrote diffs of %d edits to %s", len(edits), patchPath))
+	return nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
 func countApplied(edits []cleanup.Edit) int {
 	count := 0
 	for _, e := range edits {
@@ -966,6 +1525,7 @@ func ruleIDs(items []rules.Rule) []string {
 type spinnerDisplay struct {
 	mu     sync.Mutex
 	status string
+	paused bool
 	stopCh chan struct{}
 	doneCh chan struct{}
 }
@@ -991,8 +1551,11 @@ func (s *spinnerDisplay) Start() {
 				return
 			case <-t.C:
 				s.mu.Lock()
-				status := s.status
+				status, paused := s.status, s.paused
 				s.mu.Unlock()
+				if paused {
+					continue
+				}
 				fmt.Fprintf(os.Stdout, "\r\x1b[2K%s %s", frames[i%len(frames)], status)
 				i++
 			}
@@ -1006,6 +1569,21 @@ func (s *spinnerDisplay) Update(status string) {
 	s.mu.Unlock()
 }
 
+// Pause clears the spinner line and stops drawing until Resume, so prompts
+// can use the terminal.
+func (s *spinnerDisplay) Pause() {
+	s.mu.Lock()
+	s.paused = true
+	s.mu.Unlock()
+	fmt.Fprint(os.Stdout, "\r\x1b[2K")
+}
+
+func (s *spinnerDisplay) Resume() {
+	s.mu.Lock()
+	s.paused = false
+	s.mu.Unlock()
+}
+
 func (s *spinnerDisplay) Stop() {
 	close(s.stopCh)
 	<-s.doneCh
@@ -2,15 +2,21 @@ package mode
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
 	"cool-code-cleanup/internal/app"
 	"cool-code-cleanup/internal/cleanup"
 	"cool-code-cleanup/internal/config"
+	"cool-code-cleanup/internal/discovery"
+	"cool-code-cleanup/internal/profile"
+	"cool-code-cleanup/internal/regression"
 	"cool-code-cleanup/internal/rules"
+	"cool-code-cleanup/internal/tui"
 )
 
 func TestRunProfileNonInteractive(t *testing.T) {
@@ -198,3 +204,196 @@ func copyDir(src, dst string) error {
 		return os.WriteFile(target, data, 0o644)
 	})
 }
+
+func TestReviewParameterPlansAppliesEdits(t *testing.T) {
+	routes := []discovery.Route{{ID: "r1", Method: "POST", Path: "/orders", Framework: "node"}}
+	plans := []profile.ParameterPlan{profile.BuildPlan("r1", []profile.Param{
+		{Name: "tenant", In: profile.InBody, Type: profile.TypeString, Required: true, Source: profile.SourceZod},
+	})}
+	input := "edit 2\nedit 1\nset body.tenant=\"t-42\"\nset header.X-Tenant=t-42\ndone\naccept\n"
+	var out strings.Builder
+	edited, canceled, err := reviewParameterPlans(tui.NewIO(strings.NewReader(input), &out), routes, plans)
+	if err != nil || canceled || !edited {
+		t.Fatalf("review: edited=%v canceled=%v err=%v", edited, canceled, err)
+	}
+	if !strings.Contains(out.String(), "route number must be between 1 and 1") {
+		t.Fatalf("expected inline error for out-of-range route:\n%s", out.String())
+	}
+	valid := plans[0].Valid[0]
+	if valid.Body["tenant"] != "t-42" || valid.Header["X-Tenant"] != "t-42" {
+		t.Fatalf("edits not applied: %+v", valid)
+	}
+}
+
+func TestResolveAppReadsEnvFilesAndAllocatesPort(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".env.test"), []byte("DB_URL=sqlite://file\nMODE=test\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig().Profile.App
+	cfg.Port = 0
+	cfg.Dir = "api"
+	cfg.EnvFiles = []string{".env.test"}
+	cfg.Env = map[string]string{"MODE": "profile"}
+	target, err := resolveApp(root, cfg)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if target.Start.Port == 0 || target.BaseURL != fmt.Sprintf("http://127.0.0.1:%d", target.Start.Port) || target.HealthURL != target.BaseURL+"/health" {
+		t.Fatalf("unexpected target %+v", target)
+	}
+	if target.Start.Dir != filepath.Join(root, "api") {
+		t.Fatalf("expected dir relative to root, got %q", target.Start.Dir)
+	}
+	if want := []string{"DB_URL=sqlite://file", "MODE=test", "MODE=profile"}; !slices.Equal(target.Start.Env, want) {
+		t.Fatalf("unexpected env %v", target.Start.Env)
+	}
+
+	cfg.AttachURL = "http://localhost:3000/"
+	target, err = resolveApp(root, cfg)
+	if err != nil {
+		t.Fatalf("resolve attach: %v", err)
+	}
+	if !target.Attach || target.BaseURL != "http://localhost:3000" || target.HealthURL != "http://localhost:3000/health" {
+		t.Fatalf("unexpected attach target %+v", target)
+	}
+}
+
+func TestRegressionRoutesKeepSafeMethodsAndTheirDependencies(t *testing.T) {
+	routes := []discovery.Route{
+		{ID: "login", Method: "POST", Path: "/login"},
+		{ID: "orders", Method: "GET", Path: "/orders"},
+		{ID: "create", Method: "POST", Path: "/orders"},
+		{ID: "delete", Method: "DELETE", Path: "/orders/{id}"},
+	}
+	got, skipped := regressionRoutes(routes, map[string][]string{"orders": {"login"}, "delete": {"create"}})
+	if len(got) != 2 || got[0].ID != "login" || got[1].ID != "orders" || skipped != 2 {
+		t.Fatalf("unexpected routes %+v, skipped %d", got, skipped)
+	}
+}
+
+func TestSafeRoutesLeaveOutUnsafeMethods(t *testing.T) {
+	routes := []discovery.Route{
+		{ID: "orders", Method: "get", Path: "/orders"},
+		{ID: "create", Method: "POST", Path: "/orders"},
+		{ID: "options", Method: "OPTIONS", Path: "/orders"},
+	}
+	got, skipped := safeRoutes(routes)
+	if len(got) != 2 || got[0].ID != "orders" || got[1].ID != "options" || skipped != 1 {
+		t.Fatalf("unexpected routes %+v, skipped %d", got, skipped)
+	}
+}
+
+func TestMergeProfileFlagsMeasurementCounts(t *testing.T) {
+	cfg := config.DefaultConfig()
+	if err := mergeProfileFlags(&cfg, ProfileFlags{Iterations: 0, IterationsSet: true, Warmup: 0, WarmupSet: true}); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if cfg.Profile.Iterations != 0 || cfg.Profile.Warmup != 0 {
+		t.Fatalf("expected zero counts kept, got %+v", cfg.Profile)
+	}
+	for _, flags := range []ProfileFlags{
+		{Iterations: -1, IterationsSet: true},
+		{Warmup: -1, WarmupSet: true},
+		{Concurrency: 0, ConcurrencySet: true},
+	} {
+		if err := mergeProfileFlags(&cfg, flags); err == nil {
+			t.Fatalf("expected %+v to be rejected", flags)
+		}
+	}
+}
+
+func TestRegressionCheckRollsBackChangedBehavior(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "handler.js")
+	if err := os.WriteFile(file, []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := cleanup.ReadFiles([]string{file})
+	if err != nil {
+		t.Fatalf("read files: %v", err)
+	}
+	if err := os.WriteFile(file, []byte("cleaned"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig().Profile.Regression
+	cfg.OnChange = "rollback"
+	check := regressionCheck{cfg: cfg}
+	rt := app.NewRuntime("cleanup", config.Effective{Config: config.DefaultConfig(), NonInteractive: true})
+	before := []regression.Response{{Method: "GET", URL: "/orders", Status: 200, Body: []byte(`{"total":3,"created_at":"a"}`)}}
+	same := []regression.Response{{Method: "GET", URL: "/orders", Status: 200, Body: []byte(`{"total":3,"created_at":"b"}`)}}
+	if err := check.compare(rt, tui.IO{}, "regression", before, same, nil, snapshot, []string{file}); err != nil {
+		t.Fatalf("expected volatile-only difference to pass, got %v", err)
+	}
+
+	changed := []regression.Response{{Method: "GET", URL: "/orders", Status: 200, Body: []byte(`{"total":4}`)}}
+	if err := check.compare(rt, tui.IO{}, "regression", before, changed, nil, snapshot, []string{file}); err == nil {
+		t.Fatalf("expected changed behavior to fail the run")
+	}
+	if raw, _ := os.ReadFile(file); string(raw) != "original" {
+		t.Fatalf("expected %s to be rolled back, got %q", file, raw)
+	}
+	if last := rt.Report.Steps[len(rt.Report.Steps)-1]; last.Status != "failed" || !strings.Contains(last.Message, "rolled back 1 files") {
+		t.Fatalf("unexpected step %+v", last)
+	}
+}
+
+func TestHunkReviewerWritesOnlyApprovedHunks(t *testing.T) {
+	var before, after strings.Builder
+	before.WriteString("package main\n\nfunc a() {\n")
+	after.WriteString("package main\n\nfunc a() {\n")
+	for i := 1; i <= 30; i++ {
+		fmt.Fprintf(&before, "\tx%d()\n", i)
+		switch i {
+		case 2:
+			after.WriteString("\tfirst()\n")
+		case 15:
+			after.WriteString("\tsecond()\n")
+		case 28:
+			after.WriteString("\tthird()\n")
+		default:
+			fmt.Fprintf(&after, "\tx%d()\n", i)
+		}
+	}
+	before.WriteString("}\n")
+	after.WriteString("}\n")
+	change := cleanup.FileChange{
+		Task:   cleanup.Task{RuleID: "dry_refactor", RuleTitle: "DRY"},
+		Path:   "/repo/main.go",
+		Before: before.String(),
+		After:  after.String(),
+	}
+
+	var out strings.Builder
+	r := newHunkReviewer(tui.NewIO(strings.NewReader("bogus\nr\ne\na\n"), &out), "/repo", "per-edit")
+	r.edit = func(text string) (string, error) {
+		if !strings.Contains(text, "+\tsecond()\n") {
+			t.Fatalf("editor got %q", text)
+		}
+		return strings.Replace(text, "+\tsecond()\n", "+\tsecond(edited)\n", 1), nil
+	}
+	got, err := r.review(change)
+	if err != nil {
+		t.Fatalf("review: %v", err)
+	}
+	want := strings.Replace(strings.Replace(after.String(), "\tfirst()\n", "\tx2()\n", 1), "second()", "second(edited)", 1)
+	if got != want {
+		t.Fatalf("unexpected result:\n%s", got)
+	}
+	for _, s := range []string{"Review main.go (hunk 1/3)", "[dry_refactor] DRY", "@@ -2,7 +2,7 @@ func a() {", "Error: unknown command"} {
+		if !strings.Contains(out.String(), s) {
+			t.Fatalf("review output missing %q:\n%s", s, out.String())
+		}
+	}
+
+	// Accepting all for the rule applies to later files without asking.
+	r = newHunkReviewer(tui.NewIO(strings.NewReader("u\n"), &out), "/repo", "per-file")
+	if got, err := r.review(change); err != nil || got != change.After {
+		t.Fatalf("accept for rule: err=%v", err)
+	}
+	other := change
+	other.Path = "/repo/other.go"
+	if got, err := r.review(other); err != nil || got != other.After {
+		t.Fatalf("rule acceptance not remembered: err=%v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package mode
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cool-code-cleanup/internal/discovery"
+	"cool-code-cleanup/internal/profile"
+	"cool-code-cleanup/internal/tui"
+)
+
+// reviewParameterPlans lets the user inspect and edit the valid values of each
+// route's plan before profiling. Edits apply to every variant that does not
+// target the edited parameter.
+func reviewParameterPlans(io tui.IO, routes []discovery.Route, plans []profile.ParameterPlan) (edited bool, canceled bool, err error) {
+	byID := map[string]discovery.Route{}
+	for _, r := range routes {
+		byID[r.ID] = r
+	}
+	inlineErr := ""
+	for {
+		screen := tui.StepScreen{
+			Mode:        "Profile",
+			StepName:    "Step 3b: Review parameters",
+			Description: "Review the values sent to each route. Edit a route to pin real fixtures (tenant IDs, seed users); edits are saved to " + profile.DefaultPlanDir() + ".",
+			Actions: []tui.Action{
+				{Key: "accept", Label: "Accept", Selected: true},
+				{Key: "edit <n>", Label: "Edit route"},
+				{Key: "cancel", Label: "Cancel"},
+			},
+			InlineError: inlineErr,
+		}
+		if countAISourced(plans) > 0 {
+			screen.Description += " Values marked * were proposed by AI; double-check them."
+		}
+		for i, p := range plans {
+			r := byID[p.RouteID]
+			screen.Content = append(screen.Content, fmt.Sprintf("%d) %s %s  %s  (%d invalid variants)", i+1, r.Method, r.Path, formatSetValues(firstValid(p)), len(p.Invalid)))
+		}
+		fmt.Fprintln(io.Out, screen.Render())
+		input, err := io.Prompt("Command (edit <n>/accept/cancel): ")
+		if err != nil {
+			return edited, false, err
+		}
+		inlineErr = ""
+		fields := strings.Fields(strings.ToLower(input))
+		switch {
+		case len(fields) == 0 || fields[0] == "accept" || fields[0] == "a":
+			return edited, false, nil
+		case fields[0] == "cancel" || fields[0] == "c" || fields[0] == "quit" || fields[0] == "q":
+			return edited, true, nil
+		case (fields[0] == "edit" || fields[0] == "e") && len(fields) == 2:
+			n, convErr := strconv.Atoi(fields[1])
+			if convErr != nil || n < 1 || n > len(plans) {
+				inlineErr = fmt.Sprintf("route number must be between 1 and %d", len(plans))
+				continue
+			}
+			changed, err := editParameterPlan(io, byID[plans[n-1].RouteID], &plans[n-1])
+			if err != nil {
+				return edited, false, err
+			}
+			edited = edited || changed
+		default:
+			inlineErr = "unknown command"
+		}
+	}
+}
+
+func editParameterPlan(io tui.IO, r discovery.Route, plan *profile.ParameterPlan) (bool, error) {
+	edited := false
+	inlineErr := ""
+	for {
+		screen := tui.StepScreen{
+			Mode:        "Profile",
+			StepName:    fmt.Sprintf("Step 3b: Edit %s %s", r.Method, r.Path),
+			Description: "Set or unset values as <location>.<name> where location is path, query, header or body. Body values are parsed as JSON when possible.",
+			Actions: []tui.Action{
+				{Key: "set <loc>.<name>=<value>", Label: "Set"},
+				{Key: "unset <loc>.<name>", Label: "Unset"},
+				{Key: "done", Label: "Done", Selected: true},
+			},
+			InlineError: inlineErr,
+		}
+		values := firstValid(*plan).Flatten()
+		sources := map[string]string{}
+		for _, p := range plan.Params {
+			req := ""
+			if p.Required {
+				req = ", required"
+			}
+			sources[p.In+"."+p.Name] = fmt.Sprintf(" (%s%s, %s)", p.Type, req, p.Source)
+		}
+		for _, k := range firstValid(*plan).AISourced {
+			sources[k] += " *"
+		}
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			screen.Content = append(screen.Content, fmt.Sprintf("%s = %s%s", k, values[k], sources[k]))
+		}
+		fmt.Fprintln(io.Out, screen.Render())
+		input, err := io.Prompt("Command (set <loc>.<name>=<value>/unset <loc>.<name>/done): ")
+		if err != nil {
+			return edited, err
+		}
+		inlineErr = ""
+		verb, arg, _ := strings.Cut(strings.TrimSpace(input), " ")
+		switch strings.ToLower(verb) {
+		case "", "done", "d":
+			return edited, nil
+		case "set", "s":
+			key, value, ok := strings.Cut(arg, "=")
+			loc, name, okKey := strings.Cut(strings.TrimSpace(key), ".")
+			if !ok || !okKey || name == "" {
+				inlineErr = "usage: set <loc>.<name>=<value>"
+				continue
+			}
+			if err := plan.SetValue(strings.ToLower(loc), name, strings.TrimSpace(value)); err != nil {
+				inlineErr = err.Error()
+				continue
+			}
+			edited = true
+		case "unset", "u":
+			loc, name, ok := strings.Cut(strings.TrimSpace(arg), ".")
+			if !ok || name == "" {
+				inlineErr = "usage: unset <loc>.<name>"
+				continue
+			}
+			plan.UnsetValue(strings.ToLower(loc), name)
+			edited = true
+		default:
+			inlineErr = "unknown command"
+		}
+	}
+}
+
+func firstValid(p profile.ParameterPlan) profile.ParameterSet {
+	if len(p.Valid) == 0 {
+		return profile.ParameterSet{}
+	}
+	return p.Valid[0]
+}
+
+func formatSetValues(s profile.ParameterSet) string {
+	values := s.Flatten()
+	if len(values) == 0 {
+		return "(no parameters)"
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ai := map[string]bool{}
+	for _, k := range s.AISourced {
+		ai[k] = true
+	}
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		part := k + "=" + values[k]
+		if ai[k] {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// countAISourced returns how many plans still carry AI-proposed values.
+func countAISourced(plans []profile.ParameterPlan) int {
+	n := 0
+	for _, p := range plans {
+		if len(firstValid(p).AISourced) > 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,137 @@
+package mode
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"cool-code-cleanup/internal/discovery"
+	"cool-code-cleanup/internal/perf"
+	"cool-code-cleanup/internal/profile"
+	"cool-code-cleanup/internal/runner"
+)
+
+// cpuSeconds is how long each route is driven while pprof, or cProfile,
+// samples it.
+const cpuSeconds = 2
+
+// maxHotspots caps the hot functions saved for the cleanup rules.
+const maxHotspots = 20
+
+// profileGoRoutes drives each route in turn while a pprof endpoint samples
+// the app.
+func profileGoRoutes(pprofURL, dir, baseURL string, routes []discovery.Route, plans []profile.ParameterPlan, session *runner.Session) perf.Result {
+	res := perf.Result{Kind: perf.KindGoPprof, Dir: dir, CPUUnit: "ms", AllocUnit: "bytes"}
+	if !perf.GoPprofAvailable(pprofURL) {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("no pprof endpoint at %s; Go CPU profiling needs the app to serve net/http/pprof (import _ \"net/http/pprof\") or profile.pprof_url to point at it", pprofURL))
+		return res
+	}
+	planByID := map[string]profile.ParameterPlan{}
+	for _, p := range plans {
+		planByID[p.RouteID] = p
+	}
+	for _, r := range routes {
+		w := perf.Window{RouteID: r.ID, Method: r.Method, Path: r.Path}
+		rp, err := perf.CaptureGoRoute(pprofURL, dir, w, cpuSeconds, func(stop <-chan struct{}) int {
+			return runner.Drive(baseURL, r, planByID[r.ID], session, stop)
+		})
+		if err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s %s: cpu profile failed: %v", r.Method, r.Path, err))
+			continue
+		}
+		res.Routes = append(res.Routes, rp)
+	}
+	return res
+}
+
+// collectNodeProfiles stops the app so its profiler writes its output, then
+// reads it, attributing samples to the latency measurement windows.
+func collectNodeProfiles(dir string, proc *runner.AppProcess, latency []runner.RouteStats) perf.Result {
+	res := perf.Result{Kind: perf.KindNodeCPUProf, Dir: dir, CPUUnit: "ms"}
+	if proc == nil {
+		res.Warnings = append(res.Warnings, "app was not started by the profiler; no CPU profile collected")
+		return res
+	}
+	proc.Stop()
+	var windows []perf.Window
+	for _, s := range latency {
+		windows = append(windows, perf.Window{RouteID: s.RouteID, Method: s.Method, Path: s.Path, Start: s.StartedAt, End: s.EndedAt})
+	}
+	top, routes, err := perf.ParseNodeProfiles(dir, proc.StoppedAt, windows)
+	if err != nil {
+		res.Warnings = append(res.Warnings, err.Error())
+		return res
+	}
+	res.Top, res.Routes = top, routes
+	return res
+}
+
+// profilePythonRoutes restarts the app under cProfile for each route, the
+// way coverage does, since cProfile keeps no timestamps to attribute samples
+// by. Each process runs the route's dependencies once and then drives the
+// route for cpuSeconds, so its table is mostly that route's, though it
+// also holds the app's startup.
+func profilePythonRoutes(root, dir string, target appTarget, env []string, routes []discovery.Route, plans []profile.ParameterPlan, deps map[string][]string, tokenRules []runner.TokenRule) perf.Result {
+	res := perf.Result{Kind: perf.KindPythonCProfile, Dir: dir, CPUUnit: "ms"}
+	planByID := map[string]profile.ParameterPlan{}
+	for _, p := range plans {
+		planByID[p.RouteID] = p
+	}
+	for i, r := range routes {
+		runDir := filepath.Join(dir, fmt.Sprintf("route-%03d", i+1))
+		opts, err := perf.PythonStartOptions(runDir)
+		if err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s %s: cpu profile failed: %v", r.Method, r.Path, err))
+			continue
+		}
+		start := target.withEnv(slices.Concat(env, opts.Env)...)
+		start.Wrap, start.StopSignal = opts.Wrap, opts.StopSignal
+		proc, cmd := runner.Start(root, start)
+		if proc == nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s %s: cpu profile failed: app did not start", r.Method, r.Path))
+			continue
+		}
+		if target.Log != nil {
+			target.Log.Note("cpu profile %s %s: started %s", r.Method, r.Path, cmd)
+		}
+		_ = proc.WaitForHealth(target.HealthURL, target.HealthStatus, target.StartupTimeout)
+		session := runner.NewSession(tokenRules)
+		var before []discovery.Route
+		for _, d := range withDependencies(routes, deps, r.ID) {
+			if d.ID != r.ID {
+				before = append(before, d)
+			}
+		}
+		runner.Execute(target.BaseURL, before, plans, deps, session, nil)
+		stop := make(chan struct{})
+		timer := time.AfterFunc(cpuSeconds*time.Second, func() { close(stop) })
+		sent := runner.Drive(target.BaseURL, r, planByID[r.ID], session, stop)
+		timer.Stop()
+		proc.Stop()
+		path := perf.PythonProfilePath(runDir)
+		cpu, err := perf.ParsePythonProfile(path)
+		if err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s %s: cpu profile failed: %v", r.Method, r.Path, err))
+			continue
+		}
+		res.Routes = append(res.Routes, perf.RouteProfile{RouteID: r.ID, Method: r.Method, Path: r.Path, Requests: sent, CPU: cpu, Files: []string{path}})
+	}
+	return res
+}
+
+// formatTopFunctions renders the n hottest functions of a table on one line.
+func formatTopFunctions(fns []perf.Function, n int) string {
+	if len(fns) == 0 {
+		return "(no samples)"
+	}
+	parts := make([]string, 0, n)
+	for i, f := range fns {
+		if i == n {
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s %.1f%%", f.Name, f.FlatPct))
+	}
+	return strings.Join(parts, ", ")
+}
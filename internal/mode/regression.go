@@ -0,0 +1,291 @@
+package mode
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cool-code-cleanup/internal/app"
+	"cool-code-cleanup/internal/cleanup"
+	"cool-code-cleanup/internal/config"
+	"cool-code-cleanup/internal/discovery"
+	"cool-code-cleanup/internal/mockservice"
+	"cool-code-cleanup/internal/profile"
+	"cool-code-cleanup/internal/regression"
+	"cool-code-cleanup/internal/runner"
+	"cool-code-cleanup/internal/tui"
+)
+
+// regressionCheck restarts the app to send the same requests before and
+// after cleanup. Each run gets a freshly started app so both see the same
+// startup state.
+type regressionCheck struct {
+	root       string
+	target     appTarget
+	env        []string
+	tokenRules []runner.TokenRule
+	cfg        config.RegressionConfig
+}
+
+// start starts the app and waits until it is healthy.
+func (c regressionCheck) start(label string) (*runner.AppProcess, error) {
+	proc, cmd := runner.Start(c.root, c.target.withEnv(c.env...))
+	if proc == nil {
+		return nil, fmt.Errorf("app did not start")
+	}
+	if c.target.Log != nil {
+		c.target.Log.Note("regression check %s: started %s", label, cmd)
+	}
+	if !proc.WaitForHealth(c.target.HealthURL, c.target.HealthStatus, c.target.StartupTimeout) {
+		proc.Stop()
+		return nil, fmt.Errorf("app not healthy at %s within %s", c.target.HealthURL, c.target.StartupTimeout)
+	}
+	return proc, nil
+}
+
+// safeMethods are repeated by the profile-mode regression check and the
+// latency measurement; requests with other methods may change state the
+// next run would see.
+var safeMethods = map[string]bool{"GET": true, "HEAD": true, "OPTIONS": true}
+
+// safeRoutes returns the routes with safe methods and how many it leaves
+// out.
+func safeRoutes(routes []discovery.Route) ([]discovery.Route, int) {
+	var out []discovery.Route
+	for _, r := range routes {
+		if safeMethods[strings.ToUpper(r.Method)] {
+			out = append(out, r)
+		}
+	}
+	return out, len(routes) - len(out)
+}
+
+// regressionRoutes returns the routes the profile-mode regression check
+// repeats: those with safe methods and the routes they depend on, such as a
+// login. It also returns how many routes it leaves out.
+func regressionRoutes(routes []discovery.Route, deps map[string][]string) ([]discovery.Route, int) {
+	needed := map[string]bool{}
+	for _, r := range routes {
+		if safeMethods[strings.ToUpper(r.Method)] {
+			needed[r.ID] = true
+			for _, d := range deps[r.ID] {
+				needed[d] = true
+			}
+		}
+	}
+	var out []discovery.Route
+	for _, r := range routes {
+		if needed[r.ID] {
+			out = append(out, r)
+		}
+	}
+	return out, len(routes) - len(out)
+}
+
+// execute runs the profiling invocations of routes again, as step 4 did.
+func (c regressionCheck) execute(label string, routes []discovery.Route, plans []profile.ParameterPlan, deps map[string][]string) ([]regression.Response, error) {
+	proc, err := c.start(label)
+	if err != nil {
+		return nil, err
+	}
+	defer proc.Stop()
+	return regression.FromInvocations(runner.Execute(c.target.BaseURL, routes, plans, deps, runner.NewSession(c.tokenRules), nil)), nil
+}
+
+// replay re-sends recorded traffic.
+func (c regressionCheck) replay(label string, har runner.HAR) ([]regression.Response, error) {
+	proc, err := c.start(label)
+	if err != nil {
+		return nil, err
+	}
+	defer proc.Stop()
+	return regression.FromReplay(runner.Replay(c.target.BaseURL, har, runner.NewSession(c.tokenRules))), nil
+}
+
+// compare diffs the responses, reports them under step and applies
+// on_change: the edited files are restored from snapshot when it is rollback,
+// or when it is prompt and the user agrees. A behavior change the user did
+// not choose to keep fails the run.
+func (c regressionCheck) compare(rt *app.Runtime, io tui.IO, step string, before, after []regression.Response, afterErr error, snapshot []cleanup.ProjectFile, files []string) error {
+	res := regression.Compare(before, after, regression.Options{
+		VolatileFields: c.cfg.VolatileFields,
+		LatencyRatio:   c.cfg.LatencyRatio,
+		LatencyFloorMs: c.cfg.LatencyFloorMs,
+	})
+	if afterErr != nil {
+		res.Error = "after cleanup: " + afterErr.Error()
+	}
+	for _, ch := range res.Slower {
+		fmt.Fprintf(os.Stdout, "slower after cleanup: %s\n", regression.FormatChange(ch))
+		rt.Report.Warnings = append(rt.Report.Warnings, "slower after cleanup: "+regression.FormatChange(ch))
+	}
+	summary := map[string]any{"result": res}
+	rt.Report.Regression = summary
+	if !res.Changed() {
+		rt.AddStep(step, "completed", fmt.Sprintf("%d responses unchanged after cleanup (%d slower)", res.Compared, len(res.Slower)))
+		return nil
+	}
+
+	if res.Error != "" {
+		fmt.Fprintf(os.Stdout, "behavior check failed: %s\n", res.Error)
+	}
+	for _, ch := range res.Behavior {
+		fmt.Fprintf(os.Stdout, "changed after cleanup: %s\n", regression.FormatChange(ch))
+	}
+	detail := fmt.Sprintf("%d of %d responses changed", len(res.Behavior), res.Compared)
+	if res.Error != "" {
+		detail = res.Error
+	}
+	rollback := c.cfg.OnChange == "rollback"
+	if c.cfg.OnChange == "prompt" && !rt.Effective.NonInteractive {
+		resp, err := io.Prompt(fmt.Sprintf("Behavior changed after cleanup (%s). Roll back the %d edited files? [y/N]: ", detail, len(files)))
+		if err != nil {
+			return err
+		}
+		resp = strings.ToLower(strings.TrimSpace(resp))
+		if resp != "y" && resp != "yes" {
+			rt.Report.Warnings = append(rt.Report.Warnings, "behavior changed after cleanup; edits kept by choice: "+detail)
+			rt.AddStep(step, "completed", detail+"; edits kept")
+			return nil
+		}
+		rollback = true
+	}
+	if rollback {
+		if err := cleanup.Restore(snapshot, files); err != nil {
+			rt.AddStep(step, "failed", err.Error())
+			return err
+		}
+		summary["rolled_back"] = files
+		rt.AddStep(step, "failed", fmt.Sprintf("%s; rolled back %d files", detail, len(files)))
+		return fmt.Errorf("behavior changed after cleanup (%s); rolled back %d files", detail, len(files))
+	}
+	rt.AddStep(step, "failed", detail)
+	return fmt.Errorf("behavior changed after cleanup (%s)", detail)
+}
+
+// appliedFiles lists the files the applied edits wrote, once each.
+func appliedFiles(edits []cleanup.Edit) []string {
+	var out []string
+	for _, e := range edits {
+		if e.Applied && !slices.Contains(out, e.File) {
+			out = append(out, e.File)
+		}
+	}
+	return out
+}
+
+// editedFiles lists the files the edits touch, once each.
+func editedFiles(edits []cleanup.Edit) []string {
+	var out []string
+	for _, e := range edits {
+		if !slices.Contains(out, e.File) {
+			out = append(out, e.File)
+		}
+	}
+	return out
+}
+
+// latestHAR returns the most recently recorded .ccc/runs/<id>/traffic.har
+// under root, relative to it, or "" when there is none.
+func latestHAR(root string) string {
+	matches, _ := filepath.Glob(filepath.Join(root, runner.DefaultHARPath("*")))
+	var newest string
+	var newestMod int64
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+		if mod := info.ModTime().UnixNano(); newest == "" || mod > newestMod {
+			newest, newestMod = m, mod
+		}
+	}
+	if newest == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(root, newest)
+	if err != nil {
+		return newest
+	}
+	return rel
+}
+
+// cleanupBaseline prepares cleanup mode's regression check: it loads the
+// recorded traffic at harPath (default: the newest profile recording) and
+// replays it against the app as it is before cleanup. A nil check means the
+// check is skipped; stop releases what was started for it.
+func cleanupBaseline(rt *app.Runtime, root, harPath string) (*regressionCheck, runner.HAR, []regression.Response, func(), error) {
+	const step = "cleanup_regression_baseline"
+	stop := func() {}
+	if harPath == "" {
+		harPath = latestHAR(root)
+	}
+	if harPath == "" {
+		rt.AddStep(step, "completed", "skipped (no recorded traffic; record some with ccc profile --har or profile.har.record)")
+		return nil, runner.HAR{}, nil, stop, nil
+	}
+	har, err := runner.LoadHAR(harPath)
+	if err != nil {
+		rt.AddStep(step, "failed", err.Error())
+		return nil, runner.HAR{}, nil, stop, err
+	}
+	target, err := resolveApp(root, rt.Effective.Config.Profile.App)
+	if err != nil {
+		rt.AddStep(step, "failed", err.Error())
+		return nil, runner.HAR{}, nil, stop, err
+	}
+	if target.Attach {
+		rt.Report.Warnings = append(rt.Report.Warnings, "regression check skipped: it restarts the app, which is not possible when attaching")
+		rt.AddStep(step, "completed", "skipped (attach mode)")
+		return nil, runner.HAR{}, nil, stop, nil
+	}
+	tokenRules := runner.DefaultTokenRules()
+	sessionRules, sessionFound, err := runner.LoadSessionConfig(filepath.Join(root, runner.DefaultSessionPath()))
+	if err != nil {
+		rt.AddStep(step, "failed", err.Error())
+		return nil, runner.HAR{}, nil, stop, err
+	}
+	if sessionFound {
+		tokenRules = sessionRules.Rules
+	}
+	mocks, mocksFound, err := mockservice.Load(filepath.Join(root, mockservice.DefaultPath()))
+	if err != nil {
+		rt.AddStep(step, "failed", err.Error())
+		return nil, runner.HAR{}, nil, stop, err
+	}
+	var mockSet *mockservice.Set
+	if mocksFound {
+		if mockSet, err = mockservice.Start(mocks); err != nil {
+			rt.AddStep(step, "failed", err.Error())
+			return nil, runner.HAR{}, nil, stop, err
+		}
+	}
+	logPath := runner.DefaultAppLogPath(rt.Report.RunID)
+	if err := target.openLog(root, logPath); err != nil {
+		mockSet.Stop()
+		rt.AddStep(step, "failed", err.Error())
+		return nil, runner.HAR{}, nil, stop, err
+	}
+	rt.Report.AppLog = logPath
+	stop = func() {
+		_ = target.Log.Close()
+		mockSet.Stop()
+	}
+
+	check := &regressionCheck{root: root, target: target, env: mockSet.Env(), tokenRules: tokenRules, cfg: rt.Effective.Config.Profile.Regression}
+	if w := redactedReplayWarning(har); w != "" {
+		rt.Report.Warnings = append(rt.Report.Warnings, "regression check: "+w)
+	}
+	fmt.Fprintf(os.Stdout, "Regression check: replaying %d requests from %s before cleanup\n", len(har.Log.Entries), harPath)
+	baseline, err := check.replay("before cleanup", har)
+	if err != nil {
+		rt.Report.Warnings = append(rt.Report.Warnings, fmt.Sprintf("regression check skipped: app before cleanup: %v; see %s", err, logPath))
+		rt.AddStep(step, "completed", "skipped (app did not start before cleanup)")
+		return nil, runner.HAR{}, nil, stop, nil
+	}
+	rt.Report.HAR = harPath
+	rt.AddStep(step, "completed", fmt.Sprintf("replayed %d requests from %s", len(baseline), harPath))
+	return check, har, baseline, stop, nil
+}
@@ -0,0 +1,104 @@
+package mode
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cool-code-cleanup/internal/app"
+	"cool-code-cleanup/internal/runner"
+)
+
+type ReplayFlags struct {
+	HARPath string
+	BaseURL string
+}
+
+// RunReplay re-sends a recorded HAR to a running app and fails if any
+// request now gets a different status.
+func RunReplay(rt *app.Runtime, flags ReplayFlags) error {
+	root, _ := os.Getwd()
+	harPath := strings.TrimSpace(flags.HARPath)
+	if harPath == "" {
+		err := fmt.Errorf("replay needs a HAR file, e.g. ccc replay %s", runner.DefaultHARPath("<run-id>"))
+		rt.AddStep("replay_load", "failed", err.Error())
+		return err
+	}
+	har, err := runner.LoadHAR(harPath)
+	if err != nil {
+		rt.AddStep("replay_load", "failed", err.Error())
+		return err
+	}
+	rt.AddStep("replay_load", "completed", fmt.Sprintf("loaded %d entries from %s", len(har.Log.Entries), harPath))
+	if w := redactedReplayWarning(har); w != "" {
+		fmt.Fprintln(os.Stdout, "Warning: "+w)
+		rt.Report.Warnings = append(rt.Report.Warnings, w)
+	}
+
+	baseURL := strings.TrimRight(strings.TrimSpace(flags.BaseURL), "/")
+	if baseURL == "" {
+		cfg := rt.Effective.Config.Profile.App
+		switch {
+		case cfg.AttachURL != "":
+			baseURL = strings.TrimRight(cfg.AttachURL, "/")
+		case cfg.Port != 0:
+			baseURL = fmt.Sprintf("http://127.0.0.1:%d", cfg.Port)
+		default:
+			err := fmt.Errorf("profile.app.port is 0; pass --base-url to say where the app is running")
+			rt.AddStep("replay_send", "failed", err.Error())
+			return err
+		}
+	}
+
+	tokenRules := runner.DefaultTokenRules()
+	sessionRules, sessionFound, err := runner.LoadSessionConfig(filepath.Join(root, runner.DefaultSessionPath()))
+	if err != nil {
+		rt.AddStep("session_rules", "failed", err.Error())
+		return err
+	}
+	if sessionFound {
+		tokenRules = sessionRules.Rules
+		rt.AddStep("session_rules", "completed", fmt.Sprintf("loaded %d token rules from %s", len(tokenRules), runner.DefaultSessionPath()))
+	}
+
+	results := runner.Replay(baseURL, har, runner.NewSession(tokenRules))
+	changed := 0
+	for _, r := range results {
+		mark := " "
+		if r.StatusChanged() {
+			mark = "!"
+			changed++
+		}
+		now := fmt.Sprintf("%d %.1fms", r.Status, r.DurationMs)
+		if r.Error != "" {
+			now = "error: " + r.Error
+		}
+		if r.Redacted {
+			now += " (sent with REDACTED values)"
+		}
+		fmt.Fprintf(os.Stdout, "%s %s %s: recorded %d %.1fms, now %s\n", mark, r.Method, r.URL, r.RecordedStatus, r.RecordedMs, now)
+	}
+	rt.Report.HAR = harPath
+	rt.Report.Replay = map[string]any{
+		"base_url": baseURL,
+		"results":  results,
+		"changed":  changed,
+	}
+	rt.AddStep("replay_send", "completed", fmt.Sprintf("replayed %d requests to %s; %d changed status", len(results), baseURL, changed))
+	if changed > 0 {
+		return fmt.Errorf("%d of %d replayed requests changed status", changed, len(results))
+	}
+	return nil
+}
+
+// redactedReplayWarning explains what replaying requests recorded with
+// redacted values does, or returns "" when har has none.
+func redactedReplayWarning(har runner.HAR) string {
+	redacted := har.RedactedRequests()
+	if len(redacted) == 0 {
+		return ""
+	}
+	e := redacted[0]
+	return fmt.Sprintf("%d recorded requests (e.g. %s %s) have redacted query or body values and are replayed with REDACTED, so logins among them fail and routes needing their tokens respond differently; record with profile.har.redact_fields emptied to replay them", len(redacted), e.Request.Method, e.Request.URL)
+}
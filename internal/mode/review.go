@@ -0,0 +1,245 @@
+package mode
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"cool-code-cleanup/internal/cleanup"
+	"cool-code-cleanup/internal/tui"
+)
+
+// hunkReviewer asks, one diff hunk at a time, which parts of each file a
+// cleanup task changed should be written. In per-file mode the whole file's
+// diff is offered first. "Accept all" for a file or rule, and quitting,
+// hold for the rest of the run.
+type hunkReviewer struct {
+	io      tui.IO
+	root    string
+	perFile bool
+	// edit opens text in an editor and returns what was saved.
+	edit func(text string) (string, error)
+	// pause and resume stop progress output while a screen is shown.
+	pause, resume func()
+
+	acceptFiles map[string]bool
+	acceptRules map[string]bool
+	rejectRest  bool
+}
+
+func newHunkReviewer(io tui.IO, root, permissionMode string) *hunkReviewer {
+	return &hunkReviewer{
+		io:          io,
+		root:        root,
+		perFile:     permissionMode == "per-file",
+		edit:        editInEditor,
+		pause:       func() {},
+		resume:      func() {},
+		acceptFiles: map[string]bool{},
+		acceptRules: map[string]bool{},
+	}
+}
+
+func (r *hunkReviewer) accepted(c cleanup.FileChange) bool {
+	return r.acceptFiles[c.Path] || r.acceptRules[c.Task.RuleID]
+}
+
+// review returns the content to write for c.
+func (r *hunkReviewer) review(c cleanup.FileChange) (string, error) {
+	if r.rejectRest {
+		return c.Before, nil
+	}
+	if r.accepted(c) {
+		return c.After, nil
+	}
+	r.pause()
+	defer r.resume()
+
+	hunks := cleanup.DiffHunks(c.Before, c.After)
+	if r.perFile {
+		switch choice, err := r.reviewFile(c, hunks); {
+		case err != nil:
+			return c.Before, err
+		case choice == "accept":
+			return c.After, nil
+		case choice == "reject":
+			return c.Before, nil
+		}
+	}
+
+	var kept []cleanup.Hunk
+	inlineErr := ""
+	for i := 0; i < len(hunks); i++ {
+		h := hunks[i]
+		screen := r.screen(c, fmt.Sprintf("hunk %d/%d", i+1, len(hunks)), r.hunkLines(c, h), inlineErr, []tui.Action{
+			{Key: "a", Label: "Accept", Selected: true},
+			{Key: "r", Label: "Reject"},
+			{Key: "e", Label: "Edit"},
+			{Key: "f", Label: "Accept all in file"},
+			{Key: "u", Label: "Accept all for rule"},
+			{Key: "q", Label: "Reject rest"},
+		})
+		fmt.Fprintln(r.io.Out, screen.Render())
+		input, err := r.io.Prompt("Command (accept/reject/edit/file/rule/quit): ")
+		if err != nil {
+			return c.Before, err
+		}
+		inlineErr = ""
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "accept", "a", "y", "":
+			kept = append(kept, h)
+		case "reject", "r", "n":
+		case "edit", "e":
+			edited, err := r.editHunk(h)
+			if err != nil {
+				inlineErr = err.Error()
+				i--
+				continue
+			}
+			kept = append(kept, edited)
+		case "file", "f":
+			r.acceptFiles[c.Path] = true
+			kept = append(kept, hunks[i:]...)
+			i = len(hunks)
+		case "rule", "u":
+			r.acceptRules[c.Task.RuleID] = true
+			kept = append(kept, hunks[i:]...)
+			i = len(hunks)
+		case "quit", "q":
+			r.rejectRest = true
+			i = len(hunks)
+		default:
+			inlineErr = "unknown command"
+			i--
+		}
+	}
+	return cleanup.ApplyHunks(c.Before, kept)
+}
+
+// reviewFile offers the whole diff of c and returns "accept", "reject" or
+// "hunks" to review it hunk by hunk.
+func (r *hunkReviewer) reviewFile(c cleanup.FileChange, hunks []cleanup.Hunk) (string, error) {
+	var lines []string
+	for _, h := range hunks {
+		lines = append(lines, r.hunkLines(c, h)...)
+	}
+	inlineErr := ""
+	for {
+		screen := r.screen(c, fmt.Sprintf("%d hunks", len(hunks)), lines, inlineErr, []tui.Action{
+			{Key: "a", Label: "Accept file", Selected: true},
+			{Key: "r", Label: "Reject file"},
+			{Key: "h", Label: "Review hunks"},
+			{Key: "u", Label: "Accept all for rule"},
+			{Key: "q", Label: "Reject rest"},
+		})
+		fmt.Fprintln(r.io.Out, screen.Render())
+		input, err := r.io.Prompt("Command (accept/reject/hunks/rule/quit): ")
+		if err != nil {
+			return "", err
+		}
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "accept", "a", "y", "":
+			return "accept", nil
+		case "reject", "r", "n":
+			return "reject", nil
+		case "hunks", "h":
+			return "hunks", nil
+		case "rule", "u":
+			r.acceptRules[c.Task.RuleID] = true
+			return "accept", nil
+		case "quit", "q":
+			r.rejectRest = true
+			return "reject", nil
+		}
+		inlineErr = "unknown command"
+	}
+}
+
+func (r *hunkReviewer) screen(c cleanup.FileChange, what string, content []string, inlineErr string, actions []tui.Action) tui.StepScreen {
+	return tui.StepScreen{
+		Mode:        "Cleanup",
+		StepName:    fmt.Sprintf("Review %s (%s)", r.name(c.Path), what),
+		Description: fmt.Sprintf("[%s] %s", c.Task.RuleID, c.Task.RuleTitle),
+		Content:     content,
+		Actions:     actions,
+		InlineError: inlineErr,
+		// Keep diff indentation.
+		Preformatted: true,
+	}
+}
+
+// hunkLines shows h under its header and the declaration its first change
+// is in.
+func (r *hunkReviewer) hunkLines(c cleanup.FileChange, h cleanup.Hunk) []string {
+	header := h.Header()
+	changed := h.OldStart
+	for _, l := range h.Lines {
+		if !strings.HasPrefix(l, " ") {
+			break
+		}
+		changed++
+	}
+	if scope := cleanup.EnclosingScope(c.Path, c.Before, changed); scope != "" {
+		header += " " + scope
+	}
+	lines := []string{header}
+	for _, l := range h.Lines {
+		lines = append(lines, strings.TrimSuffix(strings.ReplaceAll(l, "\t", "    "), "\n"))
+	}
+	return lines
+}
+
+func (r *hunkReviewer) name(path string) string {
+	if rel, err := filepath.Rel(r.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+func (r *hunkReviewer) editHunk(h cleanup.Hunk) (cleanup.Hunk, error) {
+	text := "# Edit the '+' lines to change what is written. To keep a '-' line,\n" +
+		"# replace its '-' with a space. Other lines must stay as they are.\n" +
+		"# Lines starting with '#' are ignored.\n" + cleanup.FormatHunk(h)
+	edited, err := r.edit(text)
+	if err != nil {
+		return cleanup.Hunk{}, err
+	}
+	return cleanup.EditHunk(h, edited)
+}
+
+// editInEditor opens text in $VISUAL or $EDITOR (default vi) and returns the
+// saved file.
+func editInEditor(text string) (string, error) {
+	f, err := os.CreateTemp("", "ccc-hunk-*.diff")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	editor := strings.TrimSpace(os.Getenv("VISUAL"))
+	if editor == "" {
+		editor = strings.TrimSpace(os.Getenv("EDITOR"))
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s: %w", editor, err)
+	}
+	out, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,125 @@
+package mode
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cool-code-cleanup/internal/app"
+	"cool-code-cleanup/internal/shortcircuit"
+	"cool-code-cleanup/internal/tui"
+)
+
+type ShortCircuitFlags struct {
+	Action      string
+	IDs         []string
+	ManifestDir string
+}
+
+func RunShortCircuit(rt *app.Runtime, flags ShortCircuitFlags) error {
+	io := tui.NewIO(os.Stdin, os.Stdout)
+	dir := flags.ManifestDir
+	if strings.TrimSpace(dir) == "" {
+		root, _ := os.Getwd()
+		dir = filepath.Join(root, shortcircuit.DefaultManifestDir())
+	}
+	manifests, err := shortcircuit.LoadManifests(dir)
+	if err != nil {
+		rt.AddStep("shortcircuit_load", "failed", err.Error())
+		return err
+	}
+	verifications := shortcircuit.VerifyAll(manifests)
+	if len(flags.IDs) > 0 {
+		var picked []shortcircuit.Manifest
+		var pickedVerifications []shortcircuit.Verification
+		for _, id := range flags.IDs {
+			idx := slices.IndexFunc(manifests, func(m shortcircuit.Manifest) bool { return m.ID == id })
+			if idx < 0 {
+				return fmt.Errorf("short-circuit manifest %q not found in %s", id, dir)
+			}
+			picked = append(picked, manifests[idx])
+			pickedVerifications = append(pickedVerifications, verifications[idx])
+		}
+		manifests, verifications = picked, pickedVerifications
+	}
+	rt.AddStep("shortcircuit_load", "completed", fmt.Sprintf("loaded %d manifests", len(manifests)))
+
+	action := strings.TrimSpace(flags.Action)
+	if action == "" {
+		action = "list"
+	}
+	switch action {
+	case "list", "verify":
+		diverged := 0
+		for i, m := range manifests {
+			v := verifications[i]
+			if action == "list" {
+				fmt.Fprintf(os.Stdout, "%s %s %s %s (%d hunks, %s)\n", m.ID, m.Method, m.Path, m.File, len(m.Hunks), v.Status)
+			} else {
+				fmt.Fprintln(os.Stdout, formatVerification(v))
+			}
+			if v.Status != shortcircuit.StatusApplied {
+				diverged++
+			}
+			rt.Report.AppliedChanges = append(rt.Report.AppliedChanges, v)
+		}
+		if len(manifests) == 0 {
+			fmt.Fprintln(os.Stdout, "No short-circuit patches recorded.")
+		}
+		rt.AddStep("shortcircuit_"+action, "completed", fmt.Sprintf("%d manifests, %d not applied cleanly", len(manifests), diverged))
+		if action == "verify" && diverged > 0 {
+			return fmt.Errorf("%d short-circuit patches no longer match their files", diverged)
+		}
+		return nil
+	case "revert":
+	default:
+		return fmt.Errorf("unknown shortcircuit action %q (expected list, verify or revert)", action)
+	}
+
+	if len(manifests) == 0 {
+		fmt.Fprintln(os.Stdout, "No short-circuit patches to revert.")
+		rt.AddStep("shortcircuit_revert", "completed", "nothing to revert")
+		return nil
+	}
+	dryRun := rt.Effective.Config.Modes.DryRun
+	if !dryRun && !rt.Effective.NonInteractive {
+		resp, err := io.Prompt(fmt.Sprintf("Revert %d short-circuit patch(es)? [y/N]: ", len(manifests)))
+		if err != nil {
+			return err
+		}
+		if !isYes(resp) {
+			rt.AddStep("shortcircuit_revert", "canceled", "user declined revert")
+			return nil
+		}
+	}
+	results, err := shortcircuit.RevertAll(dir, manifests, dryRun)
+	for _, v := range results {
+		rt.Report.AppliedChanges = append(rt.Report.AppliedChanges, v)
+		fmt.Fprintln(os.Stdout, formatVerification(v))
+	}
+	if err != nil {
+		rt.AddStep("shortcircuit_revert", "failed", err.Error())
+		return err
+	}
+	verb := "reverted"
+	if dryRun {
+		verb = "would revert"
+	}
+	rt.AddStep("shortcircuit_revert", "completed", fmt.Sprintf("%s %d patches", verb, len(results)))
+	return nil
+}
+
+func formatVerification(v shortcircuit.Verification) string {
+	line := fmt.Sprintf("%s %s %s: %s", v.Manifest.ID, v.Manifest.Method, v.Manifest.File, v.Status)
+	if v.Detail != "" {
+		line += " (" + v.Detail + ")"
+	}
+	return line
+}
+
+func isYes(s string) bool {
+	v := strings.ToLower(strings.TrimSpace(s))
+	return v == "y" || v == "yes"
+}
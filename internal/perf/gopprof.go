@@ -0,0 +1,265 @@
+package perf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cool-code-cleanup/internal/runner"
+)
+
+// goTopNodes bounds the rows pprof prints before functions are aggregated.
+const goTopNodes = 500
+
+var (
+	goTopRow   = regexp.MustCompile(`^\s*(-?[\d.]+[a-zA-Zµ]*)\s+(-?[\d.]+)%\s+(-?[\d.]+)%\s+(-?[\d.]+[a-zA-Zµ]*)\s+(-?[\d.]+)%\s+(.+)$`)
+	goTopTotal = regexp.MustCompile(`of (-?[\d.]+[a-zA-Zµ]*) total`)
+	goFileLine = regexp.MustCompile(`^(.*):(\d+)$`)
+)
+
+// goPprofHookFile is added to the main package, through a build overlay,
+// only in the profiling binary.
+const goPprofHookFile = "zz_ccc_pprof_hook.go"
+
+// goPprofHook serves net/http/pprof on CCC_PPROF_ADDR, apart from the app's
+// own listener and mux.
+const goPprofHook = `package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+)
+
+func init() {
+	addr := os.Getenv("CCC_PPROF_ADDR")
+	if addr == "" {
+		return
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go http.Serve(ln, mux)
+}
+`
+
+// setupGo builds the app at root with the pprof hook into dir and returns
+// the options that run the binary with the hook listening on a free port.
+func setupGo(root, dir string) (Profiler, error) {
+	binary := filepath.Join(dir, "app.pprof")
+	if err := runner.BuildGo(root, binary, filepath.Join(dir, "build"), nil, map[string]string{goPprofHookFile: goPprofHook}); err != nil {
+		return Profiler{}, err
+	}
+	port, err := runner.FreePort()
+	if err != nil {
+		return Profiler{}, err
+	}
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+	return Profiler{
+		Kind: KindGoPprof,
+		Start: runner.StartOptions{
+			Env:  []string{"CCC_PPROF_ADDR=" + addr},
+			Wrap: func([]string) []string { return []string{binary} },
+		},
+		PprofURL: "http://" + addr + "/debug/pprof",
+	}, nil
+}
+
+// GoPprofAvailable reports whether pprofURL (typically
+// http://host/debug/pprof) serves net/http/pprof.
+func GoPprofAvailable(pprofURL string) bool {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(strings.TrimRight(pprofURL, "/") + "/")
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// CaptureGoRoute records a CPU profile of seconds length from pprofURL while
+// drive sends requests to the route, plus the heap allocations made in that
+// time. Raw profiles are kept in dir.
+func CaptureGoRoute(pprofURL, dir string, w Window, seconds int, drive func(stop <-chan struct{}) int) (RouteProfile, error) {
+	rp := RouteProfile{RouteID: w.RouteID, Method: w.Method, Path: w.Path}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return rp, fmt.Errorf("create profile directory: %w", err)
+	}
+	name := profileFileName(w.RouteID)
+	base := strings.TrimRight(pprofURL, "/")
+	allocsBefore := filepath.Join(dir, name+".allocs-before.pb.gz")
+	cpuPath := filepath.Join(dir, name+".cpu.pb.gz")
+	allocsAfter := filepath.Join(dir, name+".allocs.pb.gz")
+	client := &http.Client{Timeout: time.Duration(seconds)*time.Second + 10*time.Second}
+	if err := fetchTo(client, base+"/allocs", allocsBefore); err != nil {
+		return rp, err
+	}
+
+	stop := make(chan struct{})
+	sent := make(chan int, 1)
+	go func() { sent <- drive(stop) }()
+	err := fetchTo(client, fmt.Sprintf("%s/profile?seconds=%d", base, seconds), cpuPath)
+	close(stop)
+	rp.Requests = <-sent
+	if err != nil {
+		return rp, err
+	}
+	if err := fetchTo(client, base+"/allocs", allocsAfter); err != nil {
+		return rp, err
+	}
+	rp.Files = []string{cpuPath, allocsAfter}
+
+	out, err := goTool("pprof", "-top", "-lines", fmt.Sprintf("-nodecount=%d", goTopNodes), cpuPath)
+	if err != nil {
+		return rp, err
+	}
+	rp.CPU = ParseGoTop(out)
+	out, err = goTool("pprof", "-top", "-lines", fmt.Sprintf("-nodecount=%d", goTopNodes), "-sample_index=alloc_space", "-diff_base="+allocsBefore, allocsAfter)
+	if err != nil {
+		return rp, err
+	}
+	rp.Alloc = ParseGoTop(out)
+	return rp, nil
+}
+
+// ParseGoTop parses `go tool pprof -top -lines` output into per-function
+// rows. Times are in milliseconds and sizes in bytes.
+func ParseGoTop(text string) []Function {
+	byName := map[string]*Function{}
+	var order []string
+	bestFlat := map[string]float64{}
+	total := 0.0
+	sc := bufio.NewScanner(strings.NewReader(text))
+	for sc.Scan() {
+		line := sc.Text()
+		if m := goTopTotal.FindStringSubmatch(line); m != nil && total == 0 {
+			total, _ = parseQuantity(m[1])
+			continue
+		}
+		m := goTopRow.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		flat, ok1 := parseQuantity(m[1])
+		cum, ok2 := parseQuantity(m[4])
+		if !ok1 || !ok2 {
+			continue
+		}
+		name, file, lineNo := splitGoLocation(m[6])
+		f := byName[name]
+		if f == nil {
+			f = &Function{Name: name, File: file, Line: lineNo}
+			byName[name] = f
+			order = append(order, name)
+			bestFlat[name] = flat
+		}
+		if flat > bestFlat[name] {
+			bestFlat[name] = flat
+			f.File, f.Line = file, lineNo
+		}
+		f.Flat += flat
+		f.Cum += cum
+	}
+	fns := make([]Function, 0, len(order))
+	sum := 0.0
+	for _, name := range order {
+		fns = append(fns, *byName[name])
+		sum += byName[name].Flat
+	}
+	if total == 0 {
+		total = sum
+	}
+	return top(fns, total)
+}
+
+// splitGoLocation splits "pkg.Func /abs/file.go:12 (inline)" into its parts.
+func splitGoLocation(s string) (string, string, int) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "(inline)"))
+	name, loc, ok := strings.Cut(s, " ")
+	if !ok {
+		return s, "", 0
+	}
+	m := goFileLine.FindStringSubmatch(strings.TrimSpace(loc))
+	if m == nil {
+		return name, strings.TrimSpace(loc), 0
+	}
+	n, _ := strconv.Atoi(m[2])
+	return name, m[1], n
+}
+
+// parseQuantity converts a pprof value such as "1.20s", "350ms" or "2.5MB"
+// to milliseconds (durations) or bytes (sizes).
+func parseQuantity(s string) (float64, bool) {
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != '-' })
+	num, unit := s, ""
+	if i >= 0 {
+		num, unit = s[:i], s[i:]
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, false
+	}
+	scale := map[string]float64{
+		"": 1, "ns": 1e-6, "us": 1e-3, "µs": 1e-3, "ms": 1, "s": 1e3, "min": 60e3, "h": 3600e3,
+		"B": 1, "kB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30, "TB": 1 << 40,
+	}
+	f, ok := scale[unit]
+	if !ok {
+		return 0, false
+	}
+	return v * f, true
+}
+
+func fetchTo(client *http.Client, url, path string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch %s: status %d", url, resp.StatusCode)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+func goTool(args ...string) (string, error) {
+	cmd := exec.Command("go", append([]string{"tool"}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		var stderr string
+		if ee, ok := err.(*exec.ExitError); ok {
+			stderr = strings.TrimSpace(string(ee.Stderr))
+		}
+		return "", fmt.Errorf("go tool %s: %v %s", args[0], err, stderr)
+	}
+	return string(out), nil
+}
+
+// profileFileName makes a route ID safe for use in file names.
+func profileFileName(routeID string) string {
+	return strings.Trim(regexp.MustCompile(`[^A-Za-z0-9._-]+`).ReplaceAllString(routeID, "_"), "_")
+}
@@ -0,0 +1,201 @@
+package perf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// nodeExitHook makes a Node process exit normally on SIGINT/SIGTERM when
+// nothing else handles the signal, so --cpu-prof gets to write its profile.
+// Processes that install their own handlers (npm, most servers) are left to
+// shut down on their own.
+const nodeExitHook = `for (const [sig, code] of [["SIGINT", 130], ["SIGTERM", 143]]) {
+  process.on(sig, () => {
+    if (process.listenerCount(sig) === 1) process.exit(code);
+  });
+}
+`
+
+// nodeIgnoredFrames are V8 pseudo-frames that are not app work.
+var nodeIgnoredFrames = map[string]bool{"(root)": true, "(idle)": true, "(program)": true}
+
+type cpuProfile struct {
+	Nodes []struct {
+		ID        int `json:"id"`
+		CallFrame struct {
+			FunctionName string `json:"functionName"`
+			URL          string `json:"url"`
+			LineNumber   int    `json:"lineNumber"`
+		} `json:"callFrame"`
+		Children []int `json:"children"`
+	} `json:"nodes"`
+	StartTime  int64   `json:"startTime"`
+	EndTime    int64   `json:"endTime"`
+	Samples    []int   `json:"samples"`
+	TimeDeltas []int64 `json:"timeDeltas"`
+}
+
+// WriteNodeExitHook writes the exit hook script to dir and returns its path,
+// for use with node --require. Node only writes CPU profiles and coverage on
+// a normal exit.
+func WriteNodeExitHook(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create profile directory: %w", err)
+	}
+	hook := filepath.Join(dir, "exit-hook.cjs")
+	if err := os.WriteFile(hook, []byte(nodeExitHook), 0o644); err != nil {
+		return "", fmt.Errorf("write node exit hook: %w", err)
+	}
+	return hook, nil
+}
+
+// nodeEnv returns the NODE_OPTIONS setting that writes CPU profiles to dir,
+// keeping any options already in the environment.
+func nodeEnv(dir string) ([]string, error) {
+	hook, err := WriteNodeExitHook(dir)
+	if err != nil {
+		return nil, err
+	}
+	opts := strings.TrimSpace(os.Getenv("NODE_OPTIONS") + fmt.Sprintf(" --cpu-prof --cpu-prof-dir=%q --require=%q", dir, hook))
+	return []string{"NODE_OPTIONS=" + opts}, nil
+}
+
+// ParseNodeProfiles reads the .cpuprofile files in dir. Each profile's end is
+// aligned with stoppedAt, the moment the app was asked to stop, to place its
+// samples on the wall clock; samples are then attributed to the window they
+// fall in. Times are in milliseconds.
+func ParseNodeProfiles(dir string, stoppedAt time.Time, windows []Window) ([]Function, []RouteProfile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.cpuprofile"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("no .cpuprofile written to %s (the app must exit normally for --cpu-prof to write it)", dir)
+	}
+	all := newFuncTable()
+	perWindow := make([]*funcTable, len(windows))
+	for i := range perWindow {
+		perWindow[i] = newFuncTable()
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		var p cpuProfile
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		attributeNodeSamples(p, stoppedAt, windows, all, perWindow)
+	}
+	var routes []RouteProfile
+	for i, w := range windows {
+		routes = append(routes, RouteProfile{RouteID: w.RouteID, Method: w.Method, Path: w.Path, CPU: perWindow[i].top()})
+	}
+	return all.top(), routes, nil
+}
+
+func attributeNodeSamples(p cpuProfile, stoppedAt time.Time, windows []Window, all *funcTable, perWindow []*funcTable) {
+	type frame struct {
+		key    string
+		fn     Function
+		parent int
+	}
+	frames := map[int]frame{}
+	parents := map[int]int{}
+	for _, n := range p.Nodes {
+		for _, c := range n.Children {
+			parents[c] = n.ID
+		}
+	}
+	for _, n := range p.Nodes {
+		name := n.CallFrame.FunctionName
+		if name == "" {
+			name = "(anonymous)"
+		}
+		fn := Function{Name: name, File: strings.TrimPrefix(n.CallFrame.URL, "file://"), Line: n.CallFrame.LineNumber + 1}
+		frames[n.ID] = frame{key: fmt.Sprintf("%s|%s|%d", fn.Name, fn.File, fn.Line), fn: fn, parent: parents[n.ID]}
+	}
+	t := p.StartTime
+	for i, id := range p.Samples {
+		if i < len(p.TimeDeltas) {
+			t += p.TimeDeltas[i]
+		}
+		leaf, ok := frames[id]
+		if !ok || nodeIgnoredFrames[leaf.fn.Name] {
+			continue
+		}
+		weight := 0.0
+		if i+1 < len(p.TimeDeltas) {
+			weight = float64(p.TimeDeltas[i+1]) / 1000
+		} else if i < len(p.TimeDeltas) {
+			weight = float64(p.TimeDeltas[i]) / 1000
+		}
+		var stack []string
+		var fns []Function
+		seen := map[string]bool{}
+		for cur := id; cur != 0; cur = frames[cur].parent {
+			f, exists := frames[cur]
+			if !exists || nodeIgnoredFrames[f.fn.Name] {
+				break
+			}
+			if !seen[f.key] {
+				seen[f.key] = true
+				stack = append(stack, f.key)
+				fns = append(fns, f.fn)
+			}
+		}
+		all.add(stack, fns, weight)
+		at := stoppedAt.Add(-time.Duration(p.EndTime-t) * time.Microsecond)
+		for wi, w := range windows {
+			if !at.Before(w.Start) && at.Before(w.End) {
+				perWindow[wi].add(stack, fns, weight)
+			}
+		}
+	}
+}
+
+// funcTable accumulates self and inclusive weights by stack. The first
+// entry of each stack is the leaf.
+type funcTable struct {
+	byKey map[string]*Function
+	total float64
+}
+
+func newFuncTable() *funcTable {
+	return &funcTable{byKey: map[string]*Function{}}
+}
+
+func (t *funcTable) add(stack []string, fns []Function, weight float64) {
+	t.total += weight
+	for i, key := range stack {
+		f := t.byKey[key]
+		if f == nil {
+			fn := fns[i]
+			f = &fn
+			t.byKey[key] = f
+		}
+		if i == 0 {
+			f.Flat += weight
+		}
+		f.Cum += weight
+	}
+}
+
+func (t *funcTable) top() []Function {
+	keys := make([]string, 0, len(t.byKey))
+	for k := range t.byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fns := make([]Function, 0, len(keys))
+	for _, k := range keys {
+		fns = append(fns, *t.byKey[k])
+	}
+	return top(fns, t.total)
+}
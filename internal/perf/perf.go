@@ -0,0 +1,196 @@
+// Package perf collects CPU and allocation profiles from the app while
+// profiled routes are driven, and reduces them to top-function tables.
+package perf
+
+import (
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cool-code-cleanup/internal/cleanup"
+	"cool-code-cleanup/internal/runner"
+)
+
+// Profiler kinds.
+const (
+	KindGoPprof        = "go_pprof"
+	KindNodeCPUProf    = "node_cpu_prof"
+	KindPythonCProfile = "python_cprofile"
+)
+
+// TopN is how many functions each table keeps.
+const TopN = 15
+
+// Function is one row of a top-functions table. Flat is time (or bytes, for
+// allocation tables) spent in the function itself; Cum includes its callees.
+// Percentages are of the table's total.
+type Function struct {
+	Name    string  `json:"name"`
+	File    string  `json:"file,omitempty"`
+	Line    int     `json:"line,omitempty"`
+	Flat    float64 `json:"flat"`
+	FlatPct float64 `json:"flat_pct"`
+	Cum     float64 `json:"cum"`
+	CumPct  float64 `json:"cum_pct"`
+}
+
+type RouteProfile struct {
+	RouteID  string     `json:"route_id"`
+	Method   string     `json:"method"`
+	Path     string     `json:"path"`
+	Requests int        `json:"requests,omitempty"`
+	CPU      []Function `json:"cpu"`
+	Alloc    []Function `json:"alloc,omitempty"`
+	Files    []string   `json:"files,omitempty"`
+}
+
+// Result is the profile of one run. CPUUnit and AllocUnit name the units of
+// Flat and Cum. Top covers the whole run; Routes is empty when the profiler
+// cannot attribute samples to routes.
+type Result struct {
+	Kind      string         `json:"kind"`
+	Dir       string         `json:"dir"`
+	CPUUnit   string         `json:"cpu_unit"`
+	AllocUnit string         `json:"alloc_unit,omitempty"`
+	Top       []Function     `json:"top,omitempty"`
+	Routes    []RouteProfile `json:"routes,omitempty"`
+	Warnings  []string       `json:"warnings,omitempty"`
+}
+
+// Window is the wall-clock span during which a route was driven.
+type Window struct {
+	RouteID string
+	Method  string
+	Path    string
+	Start   time.Time
+	End     time.Time
+}
+
+// DefaultRunDir is where a run keeps its raw profiles.
+func DefaultRunDir(runID string) string {
+	return filepath.Join(".ccc", "runs", runID, "profiles")
+}
+
+// Profiler is how the app is profiled: the kind of profiler, the options
+// that start the app under it and, for Go, the pprof endpoint it serves.
+type Profiler struct {
+	Kind     string
+	Start    runner.StartOptions
+	PprofURL string
+}
+
+// Setup returns the profiler for the app at root, with output going to dir.
+// Go apps are built with a hook that serves net/http/pprof on a listener of
+// its own. Python apps start unchanged: cProfile keeps no timestamps, so each
+// route is profiled in a process of its own (see PythonStartOptions).
+func Setup(root, dir string) (Profiler, error) {
+	switch runner.AppFramework(root) {
+	case "go":
+		return setupGo(root, dir)
+	case "node":
+		env, err := nodeEnv(dir)
+		return Profiler{Kind: KindNodeCPUProf, Start: runner.StartOptions{Env: env}}, err
+	case "django":
+		return Profiler{Kind: KindPythonCProfile}, nil
+	}
+	return Profiler{}, nil
+}
+
+// Hotspots lists the project functions with the highest self time across
+// the result's route tables (or its run-level table when there are none),
+// with paths relative to root. Functions outside root, and vendored code,
+// are skipped.
+func Hotspots(res Result, root string, limit int) []cleanup.Hotspot {
+	type key struct{ file, name string }
+	byKey := map[key]*cleanup.Hotspot{}
+	var order []key
+	add := func(f Function, route string) {
+		rel, ok := projectFile(root, f.File)
+		if !ok {
+			return
+		}
+		k := key{rel, f.Name}
+		h := byKey[k]
+		if h == nil {
+			h = &cleanup.Hotspot{File: rel, Function: f.Name, Line: f.Line}
+			byKey[k] = h
+			order = append(order, k)
+		}
+		h.CPUPct = max(h.CPUPct, f.FlatPct)
+		if route != "" {
+			h.Routes = append(h.Routes, route)
+		}
+	}
+	if len(res.Routes) == 0 {
+		for _, f := range res.Top {
+			add(f, "")
+		}
+	}
+	for _, r := range res.Routes {
+		for _, f := range r.CPU {
+			add(f, r.Method+" "+r.Path)
+		}
+	}
+	out := make([]cleanup.Hotspot, 0, len(order))
+	for _, k := range order {
+		if h := byKey[k]; h.CPUPct > 0 {
+			out = append(out, *h)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CPUPct > out[j].CPUPct })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// projectFile returns file relative to root, when it lies inside root and
+// outside vendored dependency trees.
+func projectFile(root, file string) (string, bool) {
+	file = strings.TrimPrefix(file, "file://")
+	if file == "" {
+		return "", false
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(root, file)
+	}
+	rel, err := filepath.Rel(root, filepath.Clean(file))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, part := range strings.Split(rel, "/") {
+		switch part {
+		case "node_modules", "vendor", "site-packages", ".venv", "venv":
+			return "", false
+		}
+	}
+	return rel, true
+}
+
+// top sorts fns by flat value, fills percentages against total and keeps
+// the first TopN.
+func top(fns []Function, total float64) []Function {
+	sort.SliceStable(fns, func(i, j int) bool {
+		if fns[i].Flat != fns[j].Flat {
+			return fns[i].Flat > fns[j].Flat
+		}
+		return fns[i].Cum > fns[j].Cum
+	})
+	if len(fns) > TopN {
+		fns = fns[:TopN]
+	}
+	if total > 0 {
+		for i := range fns {
+			fns[i].FlatPct = round2(fns[i].Flat / total * 100)
+			fns[i].CumPct = round2(fns[i].Cum / total * 100)
+		}
+	}
+	return fns
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
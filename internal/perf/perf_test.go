@@ -0,0 +1,180 @@
+package perf
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+const goTopFixture = `File: app
+Type: cpu
+Duration: 2s, Total samples = 600ms (30.00%)
+Showing nodes accounting for 480ms, 80.00% of 600ms total
+      flat  flat%   sum%        cum   cum%
+     170ms 28.33% 28.33%      180ms 30.00%  main.busy /srv/app/main.go:11
+      30ms  5.00% 33.33%       30ms  5.00%  main.busy /srv/app/main.go:12
+     100ms 16.67% 50.00%      100ms 16.67%  runtime.memmove /usr/local/go/src/runtime/memmove_amd64.s:122
+      10ms  1.67% 51.67%       10ms  1.67%  internal/runtime/atomic.(*Int64).Load /usr/local/go/src/internal/runtime/atomic/types.go:74 (inline)
+         0     0% 51.67%      0.60s   100%  main.main /srv/app/main.go:30
+`
+
+func TestParseGoTopAggregatesLinesByFunction(t *testing.T) {
+	fns := ParseGoTop(goTopFixture)
+	if len(fns) != 4 {
+		t.Fatalf("expected 4 functions, got %+v", fns)
+	}
+	busy := fns[0]
+	if busy.Name != "main.busy" || busy.File != "/srv/app/main.go" || busy.Line != 11 {
+		t.Fatalf("unexpected hottest function: %+v", busy)
+	}
+	if busy.Flat != 200 || busy.FlatPct != 33.33 || busy.CumPct != 35 {
+		t.Fatalf("lines not aggregated against the 600ms total: %+v", busy)
+	}
+	if fns[2].Name != "internal/runtime/atomic.(*Int64).Load" || fns[2].Line != 74 {
+		t.Fatalf("inline marker not stripped: %+v", fns[2])
+	}
+	if main := fns[3]; main.Name != "main.main" || main.Cum != 600 {
+		t.Fatalf("expected cum-only main.main last, got %+v", main)
+	}
+}
+
+func TestParseQuantityUnits(t *testing.T) {
+	cases := map[string]float64{"1.5s": 1500, "250us": 0.25, "2kB": 2048, "1.5MB": 1.5 * (1 << 20), "0": 0, "-3ms": -3}
+	for in, want := range cases {
+		got, ok := parseQuantity(in)
+		if !ok || got != want {
+			t.Fatalf("parseQuantity(%q) = %v, %v; want %v", in, got, ok, want)
+		}
+	}
+	if _, ok := parseQuantity("3parsecs"); ok {
+		t.Fatal("expected unknown unit to be rejected")
+	}
+}
+
+func TestParseNodeProfilesAttributesSamplesToWindows(t *testing.T) {
+	dir := t.TempDir()
+	// Samples every 10ms from t=10ms to t=100ms; the profile ends at 100ms.
+	// Node 3 (handleOrders) is on CPU for the first half, node 4
+	// (renderReport) for the second, with idle samples mixed in.
+	p := map[string]any{
+		"nodes": []map[string]any{
+			{"id": 1, "callFrame": map[string]any{"functionName": "(root)", "url": "", "lineNumber": -1}, "children": []int{2, 5}},
+			{"id": 2, "callFrame": map[string]any{"functionName": "router", "url": "file:///srv/app/routes.js", "lineNumber": 4}, "children": []int{3, 4}},
+			{"id": 3, "callFrame": map[string]any{"functionName": "handleOrders", "url": "file:///srv/app/orders.js", "lineNumber": 9}},
+			{"id": 4, "callFrame": map[string]any{"functionName": "renderReport", "url": "file:///srv/app/report.js", "lineNumber": 19}},
+			{"id": 5, "callFrame": map[string]any{"functionName": "(idle)", "url": "", "lineNumber": -1}},
+		},
+		"startTime":  0,
+		"endTime":    100000,
+		"samples":    []int{3, 3, 3, 5, 3, 4, 4, 5, 4, 4},
+		"timeDeltas": []int{10000, 10000, 10000, 10000, 10000, 10000, 10000, 10000, 10000, 10000},
+	}
+	data, _ := json.Marshal(p)
+	if err := os.WriteFile(filepath.Join(dir, "CPU.1.cpuprofile"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stopped := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return stopped.Add(time.Duration(ms-100) * time.Millisecond) }
+	windows := []Window{
+		{RouteID: "orders", Method: "GET", Path: "/orders", Start: at(0), End: at(55)},
+		{RouteID: "report", Method: "GET", Path: "/report", Start: at(55), End: at(101)},
+	}
+	all, routes, err := ParseNodeProfiles(dir, stopped, windows)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(all) != 3 || all[0].Name != "handleOrders" || all[0].FlatPct != 50 {
+		t.Fatalf("unexpected run-level table: %+v", all)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 route tables, got %+v", routes)
+	}
+	orders, report := routes[0].CPU, routes[1].CPU
+	if orders[0].Name != "handleOrders" || orders[0].FlatPct != 100 || orders[0].File != "/srv/app/orders.js" || orders[0].Line != 10 {
+		t.Fatalf("unexpected orders table: %+v", orders)
+	}
+	if report[0].Name != "renderReport" || report[0].FlatPct != 100 {
+		t.Fatalf("unexpected report table: %+v", report)
+	}
+	for _, f := range append(orders, report...) {
+		if f.Name == "router" && f.CumPct != 100 {
+			t.Fatalf("expected router to carry all inclusive time, got %+v", f)
+		}
+	}
+}
+
+func TestWrapPythonRunsServerUnderCProfile(t *testing.T) {
+	got := wrapPython("/tmp/prof")([]string{"python", "manage.py", "runserver", "127.0.0.1:8000"})
+	want := []string{"python", "-m", "cProfile", "-o", "/tmp/prof/app.prof", "manage.py", "runserver", "127.0.0.1:8000", "--noreload", "--nothreading"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("unexpected wrapped command:\n got %v\nwant %v", got, want)
+	}
+	if npm := wrapPython("/tmp/prof")([]string{"npm", "run", "dev"}); !slices.Equal(npm, []string{"npm", "run", "dev"}) {
+		t.Fatalf("non-python command rewritten: %v", npm)
+	}
+}
+
+func TestSetupBuildsGoAppsWithPprof(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	root, dir := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/app\n\ngo 1.21\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n\nfunc main() { select {} }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prof, err := Setup(root, dir)
+	if err != nil || prof.Kind != KindGoPprof || prof.PprofURL == "" {
+		t.Fatalf("setup: %+v %v", prof, err)
+	}
+	argv := prof.Start.Wrap([]string{"go", "run", "."})
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Env = append(os.Environ(), prof.Start.Env...)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for !GoPprofAvailable(prof.PprofURL) {
+		if time.Now().After(deadline) {
+			t.Fatalf("no pprof at %s", prof.PprofURL)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 2 {
+		t.Fatalf("expected the project left untouched, got %v", entries)
+	}
+}
+
+func TestHotspotsKeepsProjectFunctionsOnly(t *testing.T) {
+	res := Result{Routes: []RouteProfile{
+		{Method: "GET", Path: "/orders", CPU: []Function{
+			{Name: "main.busy", File: "/srv/app/main.go", Line: 11, FlatPct: 40},
+			{Name: "runtime.memmove", File: "/usr/local/go/src/runtime/memmove_amd64.s", FlatPct: 30},
+			{Name: "lodash.merge", File: "/srv/app/node_modules/lodash/merge.js", FlatPct: 20},
+		}},
+		{Method: "GET", Path: "/report", CPU: []Function{
+			{Name: "main.busy", File: "/srv/app/main.go", Line: 11, FlatPct: 55},
+			{Name: "main.render", File: "/srv/app/render.go", Line: 3, FlatPct: 10},
+		}},
+	}}
+	got := Hotspots(res, "/srv/app", 0)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 hotspots, got %+v", got)
+	}
+	if got[0].Function != "main.busy" || got[0].File != "main.go" || got[0].CPUPct != 55 || !slices.Equal(got[0].Routes, []string{"GET /orders", "GET /report"}) {
+		t.Fatalf("unexpected top hotspot: %+v", got[0])
+	}
+	if got[1].Function != "main.render" {
+		t.Fatalf("unexpected second hotspot: %+v", got[1])
+	}
+}
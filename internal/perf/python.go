@@ -0,0 +1,89 @@
+package perf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cool-code-cleanup/internal/runner"
+)
+
+// pstatsScript prints a cProfile output file as JSON with times in ms.
+const pstatsScript = `import json, pstats, sys
+st = pstats.Stats(sys.argv[1])
+fns = []
+for (file, line, name), (cc, nc, tt, ct, callers) in st.stats.items():
+    fns.append({"name": name, "file": file, "line": line, "flat": tt * 1000, "cum": ct * 1000})
+print(json.dumps({"total": st.total_tt * 1000, "functions": fns}))
+`
+
+// PythonStartOptions returns how to start one app process under cProfile,
+// writing its output to dir. cProfile only writes its output when Python
+// exits normally, so the app is stopped with SIGINT rather than SIGTERM.
+func PythonStartOptions(dir string) (runner.StartOptions, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return runner.StartOptions{}, fmt.Errorf("create profile directory: %w", err)
+	}
+	return runner.StartOptions{Wrap: wrapPython(dir), StopSignal: os.Interrupt}, nil
+}
+
+// PythonProfilePath is where the wrapped app writes its cProfile output.
+func PythonProfilePath(dir string) string {
+	return filepath.Join(dir, "app.prof")
+}
+
+// wrapPython runs a Python launch command under cProfile. Django's
+// autoreloader and threaded server are turned off, since cProfile only sees
+// the main thread of the process it started.
+func wrapPython(dir string) func([]string) []string {
+	return func(argv []string) []string {
+		if len(argv) == 0 || !strings.HasPrefix(filepath.Base(argv[0]), "python") {
+			return argv
+		}
+		out := []string{argv[0], "-m", "cProfile", "-o", PythonProfilePath(dir)}
+		out = append(out, argv[1:]...)
+		if slices.Contains(argv, "runserver") {
+			for _, flag := range []string{"--noreload", "--nothreading"} {
+				if !slices.Contains(argv, flag) {
+					out = append(out, flag)
+				}
+			}
+		}
+		return out
+	}
+}
+
+// ParsePythonProfile reads a cProfile output file with the local Python.
+// cProfile keeps no timestamps, so the result covers the whole run.
+func ParsePythonProfile(path string) ([]Function, error) {
+	python, err := exec.LookPath("python")
+	if err != nil {
+		if python, err = exec.LookPath("python3"); err != nil {
+			return nil, fmt.Errorf("python not found to read %s", path)
+		}
+	}
+	out, err := exec.Command(python, "-c", pstatsScript, path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("read cProfile output %s: %w", path, err)
+	}
+	var parsed struct {
+		Total     float64    `json:"total"`
+		Functions []Function `json:"functions"`
+	}
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return nil, fmt.Errorf("parse cProfile output %s: %w", path, err)
+	}
+	fns := parsed.Functions[:0]
+	for _, f := range parsed.Functions {
+		// Built-ins are reported with file "~".
+		if f.File == "~" {
+			f.File = ""
+		}
+		fns = append(fns, f)
+	}
+	return top(fns, parsed.Total), nil
+}
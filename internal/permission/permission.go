@@ -13,13 +13,16 @@ type Engine struct {
 	NonInteractive bool
 }
 
-func (e Engine) ApproveFile(io tui.IO, file string, changes int) (bool, error) {
+// ApproveFile asks whether to apply a file's edits, showing diff (their
+// combined diffs) first.
+func (e Engine) ApproveFile(io tui.IO, file string, changes int, diff string) (bool, error) {
 	if e.AutoApply || e.NonInteractive {
 		return true, nil
 	}
 	if e.Mode == "per-edit" {
 		return true, nil
 	}
+	showDiff(io, diff)
 	resp, err := io.Prompt(fmt.Sprintf("Approve file changes for %s (%d edits)? [y/N]: ", file, changes))
 	if err != nil {
 		return false, err
@@ -27,13 +30,15 @@ func (e Engine) ApproveFile(io tui.IO, file string, changes int) (bool, error) {
 	return isYes(resp), nil
 }
 
-func (e Engine) ApproveEdit(io tui.IO, file, desc string) (bool, error) {
+// ApproveEdit asks whether to apply one edit, showing its diff first.
+func (e Engine) ApproveEdit(io tui.IO, file, desc, diff string) (bool, error) {
 	if e.AutoApply || e.NonInteractive {
 		return true, nil
 	}
 	if e.Mode != "per-edit" {
 		return true, nil
 	}
+	showDiff(io, diff)
 	resp, err := io.Prompt(fmt.Sprintf("Approve edit in %s: %s [y/N]: ", file, desc))
 	if err != nil {
 		return false, err
@@ -41,6 +46,12 @@ func (e Engine) ApproveEdit(io tui.IO, file, desc string) (bool, error) {
 	return isYes(resp), nil
 }
 
+func showDiff(io tui.IO, diff string) {
+	if diff != "" && io.Out != nil {
+		fmt.Fprint(io.Out, diff)
+	}
+}
+
 func isYes(s string) bool {
 	v := strings.ToLower(strings.TrimSpace(s))
 	return v == "y" || v == "yes"
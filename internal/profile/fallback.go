@@ -0,0 +1,237 @@
+package profile
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cool-code-cleanup/internal/discovery"
+)
+
+// SourceAI marks params proposed by the AI fallback rather than read from
+// type information, so their values can be double-checked before profiling.
+const SourceAI = "ai"
+
+// maxHandlerSource caps the handler text sent to the fallback.
+const maxHandlerSource = 8 << 10
+
+// Fallback proposes params for a route whose handler has no schema the
+// extractors understand. Proposals are checked with ValidateParams before use.
+type Fallback interface {
+	InferParameters(r discovery.Route, handlerSource string) ([]Param, error)
+}
+
+// needsFallback reports whether params carry no type information beyond the
+// path template and untyped request accessors.
+func needsFallback(params []Param) bool {
+	for _, p := range params {
+		if p.Source != SourcePathTemplate && p.Source != SourcePathConverter && !accessorSource(p.Source) {
+			return false
+		}
+	}
+	return true
+}
+
+// handlerSource returns the source of the route's handler: the Go handler
+// body, the Python def or class block, or the Express registration region.
+func handlerSource(r discovery.Route) string {
+	var src string
+	switch r.Framework {
+	case "go":
+		pkg := loadGoPackage(filepath.Dir(r.File))
+		if file := pkg.files[filepath.Clean(r.File)]; file != nil {
+			if body := pkg.handlerBody(file, r); body != nil {
+				start, end := pkg.fset.Position(body.Pos()), pkg.fset.Position(body.End())
+				if data, err := os.ReadFile(start.Filename); err == nil && end.Offset <= len(data) {
+					src = string(data[start.Offset:end.Offset])
+				}
+			}
+		}
+	case "django":
+		handler := djangoHandlerName(r.Handler)
+		for _, lines := range readPythonFiles(filepath.Dir(r.File)) {
+			if block := pyBlockOf(lines, handler); block != nil {
+				src = strings.Join(block, "\n")
+				break
+			}
+		}
+	default:
+		if data, err := os.ReadFile(r.File); err == nil {
+			src = jsRouteRegion(string(data), r.Line)
+		}
+	}
+	src = strings.TrimSpace(src)
+	if len(src) > maxHandlerSource {
+		src = src[:maxHandlerSource]
+	}
+	return src
+}
+
+// ValidateParams checks fallback proposals against the parameter model and
+// returns the usable params, tagged SourceAI, with a warning per rejected
+// param or dropped attribute.
+func ValidateParams(r discovery.Route, proposed []Param) ([]Param, []string) {
+	templated := map[string]bool{}
+	for _, p := range pathParams(r) {
+		templated[p.Name] = true
+	}
+	var out []Param
+	var warnings []string
+	seen := map[string]bool{}
+	reject := func(p Param, reason string) {
+		warnings = append(warnings, fmt.Sprintf("%s %s: dropped AI parameter %s.%s: %s", r.Method, r.Path, p.In, p.Name, reason))
+	}
+	for _, p := range proposed {
+		p.Name = strings.TrimSpace(p.Name)
+		p.In = strings.ToLower(strings.TrimSpace(p.In))
+		p.Type = normalizeType(p.Type)
+		p.Format = strings.ToLower(strings.TrimSpace(p.Format))
+		p.Source = SourceAI
+		switch {
+		case p.Name == "":
+			reject(p, "missing name")
+			continue
+		case p.In != InPath && p.In != InQuery && p.In != InHeader && p.In != InBody:
+			reject(p, fmt.Sprintf("unknown location %q", p.In))
+			continue
+		case p.Type == "":
+			reject(p, "unknown type")
+			continue
+		case p.In == InPath && !templated[p.Name]:
+			reject(p, "not in the route path")
+			continue
+		case p.Type == TypeFile && p.In != InBody:
+			reject(p, "file params must be in the body")
+			continue
+		case seen[p.In+"."+p.Name]:
+			reject(p, "duplicate")
+			continue
+		}
+		seen[p.In+"."+p.Name] = true
+		if p.In == InPath {
+			p.Required = true
+		}
+		if p.Min != nil && p.Max != nil && *p.Min > *p.Max {
+			warnings = append(warnings, fmt.Sprintf("%s %s: dropped min/max of AI parameter %s.%s: min > max", r.Method, r.Path, p.In, p.Name))
+			p.Min, p.Max = nil, nil
+		}
+		if (p.MinLength != nil && *p.MinLength < 0) || (p.MaxLength != nil && *p.MaxLength < 0) || (p.MinLength != nil && p.MaxLength != nil && *p.MinLength > *p.MaxLength) {
+			warnings = append(warnings, fmt.Sprintf("%s %s: dropped length bounds of AI parameter %s.%s: invalid range", r.Method, r.Path, p.In, p.Name))
+			p.MinLength, p.MaxLength = nil, nil
+		}
+		if p.Example != nil {
+			v, err := conformValue(p, p.Example)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s %s: dropped example of AI parameter %s.%s: %v", r.Method, r.Path, p.In, p.Name, err))
+			}
+			p.Example = v
+		}
+		out = append(out, p)
+	}
+	return out, warnings
+}
+
+func normalizeType(t string) string {
+	switch strings.ToLower(strings.TrimSpace(t)) {
+	case "string", "str", "text":
+		return TypeString
+	case "integer", "int", "int64":
+		return TypeInteger
+	case "number", "float", "double", "decimal":
+		return TypeNumber
+	case "boolean", "bool":
+		return TypeBoolean
+	case "array", "list":
+		return TypeArray
+	case "object", "dict", "map":
+		return TypeObject
+	case "file", "binary", "upload":
+		return TypeFile
+	}
+	return ""
+}
+
+// conformValue returns v converted to p's JSON type, or an error when it
+// violates the param's type, enum or bounds. Strings that parse as the
+// param's type are accepted, since proposals often quote numbers.
+func conformValue(p Param, v any) (any, error) {
+	if s, ok := v.(string); ok && p.Type != TypeString && p.Type != TypeFile {
+		v = typedValue(p, s)
+	}
+	switch p.Type {
+	case TypeInteger, TypeNumber:
+		f, ok := v.(float64)
+		if n, isInt := v.(int64); isInt {
+			f, ok = float64(n), true
+		}
+		if !ok {
+			return nil, fmt.Errorf("expected %s, got %T", p.Type, v)
+		}
+		if p.Min != nil && f < *p.Min || p.Max != nil && f > *p.Max {
+			return nil, fmt.Errorf("%v out of range", f)
+		}
+		if p.Type == TypeInteger {
+			if f != math.Trunc(f) {
+				return nil, fmt.Errorf("%v is not an integer", f)
+			}
+			v = int64(f)
+		}
+	case TypeBoolean:
+		if _, ok := v.(bool); !ok {
+			return nil, fmt.Errorf("expected boolean, got %T", v)
+		}
+	case TypeArray:
+		if _, ok := v.([]any); !ok {
+			return nil, fmt.Errorf("expected array, got %T", v)
+		}
+	case TypeObject:
+		if _, ok := v.(map[string]any); !ok {
+			return nil, fmt.Errorf("expected object, got %T", v)
+		}
+	case TypeFile:
+		if _, _, _, ok := FileUpload(v); !ok {
+			return nil, fmt.Errorf("expected file object with filename")
+		}
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %T", v)
+		}
+		if p.MinLength != nil && len(s) < *p.MinLength || p.MaxLength != nil && len(s) > *p.MaxLength {
+			return nil, fmt.Errorf("length %d out of range", len(s))
+		}
+	}
+	if len(p.Enum) > 0 {
+		raw := fmt.Sprint(v)
+		for _, e := range p.Enum {
+			if e == raw {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("%s is not one of %s", raw, strings.Join(p.Enum, ", "))
+	}
+	return v, nil
+}
+
+// aiSourced lists the location-prefixed keys of AI-proposed params.
+func aiSourced(params []Param) []string {
+	var out []string
+	for _, p := range params {
+		if p.Source == SourceAI {
+			out = append(out, p.In+"."+p.Name)
+		}
+	}
+	return out
+}
+
+func withoutKey(keys []string, key string) []string {
+	var out []string
+	for _, k := range keys {
+		if k != key {
+			out = append(out, k)
+		}
+	}
+	return out
+}
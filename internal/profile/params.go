@@ -2,28 +2,481 @@ package profile
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
 	"cool-code-cleanup/internal/discovery"
 )
 
+// Parameter locations.
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+	InBody   = "body"
+)
+
+// Parameter types. String formats (email, uuid, ...) are carried in Format.
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeArray   = "array"
+	TypeObject  = "object"
+	TypeFile    = "file"
+)
+
+// Body encodings. Plans without body params leave BodyEncoding empty.
+const (
+	EncodingJSON      = "json"
+	EncodingForm      = "form"
+	EncodingMultipart = "multipart"
+)
+
+// Param sources, recorded so the report shows where each parameter came from.
+const (
+	SourcePathTemplate    = "path_template"
+	SourcePathConverter   = "path_converter"
+	SourceGoStructTag     = "go_struct_tag"
+	SourceGoAccessor      = "go_request_accessor"
+	SourcePydantic        = "pydantic"
+	SourceDRF             = "drf_serializer"
+	SourceNinjaSignature  = "ninja_signature"
+	SourceDjangoAccessor  = "django_request_accessor"
+	SourceZod             = "zod"
+	SourceJoi             = "joi"
+	SourceExpressAccessor = "express_request_accessor"
+)
+
+// Invalid variant kinds.
+const (
+	InvalidWrongType       = "wrong_type"
+	InvalidMissingRequired = "missing_required"
+	InvalidOutOfRange      = "out_of_range"
+)
+
+// Param describes one request input discovered from type information.
+type Param struct {
+	Name      string   `json:"name"`
+	In        string   `json:"in"`
+	Type      string   `json:"type"`
+	Format    string   `json:"format,omitempty"`
+	Required  bool     `json:"required"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+	MinLength *int     `json:"min_length,omitempty"`
+	MaxLength *int     `json:"max_length,omitempty"`
+	Enum      []string `json:"enum,omitempty"`
+	Example   any      `json:"example,omitempty"`
+	Source    string   `json:"source"`
+}
+
+// ParameterSet is one concrete request input. Invalid sets name the Param
+// they target and the Kind of violation. AISourced lists the keys (as in
+// Flatten) whose values were proposed by the AI fallback.
+type ParameterSet struct {
+	Name      string            `json:"name"`
+	Kind      string            `json:"kind,omitempty"`
+	Param     string            `json:"param,omitempty"`
+	Path      map[string]string `json:"path,omitempty"`
+	Query     map[string]string `json:"query,omitempty"`
+	Header    map[string]string `json:"header,omitempty"`
+	Body      map[string]any    `json:"body,omitempty"`
+	AISourced []string          `json:"ai_sourced,omitempty"`
+}
+
 type ParameterPlan struct {
-	RouteID string              `json:"route_id"`
-	Valid   []map[string]string `json:"valid"`
-	Invalid []map[string]string `json:"invalid"`
+	RouteID      string         `json:"route_id"`
+	Params       []Param        `json:"params"`
+	BodyEncoding string         `json:"body_encoding,omitempty"`
+	Valid        []ParameterSet `json:"valid"`
+	Invalid      []ParameterSet `json:"invalid"`
+}
+
+// OmittedHeader returns the header a missing_required variant leaves out on
+// purpose, or "".
+func (s ParameterSet) OmittedHeader() string {
+	if s.Kind != InvalidMissingRequired || s.Name != s.Kind+":"+InHeader+"."+s.Param {
+		return ""
+	}
+	return s.Param
+}
+
+// Flatten returns the set as location-prefixed string values, e.g.
+// "path.id" or "body.email", for logging.
+func (s ParameterSet) Flatten() map[string]string {
+	out := map[string]string{}
+	for k, v := range s.Path {
+		out[InPath+"."+k] = v
+	}
+	for k, v := range s.Query {
+		out[InQuery+"."+k] = v
+	}
+	for k, v := range s.Header {
+		out[InHeader+"."+k] = v
+	}
+	for k, v := range s.Body {
+		out[InBody+"."+k] = fmt.Sprint(v)
+	}
+	return out
 }
 
-func AnalyzeParameters(routes []discovery.Route) []ParameterPlan {
+// AnalyzeParameters builds a plan per route from path templates and the
+// framework's type information (Go struct tags, pydantic models, DRF
+// serializers, zod/joi schemas). Source files that cannot be read or parsed
+// only reduce what is discovered. When fallback is non-nil, routes without
+// schema-derived params get its validated proposals; fallback failures and
+// rejected proposals are returned as warnings.
+func AnalyzeParameters(routes []discovery.Route, fallback Fallback) ([]ParameterPlan, []string) {
 	plans := make([]ParameterPlan, 0, len(routes))
-	for i, r := range routes {
-		plans = append(plans, ParameterPlan{
-			RouteID: r.ID,
-			Valid: []map[string]string{
-				{"example": "valid", "sequence": fmt.Sprintf("%d", i+1)},
-			},
-			Invalid: []map[string]string{
-				{"example": "invalid", "sequence": "0"},
-			},
-		})
-	}
-	return plans
+	var warnings []string
+	for _, r := range routes {
+		params := pathParams(r)
+		var extra []Param
+		var encoding string
+		switch r.Framework {
+		case "go":
+			extra, encoding = goParams(r)
+		case "django":
+			extra, encoding = djangoParams(r)
+		case "node":
+			extra, encoding = nodeParams(r)
+		}
+		params = mergeParams(params, extra)
+		if fallback != nil && needsFallback(params) {
+			if src := handlerSource(r); src != "" {
+				proposed, err := fallback.InferParameters(r, src)
+				if err != nil {
+					warnings = append(warnings, fmt.Sprintf("%s %s: AI parameter inference failed: %v", r.Method, r.Path, err))
+				} else {
+					accepted, rejected := ValidateParams(r, proposed)
+					warnings = append(warnings, rejected...)
+					params = mergeParams(params, accepted)
+				}
+			}
+		}
+		plan := BuildPlan(r.ID, params)
+		plan.BodyEncoding = bodyEncoding(params, encoding)
+		plans = append(plans, plan)
+	}
+	return plans, warnings
+}
+
+// bodyEncoding picks multipart when any file param exists, otherwise the
+// extractor's hint, defaulting to JSON when the route takes a body.
+func bodyEncoding(params []Param, hint string) string {
+	hasBody := false
+	for _, p := range params {
+		if p.In != InBody {
+			continue
+		}
+		hasBody = true
+		if p.Type == TypeFile {
+			return EncodingMultipart
+		}
+	}
+	if !hasBody {
+		return ""
+	}
+	if hint != "" {
+		return hint
+	}
+	return EncodingJSON
+}
+
+// FileUpload reports whether v is a synthesized file value and returns its
+// parts. File values are plain maps so they survive a JSON round trip.
+func FileUpload(v any) (filename, contentType, content string, ok bool) {
+	m, isMap := v.(map[string]any)
+	if !isMap {
+		return "", "", "", false
+	}
+	filename, ok = m["filename"].(string)
+	if !ok {
+		return "", "", "", false
+	}
+	contentType, _ = m["content_type"].(string)
+	content, _ = m["content"].(string)
+	return filename, contentType, content, true
 }
+
+// BuildPlan synthesizes one valid set and targeted invalid sets for params.
+func BuildPlan(routeID string, params []Param) ParameterPlan {
+	sortParams(params)
+	plan := ParameterPlan{RouteID: routeID, Params: params}
+	valid := validSet(params)
+	valid.Name = "valid"
+	valid.AISourced = aiSourced(params)
+	plan.Valid = []ParameterSet{valid}
+	plan.Invalid = []ParameterSet{}
+	for _, p := range params {
+		for _, inv := range invalidVariants(p) {
+			set := validSet(params)
+			set.Name = inv.kind + ":" + p.In + "." + p.Name
+			set.Kind = inv.kind
+			set.Param = p.Name
+			if inv.omit {
+				set.remove(p)
+			} else {
+				set.put(p, inv.value)
+			}
+			set.AISourced = withoutKey(aiSourced(params), p.In+"."+p.Name)
+			plan.Invalid = append(plan.Invalid, set)
+		}
+	}
+	return plan
+}
+
+func validSet(params []Param) ParameterSet {
+	var s ParameterSet
+	for _, p := range params {
+		s.put(p, validValue(p))
+	}
+	return s
+}
+
+func (s *ParameterSet) put(p Param, v any) {
+	switch p.In {
+	case InPath:
+		if s.Path == nil {
+			s.Path = map[string]string{}
+		}
+		s.Path[p.Name] = fmt.Sprint(v)
+	case InQuery:
+		if s.Query == nil {
+			s.Query = map[string]string{}
+		}
+		s.Query[p.Name] = fmt.Sprint(v)
+	case InHeader:
+		if s.Header == nil {
+			s.Header = map[string]string{}
+		}
+		s.Header[p.Name] = fmt.Sprint(v)
+	default:
+		if s.Body == nil {
+			s.Body = map[string]any{}
+		}
+		s.Body[p.Name] = v
+	}
+}
+
+func (s *ParameterSet) remove(p Param) {
+	switch p.In {
+	case InPath:
+		delete(s.Path, p.Name)
+	case InQuery:
+		delete(s.Query, p.Name)
+	case InHeader:
+		delete(s.Header, p.Name)
+	default:
+		delete(s.Body, p.Name)
+	}
+}
+
+func validValue(p Param) any {
+	if p.Example != nil {
+		return p.Example
+	}
+	if len(p.Enum) > 0 {
+		return typedValue(p, p.Enum[0])
+	}
+	switch p.Type {
+	case TypeInteger:
+		n := 1.0
+		if p.Min != nil && n < *p.Min {
+			n = *p.Min
+		}
+		if p.Max != nil && n > *p.Max {
+			n = *p.Max
+		}
+		return int64(n)
+	case TypeNumber:
+		n := 1.5
+		if p.Min != nil && n < *p.Min {
+			n = *p.Min
+		}
+		if p.Max != nil && n > *p.Max {
+			n = *p.Max
+		}
+		return n
+	case TypeBoolean:
+		return true
+	case TypeArray:
+		return []any{}
+	case TypeObject:
+		return map[string]any{}
+	case TypeFile:
+		return map[string]any{"filename": p.Name + ".txt", "content_type": "text/plain", "content": "ccc profile upload\n"}
+	}
+	return fitLength(p, stringExample(p))
+}
+
+func stringExample(p Param) string {
+	switch p.Format {
+	case "email":
+		return "user@example.com"
+	case "uuid":
+		return "123e4567-e89b-12d3-a456-426614174000"
+	case "url":
+		return "https://example.com"
+	case "date-time":
+		return "2024-01-01T00:00:00Z"
+	case "date":
+		return "2024-01-01"
+	case "slug":
+		return "example-slug"
+	}
+	name := strings.ToLower(p.Name)
+	switch {
+	case strings.Contains(name, "email"):
+		return "user@example.com"
+	case strings.Contains(name, "password"):
+		return "P@ssw0rd123"
+	case strings.Contains(name, "phone"):
+		return "+15555550123"
+	case strings.Contains(name, "otp") || name == "code":
+		return "123456"
+	case strings.Contains(name, "token"), name == "authorization":
+		return "example-token"
+	case strings.Contains(name, "url"):
+		return "https://example.com"
+	case strings.HasSuffix(name, "id"):
+		return "1"
+	}
+	return "example"
+}
+
+func fitLength(p Param, s string) string {
+	if p.MinLength != nil && len(s) < *p.MinLength {
+		s += strings.Repeat("x", *p.MinLength-len(s))
+	}
+	if p.MaxLength != nil && len(s) > *p.MaxLength {
+		s = s[:*p.MaxLength]
+	}
+	return s
+}
+
+// typedValue converts an enum literal to the parameter's JSON type.
+func typedValue(p Param, raw string) any {
+	switch p.Type {
+	case TypeInteger:
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return n
+		}
+	case TypeNumber:
+		if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			return f
+		}
+	case TypeBoolean:
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+type invalidVariant struct {
+	kind  string
+	value any
+	omit  bool
+}
+
+func invalidVariants(p Param) []invalidVariant {
+	var out []invalidVariant
+	if wrong, ok := wrongTypeValue(p); ok {
+		out = append(out, invalidVariant{kind: InvalidWrongType, value: wrong})
+	}
+	if p.Required && p.In != InPath {
+		out = append(out, invalidVariant{kind: InvalidMissingRequired, omit: true})
+	}
+	switch p.Type {
+	case TypeInteger, TypeNumber:
+		if p.Min != nil {
+			out = append(out, invalidVariant{kind: InvalidOutOfRange, value: numberValue(p, *p.Min-1)})
+		}
+		if p.Max != nil {
+			out = append(out, invalidVariant{kind: InvalidOutOfRange, value: numberValue(p, *p.Max+1)})
+		}
+	case TypeString:
+		if p.MinLength != nil && *p.MinLength > 0 {
+			out = append(out, invalidVariant{kind: InvalidOutOfRange, value: strings.Repeat("x", *p.MinLength-1)})
+		}
+		if p.MaxLength != nil {
+			out = append(out, invalidVariant{kind: InvalidOutOfRange, value: strings.Repeat("x", *p.MaxLength+1)})
+		}
+	}
+	return out
+}
+
+func numberValue(p Param, f float64) any {
+	if p.Type == TypeInteger {
+		return int64(f)
+	}
+	return f
+}
+
+func wrongTypeValue(p Param) (any, bool) {
+	if len(p.Enum) > 0 {
+		return "not-an-option", true
+	}
+	switch p.Type {
+	case TypeInteger, TypeNumber:
+		return "not-a-number", true
+	case TypeBoolean:
+		return "not-a-boolean", true
+	case TypeArray, TypeObject, TypeFile:
+		return "not-a-" + p.Type, true
+	}
+	if p.Format != "" {
+		return "not-a-" + p.Format, true
+	}
+	if p.In == InBody {
+		return int64(12345), true
+	}
+	return nil, false
+}
+
+// mergeParams overlays extra onto base keyed by location and name. Extra
+// params win, except that typed path converters are kept and params seen only
+// through request accessors (which carry no type information) never replace
+// an existing one.
+func mergeParams(base, extra []Param) []Param {
+	idx := map[string]int{}
+	for i, p := range base {
+		idx[p.In+"\x00"+p.Name] = i
+	}
+	for _, p := range extra {
+		key := p.In + "\x00" + p.Name
+		i, ok := idx[key]
+		if !ok {
+			idx[key] = len(base)
+			base = append(base, p)
+			continue
+		}
+		if (base[i].Source == SourcePathConverter && p.Source != SourcePathConverter) || accessorSource(p.Source) {
+			continue
+		}
+		base[i] = p
+	}
+	return base
+}
+
+func accessorSource(source string) bool {
+	return source == SourceGoAccessor || source == SourceDjangoAccessor || source == SourceExpressAccessor
+}
+
+func sortParams(params []Param) {
+	order := map[string]int{InPath: 0, InQuery: 1, InHeader: 2, InBody: 3}
+	sort.SliceStable(params, func(i, j int) bool {
+		if order[params[i].In] != order[params[j].In] {
+			return order[params[i].In] < order[params[j].In]
+		}
+		return params[i].Name < params[j].Name
+	})
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+func intPtr(n int) *int { return &n }
@@ -0,0 +1,396 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cool-code-cleanup/internal/discovery"
+)
+
+func TestAnalyzeParametersGoStructTagsAndPathValues(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	writeFile(t, file, `package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type createOrder struct {
+	Email    string `+"`"+`json:"email" validate:"required,email"`+"`"+`
+	Quantity int    `+"`"+`json:"quantity" validate:"required,min=1,max=10"`+"`"+`
+	Note     string `+"`"+`json:"note,omitempty" validate:"max=5"`+"`"+`
+	Internal string `+"`"+`json:"-"`+"`"+`
+}
+
+func main() {
+	http.HandleFunc("/orders/{orderId}", createOrderHandler)
+}
+
+func createOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var req createOrder
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	_ = r.URL.Query().Get("dry_run")
+	_ = r.Header.Get("X-Request-Id")
+}
+`)
+	plans, _ := AnalyzeParameters([]discovery.Route{{ID: "r", Path: "/orders/{orderId}", File: file, Line: 15, Framework: "go"}}, nil)
+	plan := plans[0]
+
+	byName := paramsByName(plan.Params)
+	if p := byName["orderId"]; p.In != InPath || p.Type != TypeInteger {
+		t.Fatalf("unexpected path param: %+v", p)
+	}
+	if p := byName["email"]; p.In != InBody || !p.Required || p.Format != "email" || p.Source != SourceGoStructTag {
+		t.Fatalf("unexpected email param: %+v", p)
+	}
+	if p := byName["quantity"]; p.Type != TypeInteger || *p.Min != 1 || *p.Max != 10 {
+		t.Fatalf("unexpected quantity param: %+v", p)
+	}
+	if p := byName["note"]; p.Required || *p.MaxLength != 5 {
+		t.Fatalf("unexpected note param: %+v", p)
+	}
+	if _, ok := byName["Internal"]; ok {
+		t.Fatalf("json:\"-\" field should be skipped")
+	}
+	if byName["dry_run"].In != InQuery || byName["X-Request-Id"].In != InHeader {
+		t.Fatalf("expected accessor params: %+v", plan.Params)
+	}
+
+	valid := plan.Valid[0]
+	if valid.Path["orderId"] != "1" || valid.Body["email"] != "user@example.com" || valid.Body["quantity"] != int64(1) {
+		t.Fatalf("unexpected valid set: %+v", valid)
+	}
+	kinds := invalidNames(plan)
+	for _, want := range []string{
+		"wrong_type:path.orderId",
+		"wrong_type:body.email",
+		"missing_required:body.email",
+		"out_of_range:body.quantity",
+		"out_of_range:body.note",
+	} {
+		if kinds[want] == 0 {
+			t.Fatalf("missing invalid variant %s in %v", want, kinds)
+		}
+	}
+	if kinds["out_of_range:body.quantity"] != 2 {
+		t.Fatalf("expected below-min and above-max variants, got %v", kinds)
+	}
+	for _, set := range plan.Invalid {
+		if set.Name == "missing_required:body.email" {
+			if _, ok := set.Body["email"]; ok {
+				t.Fatalf("missing_required variant still has email: %+v", set)
+			}
+		}
+	}
+}
+
+func TestAnalyzeParametersDjangoPydanticDRFAndConverters(t *testing.T) {
+	dir := t.TempDir()
+	urls := filepath.Join(dir, "urls.py")
+	writeFile(t, urls, `from django.urls import path
+from . import views
+
+urlpatterns = [
+    path("signup/<uuid:invite>", views.signup),
+    path("accounts/<int:pk>", views.AccountView.as_view()),
+    path("search", views.search),
+]
+`)
+	writeFile(t, filepath.Join(dir, "schemas.py"), `from typing import Literal, Optional
+from pydantic import BaseModel, EmailStr, Field
+from rest_framework import serializers
+
+
+class SignupIn(BaseModel):
+    email: EmailStr
+    age: int = Field(..., ge=18, le=120)
+    plan: Literal["free", "pro"] = "free"
+    nickname: Optional[str] = None
+
+
+class AccountSerializer(serializers.Serializer):
+    name = serializers.CharField(max_length=3)
+    active = serializers.BooleanField(required=False)
+    id = serializers.IntegerField(read_only=True)
+`)
+	writeFile(t, filepath.Join(dir, "views.py"), `import json
+from .schemas import SignupIn, AccountSerializer
+
+
+def signup(request, invite):
+    data = SignupIn(**json.loads(request.body))
+    token = request.headers.get("X-Invite-Token")
+
+
+class AccountView(APIView):
+    def put(self, request, pk):
+        serializer = AccountSerializer(data=request.data)
+
+
+def search(request, q: str, limit: int = 10):
+    page = request.GET.get("page")
+`)
+	routes, err := discovery.Discover(dir)
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	plans, _ := AnalyzeParameters(routes, nil)
+	if len(plans) != 3 {
+		t.Fatalf("expected 3 plans, got %d", len(plans))
+	}
+
+	signup := paramsByName(plans[0].Params)
+	if p := signup["invite"]; p.In != InPath || p.Format != "uuid" || p.Source != SourcePathConverter {
+		t.Fatalf("unexpected converter param: %+v", p)
+	}
+	if p := signup["age"]; !p.Required || *p.Min != 18 || *p.Max != 120 || p.Source != SourcePydantic {
+		t.Fatalf("unexpected age param: %+v", p)
+	}
+	if p := signup["plan"]; p.Required || len(p.Enum) != 2 {
+		t.Fatalf("unexpected plan param: %+v", p)
+	}
+	if signup["nickname"].Required || !signup["email"].Required || signup["X-Invite-Token"].In != InHeader {
+		t.Fatalf("unexpected signup params: %+v", plans[0].Params)
+	}
+
+	account := paramsByName(plans[1].Params)
+	if p := account["pk"]; p.Type != TypeInteger || p.Min == nil {
+		t.Fatalf("unexpected int converter: %+v", p)
+	}
+	if p := account["name"]; !p.Required || *p.MaxLength != 3 || p.Source != SourceDRF {
+		t.Fatalf("unexpected DRF name param: %+v", p)
+	}
+	if _, ok := account["id"]; ok || account["active"].Required {
+		t.Fatalf("unexpected DRF params: %+v", plans[1].Params)
+	}
+	if v := plans[1].Valid[0].Body["name"]; v != "exa" {
+		t.Fatalf("expected valid name trimmed to max_length, got %v", v)
+	}
+
+	search := paramsByName(plans[2].Params)
+	if p := search["q"]; p.In != InQuery || !p.Required || p.Source != SourceNinjaSignature {
+		t.Fatalf("unexpected q param: %+v", p)
+	}
+	if p := search["limit"]; p.Type != TypeInteger || p.Required {
+		t.Fatalf("unexpected limit param: %+v", p)
+	}
+	if search["page"].In != InQuery {
+		t.Fatalf("expected request.GET accessor param: %+v", plans[2].Params)
+	}
+}
+
+func TestAnalyzeParametersNodeZodJoi(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "routes.js")
+	writeFile(t, file, `const { z } = require("zod");
+const Joi = require("joi");
+
+const loginSchema = z.object({
+  email: z.string().email(),
+  password: z.string().min(8),
+  remember: z.boolean().optional(),
+});
+
+const listQuery = Joi.object({
+  limit: Joi.number().integer().min(1).max(50),
+  sort: Joi.string().valid("asc", "desc").required(),
+});
+
+app.post("/auth/login", (req, res) => {
+  const body = loginSchema.parse(req.body);
+  res.json({ token: "abc" });
+});
+
+app.get("/users/:userId", (req, res) => {
+  const { error } = listQuery.validate(req.query);
+  const key = req.get("X-Api-Key");
+});
+`)
+	routes, err := discovery.Discover(dir)
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	plans, _ := AnalyzeParameters(routes, nil)
+	if len(plans) != 2 {
+		t.Fatalf("expected 2 plans, got %d", len(plans))
+	}
+
+	login := paramsByName(plans[0].Params)
+	if p := login["email"]; p.In != InBody || !p.Required || p.Format != "email" || p.Source != SourceZod {
+		t.Fatalf("unexpected email param: %+v", p)
+	}
+	if p := login["password"]; *p.MinLength != 8 || len(plans[0].Valid[0].Body["password"].(string)) < 8 {
+		t.Fatalf("unexpected password param: %+v valid=%v", p, plans[0].Valid[0].Body)
+	}
+	if login["remember"].Required {
+		t.Fatalf("optional zod field should not be required")
+	}
+	if _, ok := login["limit"]; ok {
+		t.Fatalf("schema of another route leaked into login: %+v", plans[0].Params)
+	}
+
+	users := paramsByName(plans[1].Params)
+	if p := users["userId"]; p.In != InPath || p.Type != TypeInteger {
+		t.Fatalf("unexpected path param: %+v", p)
+	}
+	if p := users["limit"]; p.In != InQuery || p.Type != TypeInteger || p.Required || p.Source != SourceJoi {
+		t.Fatalf("unexpected joi limit param: %+v", p)
+	}
+	if p := users["sort"]; !p.Required || len(p.Enum) != 2 {
+		t.Fatalf("unexpected joi sort param: %+v", p)
+	}
+	if users["X-Api-Key"].In != InHeader {
+		t.Fatalf("expected header accessor param: %+v", plans[1].Params)
+	}
+	if plans[1].Valid[0].Query["sort"] != "asc" {
+		t.Fatalf("expected first enum value, got %+v", plans[1].Valid[0])
+	}
+}
+
+func TestAnalyzeParametersBodyEncoding(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "urls.py"), `from django.urls import path
+from . import views
+
+urlpatterns = [
+    path("contact", views.contact),
+    path("avatar", views.avatar),
+]
+`)
+	writeFile(t, filepath.Join(dir, "views.py"), `def contact(request):
+    name = request.POST.get("name")
+
+
+def avatar(request):
+    upload = request.FILES["avatar"]
+`)
+	routes, err := discovery.Discover(dir)
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	plans, _ := AnalyzeParameters(routes, nil)
+	if plans[0].BodyEncoding != EncodingForm || plans[1].BodyEncoding != EncodingMultipart {
+		t.Fatalf("unexpected encodings: %s %s", plans[0].BodyEncoding, plans[1].BodyEncoding)
+	}
+	if _, _, _, ok := FileUpload(plans[1].Valid[0].Body["avatar"]); !ok {
+		t.Fatalf("expected synthesized file upload, got %+v", plans[1].Valid[0].Body)
+	}
+}
+
+func TestAnalyzeParametersAIFallbackIsValidatedAndTagged(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "routes.js")
+	writeFile(t, file, `app.post("/tenants/:tenantId/invites", async (req, res) => {
+  const payload = await readJSON(req);
+  res.json(await invite(req.params.tenantId, payload));
+});
+
+app.post("/auth/login", (req, res) => {
+  const body = loginSchema.parse(req.body);
+});
+
+const loginSchema = z.object({ email: z.string().email() });
+`)
+	routes, err := discovery.Discover(dir)
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	fallback := &fakeParamFallback{params: []Param{
+		{Name: "tenantId", In: "path", Type: "int", Example: "42"},
+		{Name: "email", In: "body", Type: "string", Format: "email", Required: true, Example: "invitee@example.com"},
+		{Name: "role", In: "body", Type: "string", Enum: []string{"admin", "member"}, Example: "owner"},
+		{Name: "seats", In: "body", Type: "integer", Min: floatPtr(10), Max: floatPtr(1)},
+		{Name: "org", In: "path", Type: "string"},
+		{Name: "token", In: "cookie", Type: "string"},
+	}}
+	plans, warnings := AnalyzeParameters(routes, fallback)
+	if len(fallback.calls) != 1 || !strings.Contains(fallback.calls[0], "readJSON") {
+		t.Fatalf("expected one fallback call with the invites handler source, got %q", fallback.calls)
+	}
+
+	invites := paramsByName(plans[0].Params)
+	if p := invites["tenantId"]; p.Type != TypeInteger || p.Source != SourceAI || !p.Required {
+		t.Fatalf("unexpected tenantId param: %+v", p)
+	}
+	if p := invites["seats"]; p.Min != nil || p.Max != nil {
+		t.Fatalf("expected inverted bounds to be dropped: %+v", p)
+	}
+	if _, ok := invites["org"]; ok {
+		t.Fatalf("path param outside the template should be rejected")
+	}
+	if _, ok := invites["token"]; ok {
+		t.Fatalf("param with unknown location should be rejected")
+	}
+	valid := plans[0].Valid[0]
+	if valid.Path["tenantId"] != "42" || valid.Body["email"] != "invitee@example.com" || valid.Body["role"] != "admin" {
+		t.Fatalf("unexpected valid set: %+v", valid)
+	}
+	if !containsString(valid.AISourced, "body.email") || !containsString(valid.AISourced, "path.tenantId") {
+		t.Fatalf("expected AI-sourced tags, got %v", valid.AISourced)
+	}
+	if len(warnings) != 4 {
+		t.Fatalf("expected 4 warnings (example, bounds, path, location), got %v", warnings)
+	}
+
+	login := paramsByName(plans[1].Params)
+	if login["email"].Source != SourceZod || len(plans[1].Valid[0].AISourced) != 0 {
+		t.Fatalf("schema-backed route should not use the fallback: %+v", plans[1])
+	}
+
+	plan := plans[0]
+	if err := plan.SetValue(InBody, "email", "real@tenant.test"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if containsString(plan.Valid[0].AISourced, "body.email") {
+		t.Fatalf("edited value should no longer be tagged AI-sourced: %v", plan.Valid[0].AISourced)
+	}
+}
+
+type fakeParamFallback struct {
+	params []Param
+	calls  []string
+}
+
+func (f *fakeParamFallback) InferParameters(_ discovery.Route, source string) ([]Param, error) {
+	f.calls = append(f.calls, source)
+	return f.params, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func paramsByName(params []Param) map[string]Param {
+	out := map[string]Param{}
+	for _, p := range params {
+		out[p.Name] = p
+	}
+	return out
+}
+
+func invalidNames(plan ParameterPlan) map[string]int {
+	out := map[string]int{}
+	for _, set := range plan.Invalid {
+		out[set.Name]++
+	}
+	return out
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
@@ -0,0 +1,434 @@
+package profile
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"cool-code-cleanup/internal/discovery"
+)
+
+// goPackage holds the parsed non-test files of the package declaring a route.
+type goPackage struct {
+	fset    *token.FileSet
+	files   map[string]*ast.File
+	structs map[string]*ast.StructType
+	funcs   map[string]*ast.FuncDecl
+}
+
+// goParams resolves the route's handler and reads its inputs from decoded
+// struct types (json/query/header/uri tags plus validate/binding rules) and
+// from direct request accessors such as r.URL.Query().Get.
+func goParams(r discovery.Route) ([]Param, string) {
+	pkg := loadGoPackage(filepath.Dir(r.File))
+	file := pkg.files[filepath.Clean(r.File)]
+	if file == nil {
+		return nil, ""
+	}
+	body := pkg.handlerBody(file, r)
+	if body == nil {
+		return nil, ""
+	}
+	var out []Param
+	var encoding string
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if in, ok := goDecodeLocation(sel.Sel.Name); ok && len(call.Args) > 0 {
+			if st := pkg.decodedStruct(body, call.Args[len(call.Args)-1]); st != nil {
+				out = mergeParams(out, pkg.structParams(st, in, map[*ast.StructType]bool{}))
+			}
+			return true
+		}
+		if p, ok := goAccessorParam(sel, call.Args); ok {
+			out = mergeParams(out, []Param{p})
+			if sel.Sel.Name == "PostFormValue" {
+				encoding = EncodingForm
+			}
+		}
+		return true
+	})
+	return out, encoding
+}
+
+func loadGoPackage(dir string) goPackage {
+	pkg := goPackage{
+		fset:    token.NewFileSet(),
+		files:   map[string]*ast.File{},
+		structs: map[string]*ast.StructType{},
+		funcs:   map[string]*ast.FuncDecl{},
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return pkg
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		f, err := parser.ParseFile(pkg.fset, path, nil, 0)
+		if err != nil {
+			continue
+		}
+		pkg.files[filepath.Clean(path)] = f
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Body != nil {
+					pkg.funcs[d.Name.Name] = d
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						if st, ok := ts.Type.(*ast.StructType); ok {
+							pkg.structs[ts.Name.Name] = st
+						}
+					}
+				}
+			}
+		}
+	}
+	return pkg
+}
+
+// handlerBody finds the Handle/HandleFunc call registering r.Path (preferring
+// the one on r.Line) and resolves its handler to a function body.
+func (pkg goPackage) handlerBody(file *ast.File, r discovery.Route) *ast.BlockStmt {
+	var matches []*ast.CallExpr
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) < 2 {
+			return true
+		}
+		var name string
+		switch fn := call.Fun.(type) {
+		case *ast.Ident:
+			name = fn.Name
+		case *ast.SelectorExpr:
+			name = fn.Sel.Name
+		}
+		if name != "Handle" && name != "HandleFunc" {
+			return true
+		}
+		if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if path, err := strconv.Unquote(lit.Value); err == nil && path == r.Path {
+				matches = append(matches, call)
+			}
+		}
+		return true
+	})
+	if len(matches) == 0 {
+		return nil
+	}
+	call := matches[0]
+	for _, m := range matches {
+		if pkg.fset.Position(m.Pos()).Line == r.Line {
+			call = m
+		}
+	}
+	return pkg.funcBody(call.Args[1])
+}
+
+func (pkg goPackage) funcBody(expr ast.Expr) *ast.BlockStmt {
+	switch h := expr.(type) {
+	case *ast.FuncLit:
+		return h.Body
+	case *ast.Ident:
+		if fd := pkg.funcs[h.Name]; fd != nil && fd.Recv == nil {
+			return fd.Body
+		}
+	case *ast.SelectorExpr:
+		if fd := pkg.funcs[h.Sel.Name]; fd != nil {
+			return fd.Body
+		}
+	case *ast.CallExpr:
+		if len(h.Args) == 1 {
+			return pkg.funcBody(h.Args[0])
+		}
+	}
+	return nil
+}
+
+// goDecodeLocation reports where a decode/bind call reads from.
+func goDecodeLocation(method string) (string, bool) {
+	switch method {
+	case "Decode", "Unmarshal", "ShouldBindJSON", "BindJSON", "Bind", "ShouldBind":
+		return InBody, true
+	case "ShouldBindQuery", "BindQuery":
+		return InQuery, true
+	case "ShouldBindHeader", "BindHeader":
+		return InHeader, true
+	case "ShouldBindUri", "BindUri":
+		return InPath, true
+	}
+	return "", false
+}
+
+// decodedStruct resolves the decode target &x (or &T{}) to its struct type by
+// following x's declaration in body.
+func (pkg goPackage) decodedStruct(body *ast.BlockStmt, arg ast.Expr) *ast.StructType {
+	u, ok := arg.(*ast.UnaryExpr)
+	if !ok || u.Op != token.AND {
+		return nil
+	}
+	switch x := u.X.(type) {
+	case *ast.CompositeLit:
+		return pkg.structOf(x.Type)
+	case *ast.Ident:
+		var typ ast.Expr
+		ast.Inspect(body, func(n ast.Node) bool {
+			switch s := n.(type) {
+			case *ast.ValueSpec:
+				for i, name := range s.Names {
+					if name.Name != x.Name {
+						continue
+					}
+					if s.Type != nil {
+						typ = s.Type
+					} else if i < len(s.Values) {
+						typ = compositeType(s.Values[i])
+					}
+				}
+			case *ast.AssignStmt:
+				for i, lhs := range s.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok && id.Name == x.Name && i < len(s.Rhs) {
+						if t := compositeType(s.Rhs[i]); t != nil {
+							typ = t
+						}
+					}
+				}
+			}
+			return typ == nil
+		})
+		return pkg.structOf(typ)
+	}
+	return nil
+}
+
+func compositeType(e ast.Expr) ast.Expr {
+	switch v := e.(type) {
+	case *ast.CompositeLit:
+		return v.Type
+	case *ast.UnaryExpr:
+		return compositeType(v.X)
+	case *ast.CallExpr:
+		if id, ok := v.Fun.(*ast.Ident); ok && id.Name == "new" && len(v.Args) == 1 {
+			return v.Args[0]
+		}
+	}
+	return nil
+}
+
+func (pkg goPackage) structOf(typ ast.Expr) *ast.StructType {
+	switch t := typ.(type) {
+	case *ast.StructType:
+		return t
+	case *ast.StarExpr:
+		return pkg.structOf(t.X)
+	case *ast.Ident:
+		return pkg.structs[t.Name]
+	}
+	return nil
+}
+
+func (pkg goPackage) structParams(st *ast.StructType, in string, seen map[*ast.StructType]bool) []Param {
+	if seen[st] {
+		return nil
+	}
+	seen[st] = true
+	var out []Param
+	for _, field := range st.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			if raw, err := strconv.Unquote(field.Tag.Value); err == nil {
+				tag = reflect.StructTag(raw)
+			}
+		}
+		if len(field.Names) == 0 {
+			if embedded := pkg.structOf(field.Type); embedded != nil {
+				out = append(out, pkg.structParams(embedded, in, seen)...)
+			}
+			continue
+		}
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			p, ok := goFieldParam(name.Name, field.Type, tag, in, pkg)
+			if ok {
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
+
+func goFieldParam(fieldName string, typ ast.Expr, tag reflect.StructTag, in string, pkg goPackage) (Param, bool) {
+	p := Param{Name: fieldName, In: in, Source: SourceGoStructTag}
+	for _, loc := range []struct{ key, in string }{
+		{"uri", InPath}, {"path", InPath}, {"header", InHeader}, {"query", InQuery}, {"schema", InQuery}, {"form", in}, {"json", in},
+	} {
+		v, ok := tag.Lookup(loc.key)
+		if !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(v, ",")
+		if name == "-" {
+			return Param{}, false
+		}
+		if name != "" {
+			p.Name = name
+		}
+		p.In = loc.in
+		break
+	}
+	if p.In == InPath {
+		p.Required = true
+	}
+	p.Type, p.Format = goTypeOf(typ, pkg)
+	rules := tag.Get("validate")
+	if b := tag.Get("binding"); b != "" {
+		rules += "," + b
+	}
+	applyGoValidateRules(&p, rules)
+	return p, true
+}
+
+func goTypeOf(typ ast.Expr, pkg goPackage) (string, string) {
+	switch t := typ.(type) {
+	case *ast.StarExpr:
+		return goTypeOf(t.X, pkg)
+	case *ast.Ident:
+		switch t.Name {
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+			return TypeInteger, ""
+		case "float32", "float64":
+			return TypeNumber, ""
+		case "bool":
+			return TypeBoolean, ""
+		case "string":
+			return TypeString, ""
+		}
+		if pkg.structs[t.Name] != nil {
+			return TypeObject, ""
+		}
+	case *ast.SelectorExpr:
+		switch t.Sel.Name {
+		case "Time":
+			return TypeString, "date-time"
+		case "UUID":
+			return TypeString, "uuid"
+		case "FileHeader":
+			return TypeFile, ""
+		}
+		return TypeObject, ""
+	case *ast.ArrayType:
+		if id, ok := t.Elt.(*ast.Ident); ok && id.Name == "byte" {
+			return TypeString, ""
+		}
+		return TypeArray, ""
+	case *ast.MapType, *ast.StructType:
+		return TypeObject, ""
+	}
+	return TypeString, ""
+}
+
+// applyGoValidateRules reads go-playground validator / gin binding rules.
+func applyGoValidateRules(p *Param, rules string) {
+	for _, rule := range strings.Split(rules, ",") {
+		key, val, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		n, numErr := strconv.ParseFloat(val, 64)
+		switch key {
+		case "required":
+			p.Required = true
+		case "email", "url", "uuid", "uuid4":
+			p.Type, p.Format = TypeString, strings.TrimSuffix(key, "4")
+		case "oneof":
+			p.Enum = strings.Fields(val)
+		case "min", "gte", "gt":
+			if numErr != nil {
+				continue
+			}
+			if key == "gt" {
+				n++
+			}
+			if p.Type == TypeString {
+				p.MinLength = intPtr(int(n))
+			} else {
+				p.Min = floatPtr(n)
+			}
+		case "max", "lte", "lt":
+			if numErr != nil {
+				continue
+			}
+			if key == "lt" {
+				n--
+			}
+			if p.Type == TypeString {
+				p.MaxLength = intPtr(int(n))
+			} else {
+				p.Max = floatPtr(n)
+			}
+		case "len":
+			if numErr == nil && p.Type == TypeString {
+				p.MinLength, p.MaxLength = intPtr(int(n)), intPtr(int(n))
+			}
+		}
+	}
+}
+
+// goAccessorParam recognizes r.URL.Query().Get("q"), r.Header.Get("X"),
+// r.FormValue("f"), r.PostFormValue("f"), r.FormFile("f") and
+// r.PathValue("id").
+func goAccessorParam(sel *ast.SelectorExpr, args []ast.Expr) (Param, bool) {
+	if len(args) != 1 {
+		return Param{}, false
+	}
+	lit, ok := args[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return Param{}, false
+	}
+	name, err := strconv.Unquote(lit.Value)
+	if err != nil || name == "" {
+		return Param{}, false
+	}
+	p := Param{Name: name, Type: TypeString, Source: SourceGoAccessor}
+	switch sel.Sel.Name {
+	case "Get":
+		switch recv := sel.X.(type) {
+		case *ast.CallExpr:
+			if s, ok := recv.Fun.(*ast.SelectorExpr); ok && s.Sel.Name == "Query" {
+				p.In = InQuery
+			}
+		case *ast.SelectorExpr:
+			if recv.Sel.Name == "Header" {
+				p.In = InHeader
+			}
+		}
+	case "FormValue":
+		p.In = InQuery
+	case "PostFormValue":
+		p.In = InBody
+	case "FormFile":
+		p.In, p.Type = InBody, TypeFile
+	case "PathValue":
+		p.In, p.Required = InPath, true
+	}
+	if p.In == "" {
+		return Param{}, false
+	}
+	return p, true
+}
@@ -0,0 +1,261 @@
+package profile
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cool-code-cleanup/internal/discovery"
+)
+
+var (
+	reJSSchemaDecl   = regexp.MustCompile(`(?:const|let|var)\s+(\w+)\s*=\s*(z|Joi)\.object\s*\(`)
+	reJSRouteCall    = regexp.MustCompile(`\b(?:app|router)\.(?:get|post|put|patch|delete)\s*\(`)
+	reJSAccessor     = regexp.MustCompile(`req\.(query|body|headers|params)(?:\.(\w+)|\[\s*['"]([^'"]+)['"]\s*\])`)
+	reJSHeaderGetter = regexp.MustCompile(`req\.(?:get|header)\(\s*['"]([^'"]+)['"]`)
+	reJSDestructure  = regexp.MustCompile(`(?:const|let|var)\s*\{([^}]*)\}\s*=\s*req\.(query|body|headers|params)\b`)
+	reJSMethodCall   = regexp.MustCompile(`\.(\w+)\(([^()]*)\)`)
+	reJSKey          = regexp.MustCompile(`^\s*['"]?([\w-]+)['"]?\s*:\s*`)
+	reJSString       = regexp.MustCompile(`['"]([^'"]*)['"]`)
+	reJSMulter       = regexp.MustCompile(`\.(single|array)\(\s*['"]([^'"]+)['"]|\bname\s*:\s*['"]([^'"]+)['"]`)
+)
+
+// nodeParams reads the Express handler region (the route call up to the next
+// route registration) for zod/joi schemas it references, multer upload
+// fields, and direct req.query/body/headers/params accessors. Files that only
+// install express.urlencoded get a form encoding hint.
+func nodeParams(r discovery.Route) ([]Param, string) {
+	data, err := os.ReadFile(r.File)
+	if err != nil {
+		return nil, ""
+	}
+	content := string(data)
+	region := jsRouteRegion(content, r.Line)
+	if region == "" {
+		return nil, ""
+	}
+	var encoding string
+	if strings.Contains(content, "express.urlencoded") && !strings.Contains(content, "express.json") {
+		encoding = EncodingForm
+	}
+	schemas := parseJSSchemas(content)
+	var out []Param
+	for name, params := range schemas {
+		re := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`)
+		for _, loc := range re.FindAllStringIndex(region, -1) {
+			line := region[lineStart(region, loc[0]):]
+			if i := strings.IndexByte(line, '\n'); i >= 0 {
+				line = line[:i]
+			}
+			in := InBody
+			switch {
+			case strings.Contains(line, "req.query") || strings.Contains(line, `"query"`) || strings.Contains(line, `'query'`):
+				in = InQuery
+			case strings.Contains(line, "req.headers") || strings.Contains(line, `"headers"`) || strings.Contains(line, `'headers'`):
+				in = InHeader
+			case strings.Contains(line, "req.params") || strings.Contains(line, `"params"`) || strings.Contains(line, `'params'`):
+				in = InPath
+			}
+			out = mergeParams(out, withLocation(params, in))
+		}
+	}
+	for _, m := range reJSAccessor.FindAllStringSubmatch(region, -1) {
+		name := m[2]
+		if name == "" {
+			name = m[3]
+		}
+		out = mergeParams(out, []Param{jsAccessorParam(m[1], name)})
+	}
+	for _, m := range reJSHeaderGetter.FindAllStringSubmatch(region, -1) {
+		out = mergeParams(out, []Param{jsAccessorParam("headers", m[1])})
+	}
+	for _, m := range reJSDestructure.FindAllStringSubmatch(region, -1) {
+		for _, field := range strings.Split(m[1], ",") {
+			name := strings.TrimSpace(strings.SplitN(strings.SplitN(field, ":", 2)[0], "=", 2)[0])
+			if name != "" && !strings.HasPrefix(name, "...") {
+				out = mergeParams(out, []Param{jsAccessorParam(m[2], name)})
+			}
+		}
+	}
+	if routeLine := strings.SplitN(region, "\n", 2)[0]; strings.Contains(routeLine, "upload.") || strings.Contains(routeLine, "multer") {
+		for _, m := range reJSMulter.FindAllStringSubmatch(routeLine, -1) {
+			name := m[2]
+			if name == "" {
+				name = m[3]
+			}
+			out = mergeParams(out, []Param{{Name: name, In: InBody, Type: TypeFile, Required: true, Source: SourceExpressAccessor}})
+		}
+	}
+	return out, encoding
+}
+
+func jsAccessorParam(location, name string) Param {
+	p := Param{Name: name, Type: TypeString, Source: SourceExpressAccessor}
+	switch location {
+	case "query":
+		p.In = InQuery
+	case "headers":
+		p.In = InHeader
+	case "params":
+		p.In, p.Required = InPath, true
+	default:
+		p.In = InBody
+	}
+	return p
+}
+
+// jsRouteRegion returns the source from the route registration on line up to
+// the next route registration.
+func jsRouteRegion(content string, line int) string {
+	start := 0
+	for i := 1; i < line; i++ {
+		next := strings.IndexByte(content[start:], '\n')
+		if next < 0 {
+			return ""
+		}
+		start += next + 1
+	}
+	rest := content[start:]
+	if loc := reJSRouteCall.FindStringIndex(rest); loc != nil {
+		if next := reJSRouteCall.FindStringIndex(rest[loc[1]:]); next != nil {
+			return rest[:loc[1]+next[0]]
+		}
+	}
+	return rest
+}
+
+// parseJSSchemas collects top-level z.object / Joi.object declarations.
+func parseJSSchemas(content string) map[string][]Param {
+	out := map[string][]Param{}
+	for _, m := range reJSSchemaDecl.FindAllStringSubmatchIndex(content, -1) {
+		name := content[m[2]:m[3]]
+		source := SourceZod
+		if content[m[4]:m[5]] == "Joi" {
+			source = SourceJoi
+		}
+		open := strings.IndexByte(content[m[1]:], '{')
+		if open < 0 {
+			continue
+		}
+		open += m[1]
+		closing := matchBrace(content, open)
+		if closing < 0 {
+			continue
+		}
+		var params []Param
+		for _, entry := range splitTopLevel(content[open+1:closing], ',') {
+			if p, ok := jsSchemaField(strings.TrimSpace(entry), source); ok {
+				params = append(params, p)
+			}
+		}
+		out[name] = params
+	}
+	return out
+}
+
+func matchBrace(content string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(content); i++ {
+		c := content[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '{', '(', '[':
+			depth++
+		case '}', ')', ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// jsSchemaField reads one "key: z.string().email()" or
+// "key: Joi.number().min(1).required()" entry. zod fields are required unless
+// marked optional; joi fields are optional unless marked required.
+func jsSchemaField(entry, source string) (Param, bool) {
+	km := reJSKey.FindStringSubmatch(entry)
+	if km == nil {
+		return Param{}, false
+	}
+	chain := entry[len(km[0]):]
+	p := Param{Name: km[1], In: InBody, Type: TypeString, Required: source == SourceZod, Source: source}
+	for i, call := range reJSMethodCall.FindAllStringSubmatch(chain, -1) {
+		method, args := call[1], call[2]
+		n, numErr := strconv.ParseFloat(strings.TrimSpace(args), 64)
+		if i == 0 || method == "object" || method == "array" {
+			switch method {
+			case "string":
+				p.Type = TypeString
+			case "number":
+				p.Type = TypeNumber
+			case "boolean", "bool":
+				p.Type = TypeBoolean
+			case "date":
+				p.Type, p.Format = TypeString, "date-time"
+			case "array":
+				p.Type = TypeArray
+			case "object":
+				p.Type = TypeObject
+			case "enum":
+				for _, s := range reJSString.FindAllStringSubmatch(args, -1) {
+					p.Enum = append(p.Enum, s[1])
+				}
+			}
+			if i == 0 {
+				continue
+			}
+		}
+		switch method {
+		case "int", "integer":
+			p.Type = TypeInteger
+		case "email", "uuid", "url", "uri", "guid":
+			p.Format = map[string]string{"email": "email", "uuid": "uuid", "guid": "uuid", "url": "url", "uri": "url"}[method]
+		case "positive":
+			p.Min = floatPtr(1)
+		case "nonnegative":
+			p.Min = floatPtr(0)
+		case "min", "gte":
+			if numErr == nil {
+				if p.Type == TypeString {
+					p.MinLength = intPtr(int(n))
+				} else {
+					p.Min = floatPtr(n)
+				}
+			}
+		case "max", "lte":
+			if numErr == nil {
+				if p.Type == TypeString {
+					p.MaxLength = intPtr(int(n))
+				} else {
+					p.Max = floatPtr(n)
+				}
+			}
+		case "length":
+			if numErr == nil && p.Type == TypeString {
+				p.MinLength, p.MaxLength = intPtr(int(n)), intPtr(int(n))
+			}
+		case "valid":
+			for _, s := range reJSString.FindAllStringSubmatch(args, -1) {
+				p.Enum = append(p.Enum, s[1])
+			}
+		case "optional", "nullish", "default":
+			p.Required = false
+		case "required":
+			p.Required = true
+		}
+	}
+	return p, true
+}
@@ -0,0 +1,118 @@
+package profile
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"cool-code-cleanup/internal/discovery"
+)
+
+var (
+	reExpressParam = regexp.MustCompile(`:([A-Za-z_][A-Za-z0-9_]*)(\([^)]*\))?(\?)?`)
+	reGoParam      = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)(\.\.\.)?(?::([^}]*))?\}`)
+	reDjangoParam  = regexp.MustCompile(`<(?:([a-z]+):)?([A-Za-z_][A-Za-z0-9_]*)>`)
+)
+
+// pathParams extracts templated segments from the route path: Express
+// ":id", Go "{id}" (and "{id:[0-9]+}"), and Django "<int:pk>" converters.
+func pathParams(r discovery.Route) []Param {
+	_, path := RoutePath(r.Path)
+	var out []Param
+	switch r.Framework {
+	case "node":
+		for _, m := range reExpressParam.FindAllStringSubmatch(path, -1) {
+			p := untypedPathParam(m[1])
+			p.Required = m[3] == ""
+			if strings.Contains(m[2], `\d`) {
+				p.Type, p.Format, p.Source = TypeInteger, "", SourcePathConverter
+			}
+			out = append(out, p)
+		}
+	case "go":
+		for _, m := range reGoParam.FindAllStringSubmatch(path, -1) {
+			p := untypedPathParam(m[1])
+			if strings.Contains(m[3], "0-9") || strings.Contains(m[3], `\d`) {
+				p.Type, p.Format, p.Source = TypeInteger, "", SourcePathConverter
+			}
+			out = append(out, p)
+		}
+	case "django":
+		for _, m := range reDjangoParam.FindAllStringSubmatch(path, -1) {
+			p := untypedPathParam(m[2])
+			if m[1] != "" {
+				p.Source = SourcePathConverter
+				p.Type, p.Format = TypeString, ""
+				switch m[1] {
+				case "int":
+					p.Type = TypeInteger
+					p.Min = floatPtr(0)
+				case "slug", "uuid":
+					p.Format = m[1]
+				}
+			}
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func untypedPathParam(name string) Param {
+	p := Param{Name: name, In: InPath, Type: TypeString, Required: true, Source: SourcePathTemplate}
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "uuid"):
+		p.Format = "uuid"
+	case lower == "id" || lower == "pk" || strings.HasSuffix(lower, "_id") || strings.HasSuffix(name, "Id") || strings.HasSuffix(name, "ID"):
+		p.Type = TypeInteger
+	case strings.Contains(lower, "slug"):
+		p.Format = "slug"
+	}
+	return p
+}
+
+// RoutePath strips a Go 1.22 method prefix ("GET /items/{id}") from a route
+// path and returns the method it names, if any.
+func RoutePath(path string) (method, clean string) {
+	if i := strings.IndexByte(path, ' '); i > 0 && !strings.HasPrefix(path, "/") {
+		return strings.ToUpper(path[:i]), strings.TrimSpace(path[i+1:])
+	}
+	return "", path
+}
+
+// SubstitutePath replaces Django, Go and Express path placeholders (in that
+// order, so "<int:pk>" is not mistaken for an Express ":pk") with escaped
+// values. Placeholders without a value are left as they are, except optional
+// Express segments, which are dropped.
+func SubstitutePath(path string, values map[string]string) string {
+	path = reDjangoParam.ReplaceAllStringFunc(path, func(seg string) string {
+		m := reDjangoParam.FindStringSubmatch(seg)
+		if v, ok := values[m[2]]; ok {
+			if m[1] == "path" {
+				return strings.ReplaceAll(url.PathEscape(v), "%2F", "/")
+			}
+			return url.PathEscape(v)
+		}
+		return seg
+	})
+	path = reGoParam.ReplaceAllStringFunc(path, func(seg string) string {
+		m := reGoParam.FindStringSubmatch(seg)
+		if v, ok := values[m[1]]; ok {
+			if m[2] != "" {
+				return strings.ReplaceAll(url.PathEscape(v), "%2F", "/")
+			}
+			return url.PathEscape(v)
+		}
+		return seg
+	})
+	return reExpressParam.ReplaceAllStringFunc(path, func(seg string) string {
+		m := reExpressParam.FindStringSubmatch(seg)
+		if v, ok := values[m[1]]; ok {
+			return url.PathEscape(v)
+		}
+		if m[3] != "" {
+			return ""
+		}
+		return seg
+	})
+}
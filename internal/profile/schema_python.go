@@ -0,0 +1,489 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cool-code-cleanup/internal/discovery"
+)
+
+var (
+	rePyClass     = regexp.MustCompile(`^(\s*)class\s+(\w+)\s*\(([^)]*)\)\s*:`)
+	rePyDef       = regexp.MustCompile(`^(\s*)(?:async\s+)?def\s+(\w+)\s*\(`)
+	rePyAnnotated = regexp.MustCompile(`^(\w+)\s*:\s*(.+)$`)
+	rePyDRFField  = regexp.MustCompile(`^(\w+)\s*=\s*(?:serializers\.)?(\w+Field)\s*\((.*)\)\s*$`)
+	rePyKwarg     = regexp.MustCompile(`(\w+)\s*=\s*([^,()\[\]]+|\[[^\]]*\]|\([^)]*\))`)
+	rePyString    = regexp.MustCompile(`['"]([^'"]*)['"]`)
+	rePyAccessor  = regexp.MustCompile(`request\.(GET|POST|FILES|data|query_params|headers|META)(?:\.get\(\s*|\[\s*)['"]([^'"]+)['"]`)
+)
+
+// pyModel is a pydantic model/ninja Schema or a DRF serializer.
+type pyModel struct {
+	source string
+	params []Param
+}
+
+// djangoParams resolves the view bound in urls.py and reads inputs from
+// pydantic/ninja schemas and DRF serializers it references, from ninja-style
+// typed signatures, and from request.GET/POST/FILES/data/headers accessors.
+// Views reading request.POST get a form encoding hint.
+func djangoParams(r discovery.Route) ([]Param, string) {
+	dir := filepath.Dir(r.File)
+	files := readPythonFiles(dir)
+	models := map[string]pyModel{}
+	for _, lines := range files {
+		for name, m := range parsePyModels(lines) {
+			models[name] = m
+		}
+	}
+	handler := djangoHandlerName(r.Handler)
+	var block []string
+	for _, lines := range files {
+		if block = pyBlockOf(lines, handler); block != nil {
+			break
+		}
+	}
+	if block == nil {
+		return nil, ""
+	}
+	pathNames := map[string]bool{}
+	for _, p := range pathParams(r) {
+		pathNames[p.Name] = true
+	}
+	out := ninjaSignatureParams(block[0], models, pathNames)
+	text := strings.Join(block, "\n")
+	for name, m := range models {
+		at := indexWord(text, name)
+		if at < 0 || strings.Contains(block[0], name) {
+			continue
+		}
+		line := text[lineStart(text, at):]
+		if i := strings.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		in := InBody
+		if strings.Contains(line, "query_params") || strings.Contains(line, "request.GET") {
+			in = InQuery
+		}
+		out = mergeParams(out, withLocation(m.params, in))
+	}
+	var encoding string
+	for _, m := range rePyAccessor.FindAllStringSubmatch(text, -1) {
+		p := Param{Name: m[2], Type: TypeString, Source: SourceDjangoAccessor}
+		switch m[1] {
+		case "GET", "query_params":
+			p.In = InQuery
+		case "POST":
+			p.In = InBody
+			encoding = EncodingForm
+		case "FILES":
+			p.In, p.Type = InBody, TypeFile
+		case "data":
+			p.In = InBody
+		case "headers":
+			p.In = InHeader
+		case "META":
+			if !strings.HasPrefix(m[2], "HTTP_") {
+				continue
+			}
+			p.In, p.Name = InHeader, metaHeaderName(m[2])
+		}
+		out = mergeParams(out, []Param{p})
+	}
+	return out, encoding
+}
+
+// indexWord returns the index of the first occurrence of word in s that is
+// not part of a longer identifier, or -1.
+func indexWord(s, word string) int {
+	for from := 0; ; {
+		i := strings.Index(s[from:], word)
+		if i < 0 {
+			return -1
+		}
+		i += from
+		end := i + len(word)
+		if (i == 0 || !isWordByte(s[i-1])) && (end == len(s) || !isWordByte(s[end])) {
+			return i
+		}
+		from = i + 1
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func readPythonFiles(dir string) map[string][]string {
+	out := map[string][]string{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return out
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".py" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		out[e.Name()] = strings.Split(string(data), "\n")
+	}
+	return out
+}
+
+// djangoHandlerName reduces a urls.py binding such as views.LoginView.as_view
+// to the def or class name.
+func djangoHandlerName(binding string) string {
+	parts := strings.Split(binding, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i] != "as_view" && parts[i] != "" {
+			return parts[i]
+		}
+	}
+	return binding
+}
+
+// pyBlockOf returns the header line and indented body of the def or class
+// called name, with the header's continuation lines joined.
+func pyBlockOf(lines []string, name string) []string {
+	for i, line := range lines {
+		var indent string
+		if m := rePyDef.FindStringSubmatch(line); m != nil && m[2] == name {
+			indent = m[1]
+		} else if m := rePyClass.FindStringSubmatch(line); m != nil && m[2] == name {
+			indent = m[1]
+		} else {
+			continue
+		}
+		header := line
+		j := i + 1
+		for strings.Count(header, "(") > strings.Count(header, ")") && j < len(lines) {
+			header += " " + strings.TrimSpace(lines[j])
+			j++
+		}
+		block := []string{header}
+		for ; j < len(lines); j++ {
+			l := lines[j]
+			if strings.TrimSpace(l) != "" && !strings.HasPrefix(l, indent+" ") && !strings.HasPrefix(l, indent+"\t") {
+				break
+			}
+			block = append(block, l)
+		}
+		return block
+	}
+	return nil
+}
+
+func parsePyModels(lines []string) map[string]pyModel {
+	out := map[string]pyModel{}
+	for i, line := range lines {
+		m := rePyClass.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		var source string
+		switch bases := m[3]; {
+		case strings.Contains(bases, "Serializer"):
+			source = SourceDRF
+		case strings.Contains(bases, "BaseModel") || strings.Contains(bases, "Schema"):
+			source = SourcePydantic
+		default:
+			continue
+		}
+		model := pyModel{source: source}
+		bodyIndent := ""
+		for _, l := range lines[i+1:] {
+			trimmed := strings.TrimSpace(l)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			indent := l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+			if len(indent) <= len(m[1]) {
+				break
+			}
+			if bodyIndent == "" {
+				bodyIndent = indent
+			}
+			if indent != bodyIndent {
+				continue
+			}
+			var p Param
+			var ok bool
+			if source == SourceDRF {
+				p, ok = drfField(trimmed)
+			} else {
+				p, ok = pydanticField(trimmed)
+			}
+			if ok {
+				model.params = append(model.params, p)
+			}
+		}
+		out[m[2]] = model
+	}
+	return out
+}
+
+func pydanticField(line string) (Param, bool) {
+	if strings.HasPrefix(line, "model_config") || strings.HasPrefix(line, "class ") || strings.HasPrefix(line, "def ") || strings.HasPrefix(line, "@") {
+		return Param{}, false
+	}
+	m := rePyAnnotated.FindStringSubmatch(line)
+	if m == nil {
+		return Param{}, false
+	}
+	annotation, def := splitPyDefault(m[2])
+	p := Param{Name: m[1], In: InBody, Source: SourcePydantic}
+	applyPyAnnotation(&p, annotation)
+	p.Required = def == ""
+	if strings.HasPrefix(def, "Field(") {
+		args := strings.TrimSuffix(strings.TrimPrefix(def, "Field("), ")")
+		first := strings.TrimSpace(strings.SplitN(args, ",", 2)[0])
+		p.Required = first == "..." || (strings.Contains(first, "=") && !strings.Contains(args, "default"))
+		applyPyKwargs(&p, args)
+	}
+	return p, true
+}
+
+// splitPyDefault splits "int = Field(1)" at the first top-level "=".
+func splitPyDefault(s string) (string, string) {
+	depth := 0
+	for i, c := range s {
+		switch c {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '=':
+			if depth == 0 && (i+1 >= len(s) || s[i+1] != '=') {
+				return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+			}
+		}
+	}
+	return strings.TrimSpace(s), ""
+}
+
+func applyPyAnnotation(p *Param, t string) {
+	t = strings.TrimSpace(t)
+	if inner, ok := pyGeneric(t, "Optional"); ok {
+		t = inner
+	}
+	if inner, ok := pyGeneric(t, "Annotated"); ok {
+		parts := strings.SplitN(inner, ",", 2)
+		t = strings.TrimSpace(parts[0])
+		if len(parts) == 2 {
+			applyPyKwargs(p, parts[1])
+		}
+	}
+	t = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(t, "| None"), " "))
+	if inner, ok := pyGeneric(t, "Literal"); ok {
+		p.Type = TypeString
+		for _, m := range rePyString.FindAllStringSubmatch(inner, -1) {
+			p.Enum = append(p.Enum, m[1])
+		}
+		return
+	}
+	base := t
+	if i := strings.IndexAny(base, "[("); i >= 0 {
+		base = base[:i]
+	}
+	switch base {
+	case "int", "conint", "PositiveInt", "NonNegativeInt":
+		p.Type = TypeInteger
+		if base == "PositiveInt" {
+			p.Min = floatPtr(1)
+		} else if base == "NonNegativeInt" {
+			p.Min = floatPtr(0)
+		}
+	case "float", "Decimal", "confloat", "condecimal":
+		p.Type = TypeNumber
+	case "bool", "StrictBool":
+		p.Type = TypeBoolean
+	case "EmailStr":
+		p.Type, p.Format = TypeString, "email"
+	case "UUID", "UUID4", "UUID1":
+		p.Type, p.Format = TypeString, "uuid"
+	case "HttpUrl", "AnyUrl", "AnyHttpUrl":
+		p.Type, p.Format = TypeString, "url"
+	case "datetime":
+		p.Type, p.Format = TypeString, "date-time"
+	case "date":
+		p.Type, p.Format = TypeString, "date"
+	case "list", "List", "set", "Set", "tuple", "Tuple", "conlist":
+		p.Type = TypeArray
+	case "dict", "Dict":
+		p.Type = TypeObject
+	default:
+		p.Type = TypeString
+	}
+	if i := strings.IndexByte(t, '('); i >= 0 && strings.HasPrefix(base, "con") {
+		applyPyKwargs(p, t[i+1:])
+	}
+}
+
+func pyGeneric(t, name string) (string, bool) {
+	prefix := name + "["
+	if !strings.HasPrefix(t, prefix) || !strings.HasSuffix(t, "]") {
+		return "", false
+	}
+	return strings.TrimSpace(t[len(prefix) : len(t)-1]), true
+}
+
+// applyPyKwargs reads pydantic Field/con* and DRF constraint keywords.
+func applyPyKwargs(p *Param, args string) {
+	for _, m := range rePyKwarg.FindAllStringSubmatch(args, -1) {
+		val := strings.TrimSpace(m[2])
+		n, numErr := strconv.ParseFloat(val, 64)
+		switch m[1] {
+		case "ge", "min_value":
+			if numErr == nil {
+				p.Min = floatPtr(n)
+			}
+		case "gt":
+			if numErr == nil {
+				p.Min = floatPtr(n + 1)
+			}
+		case "le", "max_value":
+			if numErr == nil {
+				p.Max = floatPtr(n)
+			}
+		case "lt":
+			if numErr == nil {
+				p.Max = floatPtr(n - 1)
+			}
+		case "min_length":
+			if numErr == nil {
+				p.MinLength = intPtr(int(n))
+			}
+		case "max_length":
+			if numErr == nil {
+				p.MaxLength = intPtr(int(n))
+			}
+		case "choices":
+			p.Enum = nil
+			for _, s := range rePyString.FindAllStringSubmatch(val, -1) {
+				p.Enum = append(p.Enum, s[1])
+			}
+		}
+	}
+}
+
+var drfTypes = map[string][2]string{
+	"CharField":     {TypeString, ""},
+	"SlugField":     {TypeString, "slug"},
+	"EmailField":    {TypeString, "email"},
+	"URLField":      {TypeString, "url"},
+	"UUIDField":     {TypeString, "uuid"},
+	"DateTimeField": {TypeString, "date-time"},
+	"DateField":     {TypeString, "date"},
+	"IntegerField":  {TypeInteger, ""},
+	"FloatField":    {TypeNumber, ""},
+	"DecimalField":  {TypeNumber, ""},
+	"BooleanField":  {TypeBoolean, ""},
+	"ChoiceField":   {TypeString, ""},
+	"ListField":     {TypeArray, ""},
+	"FileField":     {TypeFile, ""},
+	"ImageField":    {TypeFile, ""},
+	"DictField":     {TypeObject, ""},
+	"JSONField":     {TypeObject, ""},
+}
+
+func drfField(line string) (Param, bool) {
+	m := rePyDRFField.FindStringSubmatch(line)
+	if m == nil {
+		return Param{}, false
+	}
+	args := m[3]
+	if strings.Contains(args, "read_only=True") {
+		return Param{}, false
+	}
+	t, ok := drfTypes[m[2]]
+	if !ok {
+		t = [2]string{TypeString, ""}
+	}
+	p := Param{Name: m[1], In: InBody, Type: t[0], Format: t[1], Source: SourceDRF}
+	p.Required = !strings.Contains(args, "required=False") && !strings.Contains(args, "default=")
+	applyPyKwargs(&p, args)
+	return p, true
+}
+
+// ninjaSignatureParams reads typed view arguments after request: schema
+// annotations are the body, scalars are query (or path, when named in the
+// route template).
+func ninjaSignatureParams(header string, models map[string]pyModel, pathNames map[string]bool) []Param {
+	open := strings.IndexByte(header, '(')
+	closing := strings.LastIndexByte(header, ')')
+	if open < 0 || closing <= open {
+		return nil
+	}
+	var out []Param
+	for i, arg := range splitTopLevel(header[open+1:closing], ',') {
+		arg = strings.TrimSpace(arg)
+		if i == 0 || arg == "" || strings.HasPrefix(arg, "*") {
+			continue
+		}
+		m := rePyAnnotated.FindStringSubmatch(arg)
+		if m == nil {
+			continue
+		}
+		annotation, def := splitPyDefault(m[2])
+		if model, ok := models[annotation]; ok {
+			out = mergeParams(out, withLocation(model.params, InBody))
+			continue
+		}
+		p := Param{Name: m[1], In: InQuery, Required: def == "", Source: SourceNinjaSignature}
+		applyPyAnnotation(&p, annotation)
+		if pathNames[p.Name] {
+			p.In, p.Required = InPath, true
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func splitTopLevel(s string, sep rune) []string {
+	var out []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case sep:
+			if depth == 0 {
+				out = append(out, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(out, s[start:])
+}
+
+func withLocation(params []Param, in string) []Param {
+	out := make([]Param, len(params))
+	for i, p := range params {
+		p.In = in
+		out[i] = p
+	}
+	return out
+}
+
+// metaHeaderName turns HTTP_X_API_KEY into X-Api-Key.
+func metaHeaderName(meta string) string {
+	parts := strings.Split(strings.ToLower(strings.TrimPrefix(meta, "HTTP_")), "_")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+func lineStart(content string, offset int) int {
+	return strings.LastIndex(content[:offset], "\n") + 1
+}
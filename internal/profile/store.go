@@ -0,0 +1,224 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"cool-code-cleanup/internal/discovery"
+)
+
+const PlanSchemaVersion = 1
+
+// StoredPlan is the on-disk form of a route's parameter plan. Routes are
+// keyed by framework, method and path rather than route ID, since IDs embed
+// line numbers that shift as code changes.
+type StoredPlan struct {
+	SchemaVersion int           `json:"schema_version"`
+	Framework     string        `json:"framework"`
+	Method        string        `json:"method"`
+	Path          string        `json:"path"`
+	UpdatedAt     string        `json:"updated_at"`
+	Plan          ParameterPlan `json:"plan"`
+}
+
+var rePlanUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+func DefaultPlanDir() string {
+	return filepath.Join(".ccc", "params")
+}
+
+// PlanFileName returns the file name of a route's stored plan, e.g.
+// "node-post-auth-login.json".
+func PlanFileName(r discovery.Route) string {
+	key := strings.ToLower(r.Framework + " " + r.Method + " " + r.Path)
+	return strings.Trim(rePlanUnsafe.ReplaceAllString(key, "-"), "-") + ".json"
+}
+
+// LoadPlans reads stored plans for routes from dir, keyed by route ID. Routes
+// without a stored plan are absent from the result.
+func LoadPlans(dir string, routes []discovery.Route) (map[string]ParameterPlan, error) {
+	out := map[string]ParameterPlan{}
+	for _, r := range routes {
+		path := filepath.Join(dir, PlanFileName(r))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("read parameter plan %s: %w", path, err)
+		}
+		var stored StoredPlan
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return nil, fmt.Errorf("parse parameter plan %s: %w", path, err)
+		}
+		if stored.SchemaVersion != PlanSchemaVersion {
+			return nil, fmt.Errorf("unsupported parameter plan schema_version=%d in %s (expected %d)", stored.SchemaVersion, path, PlanSchemaVersion)
+		}
+		stored.Plan.RouteID = r.ID
+		out[r.ID] = stored.Plan
+	}
+	return out, nil
+}
+
+// SavePlans writes one file per route plan and returns the written paths.
+func SavePlans(dir string, routes []discovery.Route, plans []ParameterPlan) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create parameter plan dir: %w", err)
+	}
+	byID := map[string]discovery.Route{}
+	for _, r := range routes {
+		byID[r.ID] = r
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	var written []string
+	for _, p := range plans {
+		r, ok := byID[p.RouteID]
+		if !ok {
+			continue
+		}
+		stored := StoredPlan{
+			SchemaVersion: PlanSchemaVersion,
+			Framework:     r.Framework,
+			Method:        r.Method,
+			Path:          r.Path,
+			UpdatedAt:     now,
+			Plan:          p,
+		}
+		out, err := json.MarshalIndent(stored, "", "  ")
+		if err != nil {
+			return written, fmt.Errorf("encode parameter plan for %s: %w", r.Path, err)
+		}
+		path := filepath.Join(dir, PlanFileName(r))
+		if err := os.WriteFile(path, append(out, '\n'), 0o644); err != nil {
+			return written, fmt.Errorf("write parameter plan %s: %w", path, err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// MergePlan overlays a stored plan onto a freshly generated one. Stored values
+// win for every set and key they define, so hand-tuned fixtures survive;
+// params and sets that only exist in one of the plans are kept.
+func MergePlan(generated, stored ParameterPlan) ParameterPlan {
+	out := generated
+	out.Params = mergeParams(append([]Param{}, generated.Params...), stored.Params)
+	sortParams(out.Params)
+	if stored.BodyEncoding != "" {
+		out.BodyEncoding = stored.BodyEncoding
+	}
+	out.Valid = mergeSets(generated.Valid, stored.Valid)
+	out.Invalid = mergeSets(generated.Invalid, stored.Invalid)
+	return out
+}
+
+func mergeSets(generated, stored []ParameterSet) []ParameterSet {
+	byName := map[string]ParameterSet{}
+	for _, s := range stored {
+		byName[s.Name] = s
+	}
+	seen := map[string]bool{}
+	out := make([]ParameterSet, 0, len(generated)+len(stored))
+	for _, g := range generated {
+		seen[g.Name] = true
+		s, ok := byName[g.Name]
+		if !ok {
+			out = append(out, g)
+			continue
+		}
+		merged := g
+		merged.Path = overlay(g.Path, s.Path)
+		merged.Query = overlay(g.Query, s.Query)
+		merged.Header = overlay(g.Header, s.Header)
+		merged.Body = overlay(g.Body, s.Body)
+		merged.AISourced = mergeAISourced(g, s)
+		out = append(out, merged)
+	}
+	for _, s := range stored {
+		if !seen[s.Name] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// mergeAISourced keeps a generated AI tag only where the stored set does not
+// override the value; stored values keep their own tags.
+func mergeAISourced(generated, stored ParameterSet) []string {
+	storedValues := stored.Flatten()
+	out := append([]string{}, stored.AISourced...)
+	for _, k := range generated.AISourced {
+		if _, ok := storedValues[k]; !ok {
+			out = append(out, k)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func overlay[V any](base, top map[string]V) map[string]V {
+	if len(base) == 0 && len(top) == 0 {
+		return base
+	}
+	out := make(map[string]V, len(base)+len(top))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range top {
+		out[k] = v
+	}
+	return out
+}
+
+// SetValue sets loc.name to value in the valid sets and in every invalid set
+// that does not target name, so edited fixtures (tenant IDs, seed users) are
+// used by all variants. Body values are decoded as JSON when possible. Set
+// values are no longer tagged as AI-sourced.
+func (p *ParameterPlan) SetValue(loc, name, value string) error {
+	var v any = value
+	switch loc {
+	case InPath, InQuery, InHeader:
+	case InBody:
+		var decoded any
+		if err := json.Unmarshal([]byte(value), &decoded); err == nil {
+			v = decoded
+		}
+	default:
+		return fmt.Errorf("unknown parameter location %q (expected path, query, header or body)", loc)
+	}
+	param := Param{Name: name, In: loc}
+	key := loc + "." + name
+	for i := range p.Valid {
+		p.Valid[i].put(param, v)
+		p.Valid[i].AISourced = withoutKey(p.Valid[i].AISourced, key)
+	}
+	for i := range p.Invalid {
+		if p.Invalid[i].Param != name {
+			p.Invalid[i].put(param, v)
+			p.Invalid[i].AISourced = withoutKey(p.Invalid[i].AISourced, key)
+		}
+	}
+	return nil
+}
+
+// UnsetValue removes loc.name from every set.
+func (p *ParameterPlan) UnsetValue(loc, name string) {
+	param := Param{Name: name, In: loc}
+	key := loc + "." + name
+	for i := range p.Valid {
+		p.Valid[i].remove(param)
+		p.Valid[i].AISourced = withoutKey(p.Valid[i].AISourced, key)
+	}
+	for i := range p.Invalid {
+		p.Invalid[i].remove(param)
+		p.Invalid[i].AISourced = withoutKey(p.Invalid[i].AISourced, key)
+	}
+}
@@ -0,0 +1,92 @@
+package profile
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"cool-code-cleanup/internal/discovery"
+)
+
+func TestSaveLoadAndMergePlansKeepsEditedValues(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "params")
+	route := discovery.Route{ID: "routes.js:post:/orders:4", Method: "POST", Path: "/orders", Framework: "node"}
+	generated := BuildPlan(route.ID, []Param{
+		{Name: "tenant", In: InBody, Type: TypeString, Required: true, Source: SourceZod},
+		{Name: "qty", In: InBody, Type: TypeInteger, Min: floatPtr(1), Source: SourceZod},
+	})
+
+	edited := generated
+	if err := edited.SetValue(InBody, "tenant", `"t-42"`); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := edited.SetValue("cookie", "x", "1"); err == nil {
+		t.Fatalf("expected unknown location error")
+	}
+	for _, set := range edited.Invalid {
+		if set.Param == "qty" && set.Body["tenant"] != "t-42" {
+			t.Fatalf("expected edit to reach invalid variants of other params: %+v", set)
+		}
+		if set.Name == "wrong_type:body.tenant" && set.Body["tenant"] == "t-42" {
+			t.Fatalf("edit must not overwrite the variant targeting tenant: %+v", set)
+		}
+	}
+
+	written, err := SavePlans(dir, []discovery.Route{route}, []ParameterPlan{edited})
+	if err != nil || len(written) != 1 || filepath.Base(written[0]) != "node-post-orders.json" {
+		t.Fatalf("save: %v %v", written, err)
+	}
+
+	moved := route
+	moved.ID = "routes.js:post:/orders:9"
+	loaded, err := LoadPlans(dir, []discovery.Route{moved})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	stored, ok := loaded[moved.ID]
+	if !ok {
+		t.Fatalf("expected plan found by method and path after line shift")
+	}
+
+	regenerated := BuildPlan(moved.ID, []Param{
+		{Name: "tenant", In: InBody, Type: TypeString, Required: true, Source: SourceZod},
+		{Name: "qty", In: InBody, Type: TypeInteger, Min: floatPtr(1), Source: SourceZod},
+		{Name: "note", In: InBody, Type: TypeString, Source: SourceZod},
+	})
+	merged := MergePlan(regenerated, stored)
+	valid := merged.Valid[0]
+	if valid.Body["tenant"] != "t-42" || valid.Body["note"] != "example" {
+		t.Fatalf("expected stored value kept and new param filled: %+v", valid.Body)
+	}
+	if merged.RouteID != moved.ID || len(merged.Params) != 3 {
+		t.Fatalf("unexpected merged plan: %+v", merged)
+	}
+}
+
+func TestMergePlanKeepsStoredAITags(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "params")
+	route := discovery.Route{ID: "app.py:get:/items:3", Method: "GET", Path: "/items", Framework: "python"}
+	params := []Param{
+		{Name: "limit", In: InQuery, Type: TypeInteger, Source: SourceAI},
+		{Name: "sort", In: InQuery, Type: TypeString, Source: SourceAI},
+	}
+	edited := BuildPlan(route.ID, params)
+	if err := edited.SetValue(InQuery, "sort", "name"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if _, err := SavePlans(dir, []discovery.Route{route}, []ParameterPlan{edited}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := LoadPlans(dir, []discovery.Route{route})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	merged := MergePlan(BuildPlan(route.ID, params), loaded[route.ID])
+	if got := merged.Valid[0].AISourced; !reflect.DeepEqual(got, []string{"query.limit"}) {
+		t.Fatalf("expected only the unedited value tagged, got %v", got)
+	}
+	if merged.Valid[0].Query["sort"] != "name" {
+		t.Fatalf("expected edited value kept: %+v", merged.Valid[0].Query)
+	}
+}
@@ -0,0 +1,235 @@
+package regression
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"cool-code-cleanup/internal/runner"
+)
+
+// Response is what one request returned, before or after cleanup.
+type Response struct {
+	RouteID    string
+	Variant    string
+	Method     string
+	URL        string
+	Status     int
+	DurationMs float64
+	Body       []byte
+	Error      string
+}
+
+// Options says what to ignore when comparing responses.
+type Options struct {
+	// VolatileFields are JSON field names (at any depth, case-insensitive)
+	// whose values may differ between runs, such as timestamps.
+	VolatileFields []string
+	// A response is slower when it took more than LatencyRatio times as long
+	// and at least LatencyFloorMs longer. A ratio of 0 disables the check.
+	LatencyRatio   float64
+	LatencyFloorMs float64
+}
+
+// Change is a request whose response differs after cleanup.
+type Change struct {
+	RouteID         string   `json:"route_id,omitempty"`
+	Variant         string   `json:"variant,omitempty"`
+	Method          string   `json:"method"`
+	URL             string   `json:"url"`
+	StatusBefore    int      `json:"status_before"`
+	StatusAfter     int      `json:"status_after"`
+	Fields          []string `json:"fields,omitempty"`
+	LatencyBeforeMs float64  `json:"latency_before_ms"`
+	LatencyAfterMs  float64  `json:"latency_after_ms"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// Result lists the requests whose status or body changed and those that
+// only got slower.
+type Result struct {
+	Compared int      `json:"compared"`
+	Behavior []Change `json:"behavior_changes,omitempty"`
+	Slower   []Change `json:"slower,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Changed reports whether cleanup changed what the app returns, or the app
+// could not be checked at all.
+func (r Result) Changed() bool {
+	return len(r.Behavior) > 0 || r.Error != ""
+}
+
+// Compare diffs responses to the same requests, matched by position.
+// Requests only one side has count as behavior changes.
+func Compare(before, after []Response, opts Options) Result {
+	res := Result{Compared: min(len(before), len(after))}
+	for i := 0; i < max(len(before), len(after)); i++ {
+		switch {
+		case i >= len(after):
+			c := change(before[i], Response{})
+			c.Error = "not sent after cleanup"
+			res.Behavior = append(res.Behavior, c)
+			continue
+		case i >= len(before):
+			c := change(Response{}, after[i])
+			c.Error = "not sent before cleanup"
+			res.Behavior = append(res.Behavior, c)
+			continue
+		}
+		b, a := before[i], after[i]
+		c := change(b, a)
+		switch {
+		case b.Error == "" && a.Error != "":
+			c.Error = a.Error
+		case b.Status != a.Status:
+		default:
+			c.Fields = DiffBodies(b.Body, a.Body, opts.VolatileFields)
+		}
+		if c.Error != "" || c.StatusBefore != c.StatusAfter || len(c.Fields) > 0 {
+			res.Behavior = append(res.Behavior, c)
+			continue
+		}
+		if opts.LatencyRatio > 0 && a.DurationMs > b.DurationMs*opts.LatencyRatio && a.DurationMs-b.DurationMs >= opts.LatencyFloorMs {
+			res.Slower = append(res.Slower, c)
+		}
+	}
+	return res
+}
+
+// change describes the request by its before side, or its after side when
+// it was not sent before.
+func change(b, a Response) Change {
+	id := b
+	if id.Method == "" {
+		id = a
+	}
+	return Change{
+		RouteID:         id.RouteID,
+		Variant:         id.Variant,
+		Method:          id.Method,
+		URL:             id.URL,
+		StatusBefore:    b.Status,
+		StatusAfter:     a.Status,
+		LatencyBeforeMs: b.DurationMs,
+		LatencyAfterMs:  a.DurationMs,
+	}
+}
+
+// DiffBodies returns the JSON paths whose values differ, ignoring volatile
+// fields, or "body" when either side is not JSON and the text differs.
+func DiffBodies(before, after []byte, volatile []string) []string {
+	var b, a any
+	if json.Unmarshal(before, &b) != nil || json.Unmarshal(after, &a) != nil {
+		if bytes.Equal(bytes.TrimSpace(before), bytes.TrimSpace(after)) {
+			return nil
+		}
+		return []string{"body"}
+	}
+	var out []string
+	diffValue("$", b, a, volatile, &out)
+	return out
+}
+
+func diffValue(path string, b, a any, volatile []string, out *[]string) {
+	switch bv := b.(type) {
+	case map[string]any:
+		av, ok := a.(map[string]any)
+		if !ok {
+			*out = append(*out, path)
+			return
+		}
+		keys := make([]string, 0, len(bv)+len(av))
+		for k := range bv {
+			keys = append(keys, k)
+		}
+		for k := range av {
+			if _, ok := bv[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if isVolatile(k, volatile) {
+				continue
+			}
+			bk, inB := bv[k]
+			ak, inA := av[k]
+			if inB != inA {
+				*out = append(*out, path+"."+k)
+				continue
+			}
+			diffValue(path+"."+k, bk, ak, volatile, out)
+		}
+	case []any:
+		av, ok := a.([]any)
+		if !ok || len(av) != len(bv) {
+			*out = append(*out, path)
+			return
+		}
+		for i := range bv {
+			diffValue(fmt.Sprintf("%s[%d]", path, i), bv[i], av[i], volatile, out)
+		}
+	default:
+		if b != a {
+			*out = append(*out, path)
+		}
+	}
+}
+
+func isVolatile(name string, volatile []string) bool {
+	return slices.ContainsFunc(volatile, func(v string) bool { return strings.EqualFold(v, name) })
+}
+
+// FromInvocations turns profiling invocations into responses.
+func FromInvocations(invs []runner.Invocation) []Response {
+	out := make([]Response, 0, len(invs))
+	for _, inv := range invs {
+		out = append(out, Response{
+			RouteID:    inv.RouteID,
+			Variant:    inv.Variant,
+			Method:     inv.Method,
+			URL:        inv.URL,
+			Status:     inv.Status,
+			DurationMs: inv.DurationMs,
+			Body:       inv.Body,
+			Error:      inv.Error,
+		})
+	}
+	return out
+}
+
+// FromReplay turns replay results into responses.
+func FromReplay(results []runner.ReplayResult) []Response {
+	out := make([]Response, 0, len(results))
+	for _, r := range results {
+		out = append(out, Response{
+			RouteID:    r.RouteID,
+			Variant:    r.Variant,
+			Method:     r.Method,
+			URL:        r.URL,
+			Status:     r.Status,
+			DurationMs: r.DurationMs,
+			Body:       r.Body,
+			Error:      r.Error,
+		})
+	}
+	return out
+}
+
+// FormatChange describes c on one line.
+func FormatChange(c Change) string {
+	head := fmt.Sprintf("%s %s [%s]", c.Method, c.URL, c.Variant)
+	switch {
+	case c.Error != "":
+		return fmt.Sprintf("%s: %s", head, c.Error)
+	case c.StatusBefore != c.StatusAfter:
+		return fmt.Sprintf("%s: status %d -> %d", head, c.StatusBefore, c.StatusAfter)
+	case len(c.Fields) > 0:
+		return fmt.Sprintf("%s: body changed at %s", head, strings.Join(c.Fields, ", "))
+	}
+	return fmt.Sprintf("%s: %.1fms -> %.1fms", head, c.LatencyBeforeMs, c.LatencyAfterMs)
+}
@@ -0,0 +1,43 @@
+package regression
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompareIgnoresVolatileFieldsAndFlagsChanges(t *testing.T) {
+	opts := Options{VolatileFields: []string{"updated_at"}, LatencyRatio: 2, LatencyFloorMs: 50}
+	before := []Response{
+		{Method: "GET", URL: "/users/1", Status: 200, DurationMs: 10, Body: []byte(`{"id":1,"name":"ann","updated_at":"2026-01-01"}`)},
+		{Method: "GET", URL: "/users", Status: 200, DurationMs: 10, Body: []byte(`[{"id":1,"roles":["admin"]}]`)},
+		{Method: "POST", URL: "/users", Status: 201, DurationMs: 10},
+		{Method: "GET", URL: "/report", Status: 200, DurationMs: 10, Body: []byte("ok")},
+		{Method: "GET", URL: "/health", Status: 200, DurationMs: 10},
+	}
+	after := []Response{
+		{Method: "GET", URL: "/users/1", Status: 200, DurationMs: 12, Body: []byte(`{"updated_at":"2026-02-02","name":"ann","id":1}`)},
+		{Method: "GET", URL: "/users", Status: 200, DurationMs: 11, Body: []byte(`[{"id":1,"roles":["viewer"]}]`)},
+		{Method: "POST", URL: "/users", Status: 500, DurationMs: 9},
+		{Method: "GET", URL: "/report", Status: 200, DurationMs: 90, Body: []byte("ok\n")},
+	}
+	res := Compare(before, after, opts)
+	if !res.Changed() || res.Compared != 4 || len(res.Behavior) != 3 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if got := res.Behavior[0]; got.URL != "/users" || !slices.Equal(got.Fields, []string{"$[0].roles[0]"}) {
+		t.Fatalf("expected nested body change, got %+v", got)
+	}
+	if got := res.Behavior[1]; got.StatusBefore != 201 || got.StatusAfter != 500 {
+		t.Fatalf("expected status change, got %+v", got)
+	}
+	if got := res.Behavior[2]; got.URL != "/health" || got.Error == "" {
+		t.Fatalf("expected missing request, got %+v", got)
+	}
+	if len(res.Slower) != 1 || res.Slower[0].URL != "/report" {
+		t.Fatalf("expected /report to be slower, got %+v", res.Slower)
+	}
+
+	if res := Compare(before[:1], after[:1], opts); res.Changed() || len(res.Slower) > 0 {
+		t.Fatalf("expected only volatile differences to be ignored, got %+v", res)
+	}
+}
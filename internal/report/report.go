@@ -28,6 +28,16 @@ type RunReport struct {
 	Routes          any                 `json:"routes,omitempty"`
 	Rules           any                 `json:"rules,omitempty"`
 	ProfilingRuns   []any               `json:"profiling_runs,omitempty"`
+	MockServices    any                 `json:"mock_services,omitempty"`
+	LoadTest        any                 `json:"load_test,omitempty"`
+	CPUProfile      any                 `json:"cpu_profile,omitempty"`
+	Coverage        any                 `json:"coverage,omitempty"`
+	AppLog          string              `json:"app_log,omitempty"`
+	HAR             string              `json:"har,omitempty"`
+	Replay          any                 `json:"replay,omitempty"`
+	Regression      any                 `json:"regression,omitempty"`
+	Baseline        any                 `json:"baseline,omitempty"`
+	Patch           string              `json:"patch,omitempty"`
 	CleanupPlan     []any               `json:"cleanup_plan,omitempty"`
 	AppliedChanges  []any               `json:"applied_changes,omitempty"`
 	Git             any                 `json:"git,omitempty"`
@@ -0,0 +1,139 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// maxLogLines caps the lines kept in memory for correlation; the log
+	// file always has all of them.
+	maxLogLines = 100000
+	// maxInvocationLogs caps the lines attached to one invocation.
+	maxInvocationLogs = 20
+	// logSlack is how long after a response the app may still log about it.
+	logSlack = 250 * time.Millisecond
+)
+
+// DefaultAppLogPath is where the app's stdout and stderr are written.
+func DefaultAppLogPath(runID string) string {
+	return filepath.Join(".ccc", "runs", runID, "app.log")
+}
+
+// LogLine is one line of app output and when it was read.
+type LogLine struct {
+	Time time.Time
+	Text string
+}
+
+// AppLog receives the app's combined output. Each line is written to the
+// log file with a timestamp and kept so it can be matched to the request
+// that produced it.
+type AppLog struct {
+	mu      sync.Mutex
+	f       *os.File
+	partial []byte
+	lines   []LogLine
+}
+
+// OpenAppLog creates the log file at path, and its directory.
+func OpenAppLog(path string) (*AppLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create app log directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create app log: %w", err)
+	}
+	return &AppLog{f: f}, nil
+}
+
+// Write splits p into lines; a trailing partial line waits for the rest.
+func (l *AppLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	data := append(l.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if err := l.add(now, string(bytes.TrimRight(data[:i], "\r"))); err != nil {
+			return 0, err
+		}
+		data = data[i+1:]
+	}
+	l.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// Note adds a line of ccc's own, such as which command was started.
+func (l *AppLog) Note(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_ = l.add(time.Now(), "[ccc] "+fmt.Sprintf(format, args...))
+}
+
+func (l *AppLog) add(t time.Time, text string) error {
+	if len(l.lines) < maxLogLines {
+		l.lines = append(l.lines, LogLine{Time: t, Text: text})
+	}
+	_, err := fmt.Fprintf(l.f, "%s %s\n", t.UTC().Format("2006-01-02T15:04:05.000Z"), text)
+	return err
+}
+
+// Lines returns the lines read in [start, end).
+func (l *AppLog) Lines(start, end time.Time) []LogLine {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []LogLine
+	for _, line := range l.lines {
+		if !line.Time.Before(start) && line.Time.Before(end) {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// Close flushes any partial last line and closes the file.
+func (l *AppLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.partial) > 0 {
+		_ = l.add(time.Now(), string(l.partial))
+		l.partial = nil
+	}
+	return l.f.Close()
+}
+
+// CorrelateLogs attaches to each invocation the app output read while it
+// ran and shortly after, up to the start of the next one. Invocations must
+// be sequential, as Execute makes them.
+func CorrelateLogs(invocations []Invocation, log *AppLog) {
+	if log == nil {
+		return
+	}
+	for i := range invocations {
+		inv := &invocations[i]
+		if inv.StartedAt.IsZero() {
+			continue
+		}
+		end := inv.StartedAt.Add(time.Duration(inv.DurationMs*float64(time.Millisecond)) + logSlack)
+		if i+1 < len(invocations) && !invocations[i+1].StartedAt.IsZero() && invocations[i+1].StartedAt.Before(end) {
+			end = invocations[i+1].StartedAt
+		}
+		lines := log.Lines(inv.StartedAt, end)
+		for j, line := range lines {
+			if j == maxInvocationLogs {
+				inv.Logs = append(inv.Logs, fmt.Sprintf("... %d more lines in the app log", len(lines)-j))
+				break
+			}
+			inv.Logs = append(inv.Logs, line.Text)
+		}
+	}
+}
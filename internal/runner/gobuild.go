@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// BuildGo builds the main package at root into binary with the extra go
+// build flags. files adds sources to the package by name through an overlay
+// (go build -overlay), so the project tree is never written to; the sources
+// and the overlay are kept in tmpDir.
+func BuildGo(root, binary, tmpDir string, flags []string, files map[string]string) error {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", root, err)
+	}
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		return fmt.Errorf("create build directory: %w", err)
+	}
+	replace := map[string]string{}
+	for name, src := range files {
+		target := filepath.Join(absRoot, name)
+		if _, err := os.Stat(target); err == nil {
+			return fmt.Errorf("%s already exists in the project", target)
+		}
+		path, err := filepath.Abs(filepath.Join(tmpDir, name))
+		if err != nil {
+			return fmt.Errorf("resolve %s: %w", name, err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+		replace[target] = path
+	}
+	args := append([]string{"build"}, flags...)
+	if len(replace) > 0 {
+		overlay, err := json.Marshal(map[string]any{"Replace": replace})
+		if err != nil {
+			return fmt.Errorf("encode build overlay: %w", err)
+		}
+		path, err := filepath.Abs(filepath.Join(tmpDir, "overlay.json"))
+		if err != nil {
+			return fmt.Errorf("resolve build overlay: %w", err)
+		}
+		if err := os.WriteFile(path, overlay, 0o644); err != nil {
+			return fmt.Errorf("write build overlay: %w", err)
+		}
+		args = append(args, "-overlay", path)
+	}
+	args = append(args, "-o", binary, ".")
+	cmd := exec.Command("go", args...)
+	cmd.Dir = root
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("go %s: %v: %s", strings.Join(append([]string{"build"}, flags...), " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
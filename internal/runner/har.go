@@ -0,0 +1,448 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Redacted replaces secret values in recorded traffic.
+const Redacted = "REDACTED"
+
+// harTimeFormat is fixed-width so entries sort by start time as strings.
+const harTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// DefaultHARPath is where a run's traffic is recorded unless a path is given.
+func DefaultHARPath(runID string) string {
+	return filepath.Join(".ccc", "runs", runID, "traffic.har")
+}
+
+// HAR is an HTTP Archive 1.2 document.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+	Comment string     `json:"comment,omitempty"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is one request and its response. The custom _route_id and
+// _variant fields name the route and parameter set that produced it.
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+	RouteID         string      `json:"_route_id,omitempty"`
+	Variant         string      `json:"_variant,omitempty"`
+}
+
+// RedactedRequests returns the entries whose request query or body was
+// recorded with Redacted values, e.g. the password of a login. Replaying
+// them sends REDACTED in their place.
+func (h HAR) RedactedRequests() []HAREntry {
+	var out []HAREntry
+	for _, e := range h.Log.Entries {
+		if e.Request.redacted() {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+func (r HARRequest) redacted() bool {
+	if slices.ContainsFunc(r.QueryString, func(q HARNameValue) bool { return q.Value == Redacted }) {
+		return true
+	}
+	return r.PostData != nil && strings.Contains(r.PostData.Text, Redacted)
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARTimings splits an entry's time into waiting for the response headers
+// and receiving the body; sending is not measured separately.
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// Redaction says which recorded values are replaced with Redacted. Headers
+// are matched case-insensitively, and a redacted Cookie or Set-Cookie header
+// redacts every cookie value too. Fields are query parameters, form fields
+// and JSON object keys at any depth. OmitBodies drops bodies altogether.
+type Redaction struct {
+	Headers    []string `json:"headers,omitempty"`
+	Fields     []string `json:"fields,omitempty"`
+	OmitBodies bool     `json:"omit_bodies,omitempty"`
+}
+
+// HARRecorder collects the traffic sent through its Transport.
+type HARRecorder struct {
+	redact  Redaction
+	mu      sync.Mutex
+	entries []HAREntry
+}
+
+func NewHARRecorder(r Redaction) *HARRecorder {
+	return &HARRecorder{redact: r}
+}
+
+type harLabelKey struct{}
+
+type harLabel struct {
+	routeID, variant string
+}
+
+// withHARLabel names the route and parameter set behind req in its entry.
+func withHARLabel(req *http.Request, routeID, variant string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), harLabelKey{}, harLabel{routeID, variant}))
+}
+
+// Transport wraps next (http.DefaultTransport when nil) so every exchange is
+// recorded once its response body is closed.
+func (r *HARRecorder) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return harTransport{rec: r, next: next}
+}
+
+type harTransport struct {
+	rec  *HARRecorder
+	next http.RoundTripper
+}
+
+func (t harTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			reqBody, _ = io.ReadAll(body)
+			_ = body.Close()
+		}
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	headers := time.Now()
+	resp.Body = &harBody{ReadCloser: resp.Body, done: func(body []byte, size int64) {
+		t.rec.add(req, reqBody, resp, body, size, start, headers, time.Now())
+	}}
+	return resp, nil
+}
+
+// harBody keeps up to maxResponseBody bytes of what is read and reports them
+// on the first Close.
+type harBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	size int64
+	done func([]byte, int64)
+	once sync.Once
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if room := maxResponseBody - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
+
+func (b *harBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.buf.Bytes(), b.size) })
+	return err
+}
+
+func (r *HARRecorder) add(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, respSize int64, start, headers, end time.Time) {
+	label, _ := req.Context().Value(harLabelKey{}).(harLabel)
+	e := HAREntry{
+		StartedDateTime: start.UTC().Format(harTimeFormat),
+		Time:            durationMs(end.Sub(start)),
+		RouteID:         label.routeID,
+		Variant:         label.variant,
+		Timings:         HARTimings{Wait: durationMs(headers.Sub(start)), Receive: durationMs(end.Sub(headers))},
+		Request: HARRequest{
+			Method:      req.Method,
+			URL:         r.redactURL(req.URL),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     r.cookies(req.Cookies(), "Cookie"),
+			Headers:     r.headers(req.Header),
+			QueryString: r.query(req.URL.Query()),
+			HeadersSize: -1,
+			BodySize:    len(reqBody),
+		},
+		Response: HARResponse{
+			Status:      resp.StatusCode,
+			StatusText:  http.StatusText(resp.StatusCode),
+			HTTPVersion: resp.Proto,
+			Cookies:     r.cookies(resp.Cookies(), "Set-Cookie"),
+			Headers:     r.headers(resp.Header),
+			RedirectURL: resp.Header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    respSize,
+			Content:     HARContent{Size: respSize, MimeType: resp.Header.Get("Content-Type")},
+		},
+	}
+	if len(reqBody) > 0 {
+		mimeType := req.Header.Get("Content-Type")
+		e.Request.PostData = &HARPostData{MimeType: mimeType}
+		if !r.redact.OmitBodies {
+			e.Request.PostData.Text = string(r.redactBody(mimeType, reqBody))
+		}
+	}
+	if !r.redact.OmitBodies && len(respBody) > 0 {
+		body := r.redactBody(e.Response.Content.MimeType, respBody)
+		if utf8.Valid(body) {
+			e.Response.Content.Text = string(body)
+		} else {
+			e.Response.Content.Text = base64.StdEncoding.EncodeToString(body)
+			e.Response.Content.Encoding = "base64"
+		}
+		if int64(len(respBody)) < respSize {
+			e.Response.Content.Comment = fmt.Sprintf("truncated to the first %d bytes", len(respBody))
+		}
+	}
+	r.mu.Lock()
+	r.entries = append(r.entries, e)
+	r.mu.Unlock()
+}
+
+// Entries returns the recorded entries in the order their requests started.
+func (r *HARRecorder) Entries() []HAREntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := slices.Clone(r.entries)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].StartedDateTime < out[j].StartedDateTime })
+	return out
+}
+
+// Save writes the recorded traffic to path as HAR 1.2.
+func (r *HARRecorder) Save(path string) error {
+	har := HAR{Log: HARLog{Version: "1.2", Creator: HARCreator{Name: "ccc", Version: "1"}, Entries: r.Entries()}}
+	if har.Log.Entries == nil {
+		har.Log.Entries = []HAREntry{}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create HAR directory: %w", err)
+	}
+	data, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode HAR: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write HAR %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadHAR reads a HAR file written by ccc or a browser.
+func LoadHAR(path string) (HAR, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return HAR{}, fmt.Errorf("read HAR %s: %w", path, err)
+	}
+	var har HAR
+	if err := json.Unmarshal(data, &har); err != nil {
+		return HAR{}, fmt.Errorf("parse HAR %s: %w", path, err)
+	}
+	return har, nil
+}
+
+func (r *HARRecorder) headerRedacted(name string) bool {
+	return slices.ContainsFunc(r.redact.Headers, func(h string) bool { return strings.EqualFold(h, name) })
+}
+
+func (r *HARRecorder) fieldRedacted(name string) bool {
+	return slices.ContainsFunc(r.redact.Fields, func(f string) bool { return strings.EqualFold(f, name) })
+}
+
+func (r *HARRecorder) headers(h http.Header) []HARNameValue {
+	out := []HARNameValue{}
+	for _, name := range slices.Sorted(maps.Keys(h)) {
+		for _, v := range h[name] {
+			if r.headerRedacted(name) {
+				v = Redacted
+			}
+			out = append(out, HARNameValue{Name: name, Value: v})
+		}
+	}
+	return out
+}
+
+func (r *HARRecorder) cookies(cookies []*http.Cookie, header string) []HARNameValue {
+	out := []HARNameValue{}
+	for _, c := range cookies {
+		v := c.Value
+		if r.headerRedacted(header) {
+			v = Redacted
+		}
+		out = append(out, HARNameValue{Name: c.Name, Value: v})
+	}
+	return out
+}
+
+func (r *HARRecorder) query(q url.Values) []HARNameValue {
+	out := []HARNameValue{}
+	for _, name := range slices.Sorted(maps.Keys(q)) {
+		for _, v := range q[name] {
+			if r.fieldRedacted(name) {
+				v = Redacted
+			}
+			out = append(out, HARNameValue{Name: name, Value: v})
+		}
+	}
+	return out
+}
+
+func (r *HARRecorder) redactURL(u *url.URL) string {
+	c := *u
+	q := c.Query()
+	changed := false
+	for name := range q {
+		if r.fieldRedacted(name) {
+			for i := range q[name] {
+				q[name][i] = Redacted
+			}
+			changed = true
+		}
+	}
+	if changed {
+		c.RawQuery = q.Encode()
+	}
+	return c.String()
+}
+
+// redactBody redacts fields in JSON and form-urlencoded bodies; other bodies,
+// and those with nothing to redact, are kept as sent.
+func (r *HARRecorder) redactBody(contentType string, body []byte) []byte {
+	if len(r.redact.Fields) == 0 {
+		return body
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return body
+		}
+		changed := false
+		for name := range form {
+			if r.fieldRedacted(name) {
+				for i := range form[name] {
+					form[name][i] = Redacted
+				}
+				changed = true
+			}
+		}
+		if !changed {
+			return body
+		}
+		return []byte(form.Encode())
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var v any
+		if json.Unmarshal(body, &v) != nil || !r.redactJSON(v) {
+			return body
+		}
+		out, err := json.Marshal(v)
+		if err != nil {
+			return body
+		}
+		return out
+	}
+	return body
+}
+
+// redactJSON redacts matching keys in v in place and reports whether any
+// matched.
+func (r *HARRecorder) redactJSON(v any) bool {
+	changed := false
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if r.fieldRedacted(k) {
+				t[k] = Redacted
+				changed = true
+			} else if r.redactJSON(child) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, child := range t {
+			if r.redactJSON(child) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
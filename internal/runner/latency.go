@@ -0,0 +1,219 @@
+package runner
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"cool-code-cleanup/internal/discovery"
+	"cool-code-cleanup/internal/profile"
+)
+
+// MeasureOptions controls the latency pass: Warmup unmeasured calls, then
+// Iterations measured calls spread over Concurrency workers.
+type MeasureOptions struct {
+	Iterations  int
+	Warmup      int
+	Concurrency int
+}
+
+// Sample is one measured call. Status is zero when the request failed.
+type Sample struct {
+	Duration time.Duration
+	Bytes    int64
+	Status   int
+	Err      error
+}
+
+type LatencyStats struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+type SizeStats struct {
+	Min  int64   `json:"min"`
+	Mean float64 `json:"mean"`
+	Max  int64   `json:"max"`
+}
+
+// RouteStats summarizes the measured calls of one route's valid set.
+// Request errors and non-2xx/3xx responses count as errors.
+type RouteStats struct {
+	RouteID       string       `json:"route_id"`
+	Method        string       `json:"method"`
+	Path          string       `json:"path"`
+	Requests      int          `json:"requests"`
+	Errors        int          `json:"errors"`
+	ErrorRate     float64      `json:"error_rate"`
+	Concurrency   int          `json:"concurrency"`
+	LatencyMs     LatencyStats `json:"latency_ms"`
+	ResponseBytes SizeStats    `json:"response_bytes"`
+	// StartedAt and EndedAt bound the measured calls, for attributing
+	// profiler samples to the route.
+	StartedAt time.Time `json:"-"`
+	EndedAt   time.Time `json:"-"`
+}
+
+// Measure calls each route's first valid set repeatedly, in dependency order,
+// and returns per-route statistics sorted slowest (by p95) first. Calls share
+// session so authenticated routes stay authenticated. With Iterations below
+// 1 it measures nothing.
+func Measure(baseURL string, routes []discovery.Route, plans []profile.ParameterPlan, dependencies map[string][]string, session *Session, opts MeasureOptions) []RouteStats {
+	if session == nil {
+		session = NewSession(DefaultTokenRules())
+	}
+	if opts.Iterations < 1 {
+		return nil
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	routeByID := map[string]discovery.Route{}
+	for _, r := range routes {
+		routeByID[r.ID] = r
+	}
+	planByID := map[string]profile.ParameterPlan{}
+	for _, p := range plans {
+		planByID[p.RouteID] = p
+	}
+
+	client := session.Client(&http.Client{Timeout: 5 * time.Second})
+	var out []RouteStats
+	for _, id := range executionOrder(routes, dependencies) {
+		r, ok := routeByID[id]
+		if !ok {
+			continue
+		}
+		plan := planByID[id]
+		set := firstValidSet(plan)
+		call := func() Sample {
+			req, err := BuildRequest(baseURL, r, plan, set)
+			if err != nil {
+				return Sample{Err: err}
+			}
+			session.Apply(req, set)
+			resp, body, sample := roundTrip(client, req)
+			if sample.Err == nil {
+				session.Capture(r, resp, body)
+			}
+			return sample
+		}
+		for i := 0; i < opts.Warmup; i++ {
+			call()
+		}
+		started := time.Now()
+		samples := make([]Sample, opts.Iterations)
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < opts.Concurrency; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					samples[i] = call()
+				}
+			}()
+		}
+		for i := range samples {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+
+		stats := Summarize(samples)
+		stats.RouteID, stats.Method, stats.Path = r.ID, r.Method, r.Path
+		stats.Concurrency = opts.Concurrency
+		stats.StartedAt, stats.EndedAt = started, time.Now()
+		out = append(out, stats)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].LatencyMs.P95 > out[j].LatencyMs.P95 })
+	return out
+}
+
+// Drive sends the route's first valid set back to back until stop is closed
+// and returns how many requests were sent. It keeps the app busy while a
+// profiler samples it.
+func Drive(baseURL string, r discovery.Route, plan profile.ParameterPlan, session *Session, stop <-chan struct{}) int {
+	if session == nil {
+		session = NewSession(DefaultTokenRules())
+	}
+	client := session.Client(&http.Client{Timeout: 5 * time.Second})
+	set := firstValidSet(plan)
+	n := 0
+	for {
+		select {
+		case <-stop:
+			return n
+		default:
+		}
+		req, err := BuildRequest(baseURL, r, plan, set)
+		if err != nil {
+			return n
+		}
+		session.Apply(req, set)
+		roundTrip(client, req)
+		n++
+	}
+}
+
+// Summarize computes latency (nearest-rank percentiles), size and error
+// statistics over samples.
+func Summarize(samples []Sample) RouteStats {
+	stats := RouteStats{Requests: len(samples)}
+	if len(samples) == 0 {
+		return stats
+	}
+	latencies := make([]float64, 0, len(samples))
+	var totalMs float64
+	var totalBytes int64
+	stats.ResponseBytes.Min = math.MaxInt64
+	for _, s := range samples {
+		ms := durationMs(s.Duration)
+		latencies = append(latencies, ms)
+		totalMs += ms
+		totalBytes += s.Bytes
+		stats.ResponseBytes.Min = min(stats.ResponseBytes.Min, s.Bytes)
+		stats.ResponseBytes.Max = max(stats.ResponseBytes.Max, s.Bytes)
+		if s.Err != nil || s.Status < 200 || s.Status >= 400 {
+			stats.Errors++
+		}
+	}
+	sort.Float64s(latencies)
+	n := float64(len(samples))
+	stats.ErrorRate = float64(stats.Errors) / n
+	stats.ResponseBytes.Mean = float64(totalBytes) / n
+	stats.LatencyMs = LatencyStats{
+		Min:  latencies[0],
+		Mean: totalMs / n,
+		P50:  percentile(latencies, 50),
+		P95:  percentile(latencies, 95),
+		P99:  percentile(latencies, 99),
+		Max:  latencies[len(latencies)-1],
+	}
+	return stats
+}
+
+func FormatStats(s RouteStats) string {
+	l := s.LatencyMs
+	return fmt.Sprintf("%s %s n=%d errors=%.1f%% min=%.1fms mean=%.1fms p50=%.1fms p95=%.1fms p99=%.1fms size=%.0fB", s.Method, s.Path, s.Requests, s.ErrorRate*100, l.Min, l.Mean, l.P50, l.P95, l.P99, s.ResponseBytes.Mean)
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,343 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cool-code-cleanup/internal/dependency"
+	"cool-code-cleanup/internal/discovery"
+	"cool-code-cleanup/internal/profile"
+)
+
+// defaultLoadConcurrency is the worker pool size when only a target RPS is
+// given.
+const defaultLoadConcurrency = 32
+
+// MaxLoadRPS bounds the target request rate of a load test.
+const MaxLoadRPS = 1e6
+
+// histogramBoundsMs are the upper bounds of the latency histogram buckets;
+// a final unbounded bucket catches the rest.
+var histogramBoundsMs = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000}
+
+// LoadTarget weights a route in the load mix. Route accepts a route ID,
+// "METHOD /path" or a bare "/path" (with path.Match globs).
+type LoadTarget struct {
+	Route  string `json:"route"`
+	Weight int    `json:"weight"`
+}
+
+// LoadOptions drives targets for Duration with Concurrency workers. When RPS
+// is set, requests are started at that rate (open model) and Concurrency
+// bounds how many may be in flight; otherwise each worker sends back to back.
+type LoadOptions struct {
+	Targets     []LoadTarget
+	Duration    time.Duration
+	RPS         float64
+	Concurrency int
+}
+
+type HistogramBucket struct {
+	// UpperMs is the inclusive upper bound; 0 marks the unbounded last bucket.
+	UpperMs float64 `json:"le_ms"`
+	Count   int     `json:"count"`
+}
+
+type LoadRouteResult struct {
+	RouteID   string       `json:"route_id"`
+	Method    string       `json:"method"`
+	Path      string       `json:"path"`
+	Weight    int          `json:"weight"`
+	Requests  int          `json:"requests"`
+	Errors    int          `json:"errors"`
+	LatencyMs LatencyStats `json:"latency_ms"`
+}
+
+type LoadResult struct {
+	DurationS     float64           `json:"duration_s"`
+	TargetRPS     float64           `json:"target_rps,omitempty"`
+	Concurrency   int               `json:"concurrency"`
+	Requests      int               `json:"requests"`
+	Errors        int               `json:"errors"`
+	ErrorRate     float64           `json:"error_rate"`
+	ThroughputRPS float64           `json:"throughput_rps"`
+	Missed        int               `json:"missed,omitempty"`
+	LatencyMs     LatencyStats      `json:"latency_ms"`
+	Histogram     []HistogramBucket `json:"histogram"`
+	Routes        []LoadRouteResult `json:"routes"`
+	Setup         []string          `json:"setup,omitempty"`
+}
+
+// ValidateLoadRPS checks a target request rate; 0 selects back-to-back
+// workers.
+func ValidateLoadRPS(rps float64) error {
+	if math.IsNaN(rps) || rps < 0 || rps > MaxLoadRPS {
+		return fmt.Errorf("load RPS must be between 0 and %g, got %g", MaxLoadRPS, rps)
+	}
+	return nil
+}
+
+// ParseLoadMix parses "ref=weight" entries such as "POST /orders=3"; entries
+// without a weight get 1.
+func ParseLoadMix(entries []string) ([]LoadTarget, error) {
+	var out []LoadTarget
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		t := LoadTarget{Route: e, Weight: 1}
+		if i := strings.LastIndex(e, "="); i >= 0 {
+			w, err := strconv.Atoi(strings.TrimSpace(e[i+1:]))
+			if err != nil || w < 1 {
+				return nil, fmt.Errorf("invalid load mix weight in %q (expected a positive integer)", e)
+			}
+			t.Route, t.Weight = strings.TrimSpace(e[:i]), w
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+type loadTarget struct {
+	route  discovery.Route
+	plan   profile.ParameterPlan
+	set    profile.ParameterSet
+	weight int
+}
+
+// Load runs a load test against routes. Dependencies of the mixed routes
+// that are not themselves in the mix run once first, in dependency order, so
+// session tokens and cookies are in place; every load request then shares
+// session. With no targets, every route is mixed with weight 1.
+func Load(baseURL string, routes []discovery.Route, plans []profile.ParameterPlan, dependencies map[string][]string, session *Session, opts LoadOptions) (LoadResult, error) {
+	if session == nil {
+		session = NewSession(DefaultTokenRules())
+	}
+	if opts.Duration <= 0 {
+		return LoadResult{}, fmt.Errorf("load duration must be positive")
+	}
+	if err := ValidateLoadRPS(opts.RPS); err != nil {
+		return LoadResult{}, err
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+		if opts.RPS > 0 {
+			opts.Concurrency = defaultLoadConcurrency
+		}
+	}
+	planByID := map[string]profile.ParameterPlan{}
+	for _, p := range plans {
+		planByID[p.RouteID] = p
+	}
+	routeByID := map[string]discovery.Route{}
+	for _, r := range routes {
+		routeByID[r.ID] = r
+	}
+
+	weights := map[string]int{}
+	var order []string
+	if len(opts.Targets) == 0 {
+		for _, r := range routes {
+			weights[r.ID] = 1
+			order = append(order, r.ID)
+		}
+	}
+	for _, t := range opts.Targets {
+		ids := dependency.MatchRoutes(routes, t.Route)
+		if len(ids) == 0 {
+			return LoadResult{}, fmt.Errorf("load mix route %q matched no routes", t.Route)
+		}
+		for _, id := range ids {
+			if _, ok := weights[id]; !ok {
+				order = append(order, id)
+			}
+			weights[id] += t.Weight
+		}
+	}
+	var targets []loadTarget
+	var mixed []discovery.Route
+	total := 0
+	for _, id := range order {
+		r := routeByID[id]
+		plan := planByID[id]
+		targets = append(targets, loadTarget{route: r, plan: plan, set: firstValidSet(plan), weight: weights[id]})
+		mixed = append(mixed, r)
+		total += weights[id]
+	}
+	if total == 0 {
+		return LoadResult{}, fmt.Errorf("load mix is empty")
+	}
+
+	client := session.Client(&http.Client{Timeout: 10 * time.Second})
+	res := LoadResult{Concurrency: opts.Concurrency, TargetRPS: opts.RPS}
+	for _, id := range executionOrder(mixed, dependencies) {
+		if _, inMix := weights[id]; inMix {
+			continue
+		}
+		r, ok := routeByID[id]
+		if !ok {
+			continue
+		}
+		plan := planByID[id]
+		inv := invoke(client, session, baseURL, r, plan, firstValidSet(plan), false)
+		res.Setup = append(res.Setup, fmt.Sprintf("%s %s -> %d", inv.Method, r.Path, inv.Status))
+	}
+
+	pick := func() int {
+		n := rand.IntN(total)
+		for i, t := range targets {
+			if n < t.weight {
+				return i
+			}
+			n -= t.weight
+		}
+		return len(targets) - 1
+	}
+	type result struct {
+		target int
+		sample Sample
+	}
+	results := make(chan result, opts.Concurrency)
+	send := func(i int) {
+		t := targets[i]
+		req, err := BuildRequest(baseURL, t.route, t.plan, t.set)
+		if err != nil {
+			results <- result{i, Sample{Err: err}}
+			return
+		}
+		session.Apply(req, t.set)
+		_, _, sample := roundTrip(client, req)
+		results <- result{i, sample}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Duration)
+	defer cancel()
+	var wg sync.WaitGroup
+	start := time.Now()
+	if opts.RPS > 0 {
+		slots := make(chan struct{}, opts.Concurrency)
+		interval := max(time.Duration(float64(time.Second)/opts.RPS), time.Nanosecond)
+		ticker := time.NewTicker(interval)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					select {
+					case slots <- struct{}{}:
+						wg.Add(1)
+						go func() {
+							defer wg.Done()
+							send(pick())
+							<-slots
+						}()
+					default:
+						res.Missed++
+					}
+				}
+			}
+		}()
+	} else {
+		for w := 0; w < opts.Concurrency; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for ctx.Err() == nil {
+					send(pick())
+				}
+			}()
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	perRoute := make([][]Sample, len(targets))
+	var all []Sample
+	for r := range results {
+		perRoute[r.target] = append(perRoute[r.target], r.sample)
+		all = append(all, r.sample)
+	}
+	elapsed := time.Since(start)
+
+	overall := Summarize(all)
+	res.DurationS = elapsed.Seconds()
+	res.Requests = overall.Requests
+	res.Errors = overall.Errors
+	res.ErrorRate = overall.ErrorRate
+	res.LatencyMs = overall.LatencyMs
+	if elapsed > 0 {
+		res.ThroughputRPS = float64(res.Requests) / elapsed.Seconds()
+	}
+	res.Histogram = histogram(all)
+	for i, t := range targets {
+		s := Summarize(perRoute[i])
+		res.Routes = append(res.Routes, LoadRouteResult{
+			RouteID:   t.route.ID,
+			Method:    t.route.Method,
+			Path:      t.route.Path,
+			Weight:    t.weight,
+			Requests:  s.Requests,
+			Errors:    s.Errors,
+			LatencyMs: s.LatencyMs,
+		})
+	}
+	sort.SliceStable(res.Routes, func(i, j int) bool { return res.Routes[i].Requests > res.Routes[j].Requests })
+	return res, nil
+}
+
+func histogram(samples []Sample) []HistogramBucket {
+	buckets := make([]HistogramBucket, len(histogramBoundsMs)+1)
+	for i, b := range histogramBoundsMs {
+		buckets[i].UpperMs = b
+	}
+	for _, s := range samples {
+		ms := durationMs(s.Duration)
+		i := sort.SearchFloat64s(histogramBoundsMs, ms)
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+func firstValidSet(plan profile.ParameterPlan) profile.ParameterSet {
+	if len(plan.Valid) == 0 {
+		return profile.ParameterSet{Name: "valid"}
+	}
+	return plan.Valid[0]
+}
+
+// FormatLoadResult renders a one-line summary followed by the histogram.
+func FormatLoadResult(r LoadResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "load: %d requests in %.1fs (%.1f req/s, errors=%.1f%%) p50=%.1fms p95=%.1fms p99=%.1fms", r.Requests, r.DurationS, r.ThroughputRPS, r.ErrorRate*100, r.LatencyMs.P50, r.LatencyMs.P95, r.LatencyMs.P99)
+	maxCount := 0
+	for _, h := range r.Histogram {
+		maxCount = max(maxCount, h.Count)
+	}
+	for _, h := range r.Histogram {
+		if h.Count == 0 {
+			continue
+		}
+		label := "+Inf"
+		if h.UpperMs > 0 {
+			label = strconv.FormatFloat(h.UpperMs, 'f', -1, 64)
+		}
+		bar := int(math.Round(float64(h.Count) / float64(maxCount) * 40))
+		fmt.Fprintf(&b, "\n  <=%6sms %7d %s", label, h.Count, strings.Repeat("#", bar))
+	}
+	return b.String()
+}
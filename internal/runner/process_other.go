@@ -0,0 +1,26 @@
+//go:build !unix
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Without process groups only the direct child is signalled and killed.
+// Where os.Interrupt cannot be sent, as on Windows, Stop kills right away.
+var defaultStopSignal = os.Interrupt
+
+func setProcessGroup(*exec.Cmd) {}
+
+func signalGroup(p *os.Process, sig os.Signal) error {
+	return p.Signal(sig)
+}
+
+func killGroup(p *os.Process) error {
+	return p.Kill()
+}
+
+func groupAlive(*os.Process) bool {
+	return false
+}
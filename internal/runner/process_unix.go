@@ -0,0 +1,38 @@
+//go:build unix
+
+package runner
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// defaultStopSignal asks the app and everything it spawned to shut down.
+var defaultStopSignal os.Signal = syscall.SIGTERM
+
+// setProcessGroup starts cmd as the leader of a new process group, so
+// grandchildren such as the node process under `npm run dev` can be stopped
+// with it.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalGroup sends sig to every process in p's group.
+func signalGroup(p *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return p.Signal(sig)
+	}
+	return syscall.Kill(-p.Pid, s)
+}
+
+func killGroup(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
+
+// groupAlive reports whether any process in p's group is still running.
+func groupAlive(p *os.Process) bool {
+	return !errors.Is(syscall.Kill(-p.Pid, 0), syscall.ESRCH)
+}
@@ -0,0 +1,141 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cool-code-cleanup/internal/discovery"
+	"cool-code-cleanup/internal/profile"
+)
+
+// ReplayResult compares one replayed HAR entry with its recording. Body is
+// the replayed response, kept for callers that diff it. Redacted marks a
+// request recorded with redacted query or body values, sent with REDACTED in
+// their place.
+type ReplayResult struct {
+	RouteID        string  `json:"route_id,omitempty"`
+	Variant        string  `json:"variant,omitempty"`
+	Method         string  `json:"method"`
+	URL            string  `json:"url"`
+	RecordedStatus int     `json:"recorded_status"`
+	Status         int     `json:"status"`
+	RecordedMs     float64 `json:"recorded_ms"`
+	DurationMs     float64 `json:"duration_ms"`
+	Bytes          int64   `json:"bytes"`
+	Error          string  `json:"error,omitempty"`
+	Redacted       bool    `json:"redacted,omitempty"`
+	Body           []byte  `json:"-"`
+}
+
+// StatusChanged reports whether the replay got a different status (or no
+// response at all).
+func (r ReplayResult) StatusChanged() bool {
+	return r.Error != "" || r.Status != r.RecordedStatus
+}
+
+// replaySkipHeaders are set by the client or the session rather than copied
+// from the recording.
+var replaySkipHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Connection":        true,
+	"Transfer-Encoding": true,
+	"Accept-Encoding":   true,
+	"Cookie":            true,
+}
+
+// Replay re-sends the entries of har, in order, to baseURL. Calls share
+// session like Execute's do, so cookies and tokens the app issues during the
+// replay replace the recorded ones, including any that were redacted; a nil
+// session uses DefaultTokenRules. Redacted headers nothing replaced are
+// dropped; redacted bodies and query values are sent as recorded, and their
+// results are marked Redacted.
+func Replay(baseURL string, har HAR, session *Session) []ReplayResult {
+	if session == nil {
+		session = NewSession(DefaultTokenRules())
+	}
+	client := session.Client(&http.Client{Timeout: 5 * time.Second})
+	var out []ReplayResult
+	for _, e := range har.Log.Entries {
+		res := ReplayResult{
+			RouteID:        e.RouteID,
+			Variant:        e.Variant,
+			Method:         e.Request.Method,
+			RecordedStatus: e.Response.Status,
+			RecordedMs:     e.Time,
+			Redacted:       e.Request.redacted(),
+		}
+		req, err := replayRequest(baseURL, e.Request)
+		if err != nil {
+			res.URL = e.Request.URL
+			res.Error = err.Error()
+			out = append(out, res)
+			continue
+		}
+		res.URL = req.URL.RequestURI()
+		have := map[string]bool{}
+		for _, c := range session.jar.Cookies(req.URL) {
+			have[c.Name] = true
+		}
+		for _, c := range e.Request.Cookies {
+			if c.Value != Redacted && !have[c.Name] {
+				req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+			}
+		}
+		session.Apply(req, profile.ParameterSet{})
+		for name, values := range req.Header {
+			if len(values) == 1 && values[0] == Redacted {
+				req.Header.Del(name)
+			}
+		}
+		resp, body, sample := roundTrip(client, req)
+		res.DurationMs = durationMs(sample.Duration)
+		res.Bytes = sample.Bytes
+		if sample.Err != nil {
+			res.Error = sample.Err.Error()
+			out = append(out, res)
+			continue
+		}
+		res.Status = resp.StatusCode
+		res.Body = body
+		session.Capture(discovery.Route{ID: e.RouteID, Method: req.Method, Path: req.URL.Path}, resp, body)
+		out = append(out, res)
+	}
+	return out
+}
+
+// replayRequest rebuilds a recorded request against baseURL, without its
+// cookies.
+func replayRequest(baseURL string, r HARRequest) (*http.Request, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", baseURL, err)
+	}
+	recorded, err := url.Parse(r.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recorded URL %q: %w", r.URL, err)
+	}
+	target := *recorded
+	target.Scheme, target.Host, target.User = base.Scheme, base.Host, nil
+	target.Path = strings.TrimRight(base.Path, "/") + recorded.Path
+	target.RawPath = ""
+
+	var body io.Reader
+	if r.PostData != nil && r.PostData.Text != "" {
+		body = strings.NewReader(r.PostData.Text)
+	}
+	req, err := http.NewRequest(r.Method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range r.Headers {
+		if name := http.CanonicalHeaderKey(h.Name); !replaySkipHeaders[name] {
+			req.Header.Add(name, h.Value)
+		}
+	}
+	return req, nil
+}
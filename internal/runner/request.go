@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+
+	"cool-code-cleanup/internal/discovery"
+	"cool-code-cleanup/internal/profile"
+)
+
+// djangoCSRFToken is sent as both the csrftoken cookie and the X-CSRFToken
+// header so Django's double-submit check passes for unsafe methods.
+const djangoCSRFToken = "cccprofilecsrftoken0000000000000"
+
+// BuildRequest turns a route and one of its parameter sets into a request:
+// path placeholders are substituted, query values encoded, headers set, and
+// body params encoded as JSON, form-urlencoded or multipart according to
+// plan.BodyEncoding.
+func BuildRequest(baseURL string, r discovery.Route, plan profile.ParameterPlan, set profile.ParameterSet) (*http.Request, error) {
+	method, path := profile.RoutePath(r.Path)
+	if method == "" {
+		method = r.Method
+	}
+	if method == "ANY" || method == "" {
+		method = http.MethodGet
+		if len(set.Body) > 0 {
+			method = http.MethodPost
+		}
+	}
+	target := strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(profile.SubstitutePath(path, set.Path), "/")
+	if len(set.Query) > 0 {
+		q := url.Values{}
+		for k, v := range set.Query {
+			q.Set(k, v)
+		}
+		target += "?" + q.Encode()
+	}
+
+	var body io.Reader
+	var contentType string
+	if len(set.Body) > 0 {
+		encoding := plan.BodyEncoding
+		if encoding == "" {
+			encoding = profile.EncodingJSON
+		}
+		var err error
+		body, contentType, err = encodeBody(encoding, set.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if r.Framework == "django" && method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions {
+		req.Header.Set("X-CSRFToken", djangoCSRFToken)
+		req.AddCookie(&http.Cookie{Name: "csrftoken", Value: djangoCSRFToken})
+	}
+	for k, v := range set.Header {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+func encodeBody(encoding string, values map[string]any) (io.Reader, string, error) {
+	switch encoding {
+	case profile.EncodingForm:
+		form := url.Values{}
+		for _, k := range sortedKeys(values) {
+			for _, v := range formValues(values[k]) {
+				form.Add(k, v)
+			}
+		}
+		return strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", nil
+	case profile.EncodingMultipart:
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for _, k := range sortedKeys(values) {
+			if filename, ctype, content, ok := profile.FileUpload(values[k]); ok {
+				h := textproto.MIMEHeader{}
+				h.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, k, filename))
+				if ctype == "" {
+					ctype = "application/octet-stream"
+				}
+				h.Set("Content-Type", ctype)
+				part, err := w.CreatePart(h)
+				if err != nil {
+					return nil, "", err
+				}
+				if _, err := io.WriteString(part, content); err != nil {
+					return nil, "", err
+				}
+				continue
+			}
+			for _, v := range formValues(values[k]) {
+				if err := w.WriteField(k, v); err != nil {
+					return nil, "", err
+				}
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, w.FormDataContentType(), nil
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, "", fmt.Errorf("encode request body: %w", err)
+	}
+	return bytes.NewReader(data), "application/json", nil
+}
+
+func formValues(v any) []string {
+	switch t := v.(type) {
+	case []any:
+		out := make([]string, 0, len(t))
+		for _, item := range t {
+			out = append(out, fmt.Sprint(item))
+		}
+		return out
+	case []string:
+		return t
+	case map[string]any:
+		data, _ := json.Marshal(t)
+		return []string{string(data)}
+	}
+	return []string{fmt.Sprint(v)}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -3,10 +3,13 @@ package runner
 import (
 	"context"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,57 +17,224 @@ import (
 	"cool-code-cleanup/internal/profile"
 )
 
+// Invocation outcomes. Valid variants are expected to succeed and invalid
+// variants to be rejected with a 4xx; anything else is flagged.
+const (
+	OutcomeOK              = "ok"
+	OutcomeExpected4xx     = "expected_4xx"
+	OutcomeUnexpected5xx   = "unexpected_5xx"
+	OutcomeAcceptedInvalid = "accepted_invalid_input"
+	OutcomeRejectedValid   = "rejected_valid_input"
+	OutcomeError           = "request_error"
+)
+
+// maxResponseBody caps how much of a response is kept for token capture;
+// the rest is read and counted but discarded.
+const maxResponseBody = 1 << 20
+
 type Invocation struct {
 	RouteID    string            `json:"route_id"`
 	Method     string            `json:"method"`
 	Path       string            `json:"path"`
+	URL        string            `json:"url,omitempty"`
+	Variant    string            `json:"variant"`
+	Invalid    bool              `json:"invalid"`
 	Parameters map[string]string `json:"parameters"`
+	Session    []string          `json:"session_tokens,omitempty"`
 	Success    bool              `json:"success"`
 	Status     int               `json:"status"`
+	DurationMs float64           `json:"duration_ms"`
+	Bytes      int64             `json:"bytes"`
+	Outcome    string            `json:"outcome"`
 	Error      string            `json:"error,omitempty"`
+	Logs       []string          `json:"logs,omitempty"`
+	StartedAt  time.Time         `json:"-"`
+	Body       []byte            `json:"-"`
+}
+
+// RouteFinding flags a route whose invocations crashed or accepted bad input.
+type RouteFinding struct {
+	RouteID         string   `json:"route_id"`
+	Method          string   `json:"method"`
+	Path            string   `json:"path"`
+	Crashes         []string `json:"crashes,omitempty"`
+	AcceptedInvalid []string `json:"accepted_invalid,omitempty"`
 }
 
 type AppProcess struct {
-	cmd *exec.Cmd
+	cmd       *exec.Cmd
+	stop      os.Signal
+	exited    chan struct{}
+	StartedAt time.Time
+	StoppedAt time.Time
 }
 
+// StartOptions adjusts how the app is launched. Command replaces the
+// framework heuristics and Dir (default: the project root) is where it runs
+// and where markers are looked for. The app is told its port through PORT,
+// and $PORT in the command expands to it. Env (KEY=VALUE pairs) is appended
+// to the current environment; Wrap, when set, rewrites the launch command,
+// e.g. to run it under a profiler. Output receives the app's stdout and
+// stderr (default: this process's). StopSignal replaces SIGTERM for tools
+// that only write their results on SIGINT.
+type StartOptions struct {
+	Command    []string
+	Dir        string
+	Port       int
+	Env        []string
+	Wrap       func(argv []string) []string
+	Output     io.Writer
+	StopSignal os.Signal
+}
+
+// Stop signals the app's process group so profilers and coverage tools can
+// flush their output, waits up to stopGrace for the whole group to exit and
+// then kills what is left. Stop is safe to call more than once.
 func (p *AppProcess) Stop() {
-	if p == nil || p.cmd == nil || p.cmd.Process == nil {
+	if p == nil || p.cmd == nil || p.cmd.Process == nil || !p.StoppedAt.IsZero() {
 		return
 	}
-	_ = p.cmd.Process.Kill()
-	_, _ = p.cmd.Process.Wait()
+	p.StoppedAt = time.Now()
+	if err := signalGroup(p.cmd.Process, p.stop); err != nil {
+		// The signal cannot be delivered (e.g. os.Interrupt on Windows), so
+		// waiting out the grace period would only delay the kill.
+		_ = killGroup(p.cmd.Process)
+		<-p.exited
+		return
+	}
+	deadline := time.After(stopGrace)
+	poll := time.NewTicker(50 * time.Millisecond)
+	defer poll.Stop()
+	for {
+		select {
+		case <-deadline:
+			_ = killGroup(p.cmd.Process)
+			<-p.exited
+			return
+		case <-poll.C:
+			select {
+			case <-p.exited:
+				if !groupAlive(p.cmd.Process) {
+					return
+				}
+			default:
+			}
+		}
+	}
 }
 
-func Start(projectRoot string) (*AppProcess, string) {
-	// Minimal heuristic startup command selection.
-	type candidate struct {
-		path string
-		cmd  []string
-	}
-	candidates := []candidate{
-		{path: filepath.Join(projectRoot, "package.json"), cmd: []string{"npm", "run", "dev"}},
-		{path: filepath.Join(projectRoot, "main.go"), cmd: []string{"go", "run", "."}},
-		{path: filepath.Join(projectRoot, "manage.py"), cmd: []string{"python", "manage.py", "runserver", "127.0.0.1:8000"}},
+// WaitForHealth is like the package-level WaitForHealth but gives up as soon
+// as the app exits.
+func (p *AppProcess) WaitForHealth(url string, status int, timeout time.Duration) bool {
+	return waitForHealth(url, status, timeout, p.exited)
+}
+
+const stopGrace = 5 * time.Second
+
+// startCandidate is a launch command used when its marker file exists.
+type startCandidate struct {
+	framework string
+	marker    string
+	cmd       []string
+}
+
+// DefaultPort is the port the app is expected on when none is configured.
+const DefaultPort = 8000
+
+// Minimal heuristic startup command selection.
+var startCandidates = []startCandidate{
+	{framework: "node", marker: "package.json", cmd: []string{"npm", "run", "dev"}},
+	{framework: "go", marker: "main.go", cmd: []string{"go", "run", "."}},
+	{framework: "django", marker: "manage.py", cmd: []string{"python", "manage.py", "runserver", "127.0.0.1:$PORT"}},
+}
+
+// AppFramework returns the framework Start would launch for projectRoot, or
+// "" when none matches.
+func AppFramework(projectRoot string) string {
+	for _, c := range startCandidates {
+		if _, err := os.Stat(filepath.Join(projectRoot, c.marker)); err == nil {
+			return c.framework
+		}
 	}
+	return ""
+}
 
-	for _, c := range candidates {
-		if _, err := os.Stat(c.path); err == nil {
-			if len(c.cmd) > 1 {
-				cmd := exec.Command(c.cmd[0], c.cmd[1:]...)
-				cmd.Dir = projectRoot
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-				if err := cmd.Start(); err == nil {
-					return &AppProcess{cmd: cmd}, strings.Join(c.cmd, " ")
-				}
+// Start launches opts.Command, or else the first app chosen by framework
+// heuristics that starts.
+func Start(projectRoot string, opts StartOptions) (*AppProcess, string) {
+	dir := projectRoot
+	if opts.Dir != "" {
+		dir = opts.Dir
+	}
+	port := opts.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	env := append(append(os.Environ(), opts.Env...), "PORT="+strconv.Itoa(port))
+	expand := strings.NewReplacer("${PORT}", strconv.Itoa(port), "$PORT", strconv.Itoa(port))
+	stopSignal := opts.StopSignal
+	if stopSignal == nil {
+		stopSignal = defaultStopSignal
+	}
+	commands := [][]string{opts.Command}
+	if len(opts.Command) == 0 {
+		commands = nil
+		for _, c := range startCandidates {
+			if _, err := os.Stat(filepath.Join(dir, c.marker)); err == nil {
+				commands = append(commands, c.cmd)
 			}
 		}
 	}
+	for _, c := range commands {
+		argv := make([]string, len(c))
+		for i, arg := range c {
+			argv[i] = expand.Replace(arg)
+		}
+		if opts.Wrap != nil {
+			argv = opts.Wrap(argv)
+		}
+		if len(argv) == 0 {
+			continue
+		}
+		cmd := exec.Command(argv[0], argv[1:]...)
+		cmd.Dir = dir
+		cmd.Env = env
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if opts.Output != nil {
+			cmd.Stdout, cmd.Stderr = opts.Output, opts.Output
+		}
+		// Grandchildren may hold the output pipe after the app exits.
+		cmd.WaitDelay = time.Second
+		setProcessGroup(cmd)
+		if err := cmd.Start(); err == nil {
+			p := &AppProcess{cmd: cmd, stop: stopSignal, exited: make(chan struct{}), StartedAt: time.Now()}
+			go func() {
+				_ = cmd.Wait()
+				close(p.exited)
+			}()
+			return p, strings.Join(argv, " ")
+		}
+	}
 	return nil, ""
 }
 
-func WaitForHealth(baseURL string, timeout time.Duration) bool {
+// FreePort returns a local TCP port that was free when asked.
+func FreePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("allocate port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// WaitForHealth polls url until it answers with status, or with any status
+// below 500 when status is 0, and reports whether it did within timeout.
+func WaitForHealth(url string, status int, timeout time.Duration) bool {
+	return waitForHealth(url, status, timeout, nil)
+}
+
+func waitForHealth(url string, status int, timeout time.Duration, exited <-chan struct{}) bool {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	t := time.NewTicker(500 * time.Millisecond)
@@ -74,15 +244,17 @@ func WaitForHealth(baseURL string, timeout time.Duration) bool {
 		select {
 		case <-ctx.Done():
 			return false
+		case <-exited:
+			return false
 		case <-t.C:
-			req, err := http.NewRequest(http.MethodGet, baseURL, nil)
+			req, err := http.NewRequest(http.MethodGet, url, nil)
 			if err != nil {
 				continue
 			}
 			resp, err := client.Do(req)
 			if err == nil {
 				_ = resp.Body.Close()
-				if resp.StatusCode >= 200 && resp.StatusCode < 500 {
+				if resp.StatusCode == status || (status == 0 && resp.StatusCode < 500) {
 					return true
 				}
 			}
@@ -90,7 +262,15 @@ func WaitForHealth(baseURL string, timeout time.Duration) bool {
 	}
 }
 
-func Execute(baseURL string, routes []discovery.Route, plans []profile.ParameterPlan, dependencies map[string][]string) []Invocation {
+// Execute invokes each route after its dependencies, sending every valid
+// parameter set and then every invalid one, and classifies each response.
+// Calls share session's cookie jar and captured tokens, so a login dependency
+// authenticates the routes after it; a nil session uses DefaultTokenRules.
+// When rec is not nil every exchange is recorded to it.
+func Execute(baseURL string, routes []discovery.Route, plans []profile.ParameterPlan, dependencies map[string][]string, session *Session, rec *HARRecorder) []Invocation {
+	if session == nil {
+		session = NewSession(DefaultTokenRules())
+	}
 	routeByID := map[string]discovery.Route{}
 	for _, r := range routes {
 		routeByID[r.ID] = r
@@ -100,6 +280,33 @@ func Execute(baseURL string, routes []discovery.Route, plans []profile.Parameter
 		planByID[p.RouteID] = p
 	}
 
+	client := session.Client(&http.Client{Timeout: 5 * time.Second})
+	if rec != nil {
+		client.Transport = rec.Transport(client.Transport)
+	}
+	var out []Invocation
+	for _, id := range executionOrder(routes, dependencies) {
+		r, ok := routeByID[id]
+		if !ok {
+			continue
+		}
+		p := planByID[id]
+		valid := p.Valid
+		if len(valid) == 0 {
+			valid = []profile.ParameterSet{{Name: "valid"}}
+		}
+		for _, set := range valid {
+			out = append(out, invoke(client, session, baseURL, r, p, set, false))
+		}
+		for _, set := range p.Invalid {
+			out = append(out, invoke(client, session, baseURL, r, p, set, true))
+		}
+	}
+	return out
+}
+
+// executionOrder lists route IDs with each route's dependencies before it.
+func executionOrder(routes []discovery.Route, dependencies map[string][]string) []string {
 	var order []string
 	seen := map[string]bool{}
 	for _, r := range routes {
@@ -114,48 +321,98 @@ func Execute(baseURL string, routes []discovery.Route, plans []profile.Parameter
 			seen[r.ID] = true
 		}
 	}
+	return order
+}
 
-	client := &http.Client{Timeout: 5 * time.Second}
-	var out []Invocation
-	for _, id := range order {
-		r, ok := routeByID[id]
-		if !ok {
+func invoke(client *http.Client, session *Session, baseURL string, r discovery.Route, plan profile.ParameterPlan, set profile.ParameterSet, invalid bool) Invocation {
+	inv := Invocation{
+		RouteID:    r.ID,
+		Method:     r.Method,
+		Path:       r.Path,
+		Variant:    set.Name,
+		Invalid:    invalid,
+		Parameters: set.Flatten(),
+	}
+	req, err := BuildRequest(baseURL, r, plan, set)
+	if err != nil {
+		inv.Error = err.Error()
+		inv.Outcome = OutcomeError
+		return inv
+	}
+	inv.Session = session.Apply(req, set)
+	req = withHARLabel(req, r.ID, set.Name)
+	inv.Method = req.Method
+	inv.URL = req.URL.RequestURI()
+	inv.StartedAt = time.Now()
+	resp, body, res := roundTrip(client, req)
+	inv.DurationMs = durationMs(res.Duration)
+	inv.Bytes = res.Bytes
+	if res.Err != nil {
+		inv.Error = res.Err.Error()
+		inv.Outcome = OutcomeError
+		return inv
+	}
+	if !invalid {
+		session.Capture(r, resp, body)
+	}
+	inv.Status = resp.StatusCode
+	inv.Body = body
+	inv.Outcome = Classify(resp.StatusCode, invalid)
+	inv.Success = inv.Outcome == OutcomeOK || inv.Outcome == OutcomeExpected4xx
+	return inv
+}
+
+// roundTrip sends req and reads the whole response, keeping up to
+// maxResponseBody bytes of it. The sample's duration covers the full body.
+func roundTrip(client *http.Client, req *http.Request) (*http.Response, []byte, Sample) {
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, Sample{Duration: time.Since(start), Err: err}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	rest, _ := io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	sample := Sample{Duration: time.Since(start), Bytes: int64(len(body)) + rest, Status: resp.StatusCode, Err: err}
+	return resp, body, sample
+}
+
+// Classify maps a response status to an outcome for a valid or invalid
+// variant.
+func Classify(status int, invalid bool) string {
+	switch {
+	case status >= 500:
+		return OutcomeUnexpected5xx
+	case status >= 400 && invalid:
+		return OutcomeExpected4xx
+	case status >= 400:
+		return OutcomeRejectedValid
+	case invalid:
+		return OutcomeAcceptedInvalid
+	}
+	return OutcomeOK
+}
+
+// Findings lists routes with 5xx responses or accepted invalid input, in
+// invocation order.
+func Findings(invocations []Invocation) []RouteFinding {
+	var out []RouteFinding
+	idx := map[string]int{}
+	for _, inv := range invocations {
+		if inv.Outcome != OutcomeUnexpected5xx && inv.Outcome != OutcomeAcceptedInvalid {
 			continue
 		}
-		p := planByID[id]
-		valid := map[string]string{}
-		if len(p.Valid) > 0 {
-			valid = p.Valid[0]
-		}
-		method := r.Method
-		if method == "ANY" {
-			method = http.MethodGet
-		}
-		url := strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(r.Path, "/")
-		req, reqErr := http.NewRequest(method, url, nil)
-		inv := Invocation{
-			RouteID:    r.ID,
-			Method:     method,
-			Path:       r.Path,
-			Parameters: valid,
-		}
-		if reqErr != nil {
-			inv.Error = reqErr.Error()
-			inv.Success = false
-			out = append(out, inv)
-			continue
+		i, ok := idx[inv.RouteID]
+		if !ok {
+			i = len(out)
+			idx[inv.RouteID] = i
+			out = append(out, RouteFinding{RouteID: inv.RouteID, Method: inv.Method, Path: inv.Path})
 		}
-		resp, err := client.Do(req)
-		if err != nil {
-			inv.Error = err.Error()
-			inv.Success = false
-			out = append(out, inv)
-			continue
+		if inv.Outcome == OutcomeUnexpected5xx {
+			out[i].Crashes = append(out[i].Crashes, inv.Variant)
+		} else {
+			out[i].AcceptedInvalid = append(out[i].AcceptedInvalid, inv.Variant)
 		}
-		inv.Status = resp.StatusCode
-		inv.Success = resp.StatusCode >= 200 && resp.StatusCode < 400
-		_ = resp.Body.Close()
-		out = append(out, inv)
 	}
 	return out
 }
@@ -165,5 +422,5 @@ func FormatInvocation(inv Invocation) string {
 	if inv.Success {
 		check = "✓"
 	}
-	return fmt.Sprintf("%s %s %s params=%v status=%d", check, inv.Method, inv.Path, inv.Parameters, inv.Status)
+	return fmt.Sprintf("%s %s %s [%s] params=%v status=%d outcome=%s %.1fms", check, inv.Method, inv.Path, inv.Variant, inv.Parameters, inv.Status, inv.Outcome, inv.DurationMs)
 }
@@ -0,0 +1,554 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cool-code-cleanup/internal/discovery"
+	"cool-code-cleanup/internal/profile"
+)
+
+func TestExecuteSendsEveryVariantAndClassifies(t *testing.T) {
+	statuses := []int{200, 422, 200, 500}
+	var mu sync.Mutex
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		status := statuses[calls%len(statuses)]
+		calls++
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	route := discovery.Route{ID: "r1", Method: "POST", Path: "/orders"}
+	plan := profile.ParameterPlan{
+		RouteID: "r1",
+		Valid:   []profile.ParameterSet{{Name: "valid"}},
+		Invalid: []profile.ParameterSet{
+			{Name: "wrong_type:body.qty", Kind: profile.InvalidWrongType},
+			{Name: "missing_required:body.email", Kind: profile.InvalidMissingRequired},
+			{Name: "out_of_range:body.qty", Kind: profile.InvalidOutOfRange},
+		},
+	}
+	invs := Execute(srv.URL, []discovery.Route{route}, []profile.ParameterPlan{plan}, nil, nil, nil)
+	if len(invs) != 4 {
+		t.Fatalf("expected 4 invocations, got %d", len(invs))
+	}
+	want := []string{OutcomeOK, OutcomeExpected4xx, OutcomeAcceptedInvalid, OutcomeUnexpected5xx}
+	for i, inv := range invs {
+		if inv.Outcome != want[i] {
+			t.Fatalf("invocation %d (%s): outcome=%s want %s", i, inv.Variant, inv.Outcome, want[i])
+		}
+	}
+	if !invs[1].Success || invs[2].Success {
+		t.Fatalf("expected rejected invalid input to count as success: %+v", invs)
+	}
+
+	findings := Findings(invs)
+	if len(findings) != 1 {
+		t.Fatalf("expected one flagged route, got %+v", findings)
+	}
+	f := findings[0]
+	if len(f.AcceptedInvalid) != 1 || f.AcceptedInvalid[0] != "missing_required:body.email" || len(f.Crashes) != 1 || f.Crashes[0] != "out_of_range:body.qty" {
+		t.Fatalf("unexpected finding: %+v", f)
+	}
+}
+
+func TestClassifyValidRejection(t *testing.T) {
+	if got := Classify(404, false); got != OutcomeRejectedValid {
+		t.Fatalf("expected rejected valid input, got %s", got)
+	}
+	if got := Classify(503, true); got != OutcomeUnexpected5xx {
+		t.Fatalf("expected 5xx for invalid variant, got %s", got)
+	}
+}
+
+func TestBuildRequestEncodesPathQueryHeadersAndBody(t *testing.T) {
+	set := profile.ParameterSet{
+		Path:   map[string]string{"id": "42", "slug": "a b"},
+		Query:  map[string]string{"limit": "10", "q": "x&y"},
+		Header: map[string]string{"X-Api-Key": "k"},
+		Body:   map[string]any{"email": "user@example.com", "qty": int64(2)},
+	}
+	req, err := BuildRequest("http://127.0.0.1:8000/", discovery.Route{Method: "POST", Path: "/items/:id/:slug", Framework: "node"}, profile.ParameterPlan{BodyEncoding: profile.EncodingJSON}, set)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if req.URL.RequestURI() != "/items/42/a%20b?limit=10&q=x%26y" {
+		t.Fatalf("unexpected URI %s", req.URL.RequestURI())
+	}
+	if req.Header.Get("Content-Type") != "application/json" || req.Header.Get("X-Api-Key") != "k" {
+		t.Fatalf("unexpected headers %v", req.Header)
+	}
+	var body map[string]any
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body["qty"] != float64(2) {
+		t.Fatalf("unexpected JSON body %v err=%v", body, err)
+	}
+
+	req, err = BuildRequest("http://h", discovery.Route{Method: "ANY", Path: "POST /orders/{orderId}", Framework: "go"}, profile.ParameterPlan{BodyEncoding: profile.EncodingForm}, profile.ParameterSet{
+		Path: map[string]string{"orderId": "7"},
+		Body: map[string]any{"tags": []any{"a", "b"}},
+	})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	raw, _ := io.ReadAll(req.Body)
+	if req.Method != http.MethodPost || req.URL.Path != "/orders/7" || string(raw) != "tags=a&tags=b" {
+		t.Fatalf("unexpected form request %s %s %q", req.Method, req.URL.Path, raw)
+	}
+
+	upload := map[string]any{"filename": "avatar.txt", "content_type": "text/plain", "content": "hi"}
+	req, err = BuildRequest("http://h", discovery.Route{Method: "ANY", Path: "/profile/<int:pk>", Framework: "django"}, profile.ParameterPlan{BodyEncoding: profile.EncodingMultipart}, profile.ParameterSet{
+		Path: map[string]string{"pk": "3"},
+		Body: map[string]any{"avatar": upload, "name": "n"},
+	})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if req.URL.Path != "/profile/3" || req.Header.Get("X-CSRFToken") == "" {
+		t.Fatalf("unexpected django request %s %v", req.URL.Path, req.Header)
+	}
+	if c, err := req.Cookie("csrftoken"); err != nil || c.Value != req.Header.Get("X-CSRFToken") {
+		t.Fatalf("expected matching csrftoken cookie, got %v %v", c, err)
+	}
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatalf("parse multipart: %v", err)
+	}
+	if req.FormValue("name") != "n" || len(req.MultipartForm.File["avatar"]) != 1 || req.MultipartForm.File["avatar"][0].Filename != "avatar.txt" {
+		t.Fatalf("unexpected multipart form %+v", req.MultipartForm)
+	}
+}
+
+func TestExecutePropagatesCookiesAndCapturedTokens(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s1", Path: "/"})
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"data":{"access_token":"tok-1"}}`)
+		case "/me":
+			c, err := r.Cookie("sid")
+			if err != nil || c.Value != "s1" || r.Header.Get("Authorization") != "Bearer tok-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	login := discovery.Route{ID: "login", Method: "POST", Path: "/auth/login"}
+	me := discovery.Route{ID: "me", Method: "GET", Path: "/me"}
+	deps := map[string][]string{"me": {"login"}}
+	session := NewSession(DefaultTokenRules())
+	invs := Execute(srv.URL, []discovery.Route{me, login}, nil, deps, session, nil)
+	if len(invs) != 2 || invs[0].RouteID != "login" {
+		t.Fatalf("expected login to run first, got %+v", invs)
+	}
+	if invs[1].Status != http.StatusOK || len(invs[1].Session) != 1 || invs[1].Session[0] != "bearer" {
+		t.Fatalf("expected authenticated call to /me, got %+v", invs[1])
+	}
+	summary := session.Summary()
+	if len(summary) != 1 || summary[0].RouteID != "login" || summary[0].InjectHeader != "Authorization" {
+		t.Fatalf("unexpected session summary %+v", summary)
+	}
+
+	// Without a session's captured token or jar, /me is rejected.
+	invs = Execute(srv.URL, []discovery.Route{me}, nil, nil, NewSession(nil), nil)
+	if invs[0].Status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without session, got %d", invs[0].Status)
+	}
+}
+
+func TestSessionHeaderRuleWithCustomInjection(t *testing.T) {
+	session := NewSession([]TokenRule{{Name: "api-key", Route: "POST /keys", From: TokenFromHeader, Header: "X-New-Key", InjectHeader: "X-Api-Key"}})
+	resp := &http.Response{StatusCode: 201, Header: http.Header{"X-New-Key": []string{"k-9"}}}
+	session.Capture(discovery.Route{ID: "other", Method: "POST", Path: "/other"}, resp, nil)
+	session.Capture(discovery.Route{ID: "keys", Method: "POST", Path: "/keys"}, resp, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "http://h/items", nil)
+	if got := session.Apply(req, profile.ParameterSet{}); len(got) != 1 || req.Header.Get("X-Api-Key") != "k-9" {
+		t.Fatalf("expected injected api key, got %v %v", got, req.Header)
+	}
+	req = httptest.NewRequest(http.MethodGet, "http://h/items", nil)
+	req.Header.Set("X-Api-Key", "explicit")
+	if got := session.Apply(req, profile.ParameterSet{Header: map[string]string{"X-Api-Key": "explicit"}}); len(got) != 0 || req.Header.Get("X-Api-Key") != "explicit" {
+		t.Fatalf("explicit header should win, got %v %v", got, req.Header)
+	}
+	req = httptest.NewRequest(http.MethodGet, "http://h/items", nil)
+	missing := profile.ParameterSet{Name: "missing_required:header.x-api-key", Kind: profile.InvalidMissingRequired, Param: "x-api-key"}
+	if got := session.Apply(req, missing); len(got) != 0 || req.Header.Get("X-Api-Key") != "" {
+		t.Fatalf("a variant omitting the header must not get it injected, got %v %v", got, req.Header)
+	}
+}
+
+func TestMeasureRunsWarmupAndIterationsConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	inFlight, maxInFlight := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		n := calls[r.URL.Path]
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		if r.URL.Path == "/flaky" && n%2 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer srv.Close()
+
+	routes := []discovery.Route{{ID: "a", Method: "GET", Path: "/ok"}, {ID: "b", Method: "GET", Path: "/flaky"}}
+	stats := Measure(srv.URL, routes, nil, nil, nil, MeasureOptions{Iterations: 8, Warmup: 2, Concurrency: 4})
+	if len(stats) != 2 {
+		t.Fatalf("expected stats for 2 routes, got %+v", stats)
+	}
+	if calls["/ok"] != 10 || calls["/flaky"] != 10 {
+		t.Fatalf("expected warm-up plus measured calls, got %v", calls)
+	}
+	if maxInFlight < 2 {
+		t.Fatalf("expected concurrent calls, max in flight %d", maxInFlight)
+	}
+	byPath := map[string]RouteStats{}
+	for _, s := range stats {
+		byPath[s.Path] = s
+	}
+	ok := byPath["/ok"]
+	if ok.Requests != 8 || ok.Errors != 0 || ok.ResponseBytes.Mean != 5 || ok.LatencyMs.Min < 5 || ok.LatencyMs.P99 < ok.LatencyMs.P50 {
+		t.Fatalf("unexpected /ok stats %+v", ok)
+	}
+	if flaky := byPath["/flaky"]; flaky.ErrorRate != 0.5 {
+		t.Fatalf("expected 50%% error rate, got %+v", flaky)
+	}
+}
+
+func TestSummarizePercentiles(t *testing.T) {
+	var samples []Sample
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, Sample{Duration: time.Duration(i) * time.Millisecond, Bytes: int64(i), Status: 200})
+	}
+	s := Summarize(samples)
+	l := s.LatencyMs
+	if l.Min != 1 || l.P50 != 50 || l.P95 != 95 || l.P99 != 99 || l.Max != 100 || l.Mean != 50.5 {
+		t.Fatalf("unexpected latency stats %+v", l)
+	}
+	if s.ResponseBytes.Min != 1 || s.ResponseBytes.Max != 100 || s.Errors != 0 {
+		t.Fatalf("unexpected size/error stats %+v", s)
+	}
+}
+
+func TestLoadRunsSetupAndWeightedMix(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/login":
+			_, _ = io.WriteString(w, `{"token":"t"}`)
+		default:
+			if r.Header.Get("Authorization") != "Bearer t" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	routes := []discovery.Route{
+		{ID: "login", Method: "POST", Path: "/login"},
+		{ID: "a", Method: "GET", Path: "/a"},
+		{ID: "b", Method: "GET", Path: "/b"},
+	}
+	deps := map[string][]string{"a": {"login"}, "b": {"login"}}
+	mix, err := ParseLoadMix([]string{"GET /a=3", "/b"})
+	if err != nil {
+		t.Fatalf("parse mix: %v", err)
+	}
+	res, err := Load(srv.URL, routes, nil, deps, nil, LoadOptions{Targets: mix, Duration: 200 * time.Millisecond, Concurrency: 4})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if calls["/login"] != 1 || len(res.Setup) != 1 {
+		t.Fatalf("expected login to run once as setup, got calls=%v setup=%v", calls, res.Setup)
+	}
+	if res.Errors != 0 || res.Requests < 20 || res.ThroughputRPS <= 0 {
+		t.Fatalf("unexpected load result %+v", res)
+	}
+	if len(res.Routes) != 2 || res.Routes[0].Path != "/a" || res.Routes[0].Requests <= res.Routes[1].Requests {
+		t.Fatalf("expected /a to dominate the 3:1 mix, got %+v", res.Routes)
+	}
+	counted := 0
+	for _, h := range res.Histogram {
+		counted += h.Count
+	}
+	if counted != res.Requests {
+		t.Fatalf("histogram counts %d requests, want %d", counted, res.Requests)
+	}
+
+	res, err = Load(srv.URL, routes, nil, deps, nil, LoadOptions{Targets: mix, Duration: 300 * time.Millisecond, RPS: 50})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if res.Requests < 5 || res.Requests > 20 {
+		t.Fatalf("expected about 15 requests at 50 rps, got %d", res.Requests)
+	}
+
+	if _, err := ParseLoadMix([]string{"GET /a=0"}); err == nil {
+		t.Fatalf("expected invalid weight error")
+	}
+	if _, err := Load(srv.URL, routes, nil, deps, nil, LoadOptions{Targets: []LoadTarget{{Route: "/missing", Weight: 1}}, Duration: time.Millisecond}); err == nil {
+		t.Fatalf("expected unmatched mix route error")
+	}
+	for _, rps := range []float64{-1, 2e9} {
+		if _, err := Load(srv.URL, routes, nil, deps, nil, LoadOptions{Targets: mix, Duration: time.Millisecond, RPS: rps}); err == nil {
+			t.Fatalf("expected an error for %g rps", rps)
+		}
+	}
+}
+
+func TestReadEnvFileSkipsCommentsAndUnquotes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	data := "# local settings\n\nexport DB_URL=\"postgres://app@localhost/app\"\nSECRET='a b'\nDEBUG=1\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadEnvFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	want := []string{"DB_URL=postgres://app@localhost/app", "SECRET=a b", "DEBUG=1"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("unexpected env:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestWaitForHealthMatchesExpectedStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ready" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	if !WaitForHealth(srv.URL+"/ready", http.StatusNoContent, 2*time.Second) {
+		t.Fatalf("expected /ready to report healthy")
+	}
+	if WaitForHealth(srv.URL+"/other", 0, time.Second) {
+		t.Fatalf("expected 503 not to count as healthy")
+	}
+}
+
+func TestStartRunsConfiguredCommandWithPort(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	dir := t.TempDir()
+	proc, cmd := Start(t.TempDir(), StartOptions{
+		Command: []string{"sh", "-c", "echo \"$1 $GREETING $(printenv PORT)\" > out.txt", "sh", "$PORT"},
+		Dir:     dir,
+		Port:    4321,
+		Env:     []string{"GREETING=hi", "PORT=1"},
+	})
+	if proc == nil {
+		t.Fatalf("expected the command to start")
+	}
+	<-proc.exited
+	if !strings.Contains(cmd, "sh 4321") {
+		t.Fatalf("expected $PORT to expand in the command, got %q", cmd)
+	}
+	out, err := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if err != nil {
+		t.Fatalf("expected the command to run in Dir: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "4321 hi 4321" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestBuildGoAddsFilesWithoutTouchingTheProject(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	root, tmp := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/app\n\ngo 1.21\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n\nvar greeting string\n\nfunc main() { println(greeting) }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(tmp, "app")
+	hook := map[string]string{"zz_hook.go": "package main\n\nfunc init() { greeting = \"hooked\" }\n"}
+	if err := BuildGo(root, binary, filepath.Join(tmp, "build"), nil, hook); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	out, err := exec.Command(binary).CombinedOutput()
+	if err != nil || strings.TrimSpace(string(out)) != "hooked" {
+		t.Fatalf("expected the overlay file in the binary, got %q (%v)", out, err)
+	}
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 2 {
+		t.Fatalf("expected the project left untouched, got %v", entries)
+	}
+}
+
+func TestStopTerminatesTheWholeProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are unix-only")
+	}
+	out := &bytes.Buffer{}
+	var mu sync.Mutex
+	proc, _ := Start(t.TempDir(), StartOptions{
+		Command: []string{"sh", "-c", "sleep 60 & echo started; wait"},
+		Output:  writerFunc(func(p []byte) (int, error) { mu.Lock(); defer mu.Unlock(); return out.Write(p) }),
+	})
+	if proc == nil {
+		t.Fatalf("expected the command to start")
+	}
+	for i := 0; i < 100; i++ {
+		mu.Lock()
+		ready := strings.Contains(out.String(), "started")
+		mu.Unlock()
+		if ready {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	proc.Stop()
+	// Killed children linger as zombies until init reaps them.
+	for i := 0; i < 100 && groupAlive(proc.cmd.Process); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if groupAlive(proc.cmd.Process) {
+		t.Fatalf("expected the background sleep to be stopped with its parent")
+	}
+}
+
+func TestCorrelateLogsAssignsLinesToTheRequestThatLoggedThem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs", "r1", "app.log")
+	log, err := OpenAppLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = log.Write([]byte("GET /a 200\n"))
+	time.Sleep(5 * time.Millisecond)
+	_, _ = log.Write([]byte("GET /b panic: boom\ngoroutine 1"))
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := log.Lines(time.Time{}, time.Now().Add(time.Hour))
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines including the flushed partial one, got %+v", lines)
+	}
+	invocations := []Invocation{
+		{Path: "/a", StartedAt: lines[0].Time, DurationMs: 1},
+		{Path: "/b", StartedAt: lines[1].Time, DurationMs: 1},
+	}
+	CorrelateLogs(invocations, log)
+	if !slices.Equal(invocations[0].Logs, []string{"GET /a 200"}) {
+		t.Fatalf("unexpected /a logs %v", invocations[0].Logs)
+	}
+	if !slices.Equal(invocations[1].Logs, []string{"GET /b panic: boom", "goroutine 1"}) {
+		t.Fatalf("unexpected /b logs %v", invocations[1].Logs)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || strings.Count(string(data), "\n") != 3 || !strings.Contains(string(data), "Z GET /a 200\n") {
+		t.Fatalf("unexpected log file %q (%v)", data, err)
+	}
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestExecuteRecordsRedactedHARThatReplays(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/login":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"access_token":"tok-1","user":"ann"}`)
+		case "/me":
+			if r.Header.Get("Authorization") != "Bearer tok-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = io.WriteString(w, "ann")
+		}
+	}))
+	defer srv.Close()
+
+	login := discovery.Route{ID: "login", Method: "POST", Path: "/auth/login"}
+	me := discovery.Route{ID: "me", Method: "GET", Path: "/me"}
+	plans := []profile.ParameterPlan{{RouteID: "login", BodyEncoding: "json", Valid: []profile.ParameterSet{{
+		Name: "valid",
+		Body: map[string]any{"user": "ann", "password": "hunter2"},
+	}}}}
+	rec := NewHARRecorder(Redaction{Headers: []string{"Authorization"}, Fields: []string{"password", "access_token"}})
+	invs := Execute(srv.URL, []discovery.Route{me, login}, plans, map[string][]string{"me": {"login"}}, NewSession(DefaultTokenRules()), rec)
+	if len(invs) != 2 || invs[1].Status != http.StatusOK {
+		t.Fatalf("unexpected invocations %+v", invs)
+	}
+
+	path := filepath.Join(t.TempDir(), "traffic.har")
+	if err := rec.Save(path); err != nil {
+		t.Fatalf("save har: %v", err)
+	}
+	raw, _ := os.ReadFile(path)
+	for _, secret := range []string{"hunter2", "tok-1"} {
+		if bytes.Contains(raw, []byte(secret)) {
+			t.Fatalf("expected %q to be redacted from the HAR:\n%s", secret, raw)
+		}
+	}
+	har, err := LoadHAR(path)
+	if err != nil {
+		t.Fatalf("load har: %v", err)
+	}
+	entries := har.Log.Entries
+	if har.Log.Version != "1.2" || len(entries) != 2 || entries[0].RouteID != "login" || entries[1].Variant != "valid" {
+		t.Fatalf("unexpected HAR log %+v", har.Log)
+	}
+	if !strings.Contains(entries[0].Request.PostData.Text, `"user":"ann"`) {
+		t.Fatalf("expected non-secret fields to be kept, got %q", entries[0].Request.PostData.Text)
+	}
+
+	// Replay logs in again, so /me gets the fresh token instead of the
+	// redacted one.
+	results := Replay(srv.URL, har, nil)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 replay results, got %+v", results)
+	}
+	for _, r := range results {
+		if r.StatusChanged() {
+			t.Fatalf("expected replay to match the recording, got %+v", r)
+		}
+	}
+	if string(results[1].Body) != "ann" {
+		t.Fatalf("expected authenticated replay of /me, got %q", results[1].Body)
+	}
+	if redacted := har.RedactedRequests(); len(redacted) != 1 || redacted[0].RouteID != "login" || !results[0].Redacted || results[1].Redacted {
+		t.Fatalf("expected the login to be marked as replayed with redacted values, got %+v and %+v", redacted, results)
+	}
+}
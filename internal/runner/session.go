@@ -0,0 +1,239 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cool-code-cleanup/internal/dependency"
+	"cool-code-cleanup/internal/discovery"
+	"cool-code-cleanup/internal/profile"
+)
+
+const SessionSchemaVersion = 1
+
+// Token sources.
+const (
+	TokenFromJSON   = "json"
+	TokenFromHeader = "header"
+)
+
+// TokenRule captures a value from a successful response and injects it into
+// every later request. Route accepts a route ID, "METHOD /path" or a bare
+// "/path" (with path.Match globs); empty matches any route. Injection
+// defaults to "Authorization: Bearer <token>".
+type TokenRule struct {
+	Name         string `json:"name"`
+	Route        string `json:"route,omitempty"`
+	From         string `json:"from"`
+	JSONPath     string `json:"json_path,omitempty"`
+	Header       string `json:"header,omitempty"`
+	InjectHeader string `json:"inject_header,omitempty"`
+	Prefix       string `json:"prefix,omitempty"`
+}
+
+type SessionConfig struct {
+	SchemaVersion int         `json:"schema_version"`
+	Rules         []TokenRule `json:"rules"`
+}
+
+// CapturedToken records where a token came from. Token values are never
+// reported.
+type CapturedToken struct {
+	Name         string `json:"name"`
+	RouteID      string `json:"route_id"`
+	From         string `json:"from"`
+	InjectHeader string `json:"inject_header"`
+}
+
+// Session is the per-run state shared by profiled calls: a cookie jar and the
+// tokens captured so far.
+type Session struct {
+	jar    http.CookieJar
+	rules  []TokenRule
+	mu     sync.Mutex
+	tokens map[string]capturedValue
+	order  []string
+}
+
+type capturedValue struct {
+	CapturedToken
+	value string
+}
+
+func DefaultSessionPath() string {
+	return filepath.Join(".ccc", "session.json")
+}
+
+// LoadSessionConfig reads an optional session rules file. A missing file is
+// not an error.
+func LoadSessionConfig(path string) (*SessionConfig, bool, error) {
+	clean := filepath.Clean(path)
+	data, err := os.ReadFile(clean)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("read session rules %s: %w", clean, err)
+	}
+	var c SessionConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, false, fmt.Errorf("parse session rules %s: %w", clean, err)
+	}
+	if c.SchemaVersion != SessionSchemaVersion {
+		return nil, false, fmt.Errorf("unsupported session rules schema_version=%d (expected %d)", c.SchemaVersion, SessionSchemaVersion)
+	}
+	for i, r := range c.Rules {
+		switch {
+		case r.Name == "":
+			return nil, false, fmt.Errorf("session rule %d: name is required", i+1)
+		case r.From == TokenFromJSON && r.JSONPath == "":
+			return nil, false, fmt.Errorf("session rule %s: json_path is required", r.Name)
+		case r.From == TokenFromHeader && r.Header == "":
+			return nil, false, fmt.Errorf("session rule %s: header is required", r.Name)
+		case r.From != TokenFromJSON && r.From != TokenFromHeader:
+			return nil, false, fmt.Errorf("session rule %s: from must be %q or %q", r.Name, TokenFromJSON, TokenFromHeader)
+		}
+	}
+	return &c, true, nil
+}
+
+// DefaultTokenRules capture common bearer token fields from any JSON
+// response. They share one name, so the latest token wins.
+func DefaultTokenRules() []TokenRule {
+	var out []TokenRule
+	for _, p := range []string{"access_token", "accessToken", "token", "jwt", "id_token", "data.access_token", "data.token"} {
+		out = append(out, TokenRule{Name: "bearer", From: TokenFromJSON, JSONPath: p})
+	}
+	return out
+}
+
+func NewSession(rules []TokenRule) *Session {
+	jar, _ := cookiejar.New(nil)
+	return &Session{jar: jar, rules: rules, tokens: map[string]capturedValue{}}
+}
+
+// Client returns an HTTP client that shares the session's cookie jar.
+func (s *Session) Client(base *http.Client) *http.Client {
+	c := *base
+	c.Jar = s.jar
+	return &c
+}
+
+// Apply injects captured tokens into req, leaving headers the parameter set
+// defines explicitly, or omits as a missing_required variant, untouched, and
+// returns the names of injected tokens.
+// When the jar holds a Django csrftoken (rotated on login), it replaces the
+// placeholder cookie and header BuildRequest set.
+func (s *Session) Apply(req *http.Request, set profile.ParameterSet) []string {
+	if req.Header.Get("X-CSRFToken") != "" && set.Header["X-CSRFToken"] == "" {
+		for _, c := range s.jar.Cookies(req.URL) {
+			if c.Name == "csrftoken" {
+				req.Header.Del("Cookie")
+				req.Header.Set("X-CSRFToken", c.Value)
+			}
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var injected []string
+	for _, name := range s.order {
+		t := s.tokens[name]
+		if _, explicit := set.Header[t.InjectHeader]; explicit {
+			continue
+		}
+		if omitted := set.OmittedHeader(); omitted != "" && strings.EqualFold(omitted, t.InjectHeader) {
+			continue
+		}
+		req.Header.Set(t.InjectHeader, t.value)
+		injected = append(injected, name)
+	}
+	return injected
+}
+
+// Capture applies the rules matching r to a successful response.
+func (s *Session) Capture(r discovery.Route, resp *http.Response, body []byte) {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return
+	}
+	var decoded any
+	decodedOK := json.Unmarshal(body, &decoded) == nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rule := range s.rules {
+		if rule.Route != "" && len(dependency.MatchRoutes([]discovery.Route{r}, rule.Route)) == 0 {
+			continue
+		}
+		var value string
+		switch rule.From {
+		case TokenFromHeader:
+			value = resp.Header.Get(rule.Header)
+		default:
+			if decodedOK {
+				value = lookupJSONPath(decoded, rule.JSONPath)
+			}
+		}
+		if value == "" {
+			continue
+		}
+		header := rule.InjectHeader
+		if header == "" {
+			header = "Authorization"
+		}
+		prefix := rule.Prefix
+		if prefix == "" && strings.EqualFold(header, "Authorization") && !strings.Contains(value, " ") {
+			prefix = "Bearer "
+		}
+		if _, ok := s.tokens[rule.Name]; !ok {
+			s.order = append(s.order, rule.Name)
+		}
+		s.tokens[rule.Name] = capturedValue{
+			CapturedToken: CapturedToken{Name: rule.Name, RouteID: r.ID, From: rule.From, InjectHeader: header},
+			value:         prefix + value,
+		}
+	}
+}
+
+// Summary lists captured tokens in capture order, without their values.
+func (s *Session) Summary() []CapturedToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]CapturedToken, 0, len(s.order))
+	for _, name := range s.order {
+		out = append(out, s.tokens[name].CapturedToken)
+	}
+	return out
+}
+
+// lookupJSONPath resolves a dot path such as "data.tokens.0.value" to a
+// string or number.
+func lookupJSONPath(v any, path string) string {
+	for _, key := range strings.Split(path, ".") {
+		switch t := v.(type) {
+		case map[string]any:
+			v = t[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(t) {
+				return ""
+			}
+			v = t[i]
+		default:
+			return ""
+		}
+	}
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return ""
+}
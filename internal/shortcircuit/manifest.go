@@ -0,0 +1,373 @@
+package shortcircuit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	StatusApplied  = "applied"
+	StatusReverted = "reverted"
+	StatusDiverged = "diverged"
+	StatusMissing  = "missing"
+)
+
+// Hunk is one inserted block; Offset and Line refer to the patched file.
+// Import marks an added import, which patches stacked later may rely on.
+type Hunk struct {
+	Offset int    `json:"offset"`
+	Line   int    `json:"line"`
+	Text   string `json:"text"`
+	Import bool   `json:"import,omitempty"`
+}
+
+// Manifest records a written short-circuit patch so it can be verified and
+// reverted later.
+type Manifest struct {
+	ID           string `json:"id"`
+	RouteID      string `json:"route_id"`
+	Method       string `json:"method"`
+	Path         string `json:"path"`
+	File         string `json:"file"`
+	EnvVar       string `json:"env_var"`
+	CreatedAt    string `json:"created_at"`
+	OriginalHash string `json:"original_hash"`
+	PatchedHash  string `json:"patched_hash"`
+	Hunks        []Hunk `json:"hunks"`
+}
+
+type Verification struct {
+	Manifest Manifest `json:"manifest"`
+	Status   string   `json:"status"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+var reManifestUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+func DefaultManifestDir() string {
+	return filepath.Join(".ccc", "shortcircuit")
+}
+
+func manifestID(now time.Time, routePath string) string {
+	slug := strings.Trim(reManifestUnsafe.ReplaceAllString(strings.ToLower(routePath), "-"), "-")
+	if slug == "" {
+		slug = "root"
+	}
+	return now.UTC().Format("20060102T150405.000000000") + "-" + slug
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func WriteManifest(dir string, m Manifest) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create short-circuit manifest dir: %w", err)
+	}
+	out, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode short-circuit manifest: %w", err)
+	}
+	path := filepath.Join(dir, m.ID+".json")
+	if err := os.WriteFile(path, append(out, '\n'), 0o644); err != nil {
+		return "", fmt.Errorf("write short-circuit manifest: %w", err)
+	}
+	return path, nil
+}
+
+// LoadManifests returns the manifests in dir ordered oldest first. A missing
+// directory yields no manifests.
+func LoadManifests(dir string) ([]Manifest, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read short-circuit manifest dir: %w", err)
+	}
+	var out []Manifest
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read short-circuit manifest %s: %w", path, err)
+		}
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("parse short-circuit manifest %s: %w", path, err)
+		}
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// VerifyAll compares each manifest's file against the hashes recorded in
+// manifests (oldest first, as returned by LoadManifests). Patches stacked on
+// one file form a chain, each applied to the content the one before it left,
+// so a patch is still applied when the file matches a later patch of its
+// chain.
+func VerifyAll(manifests []Manifest) []Verification {
+	out := make([]Verification, 0, len(manifests))
+	for _, m := range manifests {
+		data, err := os.ReadFile(m.File)
+		if err != nil {
+			out = append(out, Verification{Manifest: m, Status: StatusMissing, Detail: err.Error()})
+			continue
+		}
+		out = append(out, verifyContent(m, string(data), manifests))
+	}
+	return out
+}
+
+func verifyContent(m Manifest, content string, all []Manifest) Verification {
+	v := Verification{Manifest: m}
+	hash := contentHash(content)
+	for i, c := range chain(m, all) {
+		if c.PatchedHash != hash {
+			continue
+		}
+		v.Status = StatusApplied
+		if i > 0 {
+			v.Detail = fmt.Sprintf("%d later patches stacked on it", i)
+		}
+		return v
+	}
+	if hash == m.OriginalHash {
+		v.Status = StatusReverted
+		v.Detail = "file matches pre-patch content"
+		return v
+	}
+	v.Status = StatusDiverged
+	v.Detail = "file changed since the patch was applied"
+	return v
+}
+
+// chain returns m followed by the later manifests in all that were applied,
+// one after another, on top of it.
+func chain(m Manifest, all []Manifest) []Manifest {
+	out := []Manifest{m}
+	for _, x := range all {
+		last := out[len(out)-1]
+		if x.ID > last.ID && x.File == m.File && x.OriginalHash == last.PatchedHash {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// RevertAll removes the hunks recorded in manifests (oldest first, as returned
+// by LoadManifests) newest first and deletes each reverted manifest. A patch
+// with later patches of the same file stacked on it is taken out from under
+// them, and their manifests are rewritten to match the file without it. It
+// refuses, stopping at the first failure, when a file no longer matches the
+// patched content. In dry-run mode the reverts are simulated in memory.
+func RevertAll(dir string, manifests []Manifest, dryRun bool) ([]Verification, error) {
+	all, err := LoadManifests(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range manifests {
+		if !slices.ContainsFunc(all, func(x Manifest) bool { return x.ID == m.ID }) {
+			all = append(all, m)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+
+	contents := map[string]string{}
+	var out []Verification
+	for i := len(manifests) - 1; i >= 0; i-- {
+		idx := slices.IndexFunc(all, func(x Manifest) bool { return x.ID == manifests[i].ID })
+		m := all[idx]
+		content, ok := contents[m.File]
+		if !ok {
+			data, err := os.ReadFile(m.File)
+			if err != nil {
+				v := Verification{Manifest: m, Status: StatusMissing, Detail: err.Error()}
+				out = append(out, v)
+				return out, fmt.Errorf("refusing to revert %s: %s %s (%s)", m.ID, m.File, v.Status, v.Detail)
+			}
+			content = string(data)
+		}
+		v := verifyContent(m, content, all)
+		switch v.Status {
+		case StatusApplied:
+		case StatusReverted:
+			out = append(out, v)
+			all = slices.Delete(all, idx, idx+1)
+			if !dryRun {
+				if err := removeManifest(dir, m); err != nil {
+					return out, err
+				}
+			}
+			continue
+		default:
+			out = append(out, v)
+			return out, fmt.Errorf("refusing to revert %s: %s %s (%s)", m.ID, m.File, v.Status, v.Detail)
+		}
+
+		hash := contentHash(content)
+		stacked := chain(m, all)[1:]
+		for len(stacked) > 0 && stacked[len(stacked)-1].PatchedHash != hash {
+			stacked = stacked[:len(stacked)-1]
+		}
+		next, rebased, err := unstack(m, stacked, content)
+		if err != nil {
+			out = append(out, v)
+			return out, err
+		}
+		contents[m.File] = next
+		all = slices.Delete(all, idx, idx+1)
+		for _, r := range rebased {
+			all[slices.IndexFunc(all, func(x Manifest) bool { return x.ID == r.ID })] = r
+		}
+		if dryRun {
+			v.Detail = "dry-run: would revert"
+			out = append(out, v)
+			continue
+		}
+		if err := os.WriteFile(m.File, []byte(next), 0o644); err != nil {
+			return out, fmt.Errorf("write %s: %w", m.File, err)
+		}
+		for _, r := range rebased {
+			if _, err := WriteManifest(dir, r); err != nil {
+				return out, err
+			}
+		}
+		if err := removeManifest(dir, m); err != nil {
+			return out, err
+		}
+		v.Status = StatusReverted
+		v.Detail = ""
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// unstack removes m's hunks from content, on which the stacked manifests
+// were applied after m (oldest first), and returns the result with the
+// stacked manifests rebased onto it. Imports m added stay in the file for
+// the stacked patches and become hunks of the first of them.
+func unstack(m Manifest, stacked []Manifest, content string) (string, []Manifest, error) {
+	patched := content
+	for i := len(stacked) - 1; i >= 0; i-- {
+		var err error
+		if patched, err = removeHunks(stacked[i], patched); err != nil {
+			return "", nil, err
+		}
+	}
+	text, err := removeHunks(m, patched)
+	if err != nil {
+		return "", nil, err
+	}
+	// removed are m's hunks as offsets into the content each stacked patch
+	// was applied to.
+	removed := append([]Hunk{}, m.Hunks...)
+	rebased := make([]Manifest, len(stacked))
+	for i, x := range stacked {
+		// Insertions are offsets into the content x was applied to.
+		var insertions []Hunk
+		inserted := 0
+		for _, h := range sortedHunks(x.Hunks) {
+			insertions = append(insertions, Hunk{Offset: h.Offset - inserted, Text: h.Text, Import: h.Import})
+			inserted += len(h.Text)
+		}
+		own := slices.Clone(insertions)
+		if i == 0 {
+			for _, h := range removed {
+				if h.Import {
+					insertions = append(insertions, h)
+				}
+			}
+			sort.SliceStable(insertions, func(a, b int) bool { return insertions[a].Offset < insertions[b].Offset })
+		}
+		var b strings.Builder
+		var hunks []Hunk
+		prev := 0
+		for _, h := range insertions {
+			target, ok := withoutHunks(h.Offset, removed)
+			if !ok {
+				return "", nil, fmt.Errorf("refusing to revert %s: %s was inserted inside it", m.ID, x.ID)
+			}
+			b.WriteString(text[prev:target])
+			hunks = append(hunks, Hunk{Offset: b.Len(), Line: strings.Count(b.String(), "\n") + 1, Text: h.Text, Import: h.Import})
+			b.WriteString(h.Text)
+			prev = target
+		}
+		b.WriteString(text[prev:])
+		if i == 0 {
+			removed = slices.DeleteFunc(removed, func(h Hunk) bool { return h.Import })
+		}
+		for k := range removed {
+			for _, h := range own {
+				if h.Offset <= removed[k].Offset {
+					removed[k].Offset += len(h.Text)
+				}
+			}
+		}
+		x.OriginalHash = contentHash(text)
+		text = b.String()
+		x.PatchedHash = contentHash(text)
+		x.Hunks = hunks
+		rebased[i] = x
+	}
+	return text, rebased, nil
+}
+
+func sortedHunks(hunks []Hunk) []Hunk {
+	out := slices.Clone(hunks)
+	sort.Slice(out, func(i, j int) bool { return out[i].Offset < out[j].Offset })
+	return out
+}
+
+// withoutHunks maps offset to where it is once hunks are removed, failing
+// when it falls inside one.
+func withoutHunks(offset int, hunks []Hunk) (int, bool) {
+	shift := 0
+	for _, h := range hunks {
+		switch {
+		case offset >= h.Offset+len(h.Text):
+			shift += len(h.Text)
+		case offset > h.Offset:
+			return 0, false
+		}
+	}
+	return offset - shift, true
+}
+
+func removeHunks(m Manifest, content string) (string, error) {
+	hunks := append([]Hunk{}, m.Hunks...)
+	sort.Slice(hunks, func(i, j int) bool { return hunks[i].Offset > hunks[j].Offset })
+	for _, h := range hunks {
+		end := h.Offset + len(h.Text)
+		if h.Offset < 0 || end > len(content) || content[h.Offset:end] != h.Text {
+			return "", fmt.Errorf("refusing to revert %s: hunk at line %d does not match %s", m.ID, h.Line, m.File)
+		}
+		content = content[:h.Offset] + content[end:]
+	}
+	if contentHash(content) != m.OriginalHash {
+		return "", fmt.Errorf("refusing to revert %s: reverted content does not match original hash", m.ID)
+	}
+	return content, nil
+}
+
+func removeManifest(dir string, m Manifest) error {
+	if err := os.Remove(filepath.Join(dir, m.ID+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove short-circuit manifest %s: %w", m.ID, err)
+	}
+	return nil
+}
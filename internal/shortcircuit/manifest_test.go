@@ -0,0 +1,121 @@
+package shortcircuit
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRevertAllRestoresStackedPatches(t *testing.T) {
+	dir := t.TempDir()
+	manifestDir := filepath.Join(dir, ".ccc", "shortcircuit")
+	file := filepath.Join(dir, "main.go")
+	src := "package main\n\nimport (\n\t\"net/http\"\n)\n\nfunc main() {\n\thttp.HandleFunc(\"/auth/login\", func(w http.ResponseWriter, r *http.Request) {})\n\thttp.HandleFunc(\"/otp/send\", func(w http.ResponseWriter, r *http.Request) {\n\t\tw.WriteHeader(204)\n\t})\n}\n"
+	writeFile(t, file, src)
+
+	for _, c := range []PatchCandidate{
+		{RouteID: "a", Path: "/auth/login", File: file, Framework: "go"},
+		{RouteID: "b", Path: "/otp/send", File: file, Framework: "go"},
+	} {
+		applied, err := Apply([]PatchCandidate{c}, "CCC_SHORT_CIRCUIT", manifestDir, false)
+		if err != nil || !applied[0].Applied || applied[0].Manifest == "" {
+			t.Fatalf("apply %s: %+v err=%v", c.Path, applied, err)
+		}
+	}
+	manifests, err := LoadManifests(manifestDir)
+	if err != nil || len(manifests) != 2 {
+		t.Fatalf("expected 2 manifests, got %d err=%v", len(manifests), err)
+	}
+	for _, v := range VerifyAll(manifests) {
+		if v.Status != StatusApplied {
+			t.Fatalf("stacked manifest %s should verify as applied, got %s (%s)", v.Manifest.ID, v.Status, v.Detail)
+		}
+	}
+
+	if _, err := RevertAll(manifestDir, manifests, true); err != nil {
+		t.Fatalf("dry-run revert: %v", err)
+	}
+	if readFile(t, file) == src {
+		t.Fatalf("dry-run revert must not write files")
+	}
+	if _, err := RevertAll(manifestDir, manifests, false); err != nil {
+		t.Fatalf("revert: %v", err)
+	}
+	if got := readFile(t, file); got != src {
+		t.Fatalf("revert did not restore original content:\n%s", got)
+	}
+	if left, _ := LoadManifests(manifestDir); len(left) != 0 {
+		t.Fatalf("expected manifests removed after revert, got %d", len(left))
+	}
+}
+
+func TestRevertAllTakesOutOlderStackedPatch(t *testing.T) {
+	dir := t.TempDir()
+	manifestDir := filepath.Join(dir, ".ccc", "shortcircuit")
+	file := filepath.Join(dir, "main.go")
+	src := "package main\n\nimport (\n\t\"net/http\"\n)\n\nfunc main() {\n\thttp.HandleFunc(\"/auth/login\", func(w http.ResponseWriter, r *http.Request) {})\n\thttp.HandleFunc(\"/otp/send\", func(w http.ResponseWriter, r *http.Request) {\n\t\tw.WriteHeader(204)\n\t})\n}\n"
+	writeFile(t, file, src)
+
+	var onlyNewer string
+	for i, c := range []PatchCandidate{
+		{RouteID: "a", Path: "/auth/login", File: file, Framework: "go"},
+		{RouteID: "b", Path: "/otp/send", File: file, Framework: "go"},
+	} {
+		if i == 1 {
+			// What the file looks like with only the newer patch.
+			other := filepath.Join(dir, "other", "main.go")
+			writeFile(t, other, src)
+			if _, err := Apply([]PatchCandidate{{Path: c.Path, File: other, Framework: "go"}}, "CCC_SHORT_CIRCUIT", "", false); err != nil {
+				t.Fatalf("apply newer alone: %v", err)
+			}
+			onlyNewer = readFile(t, other)
+		}
+		if _, err := Apply([]PatchCandidate{c}, "CCC_SHORT_CIRCUIT", manifestDir, false); err != nil {
+			t.Fatalf("apply %s: %v", c.Path, err)
+		}
+	}
+	manifests, _ := LoadManifests(manifestDir)
+	if _, err := RevertAll(manifestDir, manifests[:1], false); err != nil {
+		t.Fatalf("revert older: %v", err)
+	}
+	if got := readFile(t, file); got != onlyNewer {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, onlyNewer)
+	}
+	left, _ := LoadManifests(manifestDir)
+	if len(left) != 1 || left[0].ID != manifests[1].ID {
+		t.Fatalf("expected only the newer manifest left, got %+v", left)
+	}
+	if v := VerifyAll(left); v[0].Status != StatusApplied {
+		t.Fatalf("rebased manifest should verify as applied, got %s (%s)", v[0].Status, v[0].Detail)
+	}
+	if _, err := RevertAll(manifestDir, left, false); err != nil {
+		t.Fatalf("revert newer: %v", err)
+	}
+	if got := readFile(t, file); got != src {
+		t.Fatalf("revert did not restore original content:\n%s", got)
+	}
+}
+
+func TestRevertAllRefusesDivergedFile(t *testing.T) {
+	dir := t.TempDir()
+	manifestDir := filepath.Join(dir, "manifests")
+	file := filepath.Join(dir, "main.go")
+	writeFile(t, file, "package main\n\nimport \"net/http\"\n\nfunc main() {\n\thttp.HandleFunc(\"/auth/login\", func(w http.ResponseWriter, r *http.Request) {})\n}\n")
+	if _, err := Apply([]PatchCandidate{{Path: "/auth/login", File: file, Framework: "go"}}, "X", manifestDir, false); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	edited := readFile(t, file) + "\n// local edit\n"
+	writeFile(t, file, edited)
+
+	manifests, _ := LoadManifests(manifestDir)
+	results, err := RevertAll(manifestDir, manifests, false)
+	if err == nil || !strings.Contains(err.Error(), "refusing") {
+		t.Fatalf("expected refusal for diverged file, got %v", err)
+	}
+	if len(results) != 1 || results[0].Status != StatusDiverged {
+		t.Fatalf("expected diverged verification, got %+v", results)
+	}
+	if readFile(t, file) != edited {
+		t.Fatalf("diverged file must be left untouched")
+	}
+}
@@ -0,0 +1,163 @@
+package shortcircuit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	rePyImportOS       = regexp.MustCompile(`(?m)^import\s+os\s*$`)
+	rePyImportJSONResp = regexp.MustCompile(`(?m)^from\s+django\.http\s+import\s+.*\bJsonResponse\b`)
+	rePyTopImport      = regexp.MustCompile(`(?m)^(?:import|from)\s+\S.*$`)
+)
+
+// patchDjango patches the view bound in urls.py. The view is resolved from the
+// route handler ("views.login_view" -> views.py next to urls.py).
+func patchDjango(c PatchCandidate, _ string, envVar string) (patch, error) {
+	viewFile, fn := djangoViewLocation(c)
+	if fn == "" {
+		return patch{}, fmt.Errorf("no view function bound to %s", c.Path)
+	}
+	raw, err := os.ReadFile(viewFile)
+	if err != nil {
+		return patch{}, fmt.Errorf("view module for %s not readable: %w", c.Handler, err)
+	}
+	content := string(raw)
+
+	def := regexp.MustCompile(`(?m)^([ \t]*)(?:async\s+)?def\s+` + regexp.QuoteMeta(fn) + `\s*\(`).FindStringSubmatchIndex(content)
+	if def == nil {
+		return patch{}, fmt.Errorf("view function %s not found in %s", fn, viewFile)
+	}
+	defIndent := content[def[2]:def[3]]
+	bodyStart, bodyIndent, ok := pythonBodyStart(content, def[1])
+	if !ok || len(bodyIndent) <= len(defIndent) {
+		return patch{}, fmt.Errorf("could not locate body of %s in %s", fn, viewFile)
+	}
+	if strings.Contains(pythonBlock(content, bodyStart, bodyIndent), markerText) {
+		return patch{file: viewFile}, nil
+	}
+	unit := strings.TrimPrefix(bodyIndent, defIndent)
+	text := fmt.Sprintf("%sif os.environ.get(%q) == \"true\":  # %s\n%s%sreturn JsonResponse({\"short_circuit\": True, \"token\": %q})\n",
+		bodyIndent, envVar, markerText, bodyIndent, unit, cannedToken)
+
+	p := patch{file: viewFile, offset: bodyStart, text: text}
+	var missing []string
+	if !rePyImportOS.MatchString(content) {
+		missing = append(missing, "import os")
+	}
+	if !rePyImportJSONResp.MatchString(content) {
+		missing = append(missing, "from django.http import JsonResponse")
+	}
+	if len(missing) > 0 {
+		p.imports = append(p.imports, pythonImportInsertion(content, missing))
+	}
+	return p, nil
+}
+
+func djangoViewLocation(c PatchCandidate) (string, string) {
+	handler := strings.TrimSpace(c.Handler)
+	dir := filepath.Dir(c.File)
+	if i := strings.LastIndex(handler, "."); i >= 0 {
+		module := handler[:i]
+		return filepath.Join(dir, filepath.FromSlash(strings.ReplaceAll(module, ".", "/"))+".py"), handler[i+1:]
+	}
+	return filepath.Join(dir, "views.py"), handler
+}
+
+// pythonBodyStart finds the first body line after a def signature that starts
+// at or after from. It skips a leading docstring so it stays the first
+// statement, and returns the offset to insert at plus the body indentation.
+func pythonBodyStart(content string, from int) (int, string, bool) {
+	depth := 1
+	i := from
+	for ; i < len(content) && depth > 0; i++ {
+		switch content[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+	}
+	colon := strings.IndexByte(content[i:], ':')
+	if colon < 0 {
+		return 0, "", false
+	}
+	nl := strings.IndexByte(content[i+colon:], '\n')
+	if nl < 0 {
+		return 0, "", false
+	}
+	if inline := strings.TrimSpace(content[i+colon+1 : i+colon+nl]); inline != "" && !strings.HasPrefix(inline, "#") {
+		return 0, "", false
+	}
+	pos := i + colon + nl + 1
+	for pos < len(content) {
+		end := strings.IndexByte(content[pos:], '\n')
+		line := content[pos:]
+		if end >= 0 {
+			line = content[pos : pos+end]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			if end < 0 {
+				break
+			}
+			pos += end + 1
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		for _, q := range []string{`"""`, `'''`} {
+			if !strings.HasPrefix(trimmed, q) {
+				continue
+			}
+			closeAt := strings.Index(content[pos+len(indent)+3:], q)
+			if closeAt < 0 {
+				return 0, "", false
+			}
+			after := pos + len(indent) + 3 + closeAt + 3
+			nl := strings.IndexByte(content[after:], '\n')
+			if nl < 0 {
+				return len(content), indent, true
+			}
+			return after + nl + 1, indent, true
+		}
+		return pos, indent, true
+	}
+	return pos, "", true
+}
+
+// pythonBlock returns the lines starting at pos that are indented at least as
+// deep as indent.
+func pythonBlock(content string, pos int, indent string) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(content[pos:], "\n") {
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, indent) {
+			break
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+func pythonImportInsertion(content string, lines []string) insertion {
+	text := strings.Join(lines, "\n") + "\n"
+	all := rePyTopImport.FindAllStringIndex(content, -1)
+	if len(all) == 0 {
+		return insertion{offset: 0, text: text + "\n"}
+	}
+	end := all[len(all)-1][1]
+	if strings.HasSuffix(strings.TrimSpace(content[all[len(all)-1][0]:end]), "(") {
+		if closeAt := strings.IndexByte(content[end:], ')'); closeAt >= 0 {
+			end += closeAt + 1
+			if nl := strings.IndexByte(content[end:], '\n'); nl >= 0 {
+				end += nl
+			}
+		}
+	}
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return insertion{offset: end, text: text}
+}
@@ -0,0 +1,187 @@
+package shortcircuit
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+func patchGo(c PatchCandidate, content string, envVar string) (patch, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, c.File, content, parser.ParseComments)
+	if err != nil {
+		return patch{}, fmt.Errorf("parse %s: %w", c.File, err)
+	}
+
+	call := findGoRouteCall(fset, file, c)
+	if call == nil {
+		return patch{}, fmt.Errorf("route registration for %s not found in %s", c.Path, c.File)
+	}
+	fnType, body := goHandlerFunc(file, call.Args[1])
+	if body == nil {
+		return patch{}, fmt.Errorf("handler for %s is not a function in %s", c.Path, c.File)
+	}
+	writer := goResponseWriterName(fnType)
+	if writer == "" {
+		return patch{}, fmt.Errorf("handler for %s has no named http.ResponseWriter parameter", c.Path)
+	}
+
+	open := fset.Position(body.Lbrace).Offset
+	closing := fset.Position(body.Rbrace).Offset
+	if strings.Contains(content[open:closing], markerText) {
+		return patch{}, nil
+	}
+
+	osName, ins, needImport := goImportInsertion(fset, file, "os")
+	indent := lineIndent(content, open)
+	inner := indent + "\t"
+	lines := []string{
+		fmt.Sprintf("%sif %s.Getenv(%q) == \"true\" { // %s", inner, osName, envVar, markerText),
+		fmt.Sprintf("%s\t%s.Header().Set(\"Content-Type\", \"application/json\")", inner, writer),
+		fmt.Sprintf("%s\t_, _ = %s.Write([]byte(`{\"short_circuit\":true,\"token\":%q}`))", inner, writer, cannedToken),
+		inner + "\treturn",
+		inner + "}",
+	}
+	p := patch{
+		offset: open + 1,
+		text:   bodyInsertion(content, open+1, indent, lines),
+	}
+	if needImport {
+		p.imports = append(p.imports, ins)
+	}
+	return p, nil
+}
+
+// findGoRouteCall locates the Handle/HandleFunc call registering c.Path,
+// preferring the call on c.Line since earlier patches may shift lines.
+func findGoRouteCall(fset *token.FileSet, file *ast.File, c PatchCandidate) *ast.CallExpr {
+	var matches []*ast.CallExpr
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) < 2 {
+			return true
+		}
+		var name string
+		switch fn := call.Fun.(type) {
+		case *ast.Ident:
+			name = fn.Name
+		case *ast.SelectorExpr:
+			name = fn.Sel.Name
+		}
+		if name != "Handle" && name != "HandleFunc" {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		if path, err := strconv.Unquote(lit.Value); err == nil && path == c.Path {
+			matches = append(matches, call)
+		}
+		return true
+	})
+	for _, call := range matches {
+		if fset.Position(call.Pos()).Line == c.Line {
+			return call
+		}
+	}
+	if len(matches) > 0 {
+		return matches[0]
+	}
+	return nil
+}
+
+// goHandlerFunc resolves a handler argument to its function type and body.
+// It accepts function literals, same-file function names and
+// http.HandlerFunc(...) conversions of either.
+func goHandlerFunc(file *ast.File, expr ast.Expr) (*ast.FuncType, *ast.BlockStmt) {
+	switch h := expr.(type) {
+	case *ast.FuncLit:
+		return h.Type, h.Body
+	case *ast.Ident:
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if ok && fd.Recv == nil && fd.Name.Name == h.Name && fd.Body != nil {
+				return fd.Type, fd.Body
+			}
+		}
+	case *ast.CallExpr:
+		if len(h.Args) == 1 {
+			return goHandlerFunc(file, h.Args[0])
+		}
+	}
+	return nil, nil
+}
+
+func goResponseWriterName(fnType *ast.FuncType) string {
+	if fnType == nil || fnType.Params == nil {
+		return ""
+	}
+	for _, field := range fnType.Params.List {
+		sel, ok := field.Type.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "ResponseWriter" || len(field.Names) == 0 {
+			continue
+		}
+		if name := field.Names[0].Name; name != "_" {
+			return name
+		}
+	}
+	return ""
+}
+
+// goImportInsertion returns the name path is imported under and the
+// insertion needed to import it, keeping a parenthesized import block sorted.
+// ok is false when path is already imported under a usable name; blank and
+// dot imports do not count.
+func goImportInsertion(fset *token.FileSet, file *ast.File, path string) (name string, ins insertion, ok bool) {
+	name = path[strings.LastIndex(path, "/")+1:]
+	for _, imp := range file.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p != path {
+			continue
+		}
+		if imp.Name == nil {
+			return name, insertion{}, false
+		}
+		if imp.Name.Name != "_" && imp.Name.Name != "." {
+			return imp.Name.Name, insertion{}, false
+		}
+	}
+	quoted := strconv.Quote(path)
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		if !gd.Lparen.IsValid() {
+			end := fset.Position(gd.End()).Offset
+			return name, insertion{offset: end, text: "\nimport " + quoted}, true
+		}
+		for _, spec := range gd.Specs {
+			is := spec.(*ast.ImportSpec)
+			if is.Path.Value > quoted {
+				off := fset.Position(is.Pos()).Offset
+				if is.Name != nil {
+					off = fset.Position(is.Name.Pos()).Offset
+				}
+				return name, insertion{offset: off, text: quoted + "\n\t"}, true
+			}
+		}
+		off := fset.Position(gd.Rparen).Offset
+		text := "\t" + quoted + "\n"
+		last := gd.Lparen
+		if len(gd.Specs) > 0 {
+			last = gd.Specs[len(gd.Specs)-1].End()
+		}
+		if fset.Position(last).Line == fset.Position(gd.Rparen).Line {
+			// In a one-line block such as import ("fmt") the closing
+			// parenthesis does not start a line of its own.
+			text = "\n" + text
+		}
+		return name, insertion{offset: off, text: text}, true
+	}
+	off := fset.Position(file.Name.End()).Offset
+	return name, insertion{offset: off, text: "\n\nimport " + quoted}, true
+}
@@ -0,0 +1,122 @@
+package shortcircuit
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	reNodeArrowParams = regexp.MustCompile(`\(\s*([A-Za-z_$][\w$]*)\s*(?::[^,)]*)?,\s*([A-Za-z_$][\w$]*)[^)]*\)\s*(?::[^=]*)?=>\s*\{`)
+	reNodeFuncParams  = regexp.MustCompile(`function\s*[\w$]*\s*\(\s*([A-Za-z_$][\w$]*)\s*(?::[^,)]*)?,\s*([A-Za-z_$][\w$]*)[^)]*\)\s*(?::[^{]*)?\{`)
+)
+
+func patchNode(c PatchCandidate, content string, envVar string) (patch, error) {
+	start, err := nodeRouteOffset(c, content)
+	if err != nil {
+		return patch{}, err
+	}
+	// Search a bounded window so we bind to this route's handler, not a later one.
+	window := content[start:]
+	if end := nodeNextRoute(window); end > 0 {
+		window = window[:end]
+	}
+
+	open, res := -1, ""
+	for _, re := range []*regexp.Regexp{reNodeArrowParams, reNodeFuncParams} {
+		if m := re.FindStringSubmatchIndex(window); m != nil && (open < 0 || m[1] < open) {
+			open = m[1] - 1
+			res = window[m[4]:m[5]]
+		}
+	}
+	if open < 0 {
+		return patch{}, fmt.Errorf("inline (req, res) handler for %s not found in %s", c.Path, c.File)
+	}
+	open += start
+
+	if strings.Contains(nodeBody(content, open), markerText) {
+		return patch{}, nil
+	}
+
+	indent := lineIndent(content, start)
+	unit := nodeIndentUnit(content)
+	inner := indent + unit
+	lines := []string{
+		fmt.Sprintf("%sif (process.env[%q] === \"true\") { // %s", inner, envVar, markerText),
+		fmt.Sprintf("%s%sreturn %s.status(200).json({ short_circuit: true, token: %q });", inner, unit, res, cannedToken),
+		inner + "}",
+	}
+	return patch{
+		offset: open + 1,
+		text:   bodyInsertion(content, open+1, indent, lines),
+	}, nil
+}
+
+func nodeRouteOffset(c PatchCandidate, content string) (int, error) {
+	quoted := []string{`"` + c.Path + `"`, `'` + c.Path + `'`, "`" + c.Path + "`"}
+	if c.Line > 0 {
+		off := lineOffset(content, c.Line)
+		if off >= 0 {
+			end := strings.IndexByte(content[off:], '\n')
+			if end < 0 {
+				end = len(content) - off
+			}
+			for _, q := range quoted {
+				if strings.Contains(content[off:off+end], q) {
+					return off, nil
+				}
+			}
+		}
+	}
+	for _, q := range quoted {
+		if i := strings.Index(content, q); i >= 0 {
+			return lineStart(content, i), nil
+		}
+	}
+	return 0, fmt.Errorf("route registration for %s not found in %s", c.Path, c.File)
+}
+
+var reNodeRouteCall = regexp.MustCompile(`\b(app|router)\.(get|post|put|patch|delete)\s*\(`)
+
+// nodeNextRoute returns the offset of the next route registration after the
+// first line of window, or -1 when there is none.
+func nodeNextRoute(window string) int {
+	nl := strings.IndexByte(window, '\n')
+	if nl < 0 {
+		return -1
+	}
+	if loc := reNodeRouteCall.FindStringIndex(window[nl:]); loc != nil {
+		return nl + loc[0]
+	}
+	return -1
+}
+
+// nodeBody returns the handler body starting at the opening brace, matching
+// braces naively (string contents are not special-cased).
+func nodeBody(content string, open int) string {
+	depth := 0
+	for i := open; i < len(content); i++ {
+		switch content[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return content[open : i+1]
+			}
+		}
+	}
+	return content[open:]
+}
+
+func nodeIndentUnit(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "\t") {
+			return "\t"
+		}
+		if trimmed := strings.TrimLeft(line, " "); trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
@@ -3,16 +3,53 @@ package shortcircuit
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"cool-code-cleanup/internal/discovery"
 )
 
+const (
+	markerText  = "CCC short-circuit"
+	cannedToken = "ccc-short-circuit-token"
+)
+
 type PatchCandidate struct {
 	RouteID     string `json:"route_id"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
 	File        string `json:"file"`
+	Line        int    `json:"line,omitempty"`
+	Handler     string `json:"handler,omitempty"`
+	Framework   string `json:"framework"`
 	Description string `json:"description"`
 	Applied     bool   `json:"applied"`
+	PatchedFile string `json:"patched_file,omitempty"`
+	Snippet     string `json:"snippet,omitempty"`
+	Manifest    string `json:"manifest,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// patch describes a single insertion into a source file.
+type patch struct {
+	file    string
+	offset  int
+	text    string
+	imports []insertion
+}
+
+type insertion struct {
+	offset int
+	text   string
+}
+
+type patcher func(c PatchCandidate, content string, envVar string) (patch, error)
+
+var patchers = map[string]patcher{
+	"go":     patchGo,
+	"node":   patchNode,
+	"django": patchDjango,
 }
 
 func Candidates(routes []discovery.Route, dependencies map[string][]string) []PatchCandidate {
@@ -31,31 +68,84 @@ func Candidates(routes []discovery.Route, dependencies map[string][]string) []Pa
 		if strings.Contains(path, "auth") || strings.Contains(path, "payment") || strings.Contains(path, "otp") || strings.Contains(path, "email") || strings.Contains(path, "phone") {
 			out = append(out, PatchCandidate{
 				RouteID:     r.ID,
+				Method:      r.Method,
+				Path:        r.Path,
 				File:        r.File,
-				Description: fmt.Sprintf("Add short-circuit marker for %s %s", r.Method, r.Path),
+				Line:        r.Line,
+				Handler:     r.Handler,
+				Framework:   r.Framework,
+				Description: fmt.Sprintf("Add env-guarded short-circuit return to %s %s", r.Method, r.Path),
 			})
 		}
 	}
 	return out
 }
 
-func Apply(candidates []PatchCandidate, envVar string, dryRun bool) ([]PatchCandidate, error) {
+// Apply inserts an early return guarded by envVar=true into the handler of each
+// candidate. Candidates whose handler cannot be located are returned with
+// Applied=false and a Reason; I/O failures abort the run. When manifestDir is
+// set, every written patch is recorded there so it can be reverted later.
+func Apply(candidates []PatchCandidate, envVar, manifestDir string, dryRun bool) ([]PatchCandidate, error) {
 	out := make([]PatchCandidate, 0, len(candidates))
 	for _, c := range candidates {
+		c.Applied, c.Reason = false, ""
+		p, ok := patchers[c.Framework]
+		if !ok {
+			c.Reason = fmt.Sprintf("unsupported framework %q", c.Framework)
+			out = append(out, c)
+			continue
+		}
 		data, err := os.ReadFile(c.File)
 		if err != nil {
 			return out, fmt.Errorf("read %s: %w", c.File, err)
 		}
-		content := string(data)
-		marker := fmt.Sprintf("CCC short-circuit marker: set %s=true to bypass external dependencies", envVar)
-		if strings.Contains(content, marker) {
+		pt, err := p(c, string(data), envVar)
+		if err != nil {
+			c.Reason = err.Error()
+			out = append(out, c)
+			continue
+		}
+		if pt.file == "" {
+			pt.file = c.File
+		}
+		target := string(data)
+		if pt.file != c.File {
+			raw, err := os.ReadFile(pt.file)
+			if err != nil {
+				return out, fmt.Errorf("read %s: %w", pt.file, err)
+			}
+			target = string(raw)
+		}
+		c.PatchedFile = pt.file
+		c.Snippet = pt.text
+		if pt.text == "" {
+			c.Reason = "already short-circuited"
 			out = append(out, c)
 			continue
 		}
-		next := "// " + marker + "\n" + content
+		next, hunks := applyPatch(target, pt)
 		if !dryRun {
-			if err := os.WriteFile(c.File, []byte(next), 0o644); err != nil {
-				return out, fmt.Errorf("write %s: %w", c.File, err)
+			if err := os.WriteFile(pt.file, []byte(next), 0o644); err != nil {
+				return out, fmt.Errorf("write %s: %w", pt.file, err)
+			}
+			if manifestDir != "" {
+				m := Manifest{
+					ID:           manifestID(time.Now(), c.Path),
+					RouteID:      c.RouteID,
+					Method:       c.Method,
+					Path:         c.Path,
+					File:         pt.file,
+					EnvVar:       envVar,
+					CreatedAt:    time.Now().UTC().Format(time.RFC3339),
+					OriginalHash: contentHash(target),
+					PatchedHash:  contentHash(next),
+					Hunks:        hunks,
+				}
+				path, err := WriteManifest(manifestDir, m)
+				if err != nil {
+					return out, err
+				}
+				c.Manifest = path
 			}
 		}
 		c.Applied = true
@@ -63,3 +153,61 @@ func Apply(candidates []PatchCandidate, envVar string, dryRun bool) ([]PatchCand
 	}
 	return out, nil
 }
+
+// applyPatch performs all insertions of p against content and returns the
+// patched text together with hunks positioned in the patched text.
+func applyPatch(content string, p patch) (string, []Hunk) {
+	all := []Hunk{{Offset: p.offset, Text: p.text}}
+	for _, ins := range p.imports {
+		all = append(all, Hunk{Offset: ins.offset, Text: ins.text, Import: true})
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Offset < all[j].Offset })
+	var b strings.Builder
+	hunks := make([]Hunk, 0, len(all))
+	prev := 0
+	for _, ins := range all {
+		b.WriteString(content[prev:ins.Offset])
+		hunks = append(hunks, Hunk{Offset: b.Len(), Line: strings.Count(b.String(), "\n") + 1, Text: ins.Text, Import: ins.Import})
+		b.WriteString(ins.Text)
+		prev = ins.Offset
+	}
+	b.WriteString(content[prev:])
+	return b.String(), hunks
+}
+
+// bodyInsertion builds the text inserted right after a handler's opening
+// brace. When the body is empty on the same line the closing brace is moved
+// to its own line at the handler's indentation.
+func bodyInsertion(content string, afterBrace int, indent string, lines []string) string {
+	rest := strings.TrimLeft(content[afterBrace:], " \t")
+	text := "\n" + strings.Join(lines, "\n")
+	if strings.HasPrefix(rest, "}") {
+		text += "\n" + indent
+	}
+	return text
+}
+
+func lineStart(content string, offset int) int {
+	return strings.LastIndex(content[:offset], "\n") + 1
+}
+
+func lineIndent(content string, offset int) string {
+	start := lineStart(content, offset)
+	line := content[start:]
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+func lineOffset(content string, line int) int {
+	if line <= 1 {
+		return 0
+	}
+	off := 0
+	for i := 1; i < line; i++ {
+		next := strings.IndexByte(content[off:], '\n')
+		if next < 0 {
+			return -1
+		}
+		off += next + 1
+	}
+	return off
+}
@@ -0,0 +1,164 @@
+package shortcircuit
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cool-code-cleanup/internal/discovery"
+)
+
+func TestApplyGoInsertsGuardIntoHandler(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	src := "package main\n\nimport \"net/http\"\n\nfunc main() {\n\thttp.HandleFunc(\"/auth/login\", func(w http.ResponseWriter, r *http.Request) {})\n\thttp.HandleFunc(\"/payment/secure\", pay)\n}\n\nfunc pay(rw http.ResponseWriter, _ *http.Request) {\n\trw.WriteHeader(500)\n}\n"
+	writeFile(t, file, src)
+
+	applied, err := Apply([]PatchCandidate{
+		{RouteID: "a", Path: "/auth/login", File: file, Line: 6, Framework: "go"},
+		{RouteID: "b", Path: "/payment/secure", File: file, Line: 7, Framework: "go"},
+	}, "CCC_SHORT_CIRCUIT", "", false)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	for _, c := range applied {
+		if !c.Applied {
+			t.Fatalf("expected %s to be applied, reason=%s", c.Path, c.Reason)
+		}
+	}
+	out := readFile(t, file)
+	if _, err := parser.ParseFile(token.NewFileSet(), file, out, 0); err != nil {
+		t.Fatalf("patched Go file does not parse: %v\n%s", err, out)
+	}
+	if strings.Count(out, "// "+markerText) != 2 || !strings.Contains(out, "import \"os\"") || !strings.Contains(out, "rw.Write(") {
+		t.Fatalf("unexpected patched Go file:\n%s", out)
+	}
+
+	again, err := Apply(applied[:1], "CCC_SHORT_CIRCUIT", "", false)
+	if err != nil || again[0].Applied {
+		t.Fatalf("expected re-apply to be a no-op, got %+v err=%v", again[0], err)
+	}
+}
+
+func TestGoImportInsertionUsesOrAddsAUsableName(t *testing.T) {
+	cases := []struct {
+		src, name string
+		add       bool
+	}{
+		{src: "package a\n\nimport \"os\"\n", name: "os"},
+		{src: "package a\n\nimport goos \"os\"\n", name: "goos"},
+		{src: "package a\n\nimport _ \"os\"\n", name: "os", add: true},
+		{src: "package a\n\nimport (\n\t. \"os\"\n\t\"strings\"\n)\n", name: "os", add: true},
+		{src: "package a\n\nimport (\"fmt\")\n", name: "os", add: true},
+		{src: "package a\n\nimport ()\n", name: "os", add: true},
+	}
+	for _, c := range cases {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "a.go", c.src, 0)
+		if err != nil {
+			t.Fatalf("parse %q: %v", c.src, err)
+		}
+		name, ins, add := goImportInsertion(fset, file, "os")
+		if name != c.name || add != c.add {
+			t.Fatalf("%q: got name %q, add %v", c.src, name, add)
+		}
+		if !add {
+			continue
+		}
+		out := c.src[:ins.offset] + ins.text + c.src[ins.offset:]
+		patched, err := parser.ParseFile(token.NewFileSet(), "a.go", out, 0)
+		if err != nil {
+			t.Fatalf("%q: patched imports do not parse: %v\n%s", c.src, err, out)
+		}
+		found := false
+		for _, imp := range patched.Imports {
+			found = found || (imp.Name == nil && imp.Path.Value == `"os"`)
+		}
+		if !found {
+			t.Fatalf("%q: no plain os import in\n%s", c.src, out)
+		}
+	}
+}
+
+func TestApplyGoUsesTheAliasOfAnExistingOsImport(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "main.go")
+	writeFile(t, file, "package main\n\nimport (\n\t\"net/http\"\n\tgoos \"os\"\n)\n\nvar _ = goos.Args\n\nfunc main() {\n\thttp.HandleFunc(\"/auth/login\", func(w http.ResponseWriter, r *http.Request) {})\n}\n")
+	applied, err := Apply([]PatchCandidate{{RouteID: "a", Path: "/auth/login", File: file, Line: 11, Framework: "go"}}, "CCC_SHORT_CIRCUIT", "", false)
+	if err != nil || !applied[0].Applied {
+		t.Fatalf("apply: %+v %v", applied, err)
+	}
+	if out := readFile(t, file); !strings.Contains(out, "if goos.Getenv(") || strings.Count(out, `"os"`) != 1 {
+		t.Fatalf("unexpected patched Go file:\n%s", out)
+	}
+}
+
+func TestApplyNodeAndDjangoUseLanguageSyntax(t *testing.T) {
+	root := filepath.Join("..", "testdata")
+	dir := t.TempDir()
+	for _, rel := range []string{"node_app/routes.js", "django_app/urls.py"} {
+		writeFile(t, filepath.Join(dir, rel), readFile(t, filepath.Join(root, rel)))
+	}
+	views := filepath.Join(dir, "django_app", "views.py")
+	writeFile(t, views, "from django.http import HttpResponse\n\n\ndef login_view(request):\n    \"\"\"Log in.\"\"\"\n    return HttpResponse(\"ok\")\n")
+
+	routes, err := discovery.Discover(dir)
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	deps := map[string][]string{}
+	for _, r := range routes {
+		if r.Path == "/account/private" {
+			for _, auth := range routes {
+				if auth.Path == "/auth/login" && auth.Framework == r.Framework {
+					deps[r.ID] = []string{auth.ID}
+				}
+			}
+		}
+	}
+	applied, err := Apply(Candidates(routes, deps), "CCC_SHORT_CIRCUIT", "", false)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(applied) != 2 {
+		t.Fatalf("expected node and django candidates, got %+v", applied)
+	}
+	for _, c := range applied {
+		if !c.Applied {
+			t.Fatalf("expected %s candidate applied, reason=%s", c.Framework, c.Reason)
+		}
+	}
+
+	js := readFile(t, filepath.Join(dir, "node_app", "routes.js"))
+	if !strings.Contains(js, `if (process.env["CCC_SHORT_CIRCUIT"] === "true") { // CCC short-circuit`) || !strings.Contains(js, "return res.status(200)") {
+		t.Fatalf("unexpected patched JS:\n%s", js)
+	}
+	py := readFile(t, views)
+	if strings.Contains(py, "//") || !strings.Contains(py, "# CCC short-circuit") {
+		t.Fatalf("expected Python comment syntax:\n%s", py)
+	}
+	if !strings.Contains(py, "import os\nfrom django.http import JsonResponse\n") || !strings.Contains(py, "\"\"\"Log in.\"\"\"\n    if os.environ.get(") {
+		t.Fatalf("expected imports and guard after docstring:\n%s", py)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
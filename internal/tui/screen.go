@@ -19,6 +19,9 @@ type StepScreen struct {
 	Content     []string
 	Actions     []Action
 	InlineError string
+	// Preformatted content keeps its spacing and is only broken at the
+	// screen width, for code and diffs.
+	Preformatted bool
 }
 
 func (s StepScreen) Render() string {
@@ -51,8 +54,15 @@ func (s StepScreen) RenderWithWidth(width int) string {
 	}
 	writeRule(&b, ruleWidth)
 	for _, line := range content {
-		for _, wrapped := range wrapToWidth(line, ruleWidth) {
-			b.WriteString(wrapped + "\n")
+		wrapped := wrapToWidth(line, ruleWidth)
+		if s.Preformatted {
+			wrapped = hardWrap(strings.TrimRight(line, " "), ruleWidth)
+			if len(wrapped) == 0 {
+				wrapped = []string{""}
+			}
+		}
+		for _, w := range wrapped {
+			b.WriteString(w + "\n")
 		}
 	}
 	if strings.TrimSpace(s.InlineError) != "" {
@@ -80,3 +80,18 @@ func TestToggleListClearsDerivationDetailsOnToggle(t *testing.T) {
 		t.Fatalf("expected details to be cleared after toggle")
 	}
 }
+
+func TestStepScreenPreformattedKeepsSpacing(t *testing.T) {
+	screen := StepScreen{
+		Mode:         "Cleanup",
+		StepName:     "Review main.go",
+		Content:      []string{"-    return  x", "", "+" + strings.Repeat("y", 80)},
+		Preformatted: true,
+	}
+	out := screen.RenderWithWidth(42)
+	for _, want := range []string{"\n-    return  x\n\n+", "\n" + strings.Repeat("y", 40) + "\ny\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("render missing %q:\n%s", want, out)
+		}
+	}
+}